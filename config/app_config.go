@@ -0,0 +1,7 @@
+package config
+
+// IsDevMode 是否为本地开发模式（APP_DEV_MODE=true）
+// 开发模式才允许使用内置的开发密钥、日志短信和模拟支付；部署在微信云托管（WX_CLOUD_TRUST_HEADERS=true）时始终不是开发模式
+func IsDevMode() bool {
+	return getEnv("APP_DEV_MODE", "false") == "true" && !GetWxConfig().TrustCloudHeaders
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 开发模式下未配置密钥时使用的默认值，只能用于本地开发
+const (
+	devTokenSecret       = "anyuyinian_dev_token_secret_change_me"
+	devTotpEncryptionKey = "anyuyinian_dev_totp_key_change_me"
+)
+
+// AuthConfig 登录鉴权配置
+type AuthConfig struct {
+	TokenSecret     string        // 令牌签名密钥
+	Issuer          string        // 令牌签发方
+	AccessTokenTTL  time.Duration // 访问令牌有效期
+	RefreshTokenTTL time.Duration // 刷新令牌有效期（即会话有效期）
+
+	// 管理员密码策略
+	PasswordMinLength  int // 最小长度
+	PasswordMinClasses int // 至少包含的字符类型数（大写、小写、数字、特殊字符）
+	PasswordMaxAgeDays int // 密码有效期（天），到期后必须修改，0表示不限制
+
+	// 管理员登录防暴力破解
+	LoginMaxAccountFailures int           // 同一账号连续失败多少次后锁定
+	LoginMaxIpFailures      int           // 同一IP连续失败多少次后锁定
+	LoginFailureWindow      time.Duration // 失败次数统计窗口，超过窗口未再失败则重新计数
+	LoginLockDuration       time.Duration // 首次锁定时长，之后每次锁定翻倍
+	LoginMaxLockDuration    time.Duration // 最长锁定时长
+	LoginFailureDelay       time.Duration // 失败响应的基础延迟，随失败次数翻倍
+	TrustedProxyCount       int           // 服务前的可信代理层数，客户端IP取X-Forwarded-For倒数第N个地址，0表示不信任转发头
+
+	// 管理员两步验证
+	TotpIssuer        string        // 验证器App中显示的发行方名称
+	TotpEncryptionKey string        // TOTP密钥加密存储使用的密钥，不能与令牌签名密钥相同
+	TotpLegacyKey     string        // 早期版本用令牌签名密钥加密TOTP密钥，仅用于解密旧记录并重新加密
+	TotpSkew          int           // 允许的时钟偏差（时间步数）
+	StepUpTTL         time.Duration // 两步验证通过后资金相关操作的有效期
+}
+
+// GetAuthConfig 获取鉴权配置
+func GetAuthConfig() *AuthConfig {
+	tokenSecret := getEnv("AUTH_TOKEN_SECRET", "")
+	totpEncryptionKey := getEnv("ADMIN_TOTP_ENCRYPTION_KEY", "")
+	if IsDevMode() {
+		if tokenSecret == "" {
+			tokenSecret = devTokenSecret
+		}
+		if totpEncryptionKey == "" {
+			totpEncryptionKey = devTotpEncryptionKey
+		}
+	}
+	return &AuthConfig{
+		TokenSecret:     tokenSecret,
+		Issuer:          getEnv("AUTH_TOKEN_ISSUER", "anyuyinian"),
+		AccessTokenTTL:  time.Duration(getEnvInt("AUTH_ACCESS_TOKEN_TTL_MINUTES", 120)) * time.Minute,
+		RefreshTokenTTL: time.Duration(getEnvInt("AUTH_REFRESH_TOKEN_TTL_HOURS", 720)) * time.Hour,
+
+		PasswordMinLength:  getEnvInt("ADMIN_PASSWORD_MIN_LENGTH", 10),
+		PasswordMinClasses: getEnvInt("ADMIN_PASSWORD_MIN_CLASSES", 3),
+		PasswordMaxAgeDays: getEnvInt("ADMIN_PASSWORD_MAX_AGE_DAYS", 90),
+
+		LoginMaxAccountFailures: getEnvInt("ADMIN_LOGIN_MAX_ACCOUNT_FAILURES", 5),
+		LoginMaxIpFailures:      getEnvInt("ADMIN_LOGIN_MAX_IP_FAILURES", 20),
+		LoginFailureWindow:      time.Duration(getEnvInt("ADMIN_LOGIN_FAILURE_WINDOW_MINUTES", 15)) * time.Minute,
+		LoginLockDuration:       time.Duration(getEnvInt("ADMIN_LOGIN_LOCK_MINUTES", 15)) * time.Minute,
+		LoginMaxLockDuration:    time.Duration(getEnvInt("ADMIN_LOGIN_MAX_LOCK_MINUTES", 1440)) * time.Minute,
+		LoginFailureDelay:       time.Duration(getEnvInt("ADMIN_LOGIN_FAILURE_DELAY_MS", 500)) * time.Millisecond,
+		TrustedProxyCount:       getEnvInt("TRUSTED_PROXY_COUNT", 1),
+
+		TotpIssuer:        getEnv("ADMIN_TOTP_ISSUER", "安愉颐年管理后台"),
+		TotpEncryptionKey: totpEncryptionKey,
+		TotpLegacyKey:     tokenSecret,
+		TotpSkew:          getEnvInt("ADMIN_TOTP_SKEW", 1),
+		StepUpTTL:         time.Duration(getEnvInt("ADMIN_STEP_UP_TTL_MINUTES", 5)) * time.Minute,
+	}
+}
+
+// Validate 校验密钥配置，服务启动时调用，未通过时拒绝启动
+// 令牌签名密钥泄露或使用公开的默认值时任何人都可以伪造登录令牌
+func (c *AuthConfig) Validate() error {
+	if c.TokenSecret == "" {
+		return fmt.Errorf("未配置AUTH_TOKEN_SECRET（本地开发可设置APP_DEV_MODE=true使用开发密钥）")
+	}
+	if c.TotpEncryptionKey == "" {
+		return fmt.Errorf("未配置ADMIN_TOTP_ENCRYPTION_KEY（本地开发可设置APP_DEV_MODE=true使用开发密钥）")
+	}
+	if c.TotpEncryptionKey == c.TokenSecret {
+		return fmt.Errorf("ADMIN_TOTP_ENCRYPTION_KEY不能与AUTH_TOKEN_SECRET相同")
+	}
+	if IsDevMode() {
+		return nil
+	}
+	if c.TokenSecret == devTokenSecret || c.TotpEncryptionKey == devTotpEncryptionKey {
+		return fmt.Errorf("生产环境不能使用开发密钥")
+	}
+	return nil
+}
+
+// getEnvInt 获取整数类型的环境变量，不存在或格式错误时返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,21 @@
+package config
+
+import (
+	"time"
+)
+
+// BookingConfig 预约时间段库存配置
+type BookingConfig struct {
+	DefaultSlotCapacity int           // 服务项目未设置时间段容量时，每个时间段默认可预约的订单数
+	RescheduleCutoff    time.Duration // 距原预约时间不足该时长时用户不能改约
+	MaxReschedules      int           // 每个订单用户最多改约次数
+}
+
+// GetBookingConfig 获取预约配置
+func GetBookingConfig() *BookingConfig {
+	return &BookingConfig{
+		DefaultSlotCapacity: getEnvInt("TIME_SLOT_DEFAULT_CAPACITY", 3),
+		RescheduleCutoff:    time.Duration(getEnvInt("ORDER_RESCHEDULE_CUTOFF_HOURS", 12)) * time.Hour,
+		MaxReschedules:      getEnvInt("ORDER_RESCHEDULE_MAX_COUNT", 2),
+	}
+}
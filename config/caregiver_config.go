@@ -0,0 +1,13 @@
+package config
+
+// CaregiverConfig 护理员服务配置
+type CaregiverConfig struct {
+	CheckInRadiusMeters int // 签到、签退位置与服务地址的最大距离（米）
+}
+
+// GetCaregiverConfig 获取护理员服务配置
+func GetCaregiverConfig() *CaregiverConfig {
+	return &CaregiverConfig{
+		CheckInRadiusMeters: getEnvInt("CAREGIVER_CHECKIN_RADIUS_METERS", 500),
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"time"
+)
+
+// IdempotencyConfig 接口幂等配置
+type IdempotencyConfig struct {
+	KeyTTL          time.Duration // 幂等键及响应快照的保留时间
+	ProcessingLease time.Duration // 处理中的租约时长，到期仍未完成视为处理请求已中断，相同请求可以接管
+}
+
+// GetIdempotencyConfig 获取接口幂等配置
+func GetIdempotencyConfig() *IdempotencyConfig {
+	return &IdempotencyConfig{
+		KeyTTL:          time.Duration(getEnvInt("IDEMPOTENCY_KEY_TTL_HOURS", 24)) * time.Hour,
+		ProcessingLease: time.Duration(getEnvInt("IDEMPOTENCY_PROCESSING_LEASE_SECONDS", 60)) * time.Second,
+	}
+}
@@ -1,28 +1,66 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"time"
 )
 
 // PaymentConfig 支付配置
 type PaymentConfig struct {
+	Provider  string          `json:"provider"` // 支付渠道：wechat（默认）或mock（本地模拟，仅开发模式）
 	WechatPay WechatPayConfig `json:"wechatPay"`
+	Mock      MockPayConfig   `json:"mock"`
 }
 
+// 支付渠道
+const (
+	PaymentProviderWechat = "wechat"
+	PaymentProviderMock   = "mock"
+)
+
+// MockPayConfig 模拟支付配置，用于本地无网络环境下走通下单、支付、回调、佣金流程
+type MockPayConfig struct {
+	Result      string        `json:"result"`      // 模拟支付结果：success（支付成功）、fail（支付失败）、none（不发送回调，需手动触发）
+	NotifyDelay time.Duration `json:"notifyDelay"` // 预下单后延迟多久发送模拟回调
+}
+
+// 模拟支付结果
+const (
+	MockPayResultSuccess = "success"
+	MockPayResultFail    = "fail"
+	MockPayResultNone    = "none"
+)
+
 // WechatPayConfig 微信支付配置
 type WechatPayConfig struct {
 	AppID       string `json:"appId"`       // 小程序AppID
 	MchID       string `json:"mchId"`       // 商户号
 	MchKey      string `json:"mchKey"`      // 商户密钥
 	NotifyURL   string `json:"notifyUrl"`   // 支付结果通知地址
-	CertPath    string `json:"certPath"`    // 证书路径
-	KeyPath     string `json:"keyPath"`     // 私钥路径
-	Environment string `json:"environment"` // 环境：sandbox或production
+	CertPath    string `json:"certPath"`    // 商户API证书路径（apiclient_cert.pem）
+	KeyPath     string `json:"keyPath"`     // 商户API私钥路径（apiclient_key.pem），v3请求签名使用
+	Environment string `json:"environment"` // 环境：production（默认）或sandbox（仿真测试系统，仅v2）
+	APIVersion  string `json:"apiVersion"`  // 接口版本：v2（XML、MD5签名）或v3（JSON、RSA签名）
+	APIv3Key    string `json:"-"`           // APIv3密钥，用于解密平台证书和支付通知（仅v3）
+	MchSerialNo string `json:"mchSerialNo"` // 商户API证书序列号，为空时从CertPath证书读取（仅v3）
+}
+
+// 微信支付接口版本
+const (
+	WechatPayAPIv2 = "v2"
+	WechatPayAPIv3 = "v3"
+)
+
+// IsV3 是否使用微信支付APIv3
+func (c *WechatPayConfig) IsV3() bool {
+	return c.APIVersion == WechatPayAPIv3
 }
 
 // GetPaymentConfig 获取支付配置
 func GetPaymentConfig() *PaymentConfig {
 	return &PaymentConfig{
+		Provider: getPaymentEnv("PAYMENT_PROVIDER", PaymentProviderWechat),
 		WechatPay: WechatPayConfig{
 			AppID:       getPaymentEnv("WECHAT_PAY_APP_ID", "wx101090677bd5219e"),
 			MchID:       getPaymentEnv("WECHAT_PAY_MCH_ID", ""),
@@ -30,11 +68,27 @@ func GetPaymentConfig() *PaymentConfig {
 			NotifyURL:   getPaymentEnv("WECHAT_PAY_NOTIFY_URL", "https://your-domain.com/api/payment/notify"),
 			CertPath:    getPaymentEnv("WECHAT_PAY_CERT_PATH", ""),
 			KeyPath:     getPaymentEnv("WECHAT_PAY_KEY_PATH", ""),
-			Environment: getPaymentEnv("WECHAT_PAY_ENVIRONMENT", "sandbox"),
+			Environment: getPaymentEnv("WECHAT_PAY_ENVIRONMENT", "production"),
+			APIVersion:  getPaymentEnv("WECHAT_PAY_API_VERSION", WechatPayAPIv2),
+			APIv3Key:    getPaymentEnv("WECHAT_PAY_API_V3_KEY", ""),
+			MchSerialNo: getPaymentEnv("WECHAT_PAY_MCH_SERIAL_NO", ""),
+		},
+		Mock: MockPayConfig{
+			Result:      getPaymentEnv("PAYMENT_MOCK_RESULT", MockPayResultSuccess),
+			NotifyDelay: time.Duration(getEnvInt("PAYMENT_MOCK_NOTIFY_DELAY_SECONDS", 2)) * time.Second,
 		},
 	}
 }
 
+// Validate 校验支付配置，服务启动时调用，未通过时拒绝启动
+// 模拟支付不经过支付平台就能把订单结算为已支付，只允许在开发模式下启用
+func (c *PaymentConfig) Validate() error {
+	if c.Provider == PaymentProviderMock && !IsDevMode() {
+		return fmt.Errorf("PAYMENT_PROVIDER=mock仅允许在开发模式（APP_DEV_MODE=true）下使用")
+	}
+	return nil
+}
+
 // getPaymentEnv 获取支付环境变量，如果不存在则返回默认值
 func getPaymentEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"time"
+)
+
+// PrivacyConfig 个人信息保护相关配置（数据导出、账号注销）
+type PrivacyConfig struct {
+	DeletionCoolingOff time.Duration // 注销冷静期，期满后才能审核通过
+}
+
+// GetPrivacyConfig 获取个人信息保护配置
+func GetPrivacyConfig() *PrivacyConfig {
+	return &PrivacyConfig{
+		DeletionCoolingOff: time.Duration(getEnvInt("ACCOUNT_DELETION_COOLING_OFF_DAYS", 15)) * 24 * time.Hour,
+	}
+}
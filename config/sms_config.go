@@ -0,0 +1,47 @@
+package config
+
+import (
+	"time"
+)
+
+// SMSConfig 短信验证码配置
+type SMSConfig struct {
+	Provider string // 短信服务商：tencent-腾讯云短信，log-仅打印日志（仅开发模式，开发模式未配置时默认使用）
+
+	// 腾讯云短信
+	SecretId   string
+	SecretKey  string
+	Region     string
+	SdkAppId   string // 短信应用ID
+	SignName   string // 短信签名
+	TemplateId string // 验证码模板ID，模板参数为{1}验证码、{2}有效期分钟数
+
+	// 验证码策略
+	CodeLength        int           // 验证码位数
+	CodeTTL           time.Duration // 验证码有效期
+	ResendInterval    time.Duration // 同一手机号两次发送的最小间隔
+	MaxPerPhonePerDay int           // 同一手机号每天最多发送次数
+	MaxPerIpPerHour   int           // 同一IP每小时最多发送次数
+	MaxVerifyAttempts int           // 单个验证码最多校验次数，超过后作废
+}
+
+// GetSMSConfig 获取短信配置
+func GetSMSConfig() *SMSConfig {
+	return &SMSConfig{
+		Provider: getEnv("SMS_PROVIDER", ""),
+
+		SecretId:   getEnv("TENCENT_SMS_SECRET_ID", ""),
+		SecretKey:  getEnv("TENCENT_SMS_SECRET_KEY", ""),
+		Region:     getEnv("TENCENT_SMS_REGION", "ap-guangzhou"),
+		SdkAppId:   getEnv("TENCENT_SMS_SDK_APP_ID", ""),
+		SignName:   getEnv("TENCENT_SMS_SIGN_NAME", ""),
+		TemplateId: getEnv("TENCENT_SMS_TEMPLATE_ID", ""),
+
+		CodeLength:        getEnvInt("SMS_CODE_LENGTH", 6),
+		CodeTTL:           time.Duration(getEnvInt("SMS_CODE_TTL_MINUTES", 5)) * time.Minute,
+		ResendInterval:    time.Duration(getEnvInt("SMS_RESEND_INTERVAL_SECONDS", 60)) * time.Second,
+		MaxPerPhonePerDay: getEnvInt("SMS_MAX_PER_PHONE_PER_DAY", 10),
+		MaxPerIpPerHour:   getEnvInt("SMS_MAX_PER_IP_PER_HOUR", 20),
+		MaxVerifyAttempts: getEnvInt("SMS_MAX_VERIFY_ATTEMPTS", 5),
+	}
+}
@@ -6,15 +6,23 @@ import (
 
 // WxConfig 微信小程序配置
 type WxConfig struct {
-	AppID     string
-	AppSecret string
+	AppID      string
+	AppSecret  string
+	APIBaseURL string // 微信开放接口地址，本地联调时可指向模拟服务
+
+	// 是否信任云托管注入的X-WX-OPENID等身份请求头
+	// 仅在部署于微信云托管且请求必经平台网关时开启，其他环境请求头可被客户端伪造
+	TrustCloudHeaders bool
 }
 
 // GetWxConfig 获取微信配置
 func GetWxConfig() *WxConfig {
 	return &WxConfig{
-		AppID:     getEnv("WX_APP_ID", "wx101090677bd5219e"),
-		AppSecret: getEnv("WX_APP_SECRET", "042ff9921818ada9336df6e91fc2287e"),
+		AppID:      getEnv("WX_APP_ID", "wx101090677bd5219e"),
+		AppSecret:  getEnv("WX_APP_SECRET", "042ff9921818ada9336df6e91fc2287e"),
+		APIBaseURL: getEnv("WX_API_BASE_URL", "https://api.weixin.qq.com"),
+
+		TrustCloudHeaders: getEnv("WX_CLOUD_TRUST_HEADERS", "false") == "true",
 	}
 }
 
@@ -5,6 +5,9 @@ import (
 	"time"
 	"wxcloudrun-golang/db"
 	"wxcloudrun-golang/db/model"
+	"wxcloudrun-golang/utils"
+
+	"gorm.io/gorm"
 )
 
 // AdminImp 管理员数据访问实现
@@ -15,11 +18,62 @@ func (a *AdminImp) AdminLogin(username, password string) (*model.UserModel, erro
 	var user model.UserModel
 	cli := db.Get()
 
-	err := cli.Table("Users").Where("adminUsername = ? AND adminPassword = ? AND isAdmin = 1", username, password).First(&user).Error
+	err := cli.Table("Users").Where("adminUsername = ? AND isAdmin = 1", username).First(&user).Error
 	if err != nil {
+		// 用户名不存在时同样校验一次密码，避免通过响应耗时判断用户名是否存在
+		utils.VerifyDummyPassword(password)
 		return nil, fmt.Errorf("管理员登录失败: %v", err)
 	}
 
+	// 只接受哈希存储的密码，历史明文密码需先执行迁移
+	if !utils.VerifyPassword(password, user.AdminPassword) {
+		return nil, fmt.Errorf("管理员登录失败: 密码错误")
+	}
+
+	// 早期版本的PBKDF2哈希在登录成功后升级为bcrypt，升级失败不影响登录
+	if utils.PasswordNeedsRehash(user.AdminPassword) {
+		if passwordHash, err := utils.HashPassword(password); err == nil {
+			err = cli.Table("Users").Where("userId = ? AND adminPassword = ?", user.UserId, user.AdminPassword).
+				Update("adminPassword", passwordHash).Error
+			if err == nil {
+				user.AdminPassword = passwordHash
+			}
+		}
+	}
+
+	return &user, nil
+}
+
+// UpdateAdminPassword 更新管理员密码哈希
+func (a *AdminImp) UpdateAdminPassword(userId, passwordHash string, mustChange int) error {
+	cli := db.Get()
+	now := time.Now()
+
+	updates := map[string]interface{}{
+		"adminPassword":           passwordHash,
+		"adminPasswordUpdatedAt":  now,
+		"adminPasswordMustChange": mustChange,
+		"updatedAt":               now,
+	}
+
+	err := cli.Table("Users").Where("userId = ? AND isAdmin = 1", userId).Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("更新管理员密码失败: %v", err)
+	}
+
+	return nil
+}
+
+// GetAdminByUsername 根据管理员用户名获取管理员信息
+func (a *AdminImp) GetAdminByUsername(username string) (*model.UserModel, error) {
+	var user model.UserModel
+	cli := db.Get()
+
+	err := cli.Table("Users").Where("adminUsername = ? AND isAdmin = 1", username).First(&user).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取管理员信息失败: %v", err)
+	}
+
 	return &user, nil
 }
 
@@ -92,6 +146,7 @@ func (a *AdminImp) RemoveAdmin(userId string) error {
 		"adminLevel":     0,
 		"parentAdminId":  nil,
 		"adminCreatedAt": nil,
+		"adminRoles":     "",
 		"updatedAt":      now,
 	}
 
@@ -103,6 +158,19 @@ func (a *AdminImp) RemoveAdmin(userId string) error {
 	return nil
 }
 
+// UpdateAdminRoles 更新管理员角色
+func (a *AdminImp) UpdateAdminRoles(userId, roles string) error {
+	cli := db.Get()
+	err := cli.Table("Users").Where("userId = ? AND isAdmin = 1", userId).Updates(map[string]interface{}{
+		"adminRoles": roles,
+		"updatedAt":  time.Now(),
+	}).Error
+	if err != nil {
+		return fmt.Errorf("更新管理员角色失败: %v", err)
+	}
+	return nil
+}
+
 // GetSubAdmins 获取下级管理员列表
 func (a *AdminImp) GetSubAdmins(parentAdminId string, page, pageSize int) ([]*model.UserModel, int64, error) {
 	var users []*model.UserModel
@@ -255,3 +323,265 @@ func (a *AdminImp) GetAdminLoginLogs(adminUserId string, page, pageSize int) ([]
 
 	return logs, total, nil
 }
+
+// RecordLoginFailure 记录一次登录失败，超过统计窗口的历史失败次数会被清零，返回更新后的计数记录
+func (a *AdminImp) RecordLoginFailure(lockType, lockKey string, window time.Duration) (*model.AdminLoginLockModel, error) {
+	cli := db.Get()
+	now := time.Now()
+
+	var lock model.AdminLoginLockModel
+	err := cli.Table("AdminLoginLocks").Where("lockType = ? AND lockKey = ?", lockType, lockKey).First(&lock).Error
+	if err == gorm.ErrRecordNotFound {
+		lock = model.AdminLoginLockModel{
+			LockType:     lockType,
+			LockKey:      lockKey,
+			FailedCount:  1,
+			LastFailedAt: &now,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		if err := cli.Table("AdminLoginLocks").Create(&lock).Error; err != nil {
+			return nil, fmt.Errorf("创建登录失败记录失败: %v", err)
+		}
+		return &lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取登录失败记录失败: %v", err)
+	}
+
+	failedCount := gorm.Expr("failedCount + 1")
+	if lock.LastFailedAt == nil || now.Sub(*lock.LastFailedAt) > window {
+		failedCount = gorm.Expr("1")
+	}
+	err = cli.Table("AdminLoginLocks").Where("id = ?", lock.Id).Updates(map[string]interface{}{
+		"failedCount":  failedCount,
+		"lastFailedAt": now,
+		"updatedAt":    now,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("更新登录失败记录失败: %v", err)
+	}
+
+	err = cli.Table("AdminLoginLocks").Where("id = ?", lock.Id).First(&lock).Error
+	return &lock, err
+}
+
+// LockLogin 锁定账号或IP至指定时间
+func (a *AdminImp) LockLogin(id int32, lockedUntil time.Time) error {
+	cli := db.Get()
+	return cli.Table("AdminLoginLocks").Where("id = ?", id).Updates(map[string]interface{}{
+		"lockedUntil": lockedUntil,
+		"lockCount":   gorm.Expr("lockCount + 1"),
+		"updatedAt":   time.Now(),
+	}).Error
+}
+
+// GetActiveLoginLock 获取仍在锁定期内的记录，未锁定时返回nil
+func (a *AdminImp) GetActiveLoginLock(lockType, lockKey string) (*model.AdminLoginLockModel, error) {
+	var locks []*model.AdminLoginLockModel
+	cli := db.Get()
+	err := cli.Table("AdminLoginLocks").
+		Where("lockType = ? AND lockKey = ? AND lockedUntil > ?", lockType, lockKey, time.Now()).
+		Limit(1).Find(&locks).Error
+	if err != nil || len(locks) == 0 {
+		return nil, err
+	}
+	return locks[0], nil
+}
+
+// ResetLoginFailures 登录成功后清零失败次数（累计锁定次数保留）
+func (a *AdminImp) ResetLoginFailures(lockType, lockKey string) error {
+	cli := db.Get()
+	return cli.Table("AdminLoginLocks").Where("lockType = ? AND lockKey = ?", lockType, lockKey).
+		Updates(map[string]interface{}{
+			"failedCount": 0,
+			"lockedUntil": nil,
+			"updatedAt":   time.Now(),
+		}).Error
+}
+
+// GetActiveLoginLocks 获取所有仍在锁定期内的记录
+func (a *AdminImp) GetActiveLoginLocks() ([]*model.AdminLoginLockModel, error) {
+	var locks []*model.AdminLoginLockModel
+	cli := db.Get()
+	err := cli.Table("AdminLoginLocks").Where("lockedUntil > ?", time.Now()).
+		Order("lockedUntil DESC").Find(&locks).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取登录锁定列表失败: %v", err)
+	}
+	return locks, nil
+}
+
+// ClearLoginLock 解除锁定并清零失败次数
+func (a *AdminImp) ClearLoginLock(id int32) error {
+	cli := db.Get()
+	result := cli.Table("AdminLoginLocks").Where("id = ?", id).Updates(map[string]interface{}{
+		"failedCount": 0,
+		"lockedUntil": nil,
+		"updatedAt":   time.Now(),
+	})
+	if result.Error != nil {
+		return fmt.Errorf("解除登录锁定失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("锁定记录不存在")
+	}
+	return nil
+}
+
+// GetRecentFailedLogins 获取最近的登录失败记录
+func (a *AdminImp) GetRecentFailedLogins(since time.Time, limit int) ([]*model.AdminLoginLogModel, error) {
+	var logs []*model.AdminLoginLogModel
+	cli := db.Get()
+	err := cli.Table("AdminLoginLogs").Where("status = ? AND loginTime >= ?", 0, since).
+		Order("loginTime DESC").Limit(limit).Find(&logs).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取登录失败记录失败: %v", err)
+	}
+	return logs, nil
+}
+
+// GetAdminTotp 获取管理员的TOTP配置，未配置时返回nil
+func (a *AdminImp) GetAdminTotp(userId string) (*model.AdminTotpModel, error) {
+	var totps []*model.AdminTotpModel
+	cli := db.Get()
+	err := cli.Table("AdminTotps").Where("userId = ?", userId).Limit(1).Find(&totps).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取两步验证配置失败: %v", err)
+	}
+	if len(totps) == 0 {
+		return nil, nil
+	}
+	return totps[0], nil
+}
+
+// SaveAdminTotpSecret 保存待验证的TOTP密钥，已有待验证密钥时覆盖
+func (a *AdminImp) SaveAdminTotpSecret(userId, encryptedSecret string) error {
+	cli := db.Get()
+	now := time.Now()
+
+	existing, err := a.GetAdminTotp(userId)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return cli.Table("AdminTotps").Create(&model.AdminTotpModel{
+			UserId:    userId,
+			Secret:    encryptedSecret,
+			Status:    0,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}).Error
+	}
+	if existing.Status == 1 {
+		return fmt.Errorf("两步验证已启用")
+	}
+	return cli.Table("AdminTotps").Where("id = ?", existing.Id).Updates(map[string]interface{}{
+		"secret":       encryptedSecret,
+		"lastUsedStep": 0,
+		"updatedAt":    now,
+	}).Error
+}
+
+// UpdateAdminTotpSecret 替换加密后的TOTP密钥，密文已被其他请求修改时不更新
+func (a *AdminImp) UpdateAdminTotpSecret(userId, oldEncryptedSecret, newEncryptedSecret string) error {
+	cli := db.Get()
+	return cli.Table("AdminTotps").Where("userId = ? AND secret = ?", userId, oldEncryptedSecret).Updates(map[string]interface{}{
+		"secret":    newEncryptedSecret,
+		"updatedAt": time.Now(),
+	}).Error
+}
+
+// EnableAdminTotp 启用两步验证并写入新的恢复码
+func (a *AdminImp) EnableAdminTotp(userId string, step int64, recoveryCodeHashes []string) error {
+	cli := db.Get()
+	now := time.Now()
+
+	return cli.Transaction(func(tx *gorm.DB) error {
+		result := tx.Table("AdminTotps").Where("userId = ? AND status = ?", userId, 0).Updates(map[string]interface{}{
+			"status":       1,
+			"lastUsedStep": step,
+			"enabledAt":    now,
+			"updatedAt":    now,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("启用两步验证失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("没有待验证的两步验证配置")
+		}
+		return replaceRecoveryCodes(tx, userId, recoveryCodeHashes)
+	})
+}
+
+// UseAdminTotpStep 记录已使用的时间步，仅当时间步大于上次使用值时成功（同一验证码只能使用一次）
+func (a *AdminImp) UseAdminTotpStep(userId string, step int64) (bool, error) {
+	cli := db.Get()
+	result := cli.Table("AdminTotps").Where("userId = ? AND lastUsedStep < ?", userId, step).Updates(map[string]interface{}{
+		"lastUsedStep": step,
+		"updatedAt":    time.Now(),
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteAdminTotp 关闭两步验证，同时删除恢复码
+func (a *AdminImp) DeleteAdminTotp(userId string) error {
+	cli := db.Get()
+	return cli.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("AdminTotps").Where("userId = ?", userId).Delete(&model.AdminTotpModel{}).Error; err != nil {
+			return fmt.Errorf("删除两步验证配置失败: %v", err)
+		}
+		if err := tx.Table("AdminRecoveryCodes").Where("userId = ?", userId).Delete(&model.AdminRecoveryCodeModel{}).Error; err != nil {
+			return fmt.Errorf("删除恢复码失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// ReplaceRecoveryCodes 重新生成恢复码，旧恢复码全部作废
+func (a *AdminImp) ReplaceRecoveryCodes(userId string, codeHashes []string) error {
+	cli := db.Get()
+	return cli.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userId, codeHashes)
+	})
+}
+
+// UseRecoveryCode 使用一个恢复码，恢复码不存在或已使用时返回false
+func (a *AdminImp) UseRecoveryCode(userId, codeHash string) (bool, error) {
+	cli := db.Get()
+	result := cli.Table("AdminRecoveryCodes").
+		Where("userId = ? AND codeHash = ? AND usedAt IS NULL", userId, codeHash).
+		Update("usedAt", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountUnusedRecoveryCodes 统计未使用的恢复码数量
+func (a *AdminImp) CountUnusedRecoveryCodes(userId string) (int64, error) {
+	var count int64
+	cli := db.Get()
+	err := cli.Table("AdminRecoveryCodes").Where("userId = ? AND usedAt IS NULL", userId).Count(&count).Error
+	return count, err
+}
+
+// replaceRecoveryCodes 在事务中删除旧恢复码并写入新恢复码
+func replaceRecoveryCodes(tx *gorm.DB, userId string, codeHashes []string) error {
+	if err := tx.Table("AdminRecoveryCodes").Where("userId = ?", userId).Delete(&model.AdminRecoveryCodeModel{}).Error; err != nil {
+		return fmt.Errorf("删除旧恢复码失败: %v", err)
+	}
+	now := time.Now()
+	codes := make([]*model.AdminRecoveryCodeModel, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, &model.AdminRecoveryCodeModel{
+			UserId:    userId,
+			CodeHash:  hash,
+			CreatedAt: now,
+		})
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	if err := tx.Table("AdminRecoveryCodes").Create(&codes).Error; err != nil {
+		return fmt.Errorf("保存恢复码失败: %v", err)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package dao
 
 import (
+	"time"
+
 	"wxcloudrun-golang/db/model"
 )
 
@@ -9,6 +11,9 @@ type AdminInterface interface {
 	// 管理员登录
 	AdminLogin(username, password string) (*model.UserModel, error)
 
+	// 根据用户名获取管理员信息
+	GetAdminByUsername(username string) (*model.UserModel, error)
+
 	// 获取管理员信息
 	GetAdminByUserId(userId string) (*model.UserModel, error)
 
@@ -21,6 +26,9 @@ type AdminInterface interface {
 	// 取消用户管理员权限
 	RemoveAdmin(userId string) error
 
+	// 更新管理员角色
+	UpdateAdminRoles(userId, roles string) error
+
 	// 获取下级管理员列表
 	GetSubAdmins(parentAdminId string, page, pageSize int) ([]*model.UserModel, int64, error)
 
@@ -35,4 +43,52 @@ type AdminInterface interface {
 
 	// 获取管理员登录日志
 	GetAdminLoginLogs(adminUserId string, page, pageSize int) ([]*model.AdminLoginLogModel, int64, error)
+
+	// 记录登录失败次数
+	RecordLoginFailure(lockType, lockKey string, window time.Duration) (*model.AdminLoginLockModel, error)
+
+	// 锁定账号或IP
+	LockLogin(id int32, lockedUntil time.Time) error
+
+	// 获取仍在锁定期内的记录
+	GetActiveLoginLock(lockType, lockKey string) (*model.AdminLoginLockModel, error)
+
+	// 登录成功后清零失败次数
+	ResetLoginFailures(lockType, lockKey string) error
+
+	// 获取所有锁定中的记录
+	GetActiveLoginLocks() ([]*model.AdminLoginLockModel, error)
+
+	// 解除锁定
+	ClearLoginLock(id int32) error
+
+	// 获取最近的登录失败记录
+	GetRecentFailedLogins(since time.Time, limit int) ([]*model.AdminLoginLogModel, error)
+
+	// 获取TOTP两步验证配置
+	GetAdminTotp(userId string) (*model.AdminTotpModel, error)
+
+	// 保存待验证的TOTP密钥
+	SaveAdminTotpSecret(userId, encryptedSecret string) error
+
+	// 替换加密后的TOTP密钥（更换加密密钥时重新加密）
+	UpdateAdminTotpSecret(userId, oldEncryptedSecret, newEncryptedSecret string) error
+
+	// 启用两步验证
+	EnableAdminTotp(userId string, step int64, recoveryCodeHashes []string) error
+
+	// 记录已使用的TOTP时间步
+	UseAdminTotpStep(userId string, step int64) (bool, error)
+
+	// 关闭两步验证
+	DeleteAdminTotp(userId string) error
+
+	// 重新生成恢复码
+	ReplaceRecoveryCodes(userId string, codeHashes []string) error
+
+	// 使用恢复码
+	UseRecoveryCode(userId, codeHash string) (bool, error)
+
+	// 统计未使用的恢复码数量
+	CountUnusedRecoveryCodes(userId string) (int64, error)
 }
@@ -0,0 +1,94 @@
+package dao
+
+import (
+	"time"
+
+	"wxcloudrun-golang/db"
+	"wxcloudrun-golang/db/model"
+)
+
+const authSessionTableName = "AuthSessions"
+
+// CreateSession 创建登录会话
+func (imp *AuthInterfaceImp) CreateSession(session *model.AuthSessionModel) error {
+	cli := db.Get()
+	session.CreatedAt = time.Now()
+	session.UpdatedAt = time.Now()
+	return cli.Table(authSessionTableName).Create(session).Error
+}
+
+// GetSessionBySessionId 根据会话ID获取会话
+func (imp *AuthInterfaceImp) GetSessionBySessionId(sessionId string) (*model.AuthSessionModel, error) {
+	var session = new(model.AuthSessionModel)
+	cli := db.Get()
+	err := cli.Table(authSessionTableName).Where("sessionId = ?", sessionId).First(session).Error
+	return session, err
+}
+
+// RotateRefreshJti 轮换刷新令牌ID，仅当旧ID仍为当前值且会话有效时成功（防止刷新令牌重放）
+func (imp *AuthInterfaceImp) RotateRefreshJti(sessionId, oldJti, newJti string, expiresAt time.Time) (bool, error) {
+	cli := db.Get()
+	result := cli.Table(authSessionTableName).
+		Where("sessionId = ? AND refreshJti = ? AND status = ?", sessionId, oldJti, 1).
+		Updates(map[string]interface{}{
+			"refreshJti": newJti,
+			"expiresAt":  expiresAt,
+			"updatedAt":  time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeSession 吊销单个会话
+func (imp *AuthInterfaceImp) RevokeSession(sessionId, reason string) error {
+	cli := db.Get()
+	now := time.Now()
+	return cli.Table(authSessionTableName).
+		Where("sessionId = ? AND status = ?", sessionId, 1).
+		Updates(map[string]interface{}{
+			"status":       0,
+			"revokedAt":    now,
+			"revokeReason": reason,
+			"updatedAt":    now,
+		}).Error
+}
+
+// RevokeUserSessions 吊销用户的全部有效会话
+func (imp *AuthInterfaceImp) RevokeUserSessions(userId, reason string) (int64, error) {
+	cli := db.Get()
+	now := time.Now()
+	result := cli.Table(authSessionTableName).
+		Where("userId = ? AND status = ?", userId, 1).
+		Updates(map[string]interface{}{
+			"status":       0,
+			"revokedAt":    now,
+			"revokeReason": reason,
+			"updatedAt":    now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// RevokeOtherSessions 吊销用户除指定会话外的其他有效会话
+func (imp *AuthInterfaceImp) RevokeOtherSessions(userId, keepSessionId, reason string) (int64, error) {
+	cli := db.Get()
+	now := time.Now()
+	result := cli.Table(authSessionTableName).
+		Where("userId = ? AND sessionId <> ? AND status = ?", userId, keepSessionId, 1).
+		Updates(map[string]interface{}{
+			"status":       0,
+			"revokedAt":    now,
+			"revokeReason": reason,
+			"updatedAt":    now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// SetSessionStepUp 记录会话通过两步验证的有效期
+func (imp *AuthInterfaceImp) SetSessionStepUp(sessionId string, until time.Time) error {
+	cli := db.Get()
+	return cli.Table(authSessionTableName).
+		Where("sessionId = ? AND status = ?", sessionId, 1).
+		Updates(map[string]interface{}{
+			"stepUpUntil": until,
+			"updatedAt":   time.Now(),
+		}).Error
+}
@@ -0,0 +1,24 @@
+package dao
+
+import (
+	"time"
+
+	"wxcloudrun-golang/db/model"
+)
+
+// AuthInterface 登录会话数据接口
+type AuthInterface interface {
+	CreateSession(session *model.AuthSessionModel) error
+	GetSessionBySessionId(sessionId string) (*model.AuthSessionModel, error)
+	RotateRefreshJti(sessionId, oldJti, newJti string, expiresAt time.Time) (bool, error)
+	RevokeSession(sessionId, reason string) error
+	RevokeUserSessions(userId, reason string) (int64, error)
+	RevokeOtherSessions(userId, keepSessionId, reason string) (int64, error)
+	SetSessionStepUp(sessionId string, until time.Time) error
+}
+
+// AuthInterfaceImp 登录会话数据实现
+type AuthInterfaceImp struct{}
+
+// AuthImp 登录会话实现实例
+var AuthImp AuthInterface = &AuthInterfaceImp{}
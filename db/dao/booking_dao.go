@@ -0,0 +1,207 @@
+package dao
+
+import (
+	"errors"
+	"time"
+
+	"wxcloudrun-golang/db"
+	"wxcloudrun-golang/db/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const timeSlotInventoryTableName = "TimeSlotInventories"
+const slotTemplateTableName = "SlotTemplates"
+const bookingBlackoutTableName = "BookingBlackouts"
+const cancellationPolicyTableName = "CancellationPolicies"
+
+// ErrTimeSlotFull 时间段已约满
+var ErrTimeSlotFull = errors.New("该时间段已约满")
+
+// GetSlotInventories 获取服务项目某天已有的时间段库存记录
+func (imp *BookingInterfaceImp) GetSlotInventories(serviceId int32, date string) ([]*model.TimeSlotInventoryModel, error) {
+	var inventories []*model.TimeSlotInventoryModel
+	cli := db.Get()
+	err := cli.Table(timeSlotInventoryTableName).
+		Where("serviceId = ? AND date = ?", serviceId, date).
+		Order("timeSlot ASC").Find(&inventories).Error
+	return inventories, err
+}
+
+// SetSlotCapacity 设置某个时间段的容量，容量可以低于已预约数，此时该时间段不再接受新订单
+func (imp *BookingInterfaceImp) SetSlotCapacity(serviceId int32, date, timeSlot string, capacity int) error {
+	cli := db.Get()
+	return cli.Transaction(func(tx *gorm.DB) error {
+		if err := ensureSlotInventory(tx, serviceId, date, timeSlot, capacity); err != nil {
+			return err
+		}
+		return tx.Table(timeSlotInventoryTableName).
+			Where("serviceId = ? AND date = ? AND timeSlot = ?", serviceId, date, timeSlot).
+			Updates(map[string]interface{}{
+				"capacity":  capacity,
+				"updatedAt": time.Now(),
+			}).Error
+	})
+}
+
+// ensureSlotInventory 时间段库存记录不存在时按给定容量创建
+func ensureSlotInventory(tx *gorm.DB, serviceId int32, date, timeSlot string, capacity int) error {
+	now := time.Now()
+	return tx.Table(timeSlotInventoryTableName).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.TimeSlotInventoryModel{
+			ServiceId: serviceId,
+			Date:      date,
+			TimeSlot:  timeSlot,
+			Capacity:  capacity,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}).Error
+}
+
+// reserveTimeSlot 在事务中占用一个时间段名额，已约满时返回ErrTimeSlotFull
+// 占用通过带条件的原子更新完成，并发下单不会超卖
+func reserveTimeSlot(tx *gorm.DB, serviceId int32, date, timeSlot string, defaultCapacity int) error {
+	if err := ensureSlotInventory(tx, serviceId, date, timeSlot, defaultCapacity); err != nil {
+		return err
+	}
+	result := tx.Table(timeSlotInventoryTableName).
+		Where("serviceId = ? AND date = ? AND timeSlot = ? AND booked < capacity", serviceId, date, timeSlot).
+		Updates(map[string]interface{}{
+			"booked":    gorm.Expr("booked + 1"),
+			"updatedAt": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTimeSlotFull
+	}
+	return nil
+}
+
+// releaseTimeSlot 在事务中释放订单占用的时间段名额
+func releaseTimeSlot(tx *gorm.DB, serviceId int32, date, timeSlot string) error {
+	return tx.Table(timeSlotInventoryTableName).
+		Where("serviceId = ? AND date = ? AND timeSlot = ? AND booked > 0", serviceId, date, timeSlot).
+		Updates(map[string]interface{}{
+			"booked":    gorm.Expr("booked - 1"),
+			"updatedAt": time.Now(),
+		}).Error
+}
+
+// GetSlotTemplates 获取时间段模板，onlyEnabled为true时只返回启用的模板
+func (imp *BookingInterfaceImp) GetSlotTemplates(onlyEnabled bool) ([]*model.SlotTemplateModel, error) {
+	var templates []*model.SlotTemplateModel
+	cli := db.Get()
+	query := cli.Table(slotTemplateTableName)
+	if onlyEnabled {
+		query = query.Where("status = ?", 1)
+	}
+	err := query.Order("id ASC").Find(&templates).Error
+	return templates, err
+}
+
+// GetSlotTemplateById 根据ID获取时间段模板
+func (imp *BookingInterfaceImp) GetSlotTemplateById(id int32) (*model.SlotTemplateModel, error) {
+	var template = new(model.SlotTemplateModel)
+	cli := db.Get()
+	err := cli.Table(slotTemplateTableName).Where("id = ?", id).First(template).Error
+	return template, err
+}
+
+// SaveSlotTemplate 新建（Id为0）或更新时间段模板
+func (imp *BookingInterfaceImp) SaveSlotTemplate(template *model.SlotTemplateModel) error {
+	cli := db.Get()
+	template.UpdatedAt = time.Now()
+	if template.Id == 0 {
+		template.CreatedAt = time.Now()
+		return cli.Table(slotTemplateTableName).Create(template).Error
+	}
+	return cli.Table(slotTemplateTableName).Where("id = ?", template.Id).Updates(map[string]interface{}{
+		"name":        template.Name,
+		"serviceId":   template.ServiceId,
+		"category":    template.Category,
+		"timeSlots":   template.TimeSlots,
+		"weekdays":    template.Weekdays,
+		"leadDays":    template.LeadDays,
+		"horizonDays": template.HorizonDays,
+		"status":      template.Status,
+		"updatedAt":   template.UpdatedAt,
+	}).Error
+}
+
+// DeleteSlotTemplate 删除时间段模板
+func (imp *BookingInterfaceImp) DeleteSlotTemplate(id int32) error {
+	cli := db.Get()
+	return cli.Table(slotTemplateTableName).Where("id = ?", id).Delete(&model.SlotTemplateModel{}).Error
+}
+
+// GetBlackouts 获取日期范围内（含首尾）的停约记录
+func (imp *BookingInterfaceImp) GetBlackouts(fromDate, toDate string) ([]*model.BookingBlackoutModel, error) {
+	var blackouts []*model.BookingBlackoutModel
+	cli := db.Get()
+	err := cli.Table(bookingBlackoutTableName).
+		Where("date >= ? AND date <= ?", fromDate, toDate).
+		Order("date ASC, id ASC").Find(&blackouts).Error
+	return blackouts, err
+}
+
+// CreateBlackout 新增停约记录
+func (imp *BookingInterfaceImp) CreateBlackout(blackout *model.BookingBlackoutModel) error {
+	cli := db.Get()
+	blackout.CreatedAt = time.Now()
+	return cli.Table(bookingBlackoutTableName).Create(blackout).Error
+}
+
+// DeleteBlackout 删除停约记录
+func (imp *BookingInterfaceImp) DeleteBlackout(id int32) error {
+	cli := db.Get()
+	return cli.Table(bookingBlackoutTableName).Where("id = ?", id).Delete(&model.BookingBlackoutModel{}).Error
+}
+
+// GetCancellationPolicies 获取取消退款政策，onlyEnabled为true时只返回启用的政策
+func (imp *BookingInterfaceImp) GetCancellationPolicies(onlyEnabled bool) ([]*model.CancellationPolicyModel, error) {
+	var policies []*model.CancellationPolicyModel
+	cli := db.Get()
+	query := cli.Table(cancellationPolicyTableName)
+	if onlyEnabled {
+		query = query.Where("status = ?", 1)
+	}
+	err := query.Order("id ASC").Find(&policies).Error
+	return policies, err
+}
+
+// GetCancellationPolicyById 根据ID获取取消退款政策
+func (imp *BookingInterfaceImp) GetCancellationPolicyById(id int32) (*model.CancellationPolicyModel, error) {
+	var policy = new(model.CancellationPolicyModel)
+	cli := db.Get()
+	err := cli.Table(cancellationPolicyTableName).Where("id = ?", id).First(policy).Error
+	return policy, err
+}
+
+// SaveCancellationPolicy 新建（Id为0）或更新取消退款政策
+func (imp *BookingInterfaceImp) SaveCancellationPolicy(policy *model.CancellationPolicyModel) error {
+	cli := db.Get()
+	policy.UpdatedAt = time.Now()
+	if policy.Id == 0 {
+		policy.CreatedAt = time.Now()
+		return cli.Table(cancellationPolicyTableName).Create(policy).Error
+	}
+	return cli.Table(cancellationPolicyTableName).Where("id = ?", policy.Id).Updates(map[string]interface{}{
+		"name":             policy.Name,
+		"serviceId":        policy.ServiceId,
+		"category":         policy.Category,
+		"tiers":            policy.Tiers,
+		"checkedInPercent": policy.CheckedInPercent,
+		"status":           policy.Status,
+		"updatedAt":        policy.UpdatedAt,
+	}).Error
+}
+
+// DeleteCancellationPolicy 删除取消退款政策
+func (imp *BookingInterfaceImp) DeleteCancellationPolicy(id int32) error {
+	cli := db.Get()
+	return cli.Table(cancellationPolicyTableName).Where("id = ?", id).Delete(&model.CancellationPolicyModel{}).Error
+}
@@ -0,0 +1,28 @@
+package dao
+
+import (
+	"wxcloudrun-golang/db/model"
+)
+
+// BookingInterface 预约时间段库存数据接口
+type BookingInterface interface {
+	GetSlotInventories(serviceId int32, date string) ([]*model.TimeSlotInventoryModel, error)
+	SetSlotCapacity(serviceId int32, date, timeSlot string, capacity int) error
+	GetSlotTemplates(onlyEnabled bool) ([]*model.SlotTemplateModel, error)
+	GetSlotTemplateById(id int32) (*model.SlotTemplateModel, error)
+	SaveSlotTemplate(template *model.SlotTemplateModel) error
+	DeleteSlotTemplate(id int32) error
+	GetBlackouts(fromDate, toDate string) ([]*model.BookingBlackoutModel, error)
+	CreateBlackout(blackout *model.BookingBlackoutModel) error
+	DeleteBlackout(id int32) error
+	GetCancellationPolicies(onlyEnabled bool) ([]*model.CancellationPolicyModel, error)
+	GetCancellationPolicyById(id int32) (*model.CancellationPolicyModel, error)
+	SaveCancellationPolicy(policy *model.CancellationPolicyModel) error
+	DeleteCancellationPolicy(id int32) error
+}
+
+// BookingInterfaceImp 预约时间段库存数据实现
+type BookingInterfaceImp struct{}
+
+// BookingImp 预约时间段库存实现实例
+var BookingImp BookingInterface = &BookingInterfaceImp{}
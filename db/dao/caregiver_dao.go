@@ -0,0 +1,255 @@
+package dao
+
+import (
+	"errors"
+	"time"
+
+	"wxcloudrun-golang/db"
+	"wxcloudrun-golang/db/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const caregiverTableName = "Caregivers"
+const serviceRecordTableName = "ServiceRecords"
+
+// ErrCaregiverConflict 护理员在同一时间段已有派单
+var ErrCaregiverConflict = errors.New("该护理员在此时间段已有其他订单")
+
+// ErrCaregiverUnavailable 护理员已停用或休假
+var ErrCaregiverUnavailable = errors.New("该护理员当前不可派单")
+
+// caregiverBusyStatuses 占用护理员时间段的订单状态
+var caregiverBusyStatuses = []int{model.OrderStatusAssigned}
+
+// GetCaregivers 获取护理员列表（分页），keyword匹配姓名或手机号
+func (imp *CaregiverInterfaceImp) GetCaregivers(status int, keyword string, page, pageSize int) ([]*model.CaregiverModel, int64, error) {
+	var caregivers []*model.CaregiverModel
+	var total int64
+	cli := db.Get()
+
+	query := cli.Table(caregiverTableName)
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+	if keyword != "" {
+		query = query.Where("name LIKE ? OR phone LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&caregivers).Error
+	return caregivers, total, err
+}
+
+// GetCaregiverById 根据ID获取护理员
+func (imp *CaregiverInterfaceImp) GetCaregiverById(id int32) (*model.CaregiverModel, error) {
+	var caregiver = new(model.CaregiverModel)
+	cli := db.Get()
+	err := cli.Table(caregiverTableName).Where("id = ?", id).First(caregiver).Error
+	return caregiver, err
+}
+
+// GetCaregiversByIds 批量获取护理员
+func (imp *CaregiverInterfaceImp) GetCaregiversByIds(ids []int32) ([]*model.CaregiverModel, error) {
+	var caregivers []*model.CaregiverModel
+	if len(ids) == 0 {
+		return caregivers, nil
+	}
+	cli := db.Get()
+	err := cli.Table(caregiverTableName).Where("id IN (?)", ids).Find(&caregivers).Error
+	return caregivers, err
+}
+
+// SaveCaregiver 新建（Id为0）或更新护理员
+func (imp *CaregiverInterfaceImp) SaveCaregiver(caregiver *model.CaregiverModel) error {
+	cli := db.Get()
+	caregiver.UpdatedAt = time.Now()
+	if caregiver.Id == 0 {
+		caregiver.CreatedAt = time.Now()
+		return cli.Table(caregiverTableName).Create(caregiver).Error
+	}
+	return cli.Table(caregiverTableName).Where("id = ?", caregiver.Id).Updates(map[string]interface{}{
+		"userId":         caregiver.UserId,
+		"name":           caregiver.Name,
+		"phone":          caregiver.Phone,
+		"gender":         caregiver.Gender,
+		"skills":         caregiver.Skills,
+		"regions":        caregiver.Regions,
+		"certifications": caregiver.Certifications,
+		"status":         caregiver.Status,
+		"remark":         caregiver.Remark,
+		"updatedAt":      caregiver.UpdatedAt,
+	}).Error
+}
+
+// GetAssignedOrdersAt 获取某个时间段内已派单（占用护理员）的订单
+func (imp *CaregiverInterfaceImp) GetAssignedOrdersAt(date, timeSlot string) ([]*model.OrderModel, error) {
+	var orders []*model.OrderModel
+	cli := db.Get()
+	err := cli.Table(orderTableName).
+		Where("appointmentDate = ? AND appointmentTime = ? AND caregiverId > 0 AND status IN (?)", date, timeSlot, caregiverBusyStatuses).
+		Find(&orders).Error
+	return orders, err
+}
+
+// CheckCaregiverAvailableTx 在调用方的事务中锁定护理员并检查其能否接该订单
+// 护理员停用或休假时返回ErrCaregiverUnavailable，同一时间段已有其他派单时返回ErrCaregiverConflict
+// 锁定护理员记录使同一护理员的派单串行执行，避免并发派单同时通过冲突检查
+func (imp *CaregiverInterfaceImp) CheckCaregiverAvailableTx(uow *UnitOfWork, caregiverId, orderId int32, date, timeSlot string) error {
+	tx := uow.tx
+	caregiver := new(model.CaregiverModel)
+	if err := tx.Table(caregiverTableName).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", caregiverId).First(caregiver).Error; err != nil {
+		return err
+	}
+	if caregiver.Status != model.CaregiverStatusActive {
+		return ErrCaregiverUnavailable
+	}
+
+	var conflicts int64
+	if err := tx.Table(orderTableName).
+		Where("caregiverId = ? AND id <> ? AND appointmentDate = ? AND appointmentTime = ? AND status IN (?)",
+			caregiverId, orderId, date, timeSlot, caregiverBusyStatuses).
+		Count(&conflicts).Error; err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return ErrCaregiverConflict
+	}
+	return nil
+}
+
+// 护理员端相关方法
+
+// GetCaregiverByUserId 根据绑定的用户ID获取护理员
+func (imp *CaregiverInterfaceImp) GetCaregiverByUserId(userId string) (*model.CaregiverModel, error) {
+	var caregiver = new(model.CaregiverModel)
+	cli := db.Get()
+	err := cli.Table(caregiverTableName).Where("userId = ?", userId).First(caregiver).Error
+	return caregiver, err
+}
+
+// GetCaregiverOrders 获取派给护理员的订单（分页），按预约时间升序
+func (imp *CaregiverInterfaceImp) GetCaregiverOrders(caregiverId int32, statuses []int, page, pageSize int) ([]*model.OrderModel, int64, error) {
+	var orders []*model.OrderModel
+	var total int64
+	cli := db.Get()
+
+	query := cli.Table(orderTableName).Where("caregiverId = ? AND status IN (?)", caregiverId, statuses)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("appointmentDate ASC, appointmentTime ASC").Offset(offset).Limit(pageSize).Find(&orders).Error
+	return orders, total, err
+}
+
+// 服务记录相关方法
+
+// GetServiceRecord 获取护理员在订单上的服务记录，没有记录时返回gorm.ErrRecordNotFound
+func (imp *CaregiverInterfaceImp) GetServiceRecord(orderId, caregiverId int32) (*model.ServiceRecordModel, error) {
+	var record = new(model.ServiceRecordModel)
+	cli := db.Get()
+	err := cli.Table(serviceRecordTableName).Where("orderId = ? AND caregiverId = ?", orderId, caregiverId).First(record).Error
+	return record, err
+}
+
+// GetServiceRecordsByOrderIds 批量获取护理员在多个订单上的服务记录
+func (imp *CaregiverInterfaceImp) GetServiceRecordsByOrderIds(caregiverId int32, orderIds []int32) ([]*model.ServiceRecordModel, error) {
+	var records []*model.ServiceRecordModel
+	if len(orderIds) == 0 {
+		return records, nil
+	}
+	cli := db.Get()
+	err := cli.Table(serviceRecordTableName).Where("caregiverId = ? AND orderId IN (?)", caregiverId, orderIds).Find(&records).Error
+	return records, err
+}
+
+// SetJobAcceptStatusTx 在调用方的事务中记录接单或拒单，记录不存在时创建
+// 已是目标状态时返回false（重复提交）
+func (imp *CaregiverInterfaceImp) SetJobAcceptStatusTx(uow *UnitOfWork, orderId, caregiverId int32, acceptStatus int, declineReason string) (bool, error) {
+	tx := uow.tx
+	now := time.Now()
+	if err := tx.Table(serviceRecordTableName).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.ServiceRecordModel{
+			OrderId:     orderId,
+			CaregiverId: caregiverId,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}).Error; err != nil {
+		return false, err
+	}
+
+	updates := map[string]interface{}{
+		"acceptStatus":  acceptStatus,
+		"declineReason": declineReason,
+		"updatedAt":     now,
+	}
+	if acceptStatus == model.JobAcceptAccepted {
+		updates["acceptedAt"] = &now
+	}
+	result := tx.Table(serviceRecordTableName).
+		Where("orderId = ? AND caregiverId = ? AND acceptStatus <> ?", orderId, caregiverId, acceptStatus).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CheckInTx 在调用方的事务中记录签到，已签到时返回false
+func (imp *CaregiverInterfaceImp) CheckInTx(uow *UnitOfWork, recordId int32, latitude, longitude float64, distance *int) (bool, error) {
+	now := time.Now()
+	result := uow.tx.Table(serviceRecordTableName).
+		Where("id = ? AND acceptStatus = ? AND checkInAt IS NULL", recordId, model.JobAcceptAccepted).
+		Updates(map[string]interface{}{
+			"checkInAt":        &now,
+			"checkInLatitude":  latitude,
+			"checkInLongitude": longitude,
+			"checkInDistance":  distance,
+			"updatedAt":        now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// CheckOutTx 在调用方的事务中记录签退，未签到或已签退时返回false
+func (imp *CaregiverInterfaceImp) CheckOutTx(uow *UnitOfWork, recordId int32, latitude, longitude float64, distance *int) (bool, error) {
+	now := time.Now()
+	result := uow.tx.Table(serviceRecordTableName).
+		Where("id = ? AND checkInAt IS NOT NULL AND checkOutAt IS NULL", recordId).
+		Updates(map[string]interface{}{
+			"checkOutAt":        &now,
+			"checkOutLatitude":  latitude,
+			"checkOutLongitude": longitude,
+			"checkOutDistance":  distance,
+			"updatedAt":         now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// CompleteServiceRecordTx 在调用方的事务中标记服务完成，未签退或已完成时返回false
+func (imp *CaregiverInterfaceImp) CompleteServiceRecordTx(uow *UnitOfWork, recordId int32) (bool, error) {
+	now := time.Now()
+	result := uow.tx.Table(serviceRecordTableName).
+		Where("id = ? AND checkOutAt IS NOT NULL AND completedAt IS NULL", recordId).
+		Updates(map[string]interface{}{
+			"completedAt": &now,
+			"updatedAt":   now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// AppendServiceRecordNotes 在服务记录末尾追加备注和照片文件ID（逗号分隔），参数为空时不改变对应字段
+func (imp *CaregiverInterfaceImp) AppendServiceRecordNotes(recordId int32, notes, photoFileIds string) error {
+	cli := db.Get()
+	return cli.Table(serviceRecordTableName).Where("id = ?", recordId).Updates(map[string]interface{}{
+		"notes":        gorm.Expr("CONCAT(IFNULL(notes, ''), ?)", notes),
+		"photoFileIds": gorm.Expr("CONCAT_WS(',', NULLIF(photoFileIds, ''), NULLIF(?, ''))", photoFileIds),
+		"updatedAt":    time.Now(),
+	}).Error
+}
@@ -0,0 +1,34 @@
+package dao
+
+import (
+	"wxcloudrun-golang/db/model"
+)
+
+// CaregiverInterface 护理员数据接口
+type CaregiverInterface interface {
+	GetCaregivers(status int, keyword string, page, pageSize int) ([]*model.CaregiverModel, int64, error) // status为-1时不限状态
+	GetCaregiverById(id int32) (*model.CaregiverModel, error)
+	GetCaregiversByIds(ids []int32) ([]*model.CaregiverModel, error)
+	SaveCaregiver(caregiver *model.CaregiverModel) error
+	GetAssignedOrdersAt(date, timeSlot string) ([]*model.OrderModel, error)
+	CheckCaregiverAvailableTx(uow *UnitOfWork, caregiverId, orderId int32, date, timeSlot string) error
+
+	// 护理员端
+	GetCaregiverByUserId(userId string) (*model.CaregiverModel, error)
+	GetCaregiverOrders(caregiverId int32, statuses []int, page, pageSize int) ([]*model.OrderModel, int64, error)
+
+	// 服务记录相关
+	GetServiceRecord(orderId, caregiverId int32) (*model.ServiceRecordModel, error)
+	GetServiceRecordsByOrderIds(caregiverId int32, orderIds []int32) ([]*model.ServiceRecordModel, error)
+	SetJobAcceptStatusTx(uow *UnitOfWork, orderId, caregiverId int32, acceptStatus int, declineReason string) (bool, error)
+	CheckInTx(uow *UnitOfWork, recordId int32, latitude, longitude float64, distance *int) (bool, error)
+	CheckOutTx(uow *UnitOfWork, recordId int32, latitude, longitude float64, distance *int) (bool, error)
+	CompleteServiceRecordTx(uow *UnitOfWork, recordId int32) (bool, error)
+	AppendServiceRecordNotes(recordId int32, notes, photoFileIds string) error
+}
+
+// CaregiverInterfaceImp 护理员数据实现
+type CaregiverInterfaceImp struct{}
+
+// CaregiverImp 护理员实现实例
+var CaregiverImp CaregiverInterface = &CaregiverInterfaceImp{}
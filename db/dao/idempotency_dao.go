@@ -0,0 +1,129 @@
+package dao
+
+import (
+	"errors"
+	"time"
+
+	"wxcloudrun-golang/db"
+	"wxcloudrun-golang/db/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const idempotencyKeyTableName = "IdempotencyKeys"
+
+// ErrIdempotencyLeaseLost 处理租约已到期并被其他请求接管
+var ErrIdempotencyLeaseLost = errors.New("幂等键处理租约已被接管")
+
+// AcquireKey 占用幂等键并持有lease时长的处理租约。键未被使用（或已过期）时写入新记录并返回acquired=true；
+// 相同请求的键仍在处理中但租约已到期（首次处理的请求已中断）时接管该键，同样返回acquired=true；
+// 否则返回已有记录，由调用方根据状态决定重放响应还是提示处理中
+func (imp *IdempotencyInterfaceImp) AcquireKey(record *model.IdempotencyKeyModel, lease time.Duration) (*model.IdempotencyKeyModel, bool, error) {
+	cli := db.Get()
+	now := time.Now()
+	// 数据库按秒存储，截断后才能在完成和释放时按租约值校验持有者
+	lockedUntil := now.Add(lease).Truncate(time.Second)
+	acquired := false
+	existing := new(model.IdempotencyKeyModel)
+
+	err := cli.Transaction(func(tx *gorm.DB) error {
+		// 过期的键视为未使用，先清除
+		if err := tx.Table(idempotencyKeyTableName).
+			Where("userId = ? AND scope = ? AND idemKey = ? AND expiresAt < ?", record.UserId, record.Scope, record.IdemKey, now).
+			Delete(&model.IdempotencyKeyModel{}).Error; err != nil {
+			return err
+		}
+
+		// 租约到期的处理中键由相同请求接管，条件更新保证只有一个请求接管成功
+		result := tx.Table(idempotencyKeyTableName).
+			Where("userId = ? AND scope = ? AND idemKey = ? AND requestHash = ? AND status = ? AND (lockedUntil IS NULL OR lockedUntil < ?)",
+				record.UserId, record.Scope, record.IdemKey, record.RequestHash, model.IdempotencyStatusProcessing, now).
+			Updates(map[string]interface{}{
+				"lockedUntil": lockedUntil,
+				"updatedAt":   now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			acquired = true
+			return tx.Table(idempotencyKeyTableName).
+				Where("userId = ? AND scope = ? AND idemKey = ?", record.UserId, record.Scope, record.IdemKey).
+				First(record).Error
+		}
+
+		record.Status = model.IdempotencyStatusProcessing
+		record.LockedUntil = &lockedUntil
+		record.CreatedAt = now
+		record.UpdatedAt = now
+		result = tx.Table(idempotencyKeyTableName).Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			acquired = true
+			return nil
+		}
+		return tx.Table(idempotencyKeyTableName).
+			Where("userId = ? AND scope = ? AND idemKey = ?", record.UserId, record.Scope, record.IdemKey).
+			First(existing).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if acquired {
+		return record, true, nil
+	}
+	return existing, false, nil
+}
+
+// CompleteKey 保存响应快照，之后使用相同键的请求直接返回该响应。
+// 租约已被其他请求接管时不保存，返回ErrIdempotencyLeaseLost
+func (imp *IdempotencyInterfaceImp) CompleteKey(record *model.IdempotencyKeyModel, responseStatus int, responseBody string) error {
+	cli := db.Get()
+	result := whereIdempotencyLeaseHolder(cli.Table(idempotencyKeyTableName), record).Updates(map[string]interface{}{
+		"status":         model.IdempotencyStatusCompleted,
+		"responseStatus": responseStatus,
+		"responseBody":   responseBody,
+		"lockedUntil":    nil,
+		"updatedAt":      time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdempotencyLeaseLost
+	}
+	return nil
+}
+
+// ReleaseKey 释放幂等键（处理失败时），允许客户端使用相同的键重试。
+// 租约已被其他请求接管时不删除，返回ErrIdempotencyLeaseLost
+func (imp *IdempotencyInterfaceImp) ReleaseKey(record *model.IdempotencyKeyModel) error {
+	cli := db.Get()
+	result := whereIdempotencyLeaseHolder(cli.Table(idempotencyKeyTableName), record).Delete(&model.IdempotencyKeyModel{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdempotencyLeaseLost
+	}
+	return nil
+}
+
+// whereIdempotencyLeaseHolder 限定为仍由record持有租约的处理中键
+func whereIdempotencyLeaseHolder(tx *gorm.DB, record *model.IdempotencyKeyModel) *gorm.DB {
+	tx = tx.Where("id = ? AND status = ?", record.Id, model.IdempotencyStatusProcessing)
+	if record.LockedUntil == nil {
+		return tx.Where("lockedUntil IS NULL")
+	}
+	return tx.Where("lockedUntil = ?", *record.LockedUntil)
+}
+
+// DeleteExpiredKeys 删除过期的幂等键，每次最多删除limit条
+func (imp *IdempotencyInterfaceImp) DeleteExpiredKeys(limit int) (int64, error) {
+	cli := db.Get()
+	result := cli.Table(idempotencyKeyTableName).Where("expiresAt < ?", time.Now()).Limit(limit).Delete(&model.IdempotencyKeyModel{})
+	return result.RowsAffected, result.Error
+}
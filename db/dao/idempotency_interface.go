@@ -0,0 +1,21 @@
+package dao
+
+import (
+	"time"
+
+	"wxcloudrun-golang/db/model"
+)
+
+// IdempotencyInterface 幂等键数据接口
+type IdempotencyInterface interface {
+	AcquireKey(record *model.IdempotencyKeyModel, lease time.Duration) (*model.IdempotencyKeyModel, bool, error)
+	CompleteKey(record *model.IdempotencyKeyModel, responseStatus int, responseBody string) error
+	ReleaseKey(record *model.IdempotencyKeyModel) error
+	DeleteExpiredKeys(limit int) (int64, error)
+}
+
+// IdempotencyInterfaceImp 幂等键数据实现
+type IdempotencyInterfaceImp struct{}
+
+// IdempotencyImp 幂等键实现实例
+var IdempotencyImp IdempotencyInterface = &IdempotencyInterfaceImp{}
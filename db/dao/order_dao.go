@@ -1,19 +1,42 @@
 package dao
 
 import (
+	"fmt"
 	"time"
 	"wxcloudrun-golang/db"
 	"wxcloudrun-golang/db/model"
+
+	"gorm.io/gorm"
 )
 
 const orderTableName = "Orders"
+const orderStatusLogTableName = "OrderStatusLogs"
+const orderRescheduleTableName = "OrderReschedules"
 
-// CreateOrder 创建订单
-func (imp *OrderInterfaceImp) CreateOrder(order *model.OrderModel) error {
+// CreateOrder 创建订单，同时占用预约时间段名额并写入创建记录
+// slotCapacity 为时间段库存记录不存在时的初始容量，时间段已约满时返回ErrTimeSlotFull
+func (imp *OrderInterfaceImp) CreateOrder(order *model.OrderModel, slotCapacity int) error {
 	cli := db.Get()
 	order.CreatedAt = time.Now()
 	order.UpdatedAt = time.Now()
-	return cli.Table(orderTableName).Create(order).Error
+	return cli.Transaction(func(tx *gorm.DB) error {
+		if order.AppointmentDate != "" && order.AppointmentTime != "" {
+			if err := reserveTimeSlot(tx, order.ServiceId, order.AppointmentDate, order.AppointmentTime, slotCapacity); err != nil {
+				return err
+			}
+		}
+		if err := tx.Table(orderTableName).Create(order).Error; err != nil {
+			return err
+		}
+		return createOrderStatusLog(tx, &model.OrderStatusLogModel{
+			OrderId:   order.Id,
+			OrderNo:   order.OrderNo,
+			Event:     "create",
+			ToStatus:  order.Status,
+			ActorType: "user",
+			ActorId:   order.UserId,
+		})
+	})
 }
 
 // GetOrderById 根据ID获取订单
@@ -63,46 +86,107 @@ func (imp *OrderInterfaceImp) UpdateOrder(order *model.OrderModel) error {
 	return cli.Table(orderTableName).Where("id = ?", order.Id).Updates(order).Error
 }
 
-// UpdateOrderStatus 更新订单状态
-func (imp *OrderInterfaceImp) UpdateOrderStatus(id int32, status int) error {
-	cli := db.Get()
-	return cli.Table(orderTableName).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":    status,
-		"updatedAt": time.Now(),
-	}).Error
+// TransitionOrderTx 在调用方的事务中变更订单状态并写入变更记录，仅当订单仍处于fromStatus/fromRefundStatus时成功（防止并发变更）
+// 订单状态已被其他请求变更时返回false且不做任何写入；releaseSlot 为true时同时释放订单占用的预约时间段名额（取消、超时、退款）
+func (imp *OrderInterfaceImp) TransitionOrderTx(uow *UnitOfWork, id int32, fromStatus, fromRefundStatus int, updates map[string]interface{}, statusLog *model.OrderStatusLogModel, releaseSlot bool) (bool, error) {
+	tx := uow.tx
+	updates["updatedAt"] = time.Now()
+	result := tx.Table(orderTableName).
+		Where("id = ? AND status = ? AND refundStatus = ?", id, fromStatus, fromRefundStatus).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// MySQL的影响行数只统计值实际变化的行，更新内容与原值相同（如updatedAt在同一秒内）时也为0，
+		// 需重新确认订单是否仍处于原状态，只有状态已被其他请求变更时才视为并发冲突
+		var matched int64
+		if err := tx.Table(orderTableName).
+			Where("id = ? AND status = ? AND refundStatus = ?", id, fromStatus, fromRefundStatus).
+			Count(&matched).Error; err != nil {
+			return false, err
+		}
+		if matched == 0 {
+			return false, nil
+		}
+	}
+	if releaseSlot {
+		order := new(model.OrderModel)
+		if err := tx.Table(orderTableName).Where("id = ?", id).First(order).Error; err != nil {
+			return false, err
+		}
+		if err := releaseTimeSlot(tx, order.ServiceId, order.AppointmentDate, order.AppointmentTime); err != nil {
+			return false, err
+		}
+	}
+	if err := createOrderStatusLog(tx, statusLog); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-// UpdatePayStatus 更新支付状态
-func (imp *OrderInterfaceImp) UpdatePayStatus(id int32, payStatus int, payTime *time.Time, transactionId string) error {
+// GetOrderStatusLogs 获取订单的状态变更记录（按时间顺序）
+func (imp *OrderInterfaceImp) GetOrderStatusLogs(orderId int32) ([]*model.OrderStatusLogModel, error) {
+	var logs []*model.OrderStatusLogModel
 	cli := db.Get()
-	updates := map[string]interface{}{
-		"payStatus":     payStatus,
-		"updatedAt":     time.Now(),
-		"transactionId": transactionId,
+	err := cli.Table(orderStatusLogTableName).Where("orderId = ?", orderId).Order("id ASC").Find(&logs).Error
+	return logs, err
+}
+
+// RescheduleOrderTx 在调用方的事务中将订单改约到history中的新日期和时间段，并写入改约记录
+// 仅当订单仍是history中的原预约时间、且改约次数小于maxCount（为0时不限制）时成功，否则返回false且不做任何写入
+// 成功时占用新时间段名额并释放原时间段名额，新时间段已约满时返回ErrTimeSlotFull
+func (imp *OrderInterfaceImp) RescheduleOrderTx(uow *UnitOfWork, order *model.OrderModel, history *model.OrderRescheduleModel, slotCapacity, maxCount int) (bool, error) {
+	tx := uow.tx
+	query := tx.Table(orderTableName).
+		Where("id = ? AND appointmentDate = ? AND appointmentTime = ?", order.Id, history.FromDate, history.FromTime)
+	if maxCount > 0 {
+		query = query.Where("rescheduleCount < ?", maxCount)
 	}
-	if payTime != nil {
-		updates["payTime"] = payTime
+	result := query.Updates(map[string]interface{}{
+		"appointmentDate": history.ToDate,
+		"appointmentTime": history.ToTime,
+		"rescheduleCount": gorm.Expr("rescheduleCount + 1"),
+		"updatedAt":       time.Now(),
+	})
+	if result.Error != nil {
+		return false, result.Error
 	}
-	return cli.Table(orderTableName).Where("id = ?", id).Updates(updates).Error
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	if err := reserveTimeSlot(tx, order.ServiceId, history.ToDate, history.ToTime, slotCapacity); err != nil {
+		return false, err
+	}
+	if err := releaseTimeSlot(tx, order.ServiceId, history.FromDate, history.FromTime); err != nil {
+		return false, err
+	}
+	history.CreatedAt = time.Now()
+	if err := tx.Table(orderRescheduleTableName).Create(history).Error; err != nil {
+		return false, fmt.Errorf("写入改约记录失败: %v", err)
+	}
+	return true, nil
 }
 
-// UpdateRefundStatus 更新退款状态
-func (imp *OrderInterfaceImp) UpdateRefundStatus(id int32, refundStatus int, refundAmount float64, refundReason string) error {
+// GetOrderReschedules 获取订单的改约记录（按时间顺序）
+func (imp *OrderInterfaceImp) GetOrderReschedules(orderId int32) ([]*model.OrderRescheduleModel, error) {
+	var reschedules []*model.OrderRescheduleModel
 	cli := db.Get()
-	updates := map[string]interface{}{
-		"refundStatus": refundStatus,
-		"refundAmount": refundAmount,
-		"refundReason": refundReason,
-		"updatedAt":    time.Now(),
-	}
-	if refundStatus == 2 { // 已退款
-		updates["refundTime"] = time.Now()
+	err := cli.Table(orderRescheduleTableName).Where("orderId = ?", orderId).Order("id ASC").Find(&reschedules).Error
+	return reschedules, err
+}
+
+// createOrderStatusLog 在事务中写入订单状态变更记录
+func createOrderStatusLog(tx *gorm.DB, statusLog *model.OrderStatusLogModel) error {
+	statusLog.CreatedAt = time.Now()
+	if err := tx.Table(orderStatusLogTableName).Create(statusLog).Error; err != nil {
+		return fmt.Errorf("写入订单状态记录失败: %v", err)
 	}
-	return cli.Table(orderTableName).Where("id = ?", id).Updates(updates).Error
+	return nil
 }
 
 // UpdateOrderAmount 更新订单金额
-func (imp *OrderInterfaceImp) UpdateOrderAmount(id int32, newAmount float64) error {
+func (imp *OrderInterfaceImp) UpdateOrderAmount(id int32, newAmount model.Money) error {
 	cli := db.Get()
 	updates := map[string]interface{}{
 		"totalAmount": newAmount,
@@ -124,19 +208,26 @@ func (imp *OrderInterfaceImp) GetExpiredOrders() ([]*model.OrderModel, error) {
 	return orders, err
 }
 
-// BatchCancelExpiredOrders 批量取消超时订单
-func (imp *OrderInterfaceImp) BatchCancelExpiredOrders() (int64, error) {
+// GetRefundingOrders 获取已向支付渠道申请退款、尚未确认退款结果的订单
+func (imp *OrderInterfaceImp) GetRefundingOrders() ([]*model.OrderModel, error) {
+	var orders []*model.OrderModel
 	cli := db.Get()
-	now := time.Now()
 
-	result := cli.Table(orderTableName).
-		Where("status = ? AND payStatus = ? AND payDeadline < ?", 0, 0, now).
-		Updates(map[string]interface{}{
-			"status":    3, // 已取消
-			"updatedAt": now,
-		})
+	err := cli.Table(orderTableName).
+		Where("status IN (?) AND refundStatus = ? AND refundNo <> ''", []int{model.OrderStatusPaid, model.OrderStatusAssigned}, model.RefundStatusRefunding).
+		Find(&orders).Error
+
+	return orders, err
+}
 
-	return result.RowsAffected, result.Error
+// UpdateRefundingOrder 更新退款中订单的退款信息（退款单号、金额、原因），订单已不在退款中时返回false
+func (imp *OrderInterfaceImp) UpdateRefundingOrder(id int32, updates map[string]interface{}) (bool, error) {
+	cli := db.Get()
+	updates["updatedAt"] = time.Now()
+	result := cli.Table(orderTableName).
+		Where("id = ? AND refundStatus = ?", id, model.RefundStatusRefunding).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
 }
 
 // GetOrdersByStatus 根据状态获取订单列表
@@ -1,23 +1,24 @@
 package dao
 
 import (
-	"time"
 	"wxcloudrun-golang/db/model"
 )
 
 // OrderInterface 订单数据接口
 type OrderInterface interface {
-	CreateOrder(order *model.OrderModel) error
+	CreateOrder(order *model.OrderModel, slotCapacity int) error
 	GetOrderById(id int32) (*model.OrderModel, error)
 	GetOrderByOrderNo(orderNo string) (*model.OrderModel, error)
 	GetOrdersByUserId(userId string, page, pageSize int) ([]*model.OrderModel, int64, error)
 	UpdateOrder(order *model.OrderModel) error
-	UpdateOrderStatus(id int32, status int) error
-	UpdatePayStatus(id int32, payStatus int, payTime *time.Time, transactionId string) error
-	UpdateRefundStatus(id int32, refundStatus int, refundAmount float64, refundReason string) error
-	UpdateOrderAmount(id int32, newAmount float64) error
+	TransitionOrderTx(uow *UnitOfWork, id int32, fromStatus, fromRefundStatus int, updates map[string]interface{}, statusLog *model.OrderStatusLogModel, releaseSlot bool) (bool, error)
+	GetOrderStatusLogs(orderId int32) ([]*model.OrderStatusLogModel, error)
+	RescheduleOrderTx(uow *UnitOfWork, order *model.OrderModel, history *model.OrderRescheduleModel, slotCapacity, maxCount int) (bool, error)
+	GetOrderReschedules(orderId int32) ([]*model.OrderRescheduleModel, error)
+	UpdateOrderAmount(id int32, newAmount model.Money) error
 	GetExpiredOrders() ([]*model.OrderModel, error)
-	BatchCancelExpiredOrders() (int64, error)
+	GetRefundingOrders() ([]*model.OrderModel, error)
+	UpdateRefundingOrder(id int32, updates map[string]interface{}) (bool, error)
 	GetOrdersByStatus(status int, page, pageSize int) ([]*model.OrderModel, int64, error)
 	GetOrdersByStatusAndUserId(status int, userId string, page, pageSize int) ([]*model.OrderModel, int64, error)
 }
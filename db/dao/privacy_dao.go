@@ -0,0 +1,321 @@
+package dao
+
+import (
+	"fmt"
+	"time"
+
+	"wxcloudrun-golang/db"
+	"wxcloudrun-golang/db/model"
+
+	"gorm.io/gorm"
+)
+
+const accountDeletionTableName = "AccountDeletionRequests"
+
+// 注销后替换个人信息使用的占位内容
+const (
+	deactivatedNickName = "已注销用户"
+	deactivatedContent  = "[已删除]"
+)
+
+// GetUserDataExport 获取用户的全部个人数据（包括已删除状态的记录）
+func (imp *PrivacyInterfaceImp) GetUserDataExport(userId string) (*UserDataExport, error) {
+	cli := db.Get()
+	export := &UserDataExport{}
+
+	profile := new(model.UserModel)
+	if err := cli.Table(userTableName).Where("userId = ?", userId).First(profile).Error; err != nil {
+		return nil, err
+	}
+	export.Profile = profile
+
+	queries := []struct {
+		table string
+		dest  interface{}
+	}{
+		{userIdentityTableName, &export.Identities},
+		{patientTableName, &export.Patients},
+		{addressTableName, &export.Addresses},
+		{orderTableName, &export.Orders},
+		{kefuMessageTableName, &export.KefuMessages},
+		{fileTableName, &export.Files},
+		{commissionTableName, &export.Commissions},
+		{cashoutTableName, &export.Cashouts},
+		{accountDeletionTableName, &export.Deletions},
+	}
+	for _, q := range queries {
+		if err := cli.Table(q.table).Where("userId = ?", userId).Order("id ASC").Find(q.dest).Error; err != nil {
+			return nil, fmt.Errorf("查询%s失败: %v", q.table, err)
+		}
+	}
+
+	var referrals []*model.ReferralModel
+	if err := cli.Table(referralTableName).Where("userId = ?", userId).Limit(1).Find(&referrals).Error; err != nil {
+		return nil, fmt.Errorf("查询%s失败: %v", referralTableName, err)
+	}
+	if len(referrals) > 0 {
+		export.Referral = referrals[0]
+	}
+
+	// 在线咨询及消息
+	if err := cli.Table(consultationTableName).Where("user_id = ?", userId).Order("id ASC").Find(&export.Consultations).Error; err != nil {
+		return nil, fmt.Errorf("查询%s失败: %v", consultationTableName, err)
+	}
+	for i := range export.Consultations {
+		var messages []model.ConsultationMessage
+		if err := cli.Table(consultationMessageTableName).
+			Where("consultation_id = ?", export.Consultations[i].ID).
+			Order("created_at ASC").
+			Find(&messages).Error; err != nil {
+			return nil, fmt.Errorf("查询%s失败: %v", consultationMessageTableName, err)
+		}
+		export.Consultations[i].Messages = messages
+	}
+
+	return export, nil
+}
+
+// CountUnfinishedBusiness 统计用户未完结的订单、退款和提现，存在时不能注销
+func (imp *PrivacyInterfaceImp) CountUnfinishedBusiness(userId string) (map[string]int64, error) {
+	cli := db.Get()
+	counts := make(map[string]int64)
+
+	var paidOrders, refundingOrders, pendingCashouts int64
+	if err := cli.Table(orderTableName).Where("userId = ? AND status = ?", userId, 1).Count(&paidOrders).Error; err != nil {
+		return nil, err
+	}
+	if err := cli.Table(orderTableName).Where("userId = ? AND refundStatus = ?", userId, 1).Count(&refundingOrders).Error; err != nil {
+		return nil, err
+	}
+	if err := cli.Table(cashoutTableName).Where("userId = ? AND status IN ?", userId, []int{0, 1}).Count(&pendingCashouts).Error; err != nil {
+		return nil, err
+	}
+	counts["paidOrders"] = paidOrders
+	counts["refundingOrders"] = refundingOrders
+	counts["pendingCashouts"] = pendingCashouts
+	return counts, nil
+}
+
+// CreateDeletionRequest 创建注销申请
+func (imp *PrivacyInterfaceImp) CreateDeletionRequest(request *model.AccountDeletionRequestModel) error {
+	cli := db.Get()
+	request.CreatedAt = time.Now()
+	request.UpdatedAt = time.Now()
+	return cli.Table(accountDeletionTableName).Create(request).Error
+}
+
+// GetPendingDeletionRequest 获取用户待处理的注销申请，没有时返回nil
+func (imp *PrivacyInterfaceImp) GetPendingDeletionRequest(userId string) (*model.AccountDeletionRequestModel, error) {
+	var requests []*model.AccountDeletionRequestModel
+	cli := db.Get()
+	err := cli.Table(accountDeletionTableName).
+		Where("userId = ? AND status = ?", userId, model.AccountDeletionStatusPending).
+		Order("id DESC").Limit(1).Find(&requests).Error
+	if err != nil || len(requests) == 0 {
+		return nil, err
+	}
+	return requests[0], nil
+}
+
+// GetDeletionRequestById 根据ID获取注销申请
+func (imp *PrivacyInterfaceImp) GetDeletionRequestById(id int32) (*model.AccountDeletionRequestModel, error) {
+	var request = new(model.AccountDeletionRequestModel)
+	cli := db.Get()
+	err := cli.Table(accountDeletionTableName).Where("id = ?", id).First(request).Error
+	return request, err
+}
+
+// GetDeletionRequests 按状态分页获取注销申请，按冷静期截止时间排序
+func (imp *PrivacyInterfaceImp) GetDeletionRequests(status int, page, pageSize int) ([]*model.AccountDeletionRequestModel, int64, error) {
+	var requests []*model.AccountDeletionRequestModel
+	var total int64
+	cli := db.Get()
+
+	if err := cli.Table(accountDeletionTableName).Where("status = ?", status).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	err := cli.Table(accountDeletionTableName).
+		Where("status = ?", status).
+		Order("coolingOffUntil ASC, id ASC").
+		Offset(offset).Limit(pageSize).
+		Find(&requests).Error
+	return requests, total, err
+}
+
+// CancelDeletionRequest 用户撤销注销申请，仅待处理的申请可以撤销
+func (imp *PrivacyInterfaceImp) CancelDeletionRequest(id int32) (bool, error) {
+	cli := db.Get()
+	now := time.Now()
+	result := cli.Table(accountDeletionTableName).
+		Where("id = ? AND status = ?", id, model.AccountDeletionStatusPending).
+		Updates(map[string]interface{}{
+			"status":      model.AccountDeletionStatusCancelled,
+			"cancelledAt": now,
+			"updatedAt":   now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RejectDeletionRequest 驳回注销申请
+func (imp *PrivacyInterfaceImp) RejectDeletionRequest(id int32, reviewerId, remark string) (bool, error) {
+	cli := db.Get()
+	now := time.Now()
+	result := cli.Table(accountDeletionTableName).
+		Where("id = ? AND status = ?", id, model.AccountDeletionStatusPending).
+		Updates(map[string]interface{}{
+			"status":       model.AccountDeletionStatusRejected,
+			"reviewerId":   reviewerId,
+			"reviewRemark": remark,
+			"reviewedAt":   now,
+			"updatedAt":    now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// AnonymizeUser 审核通过注销申请并匿名化用户个人信息，全部在一个事务中完成。
+// 订单金额、支付流水、退款、佣金和提现记录因财务核算需要保留，只清除其中的病情和表单信息。
+// 返回用户上传的文件，由调用方删除对象存储中的文件
+func (imp *PrivacyInterfaceImp) AnonymizeUser(request *model.AccountDeletionRequestModel, reviewerId, remark string) ([]*model.FileModel, error) {
+	cli := db.Get()
+	now := time.Now()
+	userId := request.UserId
+	var files []*model.FileModel
+
+	err := cli.Transaction(func(tx *gorm.DB) error {
+		result := tx.Table(accountDeletionTableName).
+			Where("id = ? AND status = ?", request.Id, model.AccountDeletionStatusPending).
+			Updates(map[string]interface{}{
+				"status":       model.AccountDeletionStatusCompleted,
+				"reviewerId":   reviewerId,
+				"reviewRemark": remark,
+				"reviewedAt":   now,
+				"completedAt":  now,
+				"updatedAt":    now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("更新注销申请失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("注销申请不是待审核状态: %d", request.Id)
+		}
+
+		user := new(model.UserModel)
+		if err := tx.Table(userTableName).Where("userId = ?", userId).First(user).Error; err != nil {
+			return fmt.Errorf("查询用户失败: %v", err)
+		}
+
+		if err := tx.Table(fileTableName).Where("userId = ? AND status = ?", userId, 1).Find(&files).Error; err != nil {
+			return fmt.Errorf("查询用户文件失败: %v", err)
+		}
+
+		// openId必须唯一且非空，替换为占位值，原openId之后再登录会注册为新用户
+		if err := tx.Table(userTableName).Where("userId = ?", userId).Updates(map[string]interface{}{
+			"openId":        "deactivated_" + userId,
+			"unionId":       "",
+			"nickName":      deactivatedNickName,
+			"avatarUrl":     "",
+			"gender":        0,
+			"phone":         "",
+			"country":       "",
+			"province":      "",
+			"city":          "",
+			"language":      "",
+			"sessionKey":    "",
+			"deactivatedAt": now,
+			"updatedAt":     now,
+		}).Error; err != nil {
+			return fmt.Errorf("匿名化用户失败: %v", err)
+		}
+
+		// 未支付的订单直接取消，释放预约时间段并记录状态变更
+		var pendingOrders []*model.OrderModel
+		if err := tx.Table(orderTableName).Where("userId = ? AND status = ?", userId, model.OrderStatusPending).
+			Find(&pendingOrders).Error; err != nil {
+			return fmt.Errorf("查询待支付订单失败: %v", err)
+		}
+		for _, order := range pendingOrders {
+			if err := tx.Table(orderTableName).Where("id = ?", order.Id).Updates(map[string]interface{}{
+				"status": model.OrderStatusCancelled, "updatedAt": now,
+			}).Error; err != nil {
+				return fmt.Errorf("取消待支付订单失败: %v", err)
+			}
+			if err := releaseTimeSlot(tx, order.ServiceId, order.AppointmentDate, order.AppointmentTime); err != nil {
+				return fmt.Errorf("释放预约时间段失败: %v", err)
+			}
+			fromStatus := order.Status
+			if err := createOrderStatusLog(tx, &model.OrderStatusLogModel{
+				OrderId:      order.Id,
+				OrderNo:      order.OrderNo,
+				Event:        "cancel",
+				FromStatus:   &fromStatus,
+				ToStatus:     model.OrderStatusCancelled,
+				RefundStatus: order.RefundStatus,
+				ActorType:    "system",
+				Reason:       "账号注销",
+			}); err != nil {
+				return err
+			}
+		}
+
+		steps := []struct {
+			table   string
+			where   string
+			updates map[string]interface{}
+		}{
+			{patientTableName, "userId = ?", map[string]interface{}{
+				"name": deactivatedNickName, "idCard": "", "phone": "", "birthday": "", "relation": "", "status": 0, "updatedAt": now,
+			}},
+			{addressTableName, "userId = ?", map[string]interface{}{
+				"name": deactivatedNickName, "phone": "", "province": "", "city": "", "district": "", "address": deactivatedContent, "status": 0, "updatedAt": now,
+			}},
+			{orderTableName, "userId = ?", map[string]interface{}{
+				"diseaseInfo": "", "formData": "", "updatedAt": now,
+			}},
+			{kefuMessageTableName, "userId = ?", map[string]interface{}{
+				"userName": deactivatedNickName, "userAvatar": "", "content": deactivatedContent, "images": "", "updatedAt": now,
+			}},
+			{fileTableName, "userId = ?", map[string]interface{}{
+				"status": 0, "updatedAt": now,
+			}},
+			{referralTableName, "userId = ?", map[string]interface{}{
+				"status": 0, "updatedAt": now,
+			}},
+			{authSessionTableName, "userId = ? AND status = 1", map[string]interface{}{
+				"status": 0, "revokedAt": now, "revokeReason": "账号注销", "updatedAt": now,
+			}},
+			{consultationTableName, "user_id = ?", map[string]interface{}{
+				"user_name": deactivatedNickName, "user_phone": "", "status": "closed", "updated_at": now,
+			}},
+		}
+		for _, step := range steps {
+			if err := tx.Table(step.table).Where(step.where, userId).Updates(step.updates).Error; err != nil {
+				return fmt.Errorf("匿名化%s失败: %v", step.table, err)
+			}
+		}
+
+		consultationIds := tx.Table(consultationTableName).Select("id").Where("user_id = ?", userId)
+		if err := tx.Table(consultationMessageTableName).Where("consultation_id IN (?)", consultationIds).
+			Update("content", deactivatedContent).Error; err != nil {
+			return fmt.Errorf("匿名化%s失败: %v", consultationMessageTableName, err)
+		}
+		if err := tx.Table(consultationNotificationTableName).Where("consultation_id IN (?)", consultationIds).
+			Update("content", "").Error; err != nil {
+			return fmt.Errorf("匿名化%s失败: %v", consultationNotificationTableName, err)
+		}
+
+		if err := tx.Table(userIdentityTableName).Where("userId = ?", userId).Delete(&model.UserIdentityModel{}).Error; err != nil {
+			return fmt.Errorf("删除用户身份失败: %v", err)
+		}
+		if user.Phone != "" {
+			if err := tx.Table(smsCodeTableName).Where("phone = ?", user.Phone).Delete(&model.SmsCodeModel{}).Error; err != nil {
+				return fmt.Errorf("删除短信验证码失败: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,40 @@
+package dao
+
+import (
+	"wxcloudrun-golang/db/model"
+)
+
+// UserDataExport 用户个人数据导出内容
+type UserDataExport struct {
+	Profile       *model.UserModel                     `json:"profile"`
+	Identities    []*model.UserIdentityModel           `json:"identities"`
+	Patients      []*model.PatientModel                `json:"patients"`
+	Addresses     []*model.UserAddressModel            `json:"addresses"`
+	Orders        []*model.OrderModel                  `json:"orders"`
+	Consultations []model.Consultation                 `json:"consultations"`
+	KefuMessages  []*model.KefuMessageModel            `json:"kefuMessages"`
+	Files         []*model.FileModel                   `json:"files"`
+	Referral      *model.ReferralModel                 `json:"referral"`
+	Commissions   []*model.CommissionModel             `json:"commissions"`
+	Cashouts      []*model.CashoutModel                `json:"cashouts"`
+	Deletions     []*model.AccountDeletionRequestModel `json:"accountDeletionRequests"`
+}
+
+// PrivacyInterface 个人数据导出与账号注销数据接口
+type PrivacyInterface interface {
+	GetUserDataExport(userId string) (*UserDataExport, error)
+	CountUnfinishedBusiness(userId string) (map[string]int64, error)
+	CreateDeletionRequest(request *model.AccountDeletionRequestModel) error
+	GetPendingDeletionRequest(userId string) (*model.AccountDeletionRequestModel, error)
+	GetDeletionRequestById(id int32) (*model.AccountDeletionRequestModel, error)
+	GetDeletionRequests(status int, page, pageSize int) ([]*model.AccountDeletionRequestModel, int64, error)
+	CancelDeletionRequest(id int32) (bool, error)
+	RejectDeletionRequest(id int32, reviewerId, remark string) (bool, error)
+	AnonymizeUser(request *model.AccountDeletionRequestModel, reviewerId, remark string) ([]*model.FileModel, error)
+}
+
+// PrivacyInterfaceImp 个人数据导出与账号注销数据实现
+type PrivacyInterfaceImp struct{}
+
+// PrivacyImp 个人数据导出与账号注销实现实例
+var PrivacyImp PrivacyInterface = &PrivacyInterfaceImp{}
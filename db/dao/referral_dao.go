@@ -77,6 +77,24 @@ func (imp *ReferralInterfaceImp) CreateCommission(commission *model.CommissionMo
 	return cli.Table(commissionTableName).Create(commission).Error
 }
 
+// CreateCommissionTx 在调用方的事务中创建佣金记录
+func (imp *ReferralInterfaceImp) CreateCommissionTx(uow *UnitOfWork, commission *model.CommissionModel) error {
+	commission.CreatedAt = time.Now()
+	commission.UpdatedAt = time.Now()
+	return uow.tx.Table(commissionTableName).Create(commission).Error
+}
+
+// CancelOrderCommissionsTx 在调用方的事务中将订单待结算的佣金置为已取消，已结算或已提现的佣金不变
+func (imp *ReferralInterfaceImp) CancelOrderCommissionsTx(uow *UnitOfWork, orderId int32) (int64, error) {
+	result := uow.tx.Table(commissionTableName).
+		Where("orderId = ? AND status = ?", orderId, model.CommissionStatusPending).
+		Updates(map[string]interface{}{
+			"status":    model.CommissionStatusCancelled,
+			"updatedAt": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
 // GetCommissionsByUserId 根据用户ID获取佣金记录（分页）
 func (imp *ReferralInterfaceImp) GetCommissionsByUserId(userId string, page, pageSize int) ([]*model.CommissionModel, int64, error) {
 	var commissions []*model.CommissionModel
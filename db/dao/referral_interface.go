@@ -14,6 +14,8 @@ type ReferralInterface interface {
 
 	// 佣金相关
 	CreateCommission(commission *model.CommissionModel) error
+	CreateCommissionTx(uow *UnitOfWork, commission *model.CommissionModel) error
+	CancelOrderCommissionsTx(uow *UnitOfWork, orderId int32) (int64, error) // 作废订单未结算的佣金
 	GetCommissionsByUserId(userId string, page, pageSize int) ([]*model.CommissionModel, int64, error)
 	UpdateCommissionStatus(id int32, status int) error
 
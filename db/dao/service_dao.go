@@ -4,9 +4,12 @@ import (
 	"time"
 	"wxcloudrun-golang/db"
 	"wxcloudrun-golang/db/model"
+
+	"gorm.io/gorm"
 )
 
 const serviceTableName = "ServiceItems"
+const serviceFormConfigTableName = "ServiceFormConfigs"
 
 // GetServiceById 根据ID获取服务
 func (imp *ServiceInterfaceImp) GetServiceById(id int32) (*model.ServiceItemModel, error) {
@@ -95,3 +98,44 @@ func (imp *ServiceInterfaceImp) DeleteService(id int32) error {
 	cli := db.Get()
 	return cli.Table(serviceTableName).Where("id = ?", id).Update("status", 0).Error
 }
+
+// PublishFormConfig 发布服务的新表单配置：版本号加1并保存历史版本，返回新的版本号
+func (imp *ServiceInterfaceImp) PublishFormConfig(serviceId int32, formConfig, createdBy string) (int, error) {
+	cli := db.Get()
+	version := 0
+	err := cli.Transaction(func(tx *gorm.DB) error {
+		// 条件更新会锁住该行，并发发布时版本号依次递增
+		result := tx.Table(serviceTableName).Where("id = ?", serviceId).Updates(map[string]interface{}{
+			"formConfig":  formConfig,
+			"formVersion": gorm.Expr("formVersion + 1"),
+			"updatedAt":   time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		service := new(model.ServiceItemModel)
+		if err := tx.Table(serviceTableName).Select("formVersion").Where("id = ?", serviceId).First(service).Error; err != nil {
+			return err
+		}
+		version = service.FormVersion
+		return tx.Table(serviceFormConfigTableName).Create(&model.ServiceFormConfigModel{
+			ServiceId:  serviceId,
+			Version:    version,
+			FormConfig: formConfig,
+			CreatedBy:  createdBy,
+			CreatedAt:  time.Now(),
+		}).Error
+	})
+	return version, err
+}
+
+// GetFormConfigVersions 获取服务表单配置的历史版本（新版本在前）
+func (imp *ServiceInterfaceImp) GetFormConfigVersions(serviceId int32) ([]*model.ServiceFormConfigModel, error) {
+	var versions []*model.ServiceFormConfigModel
+	cli := db.Get()
+	err := cli.Table(serviceFormConfigTableName).Where("serviceId = ?", serviceId).Order("version DESC").Find(&versions).Error
+	return versions, err
+}
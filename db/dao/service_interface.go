@@ -13,6 +13,8 @@ type ServiceInterface interface {
 	CreateService(service *model.ServiceItemModel) error
 	UpdateService(service *model.ServiceItemModel) error
 	DeleteService(id int32) error
+	PublishFormConfig(serviceId int32, formConfig, createdBy string) (int, error)
+	GetFormConfigVersions(serviceId int32) ([]*model.ServiceFormConfigModel, error)
 }
 
 // ServiceInterfaceImp 服务数据实现
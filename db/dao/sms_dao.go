@@ -0,0 +1,78 @@
+package dao
+
+import (
+	"time"
+
+	"wxcloudrun-golang/db"
+	"wxcloudrun-golang/db/model"
+
+	"gorm.io/gorm"
+)
+
+const smsCodeTableName = "SmsCodes"
+
+// CreateCode 保存验证码
+func (imp *SmsInterfaceImp) CreateCode(code *model.SmsCodeModel) error {
+	cli := db.Get()
+	code.CreatedAt = time.Now()
+	return cli.Table(smsCodeTableName).Create(code).Error
+}
+
+// GetLatestCode 获取手机号在指定场景下最近一条未使用的验证码，不存在时返回nil
+func (imp *SmsInterfaceImp) GetLatestCode(phone, scene string) (*model.SmsCodeModel, error) {
+	var code = new(model.SmsCodeModel)
+	cli := db.Get()
+	err := cli.Table(smsCodeTableName).
+		Where("phone = ? AND scene = ? AND status = ?", phone, scene, 0).
+		Order("id DESC").First(code).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return code, err
+}
+
+// CountCodesByPhoneSince 统计手机号在指定时间后的发送次数
+func (imp *SmsInterfaceImp) CountCodesByPhoneSince(phone string, since time.Time) (int64, error) {
+	var count int64
+	cli := db.Get()
+	err := cli.Table(smsCodeTableName).Where("phone = ? AND createdAt >= ?", phone, since).Count(&count).Error
+	return count, err
+}
+
+// CountCodesByIpSince 统计IP在指定时间后的发送次数
+func (imp *SmsInterfaceImp) CountCodesByIpSince(clientIp string, since time.Time) (int64, error) {
+	var count int64
+	cli := db.Get()
+	err := cli.Table(smsCodeTableName).Where("clientIp = ? AND createdAt >= ?", clientIp, since).Count(&count).Error
+	return count, err
+}
+
+// IncrementAttempts 校验次数加一，仅当已校验次数小于maxAttempts（为0时不限制）时成功，
+// 条件更新保证并发校验时次数不会超过上限；返回false表示次数已用完
+func (imp *SmsInterfaceImp) IncrementAttempts(id int32, maxAttempts int) (bool, error) {
+	cli := db.Get()
+	query := cli.Table(smsCodeTableName).Where("id = ?", id)
+	if maxAttempts > 0 {
+		query = query.Where("attempts < ?", maxAttempts)
+	}
+	result := query.Update("attempts", gorm.Expr("attempts + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkCodeUsed 标记验证码已使用，仅未使用的验证码可以标记成功（防止并发重复使用）
+func (imp *SmsInterfaceImp) MarkCodeUsed(id int32) (bool, error) {
+	cli := db.Get()
+	result := cli.Table(smsCodeTableName).Where("id = ? AND status = ?", id, 0).
+		Updates(map[string]interface{}{
+			"status": 1,
+			"usedAt": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// InvalidateCodes 作废手机号在指定场景下所有未使用的验证码
+func (imp *SmsInterfaceImp) InvalidateCodes(phone, scene string) error {
+	cli := db.Get()
+	return cli.Table(smsCodeTableName).Where("phone = ? AND scene = ? AND status = ?", phone, scene, 0).
+		Update("status", 2).Error
+}
@@ -0,0 +1,24 @@
+package dao
+
+import (
+	"time"
+
+	"wxcloudrun-golang/db/model"
+)
+
+// SmsInterface 短信验证码数据接口
+type SmsInterface interface {
+	CreateCode(code *model.SmsCodeModel) error
+	GetLatestCode(phone, scene string) (*model.SmsCodeModel, error)
+	CountCodesByPhoneSince(phone string, since time.Time) (int64, error)
+	CountCodesByIpSince(clientIp string, since time.Time) (int64, error)
+	IncrementAttempts(id int32, maxAttempts int) (bool, error)
+	MarkCodeUsed(id int32) (bool, error)
+	InvalidateCodes(phone, scene string) error
+}
+
+// SmsInterfaceImp 短信验证码数据实现
+type SmsInterfaceImp struct{}
+
+// SmsImp 短信验证码实现实例
+var SmsImp SmsInterface = &SmsInterfaceImp{}
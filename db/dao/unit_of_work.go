@@ -0,0 +1,21 @@
+package dao
+
+import (
+	"wxcloudrun-golang/db"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork 事务工作单元，持有同一个数据库事务
+// 将其传给DAO中以Tx结尾的方法，多次写入会一起提交或一起回滚
+type UnitOfWork struct {
+	tx *gorm.DB
+}
+
+// RunInTransaction 在一个事务中执行fn，fn返回错误或panic时整体回滚
+func RunInTransaction(fn func(uow *UnitOfWork) error) error {
+	cli := db.Get()
+	return cli.Transaction(func(tx *gorm.DB) error {
+		return fn(&UnitOfWork{tx: tx})
+	})
+}
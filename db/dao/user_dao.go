@@ -32,6 +32,36 @@ func (imp *UserInterfaceImp) GetUserByUserId(userId string) (*model.UserModel, e
 	return user, err
 }
 
+// GetUserByUnionId 根据UnionId查询最早注册且未被合并的用户
+func (imp *UserInterfaceImp) GetUserByUnionId(unionId string) (*model.UserModel, error) {
+	var user = new(model.UserModel)
+	cli := db.Get()
+	err := cli.Table(userTableName).
+		Where("unionId = ? AND (mergedInto IS NULL OR mergedInto = '')", unionId).
+		Order("id ASC").First(user).Error
+	return user, err
+}
+
+// GetUsersByUnionIdOrPhone 查询UnionId或手机号相同且未被合并的用户，用于查找重复账号
+func (imp *UserInterfaceImp) GetUsersByUnionIdOrPhone(unionId, phone string) ([]*model.UserModel, error) {
+	var users []*model.UserModel
+	if unionId == "" && phone == "" {
+		return users, nil
+	}
+	cli := db.Get()
+	query := cli.Table(userTableName).Where("mergedInto IS NULL OR mergedInto = ''")
+	switch {
+	case unionId != "" && phone != "":
+		query = query.Where("unionId = ? OR phone = ?", unionId, phone)
+	case unionId != "":
+		query = query.Where("unionId = ?", unionId)
+	default:
+		query = query.Where("phone = ?", phone)
+	}
+	err := query.Order("id ASC").Find(&users).Error
+	return users, err
+}
+
 // CreateUser 创建用户
 func (imp *UserInterfaceImp) CreateUser(user *model.UserModel) error {
 	cli := db.Get()
@@ -0,0 +1,216 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"wxcloudrun-golang/db"
+	"wxcloudrun-golang/db/model"
+
+	"gorm.io/gorm"
+)
+
+const userIdentityTableName = "UserIdentities"
+const userMergeLogTableName = "UserMergeLogs"
+
+// userOwnedTables 合并账号时需要整体迁移userId的表
+var userOwnedTables = []string{orderTableName, commissionTableName, cashoutTableName, fileTableName}
+
+// GetIdentityByOpenId 根据openId获取身份
+func (imp *UserIdentityInterfaceImp) GetIdentityByOpenId(openId string) (*model.UserIdentityModel, error) {
+	var identity = new(model.UserIdentityModel)
+	cli := db.Get()
+	err := cli.Table(userIdentityTableName).Where("openId = ?", openId).First(identity).Error
+	return identity, err
+}
+
+// GetIdentityByUnionId 根据unionId获取最早登记的身份
+func (imp *UserIdentityInterfaceImp) GetIdentityByUnionId(unionId string) (*model.UserIdentityModel, error) {
+	var identity = new(model.UserIdentityModel)
+	cli := db.Get()
+	err := cli.Table(userIdentityTableName).Where("unionId = ?", unionId).Order("id ASC").First(identity).Error
+	return identity, err
+}
+
+// GetIdentitiesByUserId 获取用户的全部身份
+func (imp *UserIdentityInterfaceImp) GetIdentitiesByUserId(userId string) ([]*model.UserIdentityModel, error) {
+	var identities []*model.UserIdentityModel
+	cli := db.Get()
+	err := cli.Table(userIdentityTableName).Where("userId = ?", userId).Order("id ASC").Find(&identities).Error
+	return identities, err
+}
+
+// CreateIdentity 登记身份
+func (imp *UserIdentityInterfaceImp) CreateIdentity(identity *model.UserIdentityModel) error {
+	cli := db.Get()
+	identity.CreatedAt = time.Now()
+	identity.UpdatedAt = time.Now()
+	return cli.Table(userIdentityTableName).Create(identity).Error
+}
+
+// MergeUsers 把source用户的订单、就诊人、地址、推荐关系、佣金、提现、文件和身份迁移到target用户，
+// 并标记source已合并、写入审计记录，全部在一个事务中完成。返回各表迁移的记录数
+func (imp *UserIdentityInterfaceImp) MergeUsers(source, target *model.UserModel, mergeLog *model.UserMergeLogModel) (map[string]int64, error) {
+	cli := db.Get()
+	now := time.Now()
+	summary := make(map[string]int64)
+
+	err := cli.Transaction(func(tx *gorm.DB) error {
+		// 先标记source已合并，条件更新保证同一账号不会被并发合并两次
+		result := tx.Table(userTableName).
+			Where("userId = ? AND (mergedInto IS NULL OR mergedInto = '')", source.UserId).
+			Updates(map[string]interface{}{
+				"mergedInto": target.UserId,
+				"mergedAt":   now,
+				"updatedAt":  now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("标记合并用户失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("用户已被合并: %s", source.UserId)
+		}
+
+		// 之前合并到source的账号改为指向target
+		if err := tx.Table(userTableName).Where("mergedInto = ?", source.UserId).
+			Update("mergedInto", target.UserId).Error; err != nil {
+			return fmt.Errorf("更新合并链失败: %v", err)
+		}
+
+		for _, table := range userOwnedTables {
+			result := tx.Table(table).Where("userId = ?", source.UserId).
+				Updates(map[string]interface{}{"userId": target.UserId, "updatedAt": now})
+			if result.Error != nil {
+				return fmt.Errorf("迁移%s失败: %v", table, result.Error)
+			}
+			summary[table] = result.RowsAffected
+		}
+
+		// 就诊人和地址：target已有默认项时，迁移过来的记录取消默认
+		for _, table := range []string{patientTableName, addressTableName} {
+			var defaults int64
+			if err := tx.Table(table).Where("userId = ? AND isDefault = ?", target.UserId, 1).Count(&defaults).Error; err != nil {
+				return fmt.Errorf("查询%s默认项失败: %v", table, err)
+			}
+			updates := map[string]interface{}{"userId": target.UserId, "updatedAt": now}
+			if defaults > 0 {
+				updates["isDefault"] = 0
+			}
+			result := tx.Table(table).Where("userId = ?", source.UserId).Updates(updates)
+			if result.Error != nil {
+				return fmt.Errorf("迁移%s失败: %v", table, result.Error)
+			}
+			summary[table] = result.RowsAffected
+		}
+
+		if err := mergeReferrals(tx, source.UserId, target.UserId, now, summary); err != nil {
+			return err
+		}
+
+		// 身份：source原来的openId也要能登录到target
+		result = tx.Table(userIdentityTableName).Where("userId = ?", source.UserId).
+			Updates(map[string]interface{}{"userId": target.UserId, "updatedAt": now})
+		if result.Error != nil {
+			return fmt.Errorf("迁移用户身份失败: %v", result.Error)
+		}
+		summary[userIdentityTableName] = result.RowsAffected
+		if source.OpenId != "" {
+			var count int64
+			if err := tx.Table(userIdentityTableName).Where("openId = ?", source.OpenId).Count(&count).Error; err != nil {
+				return fmt.Errorf("查询用户身份失败: %v", err)
+			}
+			if count == 0 {
+				identity := &model.UserIdentityModel{
+					UserId:    target.UserId,
+					OpenId:    source.OpenId,
+					UnionId:   source.UnionId,
+					Source:    model.IdentitySourceMiniProgram,
+					CreatedAt: now,
+					UpdatedAt: now,
+				}
+				if err := tx.Table(userIdentityTableName).Create(identity).Error; err != nil {
+					return fmt.Errorf("登记用户身份失败: %v", err)
+				}
+				summary[userIdentityTableName]++
+			}
+		}
+
+		// target缺少的资料用source补齐
+		targetUpdates := map[string]interface{}{}
+		if target.Phone == "" && source.Phone != "" {
+			targetUpdates["phone"] = source.Phone
+		}
+		if target.UnionId == "" && source.UnionId != "" {
+			targetUpdates["unionId"] = source.UnionId
+		}
+		if len(targetUpdates) > 0 {
+			targetUpdates["updatedAt"] = now
+			if err := tx.Table(userTableName).Where("userId = ?", target.UserId).Updates(targetUpdates).Error; err != nil {
+				return fmt.Errorf("更新保留用户资料失败: %v", err)
+			}
+		}
+
+		summaryJSON, _ := json.Marshal(summary)
+		mergeLog.SourceUserId = source.UserId
+		mergeLog.TargetUserId = target.UserId
+		mergeLog.Summary = string(summaryJSON)
+		mergeLog.CreatedAt = now
+		if err := tx.Table(userMergeLogTableName).Create(mergeLog).Error; err != nil {
+			return fmt.Errorf("写入合并记录失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// mergeReferrals 迁移推荐关系：每个用户只有一条推荐记录，target已有记录时停用source的记录；
+// source推荐的用户改为由target推荐
+func mergeReferrals(tx *gorm.DB, sourceUserId, targetUserId string, now time.Time, summary map[string]int64) error {
+	var targetCount int64
+	if err := tx.Table(referralTableName).Where("userId = ?", targetUserId).Count(&targetCount).Error; err != nil {
+		return fmt.Errorf("查询推荐关系失败: %v", err)
+	}
+	var result *gorm.DB
+	if targetCount == 0 {
+		result = tx.Table(referralTableName).Where("userId = ?", sourceUserId).
+			Updates(map[string]interface{}{"userId": targetUserId, "updatedAt": now})
+	} else {
+		result = tx.Table(referralTableName).Where("userId = ?", sourceUserId).
+			Updates(map[string]interface{}{"status": 0, "updatedAt": now})
+	}
+	if result.Error != nil {
+		return fmt.Errorf("迁移推荐关系失败: %v", result.Error)
+	}
+	summary[referralTableName] = result.RowsAffected
+
+	// target原本由source推荐时，合并后不能自己推荐自己
+	if err := tx.Table(referralTableName).Where("userId = ? AND referrerId = ?", targetUserId, sourceUserId).
+		Updates(map[string]interface{}{"referrerId": nil, "updatedAt": now}).Error; err != nil {
+		return fmt.Errorf("更新推荐人失败: %v", err)
+	}
+	result = tx.Table(referralTableName).Where("referrerId = ?", sourceUserId).
+		Updates(map[string]interface{}{"referrerId": targetUserId, "updatedAt": now})
+	if result.Error != nil {
+		return fmt.Errorf("更新推荐人失败: %v", result.Error)
+	}
+	summary["referredUsers"] = result.RowsAffected
+	return nil
+}
+
+// GetMergeLogs 分页获取账号合并记录
+func (imp *UserIdentityInterfaceImp) GetMergeLogs(page, pageSize int) ([]*model.UserMergeLogModel, int64, error) {
+	var logs []*model.UserMergeLogModel
+	var total int64
+	cli := db.Get()
+
+	if err := cli.Table(userMergeLogTableName).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	err := cli.Table(userMergeLogTableName).Order("id DESC").Offset(offset).Limit(pageSize).Find(&logs).Error
+	return logs, total, err
+}
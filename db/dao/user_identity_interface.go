@@ -0,0 +1,21 @@
+package dao
+
+import (
+	"wxcloudrun-golang/db/model"
+)
+
+// UserIdentityInterface 用户身份与账号合并数据接口
+type UserIdentityInterface interface {
+	GetIdentityByOpenId(openId string) (*model.UserIdentityModel, error)
+	GetIdentityByUnionId(unionId string) (*model.UserIdentityModel, error)
+	GetIdentitiesByUserId(userId string) ([]*model.UserIdentityModel, error)
+	CreateIdentity(identity *model.UserIdentityModel) error
+	MergeUsers(source, target *model.UserModel, mergeLog *model.UserMergeLogModel) (map[string]int64, error)
+	GetMergeLogs(page, pageSize int) ([]*model.UserMergeLogModel, int64, error)
+}
+
+// UserIdentityInterfaceImp 用户身份数据实现
+type UserIdentityInterfaceImp struct{}
+
+// UserIdentityImp 用户身份实现实例
+var UserIdentityImp UserIdentityInterface = &UserIdentityInterfaceImp{}
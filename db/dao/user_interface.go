@@ -9,6 +9,8 @@ type UserInterface interface {
 	GetUserByOpenId(openId string) (*model.UserModel, error)
 	GetUserById(id int32) (*model.UserModel, error)
 	GetUserByUserId(userId string) (*model.UserModel, error)
+	GetUserByUnionId(unionId string) (*model.UserModel, error)
+	GetUsersByUnionIdOrPhone(unionId, phone string) ([]*model.UserModel, error)
 	CreateUser(user *model.UserModel) error
 	UpdateUser(user *model.UserModel) error
 	UpsertUser(user *model.UserModel) error
@@ -0,0 +1,20 @@
+-- 管理员登录防暴力破解
+
+-- 登录日志记录提交的用户名（用户名不存在时 adminUserId 为空）
+ALTER TABLE AdminLoginLogs ADD COLUMN username VARCHAR(50) DEFAULT NULL COMMENT '登录时提交的用户名' AFTER adminUserId;
+ALTER TABLE AdminLoginLogs ADD INDEX idx_status_loginTime (status, loginTime);
+
+-- 登录失败计数与锁定表
+CREATE TABLE IF NOT EXISTS `AdminLoginLocks` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `lockType` VARCHAR(20) NOT NULL COMMENT '锁定类型 account-账号 ip-IP地址',
+  `lockKey` VARCHAR(100) NOT NULL COMMENT '用户名或IP',
+  `failedCount` INT DEFAULT 0 COMMENT '统计窗口内的连续失败次数',
+  `lockCount` INT DEFAULT 0 COMMENT '累计锁定次数',
+  `lastFailedAt` TIMESTAMP NULL COMMENT '最后一次失败时间',
+  `lockedUntil` TIMESTAMP NULL COMMENT '锁定截止时间',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY `uk_lockType_lockKey` (`lockType`, `lockKey`),
+  INDEX `idx_lockedUntil` (`lockedUntil`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='管理员登录失败计数与锁定表';
@@ -0,0 +1,9 @@
+-- 管理员密码生命周期字段
+ALTER TABLE Users ADD COLUMN adminPasswordUpdatedAt TIMESTAMP NULL COMMENT '管理员密码最后修改时间';
+ALTER TABLE Users ADD COLUMN adminPasswordMustChange TINYINT(1) DEFAULT 0 COMMENT '是否必须修改密码 0-否 1-是';
+
+-- 扩大密码字段长度以存储哈希值（格式：pbkdf2_sha256$迭代次数$盐$哈希值）
+ALTER TABLE Users MODIFY COLUMN adminPassword VARCHAR(255) DEFAULT NULL COMMENT '管理员密码哈希';
+
+-- 历史明文密码由服务启动时的迁移（MigrationService.HashAdminPasswords）自动转为哈希存储，
+-- 迁移后的账号会被标记为必须修改密码
@@ -0,0 +1,3 @@
+-- 管理员角色字段（逗号分隔，如 finance,customer_service）
+-- 超级管理员（adminLevel = 2）拥有全部权限；未分配角色的一级管理员按运营角色（operator）处理
+ALTER TABLE Users ADD COLUMN adminRoles VARCHAR(255) DEFAULT NULL COMMENT '管理员角色列表，逗号分隔' AFTER adminCreatedAt;
@@ -0,0 +1,4 @@
+-- 为登录会话表添加登录方式字段，管理接口和用户接口中的管理员特权只接受管理员账号密码登录的会话
+ALTER TABLE AuthSessions ADD COLUMN loginType VARCHAR(16) DEFAULT NULL COMMENT '登录方式：wechat-小程序登录，admin-管理员账号密码登录';
+
+-- 历史会话无法区分登录方式，loginType为空的会话不能访问管理接口，管理员需重新登录
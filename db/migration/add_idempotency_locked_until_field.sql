@@ -0,0 +1,4 @@
+-- 为幂等键表添加处理租约字段，处理中的请求崩溃后租约到期，其他请求可以接管该键
+ALTER TABLE IdempotencyKeys ADD COLUMN lockedUntil DATETIME DEFAULT NULL COMMENT '处理租约到期时间，仅处理中的键有效';
+
+-- 历史处理中的键没有租约，视为已到期，可被相同请求接管
@@ -0,0 +1,5 @@
+-- 为订单表添加支付渠道退款单号字段，退款处理中的订单由定时任务按此查询退款结果
+ALTER TABLE Orders ADD COLUMN refundNo VARCHAR(64) DEFAULT NULL COMMENT '支付渠道退款单号';
+
+-- 为退款状态创建索引，便于查询退款中的订单
+CREATE INDEX idx_refund_status ON Orders(refundStatus);
@@ -0,0 +1,49 @@
+-- 金额字段由“元”（DECIMAL）改为“分”（BIGINT）存储
+-- 只能执行一次：执行前请先备份，并确认服务已部署为按分读写金额的版本后再停机执行
+-- 每列先扩大精度防止乘以100后溢出，再四舍五入到分，最后改为整数类型
+
+-- 订单
+ALTER TABLE Orders
+  MODIFY COLUMN price DECIMAL(14,2) NOT NULL COMMENT '单价',
+  MODIFY COLUMN totalAmount DECIMAL(14,2) NOT NULL COMMENT '总金额',
+  MODIFY COLUMN refundAmount DECIMAL(14,2) COMMENT '退款金额',
+  MODIFY COLUMN commission DECIMAL(14,2) DEFAULT 0 COMMENT '佣金金额';
+UPDATE Orders SET
+  price = ROUND(price * 100),
+  totalAmount = ROUND(totalAmount * 100),
+  refundAmount = ROUND(refundAmount * 100),
+  commission = ROUND(commission * 100);
+ALTER TABLE Orders
+  MODIFY COLUMN price BIGINT NOT NULL COMMENT '单价（分）',
+  MODIFY COLUMN totalAmount BIGINT NOT NULL COMMENT '总金额（分）',
+  MODIFY COLUMN refundAmount BIGINT COMMENT '退款金额（分）',
+  MODIFY COLUMN commission BIGINT DEFAULT 0 COMMENT '佣金金额（分）';
+
+-- 佣金
+ALTER TABLE Commissions MODIFY COLUMN amount DECIMAL(14,2) NOT NULL COMMENT '佣金金额';
+UPDATE Commissions SET amount = ROUND(amount * 100);
+ALTER TABLE Commissions MODIFY COLUMN amount BIGINT NOT NULL COMMENT '佣金金额（分）';
+
+-- 提现
+ALTER TABLE Cashouts MODIFY COLUMN amount DECIMAL(14,2) NOT NULL COMMENT '提现金额';
+UPDATE Cashouts SET amount = ROUND(amount * 100);
+ALTER TABLE Cashouts MODIFY COLUMN amount BIGINT NOT NULL COMMENT '提现金额（分）';
+
+-- 服务项目
+ALTER TABLE ServiceItems
+  MODIFY COLUMN price DECIMAL(14,2) NOT NULL COMMENT '服务价格',
+  MODIFY COLUMN originalPrice DECIMAL(14,2) COMMENT '原价';
+UPDATE ServiceItems SET
+  price = ROUND(price * 100),
+  originalPrice = ROUND(originalPrice * 100);
+ALTER TABLE ServiceItems
+  MODIFY COLUMN price BIGINT NOT NULL COMMENT '服务价格（分）',
+  MODIFY COLUMN originalPrice BIGINT COMMENT '原价（分）';
+
+-- 首页服务
+ALTER TABLE Services MODIFY COLUMN price DECIMAL(14,2) COMMENT '服务价格';
+UPDATE Services SET price = ROUND(price * 100);
+ALTER TABLE Services MODIFY COLUMN price BIGINT COMMENT '服务价格（分）';
+
+-- 校验：以下查询结果应与迁移前 SUM(totalAmount) * 100 一致
+-- SELECT SUM(totalAmount) FROM Orders;
@@ -0,0 +1,20 @@
+-- 账号注销申请表
+CREATE TABLE IF NOT EXISTS `AccountDeletionRequests` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `userId` VARCHAR(24) NOT NULL COMMENT '申请注销的用户ID',
+  `reason` VARCHAR(255) DEFAULT NULL COMMENT '注销原因',
+  `status` TINYINT DEFAULT 0 COMMENT '状态 0-待审核 1-已撤销 2-已驳回 3-已注销',
+  `coolingOffUntil` TIMESTAMP NULL COMMENT '冷静期截止时间',
+  `reviewerId` VARCHAR(24) DEFAULT NULL COMMENT '审核管理员用户ID',
+  `reviewRemark` VARCHAR(255) DEFAULT NULL COMMENT '审核备注',
+  `reviewedAt` TIMESTAMP NULL COMMENT '审核时间',
+  `cancelledAt` TIMESTAMP NULL COMMENT '撤销时间',
+  `completedAt` TIMESTAMP NULL COMMENT '完成注销时间',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX `idx_userId_status` (`userId`, `status`),
+  INDEX `idx_status_coolingOffUntil` (`status`, `coolingOffUntil`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='账号注销申请表';
+
+-- 用户表增加注销时间
+ALTER TABLE Users ADD COLUMN deactivatedAt TIMESTAMP NULL COMMENT '注销并完成匿名化的时间' AFTER mergedAt;
@@ -0,0 +1,27 @@
+-- 管理员TOTP两步验证
+
+-- TOTP配置表
+CREATE TABLE IF NOT EXISTS `AdminTotps` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `userId` VARCHAR(24) NOT NULL COMMENT '管理员用户ID',
+  `secret` VARCHAR(255) NOT NULL COMMENT 'TOTP密钥（AES-GCM加密）',
+  `status` TINYINT(1) DEFAULT 0 COMMENT '状态 0-待验证 1-已启用',
+  `lastUsedStep` BIGINT DEFAULT 0 COMMENT '最后一次使用的时间步，防止验证码重放',
+  `enabledAt` TIMESTAMP NULL COMMENT '启用时间',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY `uk_userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='管理员TOTP两步验证表';
+
+-- 恢复码表
+CREATE TABLE IF NOT EXISTS `AdminRecoveryCodes` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `userId` VARCHAR(24) NOT NULL COMMENT '管理员用户ID',
+  `codeHash` VARCHAR(64) NOT NULL COMMENT '恢复码SHA-256哈希',
+  `usedAt` TIMESTAMP NULL COMMENT '使用时间，为空表示未使用',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  INDEX `idx_userId_codeHash` (`userId`, `codeHash`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='管理员两步验证恢复码表';
+
+-- 会话记录两步验证有效期
+ALTER TABLE AuthSessions ADD COLUMN stepUpUntil TIMESTAMP NULL COMMENT '两步验证有效期' AFTER revokeReason;
@@ -0,0 +1,18 @@
+-- 创建登录会话表（用于令牌刷新与服务端吊销）
+CREATE TABLE IF NOT EXISTS AuthSessions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    sessionId VARCHAR(32) NOT NULL COMMENT '会话ID',
+    userId VARCHAR(24) NOT NULL COMMENT '用户ID',
+    refreshJti VARCHAR(32) COMMENT '当前有效的刷新令牌ID',
+    clientIp VARCHAR(45) COMMENT '登录IP',
+    userAgent TEXT COMMENT '用户代理',
+    status TINYINT(1) DEFAULT 1 COMMENT '状态：1-有效，0-已吊销',
+    expiresAt DATETIME NOT NULL COMMENT '会话过期时间',
+    revokedAt DATETIME NULL COMMENT '吊销时间',
+    revokeReason VARCHAR(100) COMMENT '吊销原因',
+    createdAt DATETIME DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY uk_sessionId (sessionId),
+    INDEX idx_userId (userId),
+    INDEX idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='登录会话表';
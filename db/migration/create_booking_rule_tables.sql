@@ -0,0 +1,35 @@
+-- 预约时间段模板表，按服务项目、服务分类或全局配置可预约时间段和预约窗口
+CREATE TABLE IF NOT EXISTS `SlotTemplates` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `name` VARCHAR(64) DEFAULT NULL COMMENT '模板名称',
+  `serviceId` INT DEFAULT 0 COMMENT '适用的服务项目ID，0表示不限',
+  `category` VARCHAR(50) DEFAULT NULL COMMENT '适用的服务分类，空表示不限',
+  `timeSlots` VARCHAR(500) NOT NULL COMMENT '时间段，逗号分隔',
+  `weekdays` VARCHAR(20) DEFAULT NULL COMMENT '可预约的星期，逗号分隔，0为周日，空表示每天',
+  `leadDays` INT DEFAULT 1 COMMENT '最早可预约第几天，0为当天',
+  `horizonDays` INT DEFAULT 7 COMMENT '最晚可预约第几天',
+  `status` TINYINT DEFAULT 1 COMMENT '1-启用，0-停用',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX `idx_serviceId` (`serviceId`),
+  INDEX `idx_category` (`category`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='预约时间段模板表';
+
+-- 停约日期表（节假日、临时停约）
+CREATE TABLE IF NOT EXISTS `BookingBlackouts` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `date` VARCHAR(10) NOT NULL COMMENT '停约日期 YYYY-MM-DD',
+  `serviceId` INT DEFAULT 0 COMMENT '服务项目ID，0表示全部',
+  `category` VARCHAR(50) DEFAULT NULL COMMENT '服务分类，空表示全部',
+  `timeSlot` VARCHAR(10) DEFAULT NULL COMMENT '时间段，空表示全天',
+  `reason` VARCHAR(255) DEFAULT NULL COMMENT '停约原因',
+  `createdBy` VARCHAR(24) DEFAULT NULL COMMENT '创建人用户ID',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  INDEX `idx_date` (`date`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='停约日期表';
+
+-- 全局模板，与原先代码中写死的规则一致：明天起7天内，每天08:00-19:00
+INSERT INTO SlotTemplates (name, serviceId, category, timeSlots, weekdays, leadDays, horizonDays, status)
+SELECT '默认', 0, NULL, '08:00,09:00,10:00,11:00,14:00,15:00,16:00,17:00,18:00,19:00', NULL, 1, 7, 1
+FROM DUAL
+WHERE NOT EXISTS (SELECT 1 FROM SlotTemplates WHERE serviceId = 0 AND (category IS NULL OR category = ''));
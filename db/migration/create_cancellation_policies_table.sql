@@ -0,0 +1,19 @@
+-- 取消退款政策表，按服务项目、服务分类或全局配置取消已支付订单时的退款比例
+CREATE TABLE IF NOT EXISTS `CancellationPolicies` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `name` VARCHAR(64) DEFAULT NULL COMMENT '政策名称',
+  `serviceId` INT DEFAULT 0 COMMENT '适用的服务项目ID，0表示不限',
+  `category` VARCHAR(50) DEFAULT NULL COMMENT '适用的服务分类，空表示不限',
+  `tiers` VARCHAR(255) NOT NULL COMMENT '退款档位，逗号分隔的“提前小时数:退款百分比”',
+  `checkedInPercent` INT DEFAULT 0 COMMENT '护理员签到后取消的退款百分比',
+  `status` TINYINT DEFAULT 1 COMMENT '1-启用，0-停用',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX `idx_serviceId` (`serviceId`),
+  INDEX `idx_category` (`category`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='取消退款政策表';
+
+-- 与内置规则一致的全局政策：提前24小时以上全额退款，24小时内退款50%，签到后不退款
+INSERT INTO `CancellationPolicies` (`name`, `serviceId`, `category`, `tiers`, `checkedInPercent`, `status`)
+SELECT '默认', 0, '', '24:100,0:50', 0, 1
+WHERE NOT EXISTS (SELECT 1 FROM `CancellationPolicies`);
@@ -0,0 +1,23 @@
+-- 护理员（陪诊、陪护人员）表
+CREATE TABLE IF NOT EXISTS `Caregivers` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `userId` VARCHAR(24) DEFAULT NULL COMMENT '绑定的小程序用户ID，用于接收派单通知',
+  `name` VARCHAR(50) NOT NULL COMMENT '姓名',
+  `phone` VARCHAR(20) NOT NULL COMMENT '手机号',
+  `gender` TINYINT DEFAULT 0 COMMENT '0-未知，1-男，2-女',
+  `skills` VARCHAR(255) DEFAULT NULL COMMENT '可提供的服务分类，逗号分隔，空表示不限',
+  `regions` VARCHAR(255) DEFAULT NULL COMMENT '服务区域（城市或区县），逗号分隔，空表示不限',
+  `certifications` VARCHAR(500) DEFAULT NULL COMMENT '资质证书，逗号分隔',
+  `status` TINYINT DEFAULT 1 COMMENT '0-停用，1-在岗，2-休假',
+  `remark` VARCHAR(255) DEFAULT NULL COMMENT '备注',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX `idx_userId` (`userId`),
+  INDEX `idx_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='护理员表';
+
+-- 订单派单字段，status新增 5-已派单
+ALTER TABLE Orders
+  ADD COLUMN caregiverId INT DEFAULT 0 COMMENT '派单的护理员ID，0表示未派单',
+  ADD COLUMN assignedAt DATETIME DEFAULT NULL COMMENT '最近一次派单时间',
+  ADD INDEX idx_caregiver_slot (caregiverId, appointmentDate, appointmentTime);
@@ -0,0 +1,20 @@
+-- 幂等键表，保存Idempotency-Key及首次响应快照
+CREATE TABLE IF NOT EXISTS `IdempotencyKeys` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `userId` VARCHAR(24) NOT NULL COMMENT '用户ID',
+  `scope` VARCHAR(64) NOT NULL COMMENT '接口标识 order.submit/order.pay/order.pay_confirm',
+  `idemKey` VARCHAR(128) NOT NULL COMMENT '客户端传入的Idempotency-Key',
+  `requestHash` VARCHAR(64) DEFAULT NULL COMMENT '请求路径和请求体的SHA-256',
+  `status` TINYINT DEFAULT 0 COMMENT '0-处理中，1-已完成',
+  `responseStatus` INT DEFAULT NULL COMMENT 'HTTP状态码',
+  `responseBody` TEXT COMMENT '响应快照',
+  `expiresAt` DATETIME NOT NULL COMMENT '过期时间',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY `uk_user_scope_key` (`userId`, `scope`, `idemKey`),
+  INDEX `idx_expiresAt` (`expiresAt`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='幂等键表';
+
+-- 同一订单只能有一条佣金记录
+-- 执行前先检查是否已有重复佣金：SELECT orderId, COUNT(*) FROM Commissions GROUP BY orderId HAVING COUNT(*) > 1;
+ALTER TABLE Commissions ADD UNIQUE INDEX uk_orderId (orderId);
@@ -0,0 +1,20 @@
+-- 订单改约记录表
+CREATE TABLE IF NOT EXISTS `OrderReschedules` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `orderId` INT NOT NULL COMMENT '订单ID',
+  `orderNo` VARCHAR(64) NOT NULL COMMENT '订单号',
+  `fromDate` VARCHAR(20) NOT NULL COMMENT '原预约日期',
+  `fromTime` VARCHAR(20) NOT NULL COMMENT '原预约时间段',
+  `toDate` VARCHAR(20) NOT NULL COMMENT '新预约日期',
+  `toTime` VARCHAR(20) NOT NULL COMMENT '新预约时间段',
+  `actorType` VARCHAR(20) NOT NULL COMMENT '操作方：user, admin',
+  `actorId` VARCHAR(24) DEFAULT NULL COMMENT '操作人用户ID',
+  `reason` VARCHAR(255) DEFAULT NULL COMMENT '改约原因',
+  `override` TINYINT(1) DEFAULT 0 COMMENT '管理员是否跳过了改约规则',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  KEY `idx_order_id` (`orderId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单改约记录表';
+
+-- 订单已改约次数
+ALTER TABLE Orders
+  ADD COLUMN rescheduleCount INT DEFAULT 0 COMMENT '已改约次数';
@@ -0,0 +1,27 @@
+-- 订单状态变更记录表
+CREATE TABLE IF NOT EXISTS `OrderStatusLogs` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `orderId` INT NOT NULL COMMENT '订单ID',
+  `orderNo` VARCHAR(64) NOT NULL COMMENT '订单号',
+  `event` VARCHAR(32) NOT NULL COMMENT '事件 create-创建 pay-支付 cancel-取消 expire-超时取消 complete-完成 refund_request-申请退款 refund-退款',
+  `fromStatus` TINYINT NULL COMMENT '变更前订单状态，创建时为空',
+  `toStatus` TINYINT NOT NULL COMMENT '变更后订单状态',
+  `refundStatus` TINYINT DEFAULT 0 COMMENT '变更后退款状态',
+  `actorType` VARCHAR(20) NOT NULL COMMENT '操作方 user-用户 admin-管理员 system-系统 wechat_pay-微信支付',
+  `actorId` VARCHAR(24) DEFAULT NULL COMMENT '操作人用户ID',
+  `reason` VARCHAR(255) DEFAULT NULL COMMENT '原因',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  INDEX `idx_orderId` (`orderId`, `id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单状态变更记录表';
+
+-- 为已有订单补录创建、支付记录
+INSERT INTO OrderStatusLogs (orderId, orderNo, event, fromStatus, toStatus, refundStatus, actorType, actorId, reason, createdAt)
+SELECT o.id, o.orderNo, 'create', NULL, 0, 0, 'user', o.userId, '历史数据补录', o.createdAt
+FROM Orders o
+WHERE NOT EXISTS (SELECT 1 FROM OrderStatusLogs l WHERE l.orderId = o.id);
+
+INSERT INTO OrderStatusLogs (orderId, orderNo, event, fromStatus, toStatus, refundStatus, actorType, actorId, reason, createdAt)
+SELECT o.id, o.orderNo, 'pay', 0, 1, 0, 'system', NULL, '历史数据补录', o.payTime
+FROM Orders o
+WHERE o.payTime IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM OrderStatusLogs l WHERE l.orderId = o.id AND l.event = 'pay');
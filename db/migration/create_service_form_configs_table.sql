@@ -0,0 +1,23 @@
+-- 服务表单配置版本
+ALTER TABLE ServiceItems
+  ADD COLUMN formVersion INT DEFAULT 0 COMMENT '表单配置版本，每次修改加1，0表示未配置表单';
+
+-- 订单提交时校验表单数据所用的表单配置版本
+ALTER TABLE Orders
+  ADD COLUMN formVersion INT DEFAULT 0 COMMENT '校验表单数据所用的服务表单配置版本';
+
+-- 服务表单配置历史版本表
+CREATE TABLE IF NOT EXISTS `ServiceFormConfigs` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `serviceId` INT NOT NULL COMMENT '服务项目ID',
+  `version` INT NOT NULL COMMENT '版本号',
+  `formConfig` TEXT COMMENT '表单配置（JSON格式）',
+  `createdBy` VARCHAR(24) DEFAULT NULL COMMENT '发布人用户ID',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY `uk_service_version` (`serviceId`, `version`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='服务表单配置历史版本表';
+
+-- 已有表单配置记为版本1
+UPDATE ServiceItems SET formVersion = 1 WHERE formConfig IS NOT NULL AND formConfig <> '';
+INSERT INTO ServiceFormConfigs (serviceId, version, formConfig)
+SELECT id, 1, formConfig FROM ServiceItems WHERE formVersion = 1;
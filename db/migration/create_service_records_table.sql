@@ -0,0 +1,28 @@
+-- 护理员服务记录表：接单、签到签退、服务备注和照片
+CREATE TABLE IF NOT EXISTS `ServiceRecords` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `orderId` INT NOT NULL COMMENT '订单ID',
+  `caregiverId` INT NOT NULL COMMENT '护理员ID',
+  `acceptStatus` TINYINT DEFAULT 0 COMMENT '0-待接单，1-已接单，2-已拒单',
+  `acceptedAt` DATETIME DEFAULT NULL COMMENT '接单时间',
+  `declineReason` VARCHAR(255) DEFAULT NULL COMMENT '拒单原因',
+  `checkInAt` DATETIME DEFAULT NULL COMMENT '签到时间',
+  `checkInLatitude` DOUBLE DEFAULT NULL,
+  `checkInLongitude` DOUBLE DEFAULT NULL,
+  `checkInDistance` INT DEFAULT NULL COMMENT '签到位置距服务地址（米）',
+  `checkOutAt` DATETIME DEFAULT NULL COMMENT '签退时间',
+  `checkOutLatitude` DOUBLE DEFAULT NULL,
+  `checkOutLongitude` DOUBLE DEFAULT NULL,
+  `checkOutDistance` INT DEFAULT NULL COMMENT '签退位置距服务地址（米）',
+  `notes` TEXT COMMENT '服务备注',
+  `photoFileIds` VARCHAR(1000) DEFAULT NULL COMMENT '服务照片文件ID，逗号分隔',
+  `completedAt` DATETIME DEFAULT NULL COMMENT '服务完成时间',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY `uk_order_caregiver` (`orderId`, `caregiverId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='护理员服务记录表';
+
+-- 地址坐标，用于护理员签到、签退位置校验
+ALTER TABLE UserAddresses
+  ADD COLUMN latitude DOUBLE DEFAULT NULL COMMENT '纬度（GCJ-02）',
+  ADD COLUMN longitude DOUBLE DEFAULT NULL COMMENT '经度（GCJ-02）';
@@ -0,0 +1,17 @@
+-- 短信验证码表
+CREATE TABLE IF NOT EXISTS `SmsCodes` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `phone` VARCHAR(20) NOT NULL COMMENT '手机号',
+  `scene` VARCHAR(32) NOT NULL COMMENT '使用场景 bind_phone-绑定手机号 admin_login-管理员登录',
+  `codeHash` VARCHAR(64) NOT NULL COMMENT '验证码哈希（HMAC-SHA256）',
+  `clientIp` VARCHAR(64) DEFAULT NULL COMMENT '申请IP',
+  `provider` VARCHAR(20) DEFAULT NULL COMMENT '短信服务商',
+  `attempts` INT DEFAULT 0 COMMENT '已校验次数',
+  `status` TINYINT DEFAULT 0 COMMENT '状态 0-未使用 1-已使用 2-已作废',
+  `expiresAt` TIMESTAMP NULL COMMENT '过期时间',
+  `usedAt` TIMESTAMP NULL COMMENT '使用时间',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  INDEX `idx_phone_scene_status` (`phone`, `scene`, `status`),
+  INDEX `idx_phone_createdAt` (`phone`, `createdAt`),
+  INDEX `idx_clientIp_createdAt` (`clientIp`, `createdAt`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='短信验证码表';
@@ -0,0 +1,27 @@
+-- 服务项目默认时间段容量，0表示使用环境变量 TIME_SLOT_DEFAULT_CAPACITY（默认3）
+ALTER TABLE ServiceItems ADD COLUMN slotCapacity INT DEFAULT 0 COMMENT '每个时间段可预约订单数，0表示使用默认值';
+
+-- 预约时间段库存表
+CREATE TABLE IF NOT EXISTS `TimeSlotInventories` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `serviceId` INT NOT NULL COMMENT '服务项目ID',
+  `date` VARCHAR(10) NOT NULL COMMENT '预约日期 YYYY-MM-DD',
+  `timeSlot` VARCHAR(10) NOT NULL COMMENT '时间段 HH:MM',
+  `capacity` INT NOT NULL COMMENT '可预约订单数',
+  `booked` INT NOT NULL DEFAULT 0 COMMENT '已占用数',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY `uk_service_date_slot` (`serviceId`, `date`, `timeSlot`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='预约时间段库存表';
+
+-- 按现有未取消、未退款的订单补录已占用数，已约满的时间段不会再接受新订单
+INSERT INTO TimeSlotInventories (serviceId, date, timeSlot, capacity, booked)
+SELECT o.serviceId, o.appointmentDate, o.appointmentTime,
+       IF(s.slotCapacity > 0, s.slotCapacity, 3), COUNT(*)
+FROM Orders o
+LEFT JOIN ServiceItems s ON s.id = o.serviceId
+WHERE o.status IN (0, 1, 2)
+  AND o.appointmentDate >= DATE_FORMAT(CURDATE(), '%Y-%m-%d')
+  AND o.appointmentDate <> '' AND o.appointmentTime <> ''
+GROUP BY o.serviceId, o.appointmentDate, o.appointmentTime, s.slotCapacity
+ON DUPLICATE KEY UPDATE booked = VALUES(booked);
@@ -0,0 +1,39 @@
+-- 用户身份表：同一用户在小程序、公众号、H5下的openId不同，通过unionId关联到同一个userId
+CREATE TABLE IF NOT EXISTS `UserIdentities` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `userId` VARCHAR(24) NOT NULL COMMENT '用户ID',
+  `appId` VARCHAR(64) DEFAULT NULL COMMENT '所属应用AppID',
+  `openId` VARCHAR(100) NOT NULL COMMENT 'openId',
+  `unionId` VARCHAR(100) DEFAULT NULL COMMENT '开放平台unionId',
+  `source` VARCHAR(20) DEFAULT NULL COMMENT '来源 miniprogram-小程序 official-公众号 h5-H5预约页',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY `uk_openId` (`openId`),
+  INDEX `idx_unionId` (`unionId`),
+  INDEX `idx_userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户身份表';
+
+-- 账号合并审计表
+CREATE TABLE IF NOT EXISTS `UserMergeLogs` (
+  `id` INT AUTO_INCREMENT PRIMARY KEY,
+  `sourceUserId` VARCHAR(24) NOT NULL COMMENT '被合并的用户ID',
+  `targetUserId` VARCHAR(24) NOT NULL COMMENT '保留的用户ID',
+  `operatorId` VARCHAR(24) NOT NULL COMMENT '操作管理员用户ID',
+  `reason` VARCHAR(255) DEFAULT NULL COMMENT '合并原因',
+  `summary` TEXT COMMENT '迁移记录数（JSON）',
+  `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  INDEX `idx_sourceUserId` (`sourceUserId`),
+  INDEX `idx_targetUserId` (`targetUserId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='账号合并审计表';
+
+-- 用户表增加合并标记
+ALTER TABLE Users ADD COLUMN mergedInto VARCHAR(24) DEFAULT NULL COMMENT '已被合并到的用户ID' AFTER adminPasswordMustChange;
+ALTER TABLE Users ADD COLUMN mergedAt TIMESTAMP NULL COMMENT '合并时间' AFTER mergedInto;
+ALTER TABLE Users ADD INDEX idx_unionId (unionId);
+
+-- 为已有小程序用户补录身份记录
+INSERT INTO UserIdentities (userId, openId, unionId, source, createdAt, updatedAt)
+SELECT u.userId, u.openId, u.unionId, 'miniprogram', u.createdAt, NOW()
+FROM Users u
+WHERE u.openId IS NOT NULL AND u.openId <> ''
+  AND NOT EXISTS (SELECT 1 FROM UserIdentities i WHERE i.openId = u.openId);
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// AuthSessionModel 登录会话模型，用于令牌刷新与服务端吊销
+type AuthSessionModel struct {
+	Id           int32      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	SessionId    string     `gorm:"column:sessionId;type:varchar(32);uniqueIndex;not null" json:"sessionId"`
+	UserId       string     `gorm:"column:userId;type:varchar(24);not null" json:"userId"`
+	RefreshJti   string     `gorm:"column:refreshJti;type:varchar(32)" json:"-"` // 当前有效的刷新令牌ID，每次刷新轮换
+	ClientIp     string     `gorm:"column:clientIp" json:"clientIp"`
+	UserAgent    string     `gorm:"column:userAgent" json:"userAgent"`
+	Status       int        `gorm:"column:status;default:1" json:"status"` // 1-有效，0-已吊销
+	ExpiresAt    time.Time  `gorm:"column:expiresAt" json:"expiresAt"`     // 会话过期时间
+	RevokedAt    *time.Time `gorm:"column:revokedAt" json:"revokedAt"`
+	RevokeReason string     `gorm:"column:revokeReason" json:"revokeReason"`
+	StepUpUntil  *time.Time `gorm:"column:stepUpUntil" json:"stepUpUntil"` // 两步验证有效期，期内可调用资金相关管理接口
+	LoginType    string     `gorm:"column:loginType" json:"loginType"`     // 登录方式：wechat-小程序登录，admin-管理员账号密码登录
+	CreatedAt    time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt    time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (AuthSessionModel) TableName() string {
+	return "AuthSessions"
+}
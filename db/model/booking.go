@@ -0,0 +1,77 @@
+package model
+
+import "time"
+
+// TimeSlotInventoryModel 时间段库存模型，按服务项目、日期、时间段记录容量和已预约数
+type TimeSlotInventoryModel struct {
+	Id        int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ServiceId int32     `gorm:"column:serviceId;not null" json:"serviceId"`
+	Date      string    `gorm:"column:date;type:varchar(10);not null" json:"date"`         // 预约日期 YYYY-MM-DD
+	TimeSlot  string    `gorm:"column:timeSlot;type:varchar(10);not null" json:"timeSlot"` // 时间段 HH:MM
+	Capacity  int       `gorm:"column:capacity;not null" json:"capacity"`                  // 可预约订单数
+	Booked    int       `gorm:"column:booked;default:0" json:"booked"`                     // 已占用数（待支付、已支付、已完成的订单）
+	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (TimeSlotInventoryModel) TableName() string {
+	return "TimeSlotInventories"
+}
+
+// SlotTemplateModel 预约时间段模板，按服务项目或服务分类配置可预约时间段和预约窗口
+// ServiceId、Category都为空时为全局模板
+type SlotTemplateModel struct {
+	Id          int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Name        string    `gorm:"column:name" json:"name"`
+	ServiceId   int32     `gorm:"column:serviceId;default:0" json:"serviceId"`     // 适用的服务项目，0表示不限
+	Category    string    `gorm:"column:category" json:"category"`                 // 适用的服务分类，空表示不限
+	TimeSlots   string    `gorm:"column:timeSlots;not null" json:"timeSlots"`      // 时间段，逗号分隔，如 08:00,09:00
+	Weekdays    string    `gorm:"column:weekdays" json:"weekdays"`                 // 可预约的星期，逗号分隔，0为周日，空表示每天
+	LeadDays    int       `gorm:"column:leadDays;default:1" json:"leadDays"`       // 最早可预约第几天，0为当天，1为明天
+	HorizonDays int       `gorm:"column:horizonDays;default:7" json:"horizonDays"` // 最晚可预约第几天
+	Status      int       `gorm:"column:status;default:1" json:"status"`           // 1-启用，0-停用
+	CreatedAt   time.Time `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt   time.Time `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (SlotTemplateModel) TableName() string {
+	return "SlotTemplates"
+}
+
+// BookingBlackoutModel 停约日期（节假日、临时停约），按服务项目、分类或时间段生效
+type BookingBlackoutModel struct {
+	Id        int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Date      string    `gorm:"column:date;type:varchar(10);not null" json:"date"` // 停约日期 YYYY-MM-DD
+	ServiceId int32     `gorm:"column:serviceId;default:0" json:"serviceId"`       // 0表示全部服务项目
+	Category  string    `gorm:"column:category" json:"category"`                   // 空表示全部分类
+	TimeSlot  string    `gorm:"column:timeSlot" json:"timeSlot"`                   // 空表示全天
+	Reason    string    `gorm:"column:reason" json:"reason"`
+	CreatedBy string    `gorm:"column:createdBy;type:varchar(24)" json:"createdBy"`
+	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (BookingBlackoutModel) TableName() string {
+	return "BookingBlackouts"
+}
+
+// CancellationPolicyModel 取消退款政策，按服务项目或服务分类配置取消已支付订单时的退款比例
+// ServiceId、Category都为空时为全局政策
+type CancellationPolicyModel struct {
+	Id               int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Name             string    `gorm:"column:name" json:"name"`
+	ServiceId        int32     `gorm:"column:serviceId;default:0" json:"serviceId"`               // 适用的服务项目，0表示不限
+	Category         string    `gorm:"column:category" json:"category"`                           // 适用的服务分类，空表示不限
+	Tiers            string    `gorm:"column:tiers;not null" json:"tiers"`                        // 退款档位，逗号分隔的“提前小时数:退款百分比”，如 24:100,0:50
+	CheckedInPercent int       `gorm:"column:checkedInPercent;default:0" json:"checkedInPercent"` // 护理员签到后取消的退款百分比
+	Status           int       `gorm:"column:status;default:1" json:"status"`                     // 1-启用，0-停用
+	CreatedAt        time.Time `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt        time.Time `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (CancellationPolicyModel) TableName() string {
+	return "CancellationPolicies"
+}
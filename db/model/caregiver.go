@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+// 护理员状态
+const (
+	CaregiverStatusDisabled = 0 // 停用
+	CaregiverStatusActive   = 1 // 在岗，可派单
+	CaregiverStatusOnLeave  = 2 // 休假，暂不派单
+)
+
+// CaregiverModel 护理员（陪诊、陪护人员）
+type CaregiverModel struct {
+	Id             int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId         string    `gorm:"column:userId;type:varchar(24)" json:"userId"` // 绑定的小程序用户ID，用于接收派单通知，可为空
+	Name           string    `gorm:"column:name;not null" json:"name"`
+	Phone          string    `gorm:"column:phone;not null" json:"phone"`
+	Gender         int       `gorm:"column:gender;default:0" json:"gender"`       // 0-未知，1-男，2-女
+	Skills         string    `gorm:"column:skills" json:"skills"`                 // 可提供的服务分类，逗号分隔，空表示不限
+	Regions        string    `gorm:"column:regions" json:"regions"`               // 服务区域（城市或区县），逗号分隔，空表示不限
+	Certifications string    `gorm:"column:certifications" json:"certifications"` // 资质证书，逗号分隔，如 护士执业证书,养老护理员证
+	Status         int       `gorm:"column:status;default:1" json:"status"`       // 0-停用，1-在岗，2-休假
+	Remark         string    `gorm:"column:remark" json:"remark"`
+	CreatedAt      time.Time `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt      time.Time `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (CaregiverModel) TableName() string {
+	return "Caregivers"
+}
+
+// 护理员接单状态
+const (
+	JobAcceptPending  = 0 // 待接单
+	JobAcceptAccepted = 1 // 已接单
+	JobAcceptDeclined = 2 // 已拒单
+)
+
+// ServiceRecordModel 护理员服务记录，同一订单每个护理员一条，记录接单、签到签退和服务照片备注
+type ServiceRecordModel struct {
+	Id                int32      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	OrderId           int32      `gorm:"column:orderId;not null" json:"orderId"`
+	CaregiverId       int32      `gorm:"column:caregiverId;not null" json:"caregiverId"`
+	AcceptStatus      int        `gorm:"column:acceptStatus;default:0" json:"acceptStatus"` // 0-待接单，1-已接单，2-已拒单
+	AcceptedAt        *time.Time `gorm:"column:acceptedAt" json:"acceptedAt"`
+	DeclineReason     string     `gorm:"column:declineReason" json:"declineReason"`
+	CheckInAt         *time.Time `gorm:"column:checkInAt" json:"checkInAt"`
+	CheckInLatitude   *float64   `gorm:"column:checkInLatitude" json:"checkInLatitude"`
+	CheckInLongitude  *float64   `gorm:"column:checkInLongitude" json:"checkInLongitude"`
+	CheckInDistance   *int       `gorm:"column:checkInDistance" json:"checkInDistance"` // 签到位置与服务地址的距离（米），地址无坐标时为空
+	CheckOutAt        *time.Time `gorm:"column:checkOutAt" json:"checkOutAt"`
+	CheckOutLatitude  *float64   `gorm:"column:checkOutLatitude" json:"checkOutLatitude"`
+	CheckOutLongitude *float64   `gorm:"column:checkOutLongitude" json:"checkOutLongitude"`
+	CheckOutDistance  *int       `gorm:"column:checkOutDistance" json:"checkOutDistance"` // 签退位置与服务地址的距离（米），地址无坐标时为空
+	Notes             string     `gorm:"column:notes;type:text" json:"notes"`             // 服务备注，按时间追加
+	PhotoFileIds      string     `gorm:"column:photoFileIds" json:"photoFileIds"`         // 服务照片的文件ID，逗号分隔
+	CompletedAt       *time.Time `gorm:"column:completedAt" json:"completedAt"`
+	CreatedAt         time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt         time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (ServiceRecordModel) TableName() string {
+	return "ServiceRecords"
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// 幂等键状态
+const (
+	IdempotencyStatusProcessing = 0 // 处理中
+	IdempotencyStatusCompleted  = 1 // 已完成，保存了响应快照
+)
+
+// IdempotencyKeyModel 幂等键及响应快照，同一用户在同一接口重复使用相同的键时直接返回首次的响应
+type IdempotencyKeyModel struct {
+	Id             int32      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId         string     `gorm:"column:userId;type:varchar(24);not null" json:"userId"`
+	Scope          string     `gorm:"column:scope;type:varchar(64);not null" json:"scope"`      // 接口标识，如 order.submit
+	IdemKey        string     `gorm:"column:idemKey;type:varchar(128);not null" json:"idemKey"` // 客户端传入的Idempotency-Key
+	RequestHash    string     `gorm:"column:requestHash;type:varchar(64)" json:"requestHash"`   // 请求路径和请求体的SHA-256
+	Status         int        `gorm:"column:status;default:0" json:"status"`
+	ResponseStatus int        `gorm:"column:responseStatus" json:"responseStatus"` // HTTP状态码
+	ResponseBody   string     `gorm:"column:responseBody;type:text" json:"responseBody"`
+	ExpiresAt      time.Time  `gorm:"column:expiresAt" json:"expiresAt"`
+	LockedUntil    *time.Time `gorm:"column:lockedUntil" json:"lockedUntil"` // 处理租约到期时间，到期后相同请求可以接管处理中的键
+	CreatedAt      time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt      time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (IdempotencyKeyModel) TableName() string {
+	return "IdempotencyKeys"
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money 金额，数据库中以“分”为单位的整数存储，避免浮点误差
+// JSON中仍按“元”输出为保留两位小数的数字（如 12.30），与小程序原有格式兼容；
+// 解析JSON时接受数字或字符串形式的元金额，超过两位的小数四舍五入到分
+type Money int64
+
+// MoneyFromYuan 元转换为金额，四舍五入到分
+func MoneyFromYuan(yuan float64) Money {
+	return Money(math.Round(yuan * 100))
+}
+
+// Yuan 以元为单位的浮点值，仅用于展示和日志
+func (m Money) Yuan() float64 {
+	return float64(m) / 100
+}
+
+// Cents 以分为单位的整数值，用于支付接口
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// MulRate 按比例计算金额（如佣金），结果四舍五入到分（0.5分进位）
+func (m Money) MulRate(rate float64) Money {
+	return Money(math.Round(float64(m) * rate))
+}
+
+// String 格式化为两位小数的元金额，如 12.30
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalJSON 输出为元金额数字
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON 解析数字或字符串形式的元金额
+func (m *Money) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(strings.TrimSpace(string(data)), `"`)
+	if value == "" || value == "null" {
+		*m = 0
+		return nil
+	}
+	money, err := parseYuan(value)
+	if err != nil {
+		return fmt.Errorf("无效的金额: %s", value)
+	}
+	*m = money
+	return nil
+}
+
+// parseYuan 按十进制字符串把元金额转换为分，第三位小数四舍五入
+// 不经过浮点数，避免 1.005 这类金额因二进制误差被舍成 1.00
+func parseYuan(value string) (Money, error) {
+	if strings.ContainsAny(value, "eE") {
+		yuan, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, err
+		}
+		return MoneyFromYuan(yuan), nil
+	}
+
+	negative := strings.HasPrefix(value, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(value, "-"), "+")
+	intPart, fracPart := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		intPart, fracPart = digits[:i], digits[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+	for _, c := range intPart + fracPart {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid character %q", c)
+		}
+	}
+
+	yuan := int64(0)
+	if intPart != "" {
+		var err error
+		if yuan, err = strconv.ParseInt(intPart, 10, 64); err != nil || yuan > math.MaxInt64/100-1 {
+			return 0, fmt.Errorf("amount out of range")
+		}
+	}
+	fracPart += "000"
+	cents := yuan*100 + int64(fracPart[0]-'0')*10 + int64(fracPart[1]-'0')
+	if fracPart[2] >= '5' {
+		cents++
+	}
+	if negative {
+		cents = -cents
+	}
+	return Money(cents), nil
+}
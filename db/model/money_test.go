@@ -0,0 +1,154 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMoneyFromYuan(t *testing.T) {
+	tests := []struct {
+		yuan float64
+		want Money
+	}{
+		{0, 0},
+		{0.01, 1},
+		{0.1, 10},
+		{1.5, 150},
+		{12.3, 1230},
+		{19.99, 1999},
+		{99.995, 10000},
+		{0.29, 29},
+		{-12.34, -1234},
+		{100000, 10000000},
+	}
+	for _, tt := range tests {
+		if got := MoneyFromYuan(tt.yuan); got != tt.want {
+			t.Errorf("MoneyFromYuan(%v) = %d, want %d", tt.yuan, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyMulRate(t *testing.T) {
+	tests := []struct {
+		money Money
+		rate  float64
+		want  Money
+	}{
+		{10000, 0.05, 500},
+		{1999, 0.05, 100}, // 99.95分，四舍五入
+		{1990, 0.05, 100}, // 99.5分，0.5分进位
+		{1989, 0.05, 99},  // 99.45分
+		{10, 0.05, 1},     // 0.5分进位
+		{9, 0.05, 0},      // 0.45分舍去
+		{12345, 1, 12345},
+		{12345, 0, 0},
+		{1000, 0.333, 333},
+	}
+	for _, tt := range tests {
+		if got := tt.money.MulRate(tt.rate); got != tt.want {
+			t.Errorf("Money(%d).MulRate(%v) = %d, want %d", tt.money, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{10, "0.10"},
+		{1230, "12.30"},
+		{100000, "1000.00"},
+		{-5, "-0.05"},
+		{-1234, "-12.34"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	for _, money := range []Money{0, 1, 10, 99, 1230, 1999, 123456789, -1, -1234} {
+		data, err := json.Marshal(money)
+		if err != nil {
+			t.Fatalf("Marshal(%d): %v", money, err)
+		}
+		var got Money
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if got != money {
+			t.Errorf("round trip %d -> %s -> %d", money, data, got)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{`12.3`, 1230, false},
+		{`12.30`, 1230, false},
+		{`"12.30"`, 1230, false},
+		{`"8.5"`, 850, false},
+		{`1.005`, 101, false},
+		{`"1.005"`, 101, false},
+		{`2.675`, 268, false},
+		{`".5"`, 50, false},
+		{`"7."`, 700, false},
+		{`1e2`, 10000, false},
+		{`"-"`, 0, true},
+		{`"."`, 0, true},
+		{`"1.2.3"`, 0, true},
+		{`0`, 0, false},
+		{`""`, 0, false},
+		{`null`, 0, false},
+		{`0.005`, 1, false},
+		{`0.004`, 0, false},
+		{`19.999`, 2000, false},
+		{`-3.2`, -320, false},
+		{`-0.005`, -1, false},
+		{`"abc"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.input), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyInStructJSON(t *testing.T) {
+	type order struct {
+		TotalAmount Money  `json:"totalAmount"`
+		Commission  *Money `json:"commission"`
+	}
+	commission := Money(50)
+	data, err := json.Marshal(order{TotalAmount: 1000, Commission: &commission})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"totalAmount":10.00,"commission":0.50}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	var got order
+	if err := json.Unmarshal([]byte(`{"totalAmount":"99.9","commission":null}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.TotalAmount != 9990 || got.Commission != nil {
+		t.Errorf("Unmarshal = %+v", got)
+	}
+}
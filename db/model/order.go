@@ -2,6 +2,23 @@ package model
 
 import "time"
 
+// 订单状态
+const (
+	OrderStatusPending   = 0 // 待支付
+	OrderStatusPaid      = 1 // 已支付
+	OrderStatusCompleted = 2 // 已完成
+	OrderStatusCancelled = 3 // 已取消
+	OrderStatusRefunded  = 4 // 已退款
+	OrderStatusAssigned  = 5 // 已派单（已支付并安排护理员）
+)
+
+// 退款状态
+const (
+	RefundStatusNone      = 0 // 未退款
+	RefundStatusRefunding = 1 // 退款中
+	RefundStatusRefunded  = 2 // 已退款
+)
+
 // OrderModel 订单模型
 type OrderModel struct {
 	Id               int32      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
@@ -15,28 +32,74 @@ type OrderModel struct {
 	DiseaseInfo      string     `gorm:"column:diseaseInfo" json:"diseaseInfo"`                     // 既往病史
 	NeedToiletAssist int        `gorm:"column:needToiletAssist;default:0" json:"needToiletAssist"` // 是否需要助排二便：0-不需要，1-需要
 	ServiceName      string     `gorm:"column:serviceName;not null" json:"serviceName"`
-	Price            float64    `gorm:"column:price;not null" json:"price"`
+	Price            Money      `gorm:"column:price;not null" json:"price"`
 	Quantity         int        `gorm:"column:quantity;default:1" json:"quantity"`
-	TotalAmount      float64    `gorm:"column:totalAmount;not null" json:"totalAmount"`
-	FormData         string     `gorm:"column:formData" json:"formData"`             // JSON格式的表单数据
-	Status           int        `gorm:"column:status;default:0" json:"status"`       // 0-待支付，1-已支付，2-已完成，3-已取消，4-已退款
-	PayStatus        int        `gorm:"column:payStatus;default:0" json:"payStatus"` // 0-未支付，1-已支付
-	PayDeadline      *time.Time `gorm:"column:payDeadline" json:"payDeadline"`       // 支付截止时间
+	TotalAmount      Money      `gorm:"column:totalAmount;not null" json:"totalAmount"`
+	FormData         string     `gorm:"column:formData" json:"formData"`                 // JSON格式的表单数据
+	FormVersion      int        `gorm:"column:formVersion;default:0" json:"formVersion"` // 校验表单数据所用的服务表单配置版本，0表示服务未配置表单
+	Status           int        `gorm:"column:status;default:0" json:"status"`           // 0-待支付，1-已支付，2-已完成，3-已取消，4-已退款，5-已派单
+	PayStatus        int        `gorm:"column:payStatus;default:0" json:"payStatus"`     // 0-未支付，1-已支付
+	PayDeadline      *time.Time `gorm:"column:payDeadline" json:"payDeadline"`           // 支付截止时间
 	PayTime          *time.Time `gorm:"column:payTime" json:"payTime"`
 	PayMethod        string     `gorm:"column:payMethod" json:"payMethod"`                 // 支付方式：wechat, alipay等
 	TransactionId    string     `gorm:"column:transactionId" json:"transactionId"`         // 第三方支付交易号
 	RefundStatus     int        `gorm:"column:refundStatus;default:0" json:"refundStatus"` // 0-未退款，1-退款中，2-已退款
 	RefundTime       *time.Time `gorm:"column:refundTime" json:"refundTime"`
-	RefundAmount     float64    `gorm:"column:refundAmount" json:"refundAmount"`
+	RefundAmount     Money      `gorm:"column:refundAmount" json:"refundAmount"`
 	RefundReason     string     `gorm:"column:refundReason" json:"refundReason"`
+	RefundNo         string     `gorm:"column:refundNo" json:"refundNo"` // 支付渠道退款单号，退款处理中时按此查询退款结果
 	Remark           string     `gorm:"column:remark" json:"remark"`
-	ReferrerId       int32      `gorm:"column:referrerId" json:"referrerId"` // 推荐人ID
-	Commission       float64    `gorm:"column:commission" json:"commission"` // 佣金金额
+	ReferrerId       int32      `gorm:"column:referrerId" json:"referrerId"`                     // 推荐人ID
+	Commission       Money      `gorm:"column:commission" json:"commission"`                     // 佣金金额
+	CaregiverId      int32      `gorm:"column:caregiverId;default:0" json:"caregiverId"`         // 派单的护理员ID，0表示未派单
+	AssignedAt       *time.Time `gorm:"column:assignedAt" json:"assignedAt"`                     // 最近一次派单时间
+	RescheduleCount  int        `gorm:"column:rescheduleCount;default:0" json:"rescheduleCount"` // 已改约次数
 	CreatedAt        time.Time  `gorm:"column:createdAt" json:"createdAt"`
 	UpdatedAt        time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
 }
 
+// OrderStatusLogModel 订单状态变更记录
+type OrderStatusLogModel struct {
+	Id           int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	OrderId      int32     `gorm:"column:orderId;not null" json:"orderId"`
+	OrderNo      string    `gorm:"column:orderNo;not null" json:"orderNo"`
+	Event        string    `gorm:"column:event;not null" json:"event"`             // 事件：create, pay, cancel, expire, reschedule, assign, reassign, accept, decline, check_in, check_out, complete, refund_request, refund
+	FromStatus   *int      `gorm:"column:fromStatus" json:"fromStatus"`            // 变更前订单状态，创建订单时为空
+	ToStatus     int       `gorm:"column:toStatus;not null" json:"toStatus"`       // 变更后订单状态
+	RefundStatus int       `gorm:"column:refundStatus" json:"refundStatus"`        // 变更后退款状态
+	ActorType    string    `gorm:"column:actorType;not null" json:"actorType"`     // 操作方：user, admin, system, wechat_pay, caregiver
+	ActorId      string    `gorm:"column:actorId;type:varchar(24)" json:"actorId"` // 操作人用户ID，系统操作为空
+	Reason       string    `gorm:"column:reason" json:"reason"`
+	CreatedAt    time.Time `gorm:"column:createdAt" json:"createdAt"`
+}
+
+// OrderRescheduleModel 订单改约记录
+type OrderRescheduleModel struct {
+	Id        int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	OrderId   int32     `gorm:"column:orderId;not null" json:"orderId"`
+	OrderNo   string    `gorm:"column:orderNo;not null" json:"orderNo"`
+	FromDate  string    `gorm:"column:fromDate;not null" json:"fromDate"`
+	FromTime  string    `gorm:"column:fromTime;not null" json:"fromTime"`
+	ToDate    string    `gorm:"column:toDate;not null" json:"toDate"`
+	ToTime    string    `gorm:"column:toTime;not null" json:"toTime"`
+	ActorType string    `gorm:"column:actorType;not null" json:"actorType"`     // 操作方：user, admin
+	ActorId   string    `gorm:"column:actorId;type:varchar(24)" json:"actorId"` // 操作人用户ID
+	Reason    string    `gorm:"column:reason" json:"reason"`
+	Override  bool      `gorm:"column:override;default:false" json:"override"` // 管理员是否跳过了改约规则
+	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
+}
+
 // TableName 指定表名
 func (OrderModel) TableName() string {
 	return "Orders"
 }
+
+// TableName 指定表名
+func (OrderStatusLogModel) TableName() string {
+	return "OrderStatusLogs"
+}
+
+// TableName 指定表名
+func (OrderRescheduleModel) TableName() string {
+	return "OrderReschedules"
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// 账号注销申请状态
+const (
+	AccountDeletionStatusPending   = 0 // 冷静期/待审核
+	AccountDeletionStatusCancelled = 1 // 用户撤销
+	AccountDeletionStatusRejected  = 2 // 审核驳回
+	AccountDeletionStatusCompleted = 3 // 已注销（已匿名化）
+)
+
+// AccountDeletionRequestModel 账号注销申请模型
+type AccountDeletionRequestModel struct {
+	Id              int32      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId          string     `gorm:"column:userId;type:varchar(24);not null" json:"userId"`
+	Reason          string     `gorm:"column:reason" json:"reason"`
+	Status          int        `gorm:"column:status;default:0" json:"status"`         // 0-待审核，1-已撤销，2-已驳回，3-已注销
+	CoolingOffUntil time.Time  `gorm:"column:coolingOffUntil" json:"coolingOffUntil"` // 冷静期截止时间，之前用户可撤销、管理员不能通过
+	ReviewerId      string     `gorm:"column:reviewerId;type:varchar(24)" json:"reviewerId"`
+	ReviewRemark    string     `gorm:"column:reviewRemark" json:"reviewRemark"`
+	ReviewedAt      *time.Time `gorm:"column:reviewedAt" json:"reviewedAt"`
+	CancelledAt     *time.Time `gorm:"column:cancelledAt" json:"cancelledAt"`
+	CompletedAt     *time.Time `gorm:"column:completedAt" json:"completedAt"`
+	CreatedAt       time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt       time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (AccountDeletionRequestModel) TableName() string {
+	return "AccountDeletionRequests"
+}
@@ -2,6 +2,14 @@ package model
 
 import "time"
 
+// 佣金状态
+const (
+	CommissionStatusPending   = 0 // 待结算
+	CommissionStatusSettled   = 1 // 已结算
+	CommissionStatusWithdrawn = 2 // 已提现
+	CommissionStatusCancelled = 3 // 已取消（订单退款）
+)
+
 // ReferralModel 推荐关系模型
 type ReferralModel struct {
 	Id           int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
@@ -20,9 +28,9 @@ type CommissionModel struct {
 	UserId      string     `gorm:"column:userId;not null;type:varchar(24)" json:"userId"`
 	OrderId     int32      `gorm:"column:orderId;not null" json:"orderId"`
 	OrderNo     string     `gorm:"column:orderNo;not null" json:"orderNo"`
-	Amount      float64    `gorm:"column:amount;not null" json:"amount"`  // 佣金金额
+	Amount      Money      `gorm:"column:amount;not null" json:"amount"`  // 佣金金额
 	Rate        float64    `gorm:"column:rate;not null" json:"rate"`      // 佣金比例
-	Status      int        `gorm:"column:status;default:0" json:"status"` // 0-待结算，1-已结算，2-已提现
+	Status      int        `gorm:"column:status;default:0" json:"status"` // 0-待结算，1-已结算，2-已提现，3-已取消
 	CashoutTime *time.Time `gorm:"column:cashoutTime" json:"cashoutTime"`
 	CreatedAt   time.Time  `gorm:"column:createdAt" json:"createdAt"`
 	UpdatedAt   time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
@@ -32,7 +40,7 @@ type CommissionModel struct {
 type CashoutModel struct {
 	Id          int32      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
 	UserId      string     `gorm:"column:userId;not null;type:varchar(24)" json:"userId"`
-	Amount      float64    `gorm:"column:amount;not null" json:"amount"`
+	Amount      Money      `gorm:"column:amount;not null" json:"amount"`
 	Method      string     `gorm:"column:method;not null" json:"method"`   // 提现方式：wechat, alipay, bank
 	Account     string     `gorm:"column:account;not null" json:"account"` // 提现账户
 	Status      int        `gorm:"column:status;default:0" json:"status"`  // 0-待审核，1-已通过，2-已拒绝，3-已到账
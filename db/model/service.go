@@ -8,13 +8,15 @@ type ServiceItemModel struct {
 	Name          string    `gorm:"column:name;not null" json:"name"`
 	Description   string    `gorm:"column:description" json:"description"`
 	Category      string    `gorm:"column:category;not null" json:"category"`
-	Price         float64   `gorm:"column:price;not null" json:"price"`
-	OriginalPrice float64   `gorm:"column:originalPrice" json:"originalPrice"`
+	Price         Money     `gorm:"column:price;not null" json:"price"`
+	OriginalPrice Money     `gorm:"column:originalPrice" json:"originalPrice"`
 	ImageUrl      string    `gorm:"column:imageUrl" json:"imageUrl"`
-	DetailImages  string    `gorm:"column:detailImages" json:"detailImages"` // JSON数组
-	FormConfig    string    `gorm:"column:formConfig" json:"formConfig"`     // JSON配置
-	Status        int       `gorm:"column:status;default:1" json:"status"`   // 1-上架，0-下架
+	DetailImages  string    `gorm:"column:detailImages" json:"detailImages"`         // JSON数组
+	FormConfig    string    `gorm:"column:formConfig" json:"formConfig"`             // JSON配置
+	FormVersion   int       `gorm:"column:formVersion;default:0" json:"formVersion"` // 表单配置版本，每次修改加1，0表示未配置表单
+	Status        int       `gorm:"column:status;default:1" json:"status"`           // 1-上架，0-下架
 	Sort          int       `gorm:"column:sort;default:0" json:"sort"`
+	SlotCapacity  int       `gorm:"column:slotCapacity;default:0" json:"slotCapacity"` // 每个时间段可预约订单数，0表示使用默认值
 	CreatedAt     time.Time `gorm:"column:createdAt" json:"createdAt"`
 	UpdatedAt     time.Time `gorm:"column:updatedAt" json:"updatedAt"`
 }
@@ -23,3 +25,18 @@ type ServiceItemModel struct {
 func (ServiceItemModel) TableName() string {
 	return "ServiceItems"
 }
+
+// ServiceFormConfigModel 服务表单配置的历史版本，订单记录提交时校验所用的版本号
+type ServiceFormConfigModel struct {
+	Id         int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ServiceId  int32     `gorm:"column:serviceId;not null" json:"serviceId"`
+	Version    int       `gorm:"column:version;not null" json:"version"`
+	FormConfig string    `gorm:"column:formConfig;type:text" json:"formConfig"`
+	CreatedBy  string    `gorm:"column:createdBy;type:varchar(24)" json:"createdBy"` // 发布人用户ID
+	CreatedAt  time.Time `gorm:"column:createdAt" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (ServiceFormConfigModel) TableName() string {
+	return "ServiceFormConfigs"
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// SmsCodeModel 短信验证码模型
+type SmsCodeModel struct {
+	Id        int32      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Phone     string     `gorm:"column:phone;type:varchar(20);not null" json:"phone"`
+	Scene     string     `gorm:"column:scene;type:varchar(32);not null" json:"scene"` // 使用场景，如bind_phone
+	CodeHash  string     `gorm:"column:codeHash;not null" json:"-"`                   // 验证码哈希，不保存明文
+	ClientIp  string     `gorm:"column:clientIp" json:"clientIp"`
+	Provider  string     `gorm:"column:provider" json:"provider"`
+	Attempts  int        `gorm:"column:attempts;default:0" json:"attempts"` // 已校验次数
+	Status    int        `gorm:"column:status;default:0" json:"status"`     // 0-未使用，1-已使用，2-已作废
+	ExpiresAt time.Time  `gorm:"column:expiresAt" json:"expiresAt"`
+	UsedAt    *time.Time `gorm:"column:usedAt" json:"usedAt"`
+	CreatedAt time.Time  `gorm:"column:createdAt" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (SmsCodeModel) TableName() string {
+	return "SmsCodes"
+}
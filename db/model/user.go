@@ -27,12 +27,22 @@ type UserModel struct {
 	AdminUsername  string     `gorm:"column:adminUsername" json:"adminUsername"`
 	ParentAdminId  string     `gorm:"column:parentAdminId" json:"parentAdminId"`
 	AdminCreatedAt *time.Time `gorm:"column:adminCreatedAt" json:"adminCreatedAt"`
+	AdminRoles     string     `gorm:"column:adminRoles" json:"adminRoles"` // 角色列表，逗号分隔，如 finance,customer_service
+	// 管理员密码生命周期
+	AdminPasswordUpdatedAt  *time.Time `gorm:"column:adminPasswordUpdatedAt" json:"adminPasswordUpdatedAt"`
+	AdminPasswordMustChange int        `gorm:"column:adminPasswordMustChange;default:0" json:"adminPasswordMustChange"` // 1-下次登录后必须修改密码
+	// 账号合并
+	MergedInto string     `gorm:"column:mergedInto;type:varchar(24)" json:"mergedInto"` // 已被合并到的用户ID，为空表示正常账号
+	MergedAt   *time.Time `gorm:"column:mergedAt" json:"mergedAt"`
+	// 账号注销
+	DeactivatedAt *time.Time `gorm:"column:deactivatedAt" json:"deactivatedAt"` // 注销并完成匿名化的时间，为空表示正常账号
 }
 
 // AdminLoginLogModel 管理员登录记录模型
 type AdminLoginLogModel struct {
 	Id          int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	AdminUserId string    `gorm:"column:adminUserId;not null" json:"adminUserId"`
+	AdminUserId string    `gorm:"column:adminUserId;not null" json:"adminUserId"` // 用户名不存在时为空
+	Username    string    `gorm:"column:username" json:"username"`                // 登录时提交的用户名
 	LoginTime   time.Time `gorm:"column:loginTime;default:CURRENT_TIMESTAMP" json:"loginTime"`
 	LoginIp     string    `gorm:"column:loginIp" json:"loginIp"`
 	UserAgent   string    `gorm:"column:userAgent" json:"userAgent"`
@@ -41,6 +51,40 @@ type AdminLoginLogModel struct {
 	CreatedAt   time.Time `gorm:"column:createdAt;default:CURRENT_TIMESTAMP" json:"createdAt"`
 }
 
+// AdminLoginLockModel 管理员登录失败计数与锁定模型
+type AdminLoginLockModel struct {
+	Id           int32      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	LockType     string     `gorm:"column:lockType;not null" json:"lockType"`        // account-账号，ip-IP地址
+	LockKey      string     `gorm:"column:lockKey;not null" json:"lockKey"`          // 用户名或IP
+	FailedCount  int        `gorm:"column:failedCount;default:0" json:"failedCount"` // 统计窗口内的连续失败次数
+	LockCount    int        `gorm:"column:lockCount;default:0" json:"lockCount"`     // 累计锁定次数，用于递增锁定时长
+	LastFailedAt *time.Time `gorm:"column:lastFailedAt" json:"lastFailedAt"`
+	LockedUntil  *time.Time `gorm:"column:lockedUntil" json:"lockedUntil"` // 锁定截止时间，为空或已过期表示未锁定
+	CreatedAt    time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt    time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// AdminTotpModel 管理员TOTP两步验证模型
+type AdminTotpModel struct {
+	Id           int32      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId       string     `gorm:"column:userId;type:varchar(24);uniqueIndex;not null" json:"userId"`
+	Secret       string     `gorm:"column:secret;not null" json:"-"`        // 加密后的TOTP密钥
+	Status       int        `gorm:"column:status;default:0" json:"status"`  // 0-待验证，1-已启用
+	LastUsedStep int64      `gorm:"column:lastUsedStep;default:0" json:"-"` // 最后一次使用的时间步，防止验证码重放
+	EnabledAt    *time.Time `gorm:"column:enabledAt" json:"enabledAt"`
+	CreatedAt    time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt    time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// AdminRecoveryCodeModel 管理员两步验证恢复码模型
+type AdminRecoveryCodeModel struct {
+	Id        int32      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId    string     `gorm:"column:userId;type:varchar(24);not null" json:"userId"`
+	CodeHash  string     `gorm:"column:codeHash;not null" json:"-"`
+	UsedAt    *time.Time `gorm:"column:usedAt" json:"usedAt"` // 为空表示未使用
+	CreatedAt time.Time  `gorm:"column:createdAt" json:"createdAt"`
+}
+
 // TableName 指定表名
 func (UserModel) TableName() string {
 	return "Users"
@@ -50,3 +94,18 @@ func (UserModel) TableName() string {
 func (AdminLoginLogModel) TableName() string {
 	return "AdminLoginLogs"
 }
+
+// TableName 指定表名
+func (AdminLoginLockModel) TableName() string {
+	return "AdminLoginLocks"
+}
+
+// TableName 指定表名
+func (AdminTotpModel) TableName() string {
+	return "AdminTotps"
+}
+
+// TableName 指定表名
+func (AdminRecoveryCodeModel) TableName() string {
+	return "AdminRecoveryCodes"
+}
@@ -12,6 +12,8 @@ type UserAddressModel struct {
 	City      string    `gorm:"column:city" json:"city"`
 	District  string    `gorm:"column:district" json:"district"`
 	Address   string    `gorm:"column:address;not null" json:"address"`
+	Latitude  *float64  `gorm:"column:latitude" json:"latitude"`             // 纬度（GCJ-02，wx.chooseLocation返回值），用于护理员签到校验
+	Longitude *float64  `gorm:"column:longitude" json:"longitude"`           // 经度（GCJ-02）
 	IsDefault int       `gorm:"column:isDefault;default:0" json:"isDefault"` // 1-默认地址，0-非默认
 	Status    int       `gorm:"column:status;default:1" json:"status"`       // 1-正常，0-删除
 	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
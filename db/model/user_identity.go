@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// 身份来源
+const (
+	IdentitySourceMiniProgram = "miniprogram" // 小程序
+	IdentitySourceOfficial    = "official"    // 公众号
+	IdentitySourceH5          = "h5"          // H5预约页
+)
+
+// UserIdentityModel 用户身份模型，同一用户在小程序、公众号、H5下的openId不同，通过unionId关联到同一个userId
+type UserIdentityModel struct {
+	Id        int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId    string    `gorm:"column:userId;type:varchar(24);not null" json:"userId"`
+	AppId     string    `gorm:"column:appId" json:"appId"`
+	OpenId    string    `gorm:"column:openId;uniqueIndex;not null" json:"openId"`
+	UnionId   string    `gorm:"column:unionId" json:"unionId"`
+	Source    string    `gorm:"column:source" json:"source"` // miniprogram, official, h5
+	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+// UserMergeLogModel 账号合并审计记录
+type UserMergeLogModel struct {
+	Id           int32     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	SourceUserId string    `gorm:"column:sourceUserId;type:varchar(24);not null" json:"sourceUserId"` // 被合并（注销）的用户ID
+	TargetUserId string    `gorm:"column:targetUserId;type:varchar(24);not null" json:"targetUserId"` // 保留的用户ID
+	OperatorId   string    `gorm:"column:operatorId;type:varchar(24);not null" json:"operatorId"`     // 操作管理员
+	Reason       string    `gorm:"column:reason" json:"reason"`
+	Summary      string    `gorm:"column:summary;type:text" json:"summary"` // 迁移记录数（JSON）
+	CreatedAt    time.Time `gorm:"column:createdAt" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (UserIdentityModel) TableName() string {
+	return "UserIdentities"
+}
+
+func (UserMergeLogModel) TableName() string {
+	return "UserMergeLogs"
+}
@@ -4,15 +4,30 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"wxcloudrun-golang/config"
 	"wxcloudrun-golang/db"
 	"wxcloudrun-golang/service"
 )
 
 func main() {
+	// 未配置登录令牌密钥时拒绝启动，避免使用公开的默认密钥签发令牌
+	if err := config.GetAuthConfig().Validate(); err != nil {
+		panic(fmt.Sprintf("auth config invalid: %v", err))
+	}
+	// 非开发模式下拒绝启用模拟支付
+	if err := config.GetPaymentConfig().Validate(); err != nil {
+		panic(fmt.Sprintf("payment config invalid: %v", err))
+	}
+
 	if err := db.Init(); err != nil {
 		panic(fmt.Sprintf("mysql init failed with %+v", err))
 	}
 
+	// 迁移历史明文存储的管理员密码（已哈希的会跳过）
+	if _, err := service.NewMigrationService().HashAdminPasswords(); err != nil {
+		log.Printf("管理员密码迁移失败: %v", err)
+	}
+
 	// 初始化订单超时处理服务
 	service.InitOrderTimeoutService()
 
@@ -26,25 +41,34 @@ func main() {
 	// 微信登录相关接口
 	http.HandleFunc("/api/wx/login", service.NewLogMiddleware(service.WxLoginHandler))
 
+	// 登录令牌相关接口
+	http.HandleFunc("/api/auth/refresh", service.NewLogMiddleware(service.RefreshTokenHandler))
+	http.HandleFunc("/api/auth/logout", service.NewLogMiddleware(service.NewAuthMiddleware(service.LogoutHandler)))
+
 	// 首页初始化接口
 	http.HandleFunc("/api/home/init", service.NewLogMiddleware(service.HomeInitHandler))
 
 	// 文件上传和管理接口
-	http.HandleFunc("/api/upload", service.NewLogMiddleware(service.UploadHandler))
-	http.HandleFunc("/api/files", service.NewLogMiddleware(service.GetFileListHandler))
-	http.HandleFunc("/api/file/delete", service.NewLogMiddleware(service.DeleteFileHandler))
-	http.HandleFunc("/api/file/permission", service.NewLogMiddleware(service.UpdateFilePermissionHandler))
-	http.HandleFunc("/api/file/permission/get", service.NewLogMiddleware(service.GetFilePermissionHandler))
+	http.HandleFunc("/api/upload", service.NewLogMiddleware(service.NewAuthMiddleware(service.UploadHandler)))
+	http.HandleFunc("/api/files", service.NewLogMiddleware(service.NewAuthMiddleware(service.GetFileListHandler)))
+	http.HandleFunc("/api/file/delete", service.NewLogMiddleware(service.NewAuthMiddleware(service.DeleteFileHandler)))
+	http.HandleFunc("/api/file/permission", service.NewLogMiddleware(service.NewAuthMiddleware(service.UpdateFilePermissionHandler)))
+	http.HandleFunc("/api/file/permission/get", service.NewLogMiddleware(service.NewAuthMiddleware(service.GetFilePermissionHandler)))
 
 	// 系统配置接口
 	http.HandleFunc("/api/config", service.NewLogMiddleware(service.ConfigHandler))
 
 	// 用户相关接口
-	http.HandleFunc("/api/user/info", service.NewLogMiddleware(service.GetUserInfoHandler))
-	http.HandleFunc("/api/user/bind_phone", service.NewLogMiddleware(service.BindPhoneHandler))
-	http.HandleFunc("/api/user/update_info", service.NewLogMiddleware(service.UpdateUserInfoHandler))
-	http.HandleFunc("/api/user/address", service.NewLogMiddleware(service.AddressHandler))
-	http.HandleFunc("/api/user/patient", service.NewLogMiddleware(service.PatientHandler))
+	http.HandleFunc("/api/user/info", service.NewLogMiddleware(service.NewAuthMiddleware(service.GetUserInfoHandler)))
+	http.HandleFunc("/api/user/bind_phone", service.NewLogMiddleware(service.NewAuthMiddleware(service.BindPhoneHandler)))
+	http.HandleFunc("/api/sms/send", service.NewLogMiddleware(service.SendSMSCodeHandler))
+	http.HandleFunc("/api/user/update_info", service.NewLogMiddleware(service.NewAuthMiddleware(service.UpdateUserInfoHandler)))
+	http.HandleFunc("/api/user/address", service.NewLogMiddleware(service.NewAuthMiddleware(service.AddressHandler)))
+	http.HandleFunc("/api/user/patient", service.NewLogMiddleware(service.NewAuthMiddleware(service.PatientHandler)))
+	http.HandleFunc("/api/user/data-export", service.NewLogMiddleware(service.NewAuthMiddleware(service.ExportUserDataHandler)))
+	http.HandleFunc("/api/user/account-deletion", service.NewLogMiddleware(service.NewAuthMiddleware(service.GetAccountDeletionStatusHandler)))
+	http.HandleFunc("/api/user/account-deletion/request", service.NewLogMiddleware(service.NewAuthMiddleware(service.RequestAccountDeletionHandler)))
+	http.HandleFunc("/api/user/account-deletion/cancel", service.NewLogMiddleware(service.NewAuthMiddleware(service.CancelAccountDeletionHandler)))
 
 	// 服务相关接口
 	http.HandleFunc("/api/service/list", service.NewLogMiddleware(service.ServiceListHandler))
@@ -52,41 +76,56 @@ func main() {
 	http.HandleFunc("/api/service/form_config/", service.NewLogMiddleware(service.ServiceFormConfigHandler))
 
 	// 订单相关接口
-	http.HandleFunc("/api/order/submit", service.NewLogMiddleware(service.SubmitOrderHandler))
-	http.HandleFunc("/api/order/pay/", service.NewLogMiddleware(service.PayOrderHandler))
-	http.HandleFunc("/api/order/pay_confirm/", service.NewLogMiddleware(service.PayConfirmHandler))
-	http.HandleFunc("/api/order/cancel/", service.NewLogMiddleware(service.CancelOrderHandler))
-	http.HandleFunc("/api/order/refund/", service.NewLogMiddleware(service.RefundOrderHandler))
-	http.HandleFunc("/api/order/list", service.NewLogMiddleware(service.OrderListHandler))
-	http.HandleFunc("/api/order/detail", service.NewLogMiddleware(service.OrderDetailHandler))
+	http.HandleFunc("/api/order/submit", service.NewLogMiddleware(service.NewAuthMiddleware(service.NewIdempotencyMiddleware(service.IdempotencyScopeOrderSubmit, service.SubmitOrderHandler))))
+	http.HandleFunc("/api/order/pay/", service.NewLogMiddleware(service.NewAuthMiddleware(service.NewIdempotencyMiddleware(service.IdempotencyScopeOrderPay, service.PayOrderHandler))))
+	http.HandleFunc("/api/order/pay_confirm/", service.NewLogMiddleware(service.NewAuthMiddleware(service.PayConfirmHandler)))
+	http.HandleFunc("/api/order/cancel/", service.NewLogMiddleware(service.NewAuthMiddleware(service.CancelOrderHandler)))
+	http.HandleFunc("/api/order/refund/", service.NewLogMiddleware(service.NewAuthMiddleware(service.RefundOrderHandler)))
+	http.HandleFunc("/api/order/list", service.NewLogMiddleware(service.NewAuthMiddleware(service.OrderListHandler)))
+	http.HandleFunc("/api/order/detail", service.NewLogMiddleware(service.NewAuthMiddleware(service.OrderDetailHandler)))
+	http.HandleFunc("/api/order/timeline", service.NewLogMiddleware(service.NewAuthMiddleware(service.OrderTimelineHandler)))
+	http.HandleFunc("/api/order/reschedule", service.NewLogMiddleware(service.NewAuthMiddleware(service.RescheduleOrderHandler)))
+	http.HandleFunc("/api/order/reschedules", service.NewLogMiddleware(service.NewAuthMiddleware(service.OrderReschedulesHandler)))
+	http.HandleFunc("/api/order/refund-quote", service.NewLogMiddleware(service.NewAuthMiddleware(service.OrderRefundQuoteHandler)))
 	http.HandleFunc("/api/order/time_slots", service.NewLogMiddleware(service.GetAvailableTimeSlotsHandler))
 
+	// 护理员端接口
+	http.HandleFunc("/api/caregiver/jobs", service.NewLogMiddleware(service.NewAuthMiddleware(service.CaregiverJobsHandler)))
+	http.HandleFunc("/api/caregiver/job", service.NewLogMiddleware(service.NewAuthMiddleware(service.CaregiverJobDetailHandler)))
+	http.HandleFunc("/api/caregiver/jobs/accept", service.NewLogMiddleware(service.NewAuthMiddleware(service.CaregiverAcceptJobHandler)))
+	http.HandleFunc("/api/caregiver/jobs/decline", service.NewLogMiddleware(service.NewAuthMiddleware(service.CaregiverDeclineJobHandler)))
+	http.HandleFunc("/api/caregiver/jobs/check-in", service.NewLogMiddleware(service.NewAuthMiddleware(service.CaregiverCheckInHandler)))
+	http.HandleFunc("/api/caregiver/jobs/check-out", service.NewLogMiddleware(service.NewAuthMiddleware(service.CaregiverCheckOutHandler)))
+	http.HandleFunc("/api/caregiver/jobs/record", service.NewLogMiddleware(service.NewAuthMiddleware(service.CaregiverServiceRecordHandler)))
+	http.HandleFunc("/api/caregiver/jobs/complete", service.NewLogMiddleware(service.NewAuthMiddleware(service.CaregiverCompleteJobHandler)))
+
 	// 支付相关接口
-	http.HandleFunc("/api/payment/notify", service.NewLogMiddleware(service.HandleWechatPayNotify))
+	http.HandleFunc("/api/payment/notify", service.NewLogMiddleware(service.PaymentNotifyHandler))
+	http.HandleFunc("/api/admin/payment/mock-notify", service.NewLogMiddleware(service.NewAdminPermissionMiddleware(service.PermSystemMaintain, service.MockPaymentNotifyHandler)))
 
-	// 订单超时相关接口
-	http.HandleFunc("/api/order/check_expired", service.NewLogMiddleware(service.CheckExpiredOrdersHandler))
-	http.HandleFunc("/api/order/expired_count", service.NewLogMiddleware(service.GetExpiredOrdersCountHandler))
+	// 订单超时相关接口（运维接口，仅超级管理员）
+	http.HandleFunc("/api/order/check_expired", service.NewLogMiddleware(service.NewAdminPermissionMiddleware(service.PermSystemMaintain, service.CheckExpiredOrdersHandler)))
+	http.HandleFunc("/api/order/expired_count", service.NewLogMiddleware(service.NewAdminPermissionMiddleware(service.PermSystemMaintain, service.GetExpiredOrdersCountHandler)))
 
 	// 推荐相关接口
-	http.HandleFunc("/api/referral/qrcode", service.NewLogMiddleware(service.ReferralQrCodeHandler))
-	http.HandleFunc("/api/referral/report", service.NewLogMiddleware(service.ReferralReportHandler))
+	http.HandleFunc("/api/referral/qrcode", service.NewLogMiddleware(service.NewAuthMiddleware(service.ReferralQrCodeHandler)))
+	http.HandleFunc("/api/referral/report", service.NewLogMiddleware(service.NewAuthMiddleware(service.ReferralReportHandler)))
 	http.HandleFunc("/api/referral/config", service.NewLogMiddleware(service.ReferralConfigHandler))
-	http.HandleFunc("/api/referral/apply_cashout", service.NewLogMiddleware(service.ApplyCashoutHandler))
+	http.HandleFunc("/api/referral/apply_cashout", service.NewLogMiddleware(service.NewAuthMiddleware(service.ApplyCashoutHandler)))
 
 	// 推广中心相关接口
-	http.HandleFunc("/api/promoter/info", service.NewLogMiddleware(service.GetPromoterInfoHandler))
-	http.HandleFunc("/api/promoter/commission_list", service.NewLogMiddleware(service.GetCommissionListHandler))
-	http.HandleFunc("/api/promoter/cashout_list", service.NewLogMiddleware(service.GetCashoutListHandler))
+	http.HandleFunc("/api/promoter/info", service.NewLogMiddleware(service.NewAuthMiddleware(service.GetPromoterInfoHandler)))
+	http.HandleFunc("/api/promoter/commission_list", service.NewLogMiddleware(service.NewAuthMiddleware(service.GetCommissionListHandler)))
+	http.HandleFunc("/api/promoter/cashout_list", service.NewLogMiddleware(service.NewAuthMiddleware(service.GetCashoutListHandler)))
 	http.HandleFunc("/api/promoter/find_user", service.NewLogMiddleware(service.GetUserByPromoterCodeHandler))
-	http.HandleFunc("/api/promoter/generate_codes", service.NewLogMiddleware(service.GeneratePromoterCodesHandler))
+	http.HandleFunc("/api/promoter/generate_codes", service.NewLogMiddleware(service.NewAdminPermissionMiddleware(service.PermSystemMaintain, service.GeneratePromoterCodesHandler)))
 
 	// 二维码相关接口
 	http.HandleFunc("/api/qrcode/generate", service.NewLogMiddleware(service.GenerateQRCodeHandler))
 	http.HandleFunc("/api/qrcode/generate_base64", service.NewLogMiddleware(service.GenerateQRCodeBase64Handler))
 
 	// 客服相关接口
-	http.HandleFunc("/api/kefu/send_msg", service.NewLogMiddleware(service.SendMessageHandler))
+	http.HandleFunc("/api/kefu/send_msg", service.NewLogMiddleware(service.NewAuthMiddleware(service.SendMessageHandler)))
 	http.HandleFunc("/api/kefu/faq", service.NewLogMiddleware(service.FaqHandler))
 
 	// 医院相关接口
@@ -96,43 +135,82 @@ func main() {
 	// SSE路由（替代WebSocket）
 	http.HandleFunc("/sse", service.NewLogMiddleware(service.SSEHandler))
 
-	// 迁移服务相关接口
-	http.HandleFunc("/api/migration/generate_user_ids", service.NewLogMiddleware(service.GenerateUserIdHandler))
-	http.HandleFunc("/api/migration/migrate_users", service.NewLogMiddleware(service.MigrateUsersHandler))
-	http.HandleFunc("/api/migration/migrate_all_tables", service.NewLogMiddleware(service.MigrateAllTablesUserIdHandler))
-	http.HandleFunc("/api/migration/validate", service.NewLogMiddleware(service.ValidateUserIdsHandler))
+	// 迁移服务相关接口（运维接口，仅超级管理员）
+	http.HandleFunc("/api/migration/generate_user_ids", service.NewLogMiddleware(service.NewAdminPermissionMiddleware(service.PermSystemMaintain, service.GenerateUserIdHandler)))
+	http.HandleFunc("/api/migration/migrate_users", service.NewLogMiddleware(service.NewAdminPermissionMiddleware(service.PermSystemMaintain, service.MigrateUsersHandler)))
+	http.HandleFunc("/api/migration/migrate_all_tables", service.NewLogMiddleware(service.NewAdminPermissionMiddleware(service.PermSystemMaintain, service.MigrateAllTablesUserIdHandler)))
+	http.HandleFunc("/api/migration/validate", service.NewLogMiddleware(service.NewAdminPermissionMiddleware(service.PermSystemMaintain, service.ValidateUserIdsHandler)))
 
-	// 紧急修复相关接口
-	http.HandleFunc("/api/emergency/fix_user_ids", service.NewLogMiddleware(service.EmergencyFixUserIdsHandler))
-	http.HandleFunc("/api/emergency/test_user_info", service.NewLogMiddleware(service.TestUserInfoHandler))
-	http.HandleFunc("/api/emergency/user_status", service.NewLogMiddleware(service.GetUserStatusHandler))
+	// 紧急修复相关接口（运维接口，仅超级管理员）
+	http.HandleFunc("/api/emergency/fix_user_ids", service.NewLogMiddleware(service.NewAdminPermissionMiddleware(service.PermSystemMaintain, service.EmergencyFixUserIdsHandler)))
+	http.HandleFunc("/api/emergency/user_status", service.NewLogMiddleware(service.NewAdminPermissionMiddleware(service.PermSystemMaintain, service.GetUserStatusHandler)))
 
 	// 管理员相关接口
 	http.HandleFunc("/api/admin/login", service.NewLogMiddleware(service.AdminLoginHandler))
-	http.HandleFunc("/api/admin/check-status", service.NewLogMiddleware(service.CheckAdminStatusHandler))
-	http.HandleFunc("/api/admin/users", service.NewLogMiddleware(service.GetAdminUsersHandler))
-	http.HandleFunc("/api/admin/orders", service.NewLogMiddleware(service.GetAdminOrdersHandler))
-	http.HandleFunc("/api/admin/set-admin", service.NewLogMiddleware(service.SetAdminHandler))
-	http.HandleFunc("/api/admin/remove-admin", service.NewLogMiddleware(service.RemoveAdminHandler))
-	http.HandleFunc("/api/admin/stats", service.NewLogMiddleware(service.AdminStatsHandler))
-	http.HandleFunc("/api/admin/admins", service.NewLogMiddleware(service.AdminAdminsHandler))
-	http.HandleFunc("/api/admin/order/update-amount", service.NewLogMiddleware(service.UpdateOrderAmountHandler))
-	http.HandleFunc("/api/admin/order/refund", service.NewLogMiddleware(service.AdminRefundOrderHandler))
+	http.HandleFunc("/api/admin/check-status", service.NewLogMiddleware(service.NewAuthMiddleware(service.CheckAdminStatusHandler)))
+	http.HandleFunc("/api/admin/users", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetAdminUsersHandler)))
+	http.HandleFunc("/api/admin/orders", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetAdminOrdersHandler)))
+	http.HandleFunc("/api/admin/set-admin", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.SetAdminHandler)))
+	http.HandleFunc("/api/admin/remove-admin", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.RemoveAdminHandler)))
+	http.HandleFunc("/api/admin/stats", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.AdminStatsHandler)))
+	http.HandleFunc("/api/admin/admins", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.AdminAdminsHandler)))
+	http.HandleFunc("/api/admin/roles", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetAdminRolesHandler)))
+	http.HandleFunc("/api/admin/roles/update", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.UpdateAdminRolesHandler)))
+	http.HandleFunc("/api/admin/users/duplicates", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetUserDuplicatesHandler)))
+	http.HandleFunc("/api/admin/users/merge", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.MergeUsersHandler)))
+	http.HandleFunc("/api/admin/users/merge-logs", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetUserMergeLogsHandler)))
+	http.HandleFunc("/api/admin/account-deletions", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetAccountDeletionRequestsHandler)))
+	http.HandleFunc("/api/admin/account-deletions/review", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.ReviewAccountDeletionHandler)))
+	http.HandleFunc("/api/admin/order/update-amount", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.NewAdminStepUpMiddleware(service.UpdateOrderAmountHandler))))
+	http.HandleFunc("/api/admin/order/refund", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.NewAdminStepUpMiddleware(service.AdminRefundOrderHandler))))
+	http.HandleFunc("/api/admin/order/timeline", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.AdminOrderTimelineHandler)))
+	http.HandleFunc("/api/admin/order/reschedule", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.AdminRescheduleOrderHandler)))
+	http.HandleFunc("/api/admin/order/reschedules", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.AdminOrderReschedulesHandler)))
+	http.HandleFunc("/api/admin/order/refund-quote", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.AdminOrderRefundQuoteHandler)))
+	http.HandleFunc("/api/admin/password/change", service.NewLogMiddleware(service.NewAuthMiddleware(service.ChangeAdminPasswordHandler)))
+	http.HandleFunc("/api/admin/password/reset", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.ResetAdminPasswordHandler)))
+	http.HandleFunc("/api/admin/login-locks", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetAdminLoginLocksHandler)))
+	http.HandleFunc("/api/admin/login-locks/clear", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.ClearAdminLoginLockHandler)))
+	http.HandleFunc("/api/admin/2fa/status", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetAdminTotpStatusHandler)))
+	http.HandleFunc("/api/admin/2fa/setup", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.SetupAdminTotpHandler)))
+	http.HandleFunc("/api/admin/2fa/enable", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.EnableAdminTotpHandler)))
+	http.HandleFunc("/api/admin/2fa/disable", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.DisableAdminTotpHandler)))
+	http.HandleFunc("/api/admin/2fa/recovery-codes", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.RegenerateAdminRecoveryCodesHandler)))
+	http.HandleFunc("/api/admin/2fa/step-up", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.AdminStepUpHandler)))
 
 	// 管理员服务管理相关接口
-	http.HandleFunc("/api/admin/services", service.NewLogMiddleware(service.GetAdminServicesHandler))
-	http.HandleFunc("/api/admin/service/update-price", service.NewLogMiddleware(service.UpdateServicePriceHandler))
+	http.HandleFunc("/api/admin/services", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetAdminServicesHandler)))
+	http.HandleFunc("/api/admin/service/update-price", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.UpdateServicePriceHandler)))
+	http.HandleFunc("/api/admin/service/form-config", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.PublishFormConfigHandler)))
+	http.HandleFunc("/api/admin/service/form-configs", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetFormConfigVersionsHandler)))
+	http.HandleFunc("/api/admin/time-slots", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetAdminTimeSlotsHandler)))
+	http.HandleFunc("/api/admin/time-slots/capacity", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.SetSlotCapacityHandler)))
+	http.HandleFunc("/api/admin/slot-templates", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetSlotTemplatesHandler)))
+	http.HandleFunc("/api/admin/slot-templates/save", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.SaveSlotTemplateHandler)))
+	http.HandleFunc("/api/admin/slot-templates/delete", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.DeleteSlotTemplateHandler)))
+	http.HandleFunc("/api/admin/cancellation-policies", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetCancellationPoliciesHandler)))
+	http.HandleFunc("/api/admin/cancellation-policies/save", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.SaveCancellationPolicyHandler)))
+	http.HandleFunc("/api/admin/cancellation-policies/delete", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.DeleteCancellationPolicyHandler)))
+	http.HandleFunc("/api/admin/booking-blackouts", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetBlackoutsHandler)))
+	http.HandleFunc("/api/admin/booking-blackouts/create", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.CreateBlackoutHandler)))
+	http.HandleFunc("/api/admin/booking-blackouts/delete", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.DeleteBlackoutHandler)))
+
+	// 管理员护理员和派单相关接口
+	http.HandleFunc("/api/admin/caregivers", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.AdminCaregiversHandler)))
+	http.HandleFunc("/api/admin/caregivers/save", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.SaveCaregiverHandler)))
+	http.HandleFunc("/api/admin/dispatch/candidates", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.DispatchCandidatesHandler)))
+	http.HandleFunc("/api/admin/dispatch", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.DispatchOrderHandler)))
 
 	// 咨询相关接口
-	http.HandleFunc("/api/consultation/create", service.NewLogMiddleware(service.CreateConsultationHandler))
-	http.HandleFunc("/api/consultation/messages", service.NewLogMiddleware(service.GetConsultationMessagesHandler))
-	http.HandleFunc("/api/consultation/send", service.NewLogMiddleware(service.SendConsultationMessageHandler))
-	http.HandleFunc("/api/consultation/status", service.NewLogMiddleware(service.GetConsultationStatusHandler))
-	http.HandleFunc("/api/consultation/close", service.NewLogMiddleware(service.CloseConsultationHandler))
-	http.HandleFunc("/api/consultation/active", service.NewLogMiddleware(service.GetActiveConsultationsHandler))
-	http.HandleFunc("/api/consultation/stats", service.NewLogMiddleware(service.GetConsultationStatsHandler))
-	http.HandleFunc("/api/consultation/notifications", service.NewLogMiddleware(service.GetUnreadNotificationsHandler))
-	http.HandleFunc("/api/consultation/notification/read", service.NewLogMiddleware(service.MarkNotificationAsReadHandler))
+	http.HandleFunc("/api/consultation/create", service.NewLogMiddleware(service.NewAuthMiddleware(service.CreateConsultationHandler)))
+	http.HandleFunc("/api/consultation/messages", service.NewLogMiddleware(service.NewAuthMiddleware(service.GetConsultationMessagesHandler)))
+	http.HandleFunc("/api/consultation/send", service.NewLogMiddleware(service.NewAuthMiddleware(service.SendConsultationMessageHandler)))
+	http.HandleFunc("/api/consultation/status", service.NewLogMiddleware(service.NewAuthMiddleware(service.GetConsultationStatusHandler)))
+	http.HandleFunc("/api/consultation/close", service.NewLogMiddleware(service.NewAuthMiddleware(service.CloseConsultationHandler)))
+	http.HandleFunc("/api/consultation/active", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetActiveConsultationsHandler)))
+	http.HandleFunc("/api/consultation/stats", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetConsultationStatsHandler)))
+	http.HandleFunc("/api/consultation/notifications", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.GetUnreadNotificationsHandler)))
+	http.HandleFunc("/api/consultation/notification/read", service.NewLogMiddleware(service.NewAdminAuthMiddleware(service.MarkNotificationAsReadHandler)))
 
 	log.Fatal(http.ListenAndServe(":80", nil))
 }
@@ -4,6 +4,8 @@
 # 使用方法: ./emergency_fix.sh [base_url]
 
 BASE_URL=${1:-"https://prod-5g94mx7a3d07e78c.service.tcloudbase.com"}
+# 运维接口需要超级管理员登录令牌（/api/admin/login 返回的accessToken）
+ADMIN_TOKEN=${ADMIN_TOKEN:-""}
 echo "🚨 紧急修复UserId问题"
 echo "使用基础URL: $BASE_URL"
 
@@ -38,7 +40,7 @@ check_service() {
 get_user_status() {
     print_message $BLUE "📊 获取用户状态..."
     
-    response=$(curl -s -X GET "$BASE_URL/api/emergency/user_status")
+    response=$(curl -s -X GET -H "Authorization: Bearer $ADMIN_TOKEN" "$BASE_URL/api/emergency/user_status")
     echo "用户状态响应: $response"
     
     if echo "$response" | grep -q '"code":0'; then
@@ -54,7 +56,7 @@ get_user_status() {
 fix_user_ids() {
     print_message $BLUE "🔧 执行紧急修复..."
     
-    response=$(curl -s -X POST "$BASE_URL/api/emergency/fix_user_ids")
+    response=$(curl -s -X POST -H "Authorization: Bearer $ADMIN_TOKEN" "$BASE_URL/api/emergency/fix_user_ids")
     echo "修复响应: $response"
     
     if echo "$response" | grep -q '"code":0'; then
@@ -66,22 +68,6 @@ fix_user_ids() {
     fi
 }
 
-# 测试用户信息API
-test_user_info() {
-    print_message $BLUE "🧪 测试用户信息API..."
-    
-    response=$(curl -s -X GET "$BASE_URL/api/emergency/test_user_info?userId=1")
-    echo "用户信息API响应: $response"
-    
-    if echo "$response" | grep -q '"code":0'; then
-        print_message $GREEN "✅ 用户信息API测试成功"
-        return 0
-    else
-        print_message $YELLOW "⚠️  用户信息API仍然有问题"
-        return 1
-    fi
-}
-
 # 显示修复结果
 show_results() {
     echo ""
@@ -122,9 +108,6 @@ main() {
         exit 1
     fi
     
-    # 测试修复效果
-    test_user_info
-    
     # 显示结果
     show_results
     
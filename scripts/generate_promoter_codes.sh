@@ -4,6 +4,8 @@
 # 为现有用户生成六位随机推广码
 
 BASE_URL=${1:-"https://prod-5g94mx7a3d07e78c.service.tcloudbase.com"}
+# 运维接口需要超级管理员登录令牌（/api/admin/login 返回的accessToken）
+ADMIN_TOKEN=${ADMIN_TOKEN:-""}
 echo "🔧 生成推广码脚本"
 echo "使用基础URL: $BASE_URL"
 
@@ -38,7 +40,7 @@ check_service() {
 generate_promoter_codes() {
     print_message $BLUE "🔧 生成推广码..."
     
-    response=$(curl -s -X POST "$BASE_URL/api/emergency/generate_promoter_codes")
+    response=$(curl -s -X POST -H "Authorization: Bearer $ADMIN_TOKEN" "$BASE_URL/api/promoter/generate_codes")
     echo "生成推广码响应: $response"
     
     if echo "$response" | grep -q '"code":0'; then
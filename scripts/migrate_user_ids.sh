@@ -4,6 +4,8 @@
 # 使用方法: ./migrate_user_ids.sh [base_url]
 
 BASE_URL=${1:-"http://localhost:80"}
+# 运维接口需要超级管理员登录令牌（/api/admin/login 返回的accessToken）
+ADMIN_TOKEN=${ADMIN_TOKEN:-""}
 echo "使用基础URL: $BASE_URL"
 
 # 颜色定义
@@ -38,7 +40,7 @@ test_connection() {
 test_generate_user_id() {
     print_message $BLUE "🧪 测试UserId生成..."
     
-    response=$(curl -s -X POST "$BASE_URL/api/migration/generate_user_ids")
+    response=$(curl -s -X POST -H "Authorization: Bearer $ADMIN_TOKEN" "$BASE_URL/api/migration/generate_user_ids")
     echo "响应: $response"
     
     # 检查响应
@@ -55,7 +57,7 @@ test_generate_user_id() {
 migrate_users() {
     print_message $BLUE "🔄 开始迁移用户UserId..."
     
-    response=$(curl -s -X POST "$BASE_URL/api/migration/migrate_users")
+    response=$(curl -s -X POST -H "Authorization: Bearer $ADMIN_TOKEN" "$BASE_URL/api/migration/migrate_users")
     echo "响应: $response"
     
     # 检查响应
@@ -72,7 +74,7 @@ migrate_users() {
 migrate_all_tables() {
     print_message $BLUE "🔄 开始迁移所有表的UserId..."
     
-    response=$(curl -s -X POST "$BASE_URL/api/migration/migrate_all_tables")
+    response=$(curl -s -X POST -H "Authorization: Bearer $ADMIN_TOKEN" "$BASE_URL/api/migration/migrate_all_tables")
     echo "响应: $response"
     
     # 检查响应
@@ -89,7 +91,7 @@ migrate_all_tables() {
 validate_user_ids() {
     print_message $BLUE "🔍 验证UserId..."
     
-    response=$(curl -s -X GET "$BASE_URL/api/migration/validate")
+    response=$(curl -s -X GET -H "Authorization: Bearer $ADMIN_TOKEN" "$BASE_URL/api/migration/validate")
     echo "响应: $response"
     
     # 检查响应
@@ -4,6 +4,8 @@
 # 使用方法: ./quick_fix_user_id.sh [base_url]
 
 BASE_URL=${1:-"http://localhost:80"}
+# 运维接口需要超级管理员登录令牌（/api/admin/login 返回的accessToken）
+ADMIN_TOKEN=${ADMIN_TOKEN:-""}
 echo "🔧 快速修复UserId问题"
 echo "使用基础URL: $BASE_URL"
 
@@ -38,7 +40,7 @@ check_service() {
 migrate_users() {
     print_message $BLUE "🔄 执行用户UserId迁移..."
     
-    response=$(curl -s -X POST "$BASE_URL/api/migration/migrate_users")
+    response=$(curl -s -X POST -H "Authorization: Bearer $ADMIN_TOKEN" "$BASE_URL/api/migration/migrate_users")
     echo "迁移响应: $response"
     
     if echo "$response" | grep -q '"code":0'; then
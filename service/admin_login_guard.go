@@ -0,0 +1,234 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+const (
+	loginLockTypeAccount = "account" // 按账号锁定
+	loginLockTypeIp      = "ip"      // 按IP锁定
+)
+
+// ClearAdminLoginLockRequest 解除登录锁定请求
+type ClearAdminLoginLockRequest struct {
+	Id int32 `json:"id"`
+}
+
+// getActiveAdminLoginLock 检查账号或IP是否处于锁定期
+func getActiveAdminLoginLock(username, ip string) *model.AdminLoginLockModel {
+	adminImp := &dao.AdminImp{}
+	if lock, err := adminImp.GetActiveLoginLock(loginLockTypeAccount, username); err == nil && lock != nil {
+		return lock
+	}
+	if lock, err := adminImp.GetActiveLoginLock(loginLockTypeIp, ip); err == nil && lock != nil {
+		return lock
+	}
+	return nil
+}
+
+// recordAdminLoginFailure 记录登录失败日志和失败次数，达到阈值时锁定并通知超级管理员，返回本次失败应延迟响应的时长
+func recordAdminLoginFailure(r *http.Request, username, adminUserId, remark string) time.Duration {
+	adminImp := &dao.AdminImp{}
+	authConfig := config.GetAuthConfig()
+	ip := getClientIp(r)
+
+	adminImp.LogAdminLogin(&model.AdminLoginLogModel{
+		AdminUserId: adminUserId,
+		Username:    username,
+		LoginTime:   time.Now(),
+		LoginIp:     ip,
+		UserAgent:   r.UserAgent(),
+		Status:      0,
+		Remark:      remark,
+	})
+
+	maxFailedCount := 0
+	counters := []struct {
+		lockType    string
+		lockKey     string
+		maxFailures int
+	}{
+		{loginLockTypeAccount, username, authConfig.LoginMaxAccountFailures},
+		{loginLockTypeIp, ip, authConfig.LoginMaxIpFailures},
+	}
+	for _, counter := range counters {
+		lock, err := adminImp.RecordLoginFailure(counter.lockType, counter.lockKey, authConfig.LoginFailureWindow)
+		if err != nil {
+			LogError("记录登录失败次数失败", err)
+			continue
+		}
+		if lock.FailedCount > maxFailedCount {
+			maxFailedCount = lock.FailedCount
+		}
+		if counter.maxFailures > 0 && lock.FailedCount >= counter.maxFailures {
+			lockAdminLogin(lock, username, ip)
+		}
+	}
+
+	return adminLoginFailureDelay(maxFailedCount)
+}
+
+// lockAdminLogin 锁定账号或IP，锁定时长随累计锁定次数翻倍
+func lockAdminLogin(lock *model.AdminLoginLockModel, username, ip string) {
+	authConfig := config.GetAuthConfig()
+
+	duration := authConfig.LoginLockDuration
+	for i := 0; i < lock.LockCount && duration < authConfig.LoginMaxLockDuration; i++ {
+		duration *= 2
+	}
+	if duration > authConfig.LoginMaxLockDuration {
+		duration = authConfig.LoginMaxLockDuration
+	}
+	lockedUntil := time.Now().Add(duration)
+
+	adminImp := &dao.AdminImp{}
+	if err := adminImp.LockLogin(lock.Id, lockedUntil); err != nil {
+		LogError("锁定管理员登录失败", err)
+		return
+	}
+
+	alert := map[string]interface{}{
+		"lockId":      lock.Id,
+		"lockType":    lock.LockType,
+		"lockKey":     lock.LockKey,
+		"failedCount": lock.FailedCount,
+		"lockedUntil": lockedUntil,
+		"username":    username,
+		"ip":          ip,
+	}
+	LogInfo("管理员登录已锁定", alert)
+	SendSSEMessageToSuperAdmins("adminLoginLocked", alert)
+}
+
+// resetAdminLoginFailures 登录成功后清零账号和IP的失败次数
+func resetAdminLoginFailures(username, ip string) {
+	adminImp := &dao.AdminImp{}
+	if err := adminImp.ResetLoginFailures(loginLockTypeAccount, username); err != nil {
+		LogError("清零账号登录失败次数失败", err)
+	}
+	if err := adminImp.ResetLoginFailures(loginLockTypeIp, ip); err != nil {
+		LogError("清零IP登录失败次数失败", err)
+	}
+}
+
+// adminLoginFailureDelay 失败响应延迟：基础延迟 × 2^(失败次数-1)，最长8秒
+func adminLoginFailureDelay(failedCount int) time.Duration {
+	const maxDelay = 8 * time.Second
+	if failedCount <= 0 {
+		return 0
+	}
+	delay := config.GetAuthConfig().LoginFailureDelay
+	for i := 1; i < failedCount && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// GetAdminLoginLocksHandler 查看当前被锁定的账号和IP，以及最近24小时的登录失败记录（需要admin.manage权限）
+func GetAdminLoginLocksHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理获取登录锁定列表请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodGet {
+		LogError("请求方法不支持", fmt.Errorf("期望GET方法，实际为%s", r.Method))
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdminPermission(w, r, PermAdminManage) {
+		return
+	}
+
+	adminImp := &dao.AdminImp{}
+	locks, err := adminImp.GetActiveLoginLocks()
+	if err != nil {
+		LogError("获取登录锁定列表失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	failedLogins, err := adminImp.GetRecentFailedLogins(time.Now().Add(-24*time.Hour), 100)
+	if err != nil {
+		LogError("获取登录失败记录失败", err)
+		failedLogins = []*model.AdminLoginLogModel{}
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"locks":        locks,
+			"failedLogins": failedLogins,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ClearAdminLoginLockHandler 解除账号或IP的登录锁定
+func ClearAdminLoginLockHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理解除登录锁定请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdminPermission(w, r, PermAdminManage) {
+		return
+	}
+	operator := GetAuthUser(r)
+
+	var req ClearAdminLoginLockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Id <= 0 {
+		LogError("解析请求体失败", err)
+		http.Error(w, "缺少锁定记录ID", http.StatusBadRequest)
+		return
+	}
+
+	adminImp := &dao.AdminImp{}
+	if err := adminImp.ClearLoginLock(req.Id); err != nil {
+		LogError("解除登录锁定失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogStep("解除登录锁定成功", map[string]interface{}{
+		"lockId":     req.Id,
+		"operatorId": operator.UserId,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"message": "已解除锁定",
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+	"wxcloudrun-golang/utils"
+)
+
+// ChangeAdminPasswordRequest 修改管理员密码请求
+type ChangeAdminPasswordRequest struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+// ResetAdminPasswordRequest 重置管理员密码请求
+type ResetAdminPasswordRequest struct {
+	UserId string `json:"userId"` // 被重置的管理员用户ID
+}
+
+// ValidateAdminPassword 按密码策略校验管理员密码（长度、字符类型、不得包含用户名）
+func ValidateAdminPassword(password, username string) error {
+	authConfig := config.GetAuthConfig()
+
+	if len([]rune(password)) < authConfig.PasswordMinLength {
+		return fmt.Errorf("密码长度不能少于%d位", authConfig.PasswordMinLength)
+	}
+	if len(password) > utils.PasswordMaxLength {
+		return fmt.Errorf("密码长度不能超过%d个字节", utils.PasswordMaxLength)
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSpecial bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsSpace(c):
+			return fmt.Errorf("密码不能包含空白字符")
+		default:
+			hasSpecial = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{hasLower, hasUpper, hasDigit, hasSpecial} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < authConfig.PasswordMinClasses {
+		return fmt.Errorf("密码需至少包含大写字母、小写字母、数字、特殊字符中的%d种", authConfig.PasswordMinClasses)
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return fmt.Errorf("密码不能包含用户名")
+	}
+	return nil
+}
+
+// hashNewAdminPassword 校验密码策略并生成密码哈希
+func hashNewAdminPassword(password, username string) (string, error) {
+	if err := ValidateAdminPassword(password, username); err != nil {
+		return "", err
+	}
+	return utils.HashPassword(password)
+}
+
+// isAdminPasswordExpired 判断管理员是否需要修改密码（被要求修改或超过有效期）
+func isAdminPasswordExpired(admin *model.UserModel) bool {
+	if admin.AdminPasswordMustChange == 1 {
+		return true
+	}
+
+	maxAgeDays := config.GetAuthConfig().PasswordMaxAgeDays
+	if maxAgeDays <= 0 {
+		return false
+	}
+	if admin.AdminPasswordUpdatedAt == nil {
+		return true
+	}
+	return time.Since(*admin.AdminPasswordUpdatedAt) > time.Duration(maxAgeDays)*24*time.Hour
+}
+
+// ChangeAdminPasswordHandler 管理员修改自己的密码
+func ChangeAdminPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理修改管理员密码请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ChangeAdminPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		LogError("解析请求体失败", err)
+		http.Error(w, "请求体格式错误", http.StatusBadRequest)
+		return
+	}
+
+	// 密码过期的管理员也需要能调用本接口，因此这里单独校验管理员身份
+	admin := GetAuthUser(r)
+	if !IsAdminSession(r) {
+		writeAuthError(w, "请使用管理员账号登录")
+		return
+	}
+	if admin.IsAdmin != 1 {
+		LogError("修改管理员密码失败", fmt.Errorf("用户不是管理员: %s", admin.UserId))
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "无管理员权限",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if req.OldPassword == "" || req.NewPassword == "" {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "原密码和新密码不能为空",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if !utils.VerifyPassword(req.OldPassword, admin.AdminPassword) {
+		LogError("修改管理员密码失败", fmt.Errorf("原密码错误: %s", admin.UserId))
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "原密码错误",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if req.NewPassword == req.OldPassword {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "新密码不能与原密码相同",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	passwordHash, err := hashNewAdminPassword(req.NewPassword, admin.AdminUsername)
+	if err != nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	adminImp := &dao.AdminImp{}
+	if err := adminImp.UpdateAdminPassword(admin.UserId, passwordHash, 0); err != nil {
+		LogError("更新管理员密码失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "修改密码失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 修改密码后其他设备上的登录全部失效，保留当前会话
+	revoked, err := dao.AuthImp.RevokeOtherSessions(admin.UserId, GetAuthClaims(r).SessionId, "管理员修改密码")
+	if err != nil {
+		LogError("吊销其他会话失败", err)
+	}
+
+	LogStep("管理员密码修改成功", map[string]interface{}{
+		"userId":          admin.UserId,
+		"revokedSessions": revoked,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"message": "密码修改成功",
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ResetAdminPasswordHandler 重置其他管理员的密码（需要admin.manage权限），返回一次性临时密码
+func ResetAdminPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理重置管理员密码请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdminPermission(w, r, PermAdminManage) {
+		return
+	}
+	operator := GetAuthUser(r)
+
+	var req ResetAdminPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserId == "" {
+		LogError("解析请求体失败", err)
+		http.Error(w, "缺少userId参数", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserId == operator.UserId {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "不能重置自己的密码，请使用修改密码功能",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	adminImp := &dao.AdminImp{}
+	target, err := adminImp.GetAdminByUserId(req.UserId)
+	if err != nil {
+		LogError("获取管理员信息失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "管理员不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if target.AdminLevel == 2 && operator.AdminLevel != 2 {
+		LogError("权限不足", fmt.Errorf("非超级管理员不能重置超级管理员密码: %s", target.UserId))
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "只有超级管理员可以重置超级管理员的密码",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	authConfig := config.GetAuthConfig()
+	tempLength := authConfig.PasswordMinLength + 2
+	tempPassword, err := utils.GenerateRandomPassword(tempLength)
+	if err == nil && ValidateAdminPassword(tempPassword, target.AdminUsername) != nil {
+		// 随机密码恰好包含用户名时重新生成一次
+		tempPassword, err = utils.GenerateRandomPassword(tempLength)
+	}
+	var passwordHash string
+	if err == nil {
+		passwordHash, err = utils.HashPassword(tempPassword)
+	}
+	if err != nil {
+		LogError("生成临时密码失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "重置密码失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 临时密码登录后必须立即修改
+	if err := adminImp.UpdateAdminPassword(target.UserId, passwordHash, 1); err != nil {
+		LogError("更新管理员密码失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "重置密码失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	revoked, err := dao.AuthImp.RevokeUserSessions(target.UserId, "超级管理员重置密码")
+	if err != nil {
+		LogError("吊销管理员会话失败", err)
+	}
+
+	LogStep("管理员密码重置成功", map[string]interface{}{
+		"operatorId":      operator.UserId,
+		"userId":          target.UserId,
+		"revokedSessions": revoked,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"userId":       target.UserId,
+			"tempPassword": tempPassword,
+			"message":      "密码已重置，该管理员使用临时密码登录后必须立即修改密码",
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
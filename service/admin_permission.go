@@ -0,0 +1,332 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// 管理员权限
+const (
+	PermUserView           = "user.view"            // 查看用户
+	PermUserMerge          = "user.merge"           // 合并重复账号（不属于任何内置角色，仅超级管理员）
+	PermUserDeletionReview = "user.deletion.review" // 审核账号注销申请
+	PermOrderView          = "order.view"           // 查看订单
+	PermOrderRefund        = "order.refund"         // 订单退款
+	PermOrderAmountUpdate  = "order.amount.update"  // 修改订单金额
+	PermOrderDispatch      = "order.dispatch"       // 派单、改派护理员
+	PermOrderReschedule    = "order.reschedule"     // 改约订单，可跳过改约规则
+	PermStatsView          = "stats.view"           // 查看营收统计
+	PermAdminView          = "admin.view"           // 查看管理员列表
+	PermAdminManage        = "admin.manage"         // 设置/取消管理员、重置密码、解除登录锁定
+	PermServiceView        = "service.view"         // 查看服务项目
+	PermServicePriceUpdate = "service.price.update" // 修改服务价格
+	PermServiceFormUpdate  = "service.form.update"  // 发布服务表单配置
+	PermSlotCapacityUpdate = "service.slot.update"  // 管理预约时间段（容量、模板、停约日期）
+	PermRefundPolicyUpdate = "refund.policy.update" // 管理取消退款政策
+	PermConsultationReply  = "consultation.reply"   // 处理在线咨询
+	PermCashoutApprove     = "cashout.approve"      // 审核提现
+	PermContentEdit        = "content.edit"         // 编辑首页、轮播图等内容
+	PermCaregiverView      = "caregiver.view"       // 查看护理员
+	PermCaregiverManage    = "caregiver.manage"     // 新建、修改护理员
+	PermSystemMaintain     = "system.maintain"      // 数据迁移、紧急修复、订单超时检查等运维接口（不属于任何内置角色，仅超级管理员）
+)
+
+// 管理员角色
+const (
+	RoleOperator        = "operator"         // 运营（未分配角色的一级管理员默认角色）
+	RoleFinance         = "finance"          // 财务
+	RoleDispatcher      = "dispatcher"       // 调度
+	RoleCustomerService = "customer_service" // 客服
+	RoleContentEditor   = "content_editor"   // 内容编辑
+)
+
+// AdminRole 管理员角色定义
+type AdminRole struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateAdminRolesRequest 修改管理员角色请求
+type UpdateAdminRolesRequest struct {
+	UserId string   `json:"userId"`
+	Roles  []string `json:"roles"`
+}
+
+// adminRoles 内置角色及其权限，超级管理员（AdminLevel=2）拥有全部权限
+var adminRoles = map[string]*AdminRole{
+	RoleOperator: {
+		Name:        RoleOperator,
+		Title:       "运营",
+		Permissions: []string{PermUserView, PermOrderView, PermStatsView, PermAdminView, PermServiceView, PermConsultationReply},
+	},
+	RoleFinance: {
+		Name:        RoleFinance,
+		Title:       "财务",
+		Permissions: []string{PermOrderView, PermOrderRefund, PermOrderAmountUpdate, PermStatsView, PermServiceView, PermServicePriceUpdate, PermRefundPolicyUpdate, PermCashoutApprove},
+	},
+	RoleDispatcher: {
+		Name:        RoleDispatcher,
+		Title:       "调度",
+		Permissions: []string{PermUserView, PermOrderView, PermOrderDispatch, PermOrderReschedule, PermServiceView, PermSlotCapacityUpdate, PermCaregiverView, PermCaregiverManage},
+	},
+	RoleCustomerService: {
+		Name:        RoleCustomerService,
+		Title:       "客服",
+		Permissions: []string{PermUserView, PermOrderView, PermOrderReschedule, PermConsultationReply, PermUserDeletionReview},
+	},
+	RoleContentEditor: {
+		Name:        RoleContentEditor,
+		Title:       "内容编辑",
+		Permissions: []string{PermServiceView, PermServiceFormUpdate, PermContentEdit},
+	},
+}
+
+// allAdminPermissions 全部权限，用于超级管理员
+var allAdminPermissions = []string{
+	PermUserView, PermUserMerge, PermUserDeletionReview, PermOrderView, PermOrderRefund,
+	PermOrderAmountUpdate, PermStatsView, PermAdminView, PermAdminManage, PermServiceView, PermServicePriceUpdate,
+	PermSlotCapacityUpdate, PermConsultationReply, PermCashoutApprove, PermContentEdit,
+	PermOrderDispatch, PermCaregiverView, PermCaregiverManage, PermOrderReschedule, PermRefundPolicyUpdate,
+	PermServiceFormUpdate, PermSystemMaintain,
+}
+
+// GetAdminRoleNames 解析管理员的角色列表，未分配角色的一级管理员视为运营角色
+func GetAdminRoleNames(admin *model.UserModel) []string {
+	var roles []string
+	for _, role := range strings.Split(admin.AdminRoles, ",") {
+		role = strings.TrimSpace(role)
+		if role != "" {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 && admin.AdminLevel != 2 {
+		roles = []string{RoleOperator}
+	}
+	return roles
+}
+
+// GetAdminPermissions 获取管理员拥有的全部权限（已排序）
+func GetAdminPermissions(admin *model.UserModel) []string {
+	if admin == nil || admin.IsAdmin != 1 {
+		return []string{}
+	}
+	if admin.AdminLevel == 2 {
+		return append([]string{}, allAdminPermissions...)
+	}
+
+	set := map[string]bool{}
+	for _, name := range GetAdminRoleNames(admin) {
+		if role, ok := adminRoles[name]; ok {
+			for _, perm := range role.Permissions {
+				set[perm] = true
+			}
+		}
+	}
+	permissions := make([]string, 0, len(set))
+	for perm := range set {
+		permissions = append(permissions, perm)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// HasAdminPermission 判断管理员是否拥有指定权限
+func HasAdminPermission(admin *model.UserModel, permission string) bool {
+	for _, perm := range GetAdminPermissions(admin) {
+		if perm == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAdminPermission 校验当前登录管理员的权限，无权限时写入403响应并返回false
+// 所有管理接口统一通过该方法鉴权，不再直接判断AdminLevel；只接受管理员账号密码登录的会话
+func requireAdminPermission(w http.ResponseWriter, r *http.Request, permission string) bool {
+	admin := GetAuthUser(r)
+	if hasAdminSessionPermission(r, permission) {
+		return true
+	}
+
+	adminUserId := ""
+	if admin != nil {
+		adminUserId = admin.UserId
+	}
+	LogError("管理员权限不足", fmt.Errorf("userId=%s, 需要权限%s", adminUserId, permission))
+	response := &AdminResponse{
+		Code:     -1,
+		ErrorMsg: "无权限执行该操作",
+		Data: map[string]interface{}{
+			"permission": permission,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(response)
+	return false
+}
+
+// NewAdminPermissionMiddleware 管理员鉴权并校验指定权限，用于处理函数内部不做权限校验的运维接口
+func NewAdminPermissionMiddleware(permission string, handler http.HandlerFunc) http.HandlerFunc {
+	return NewAdminAuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdminPermission(w, r, permission) {
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// normalizeAdminRoles 校验并去重角色列表，返回逗号分隔的存储格式
+func normalizeAdminRoles(roles []string) (string, error) {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, role := range roles {
+		role = strings.TrimSpace(role)
+		if role == "" || seen[role] {
+			continue
+		}
+		if _, ok := adminRoles[role]; !ok {
+			return "", fmt.Errorf("未知的角色: %s", role)
+		}
+		seen[role] = true
+		normalized = append(normalized, role)
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+// checkAdminGrant 校验操作人能否授予目标等级和角色：非超级管理员不能设置超级管理员，也不能授予自己没有的权限
+func checkAdminGrant(operator *model.UserModel, adminLevel int, roles []string) error {
+	if operator.AdminLevel == 2 {
+		return nil
+	}
+	if adminLevel == 2 {
+		return fmt.Errorf("只有超级管理员可以设置超级管理员")
+	}
+
+	target := &model.UserModel{IsAdmin: 1, AdminLevel: adminLevel, AdminRoles: strings.Join(roles, ",")}
+	for _, perm := range GetAdminPermissions(target) {
+		if !HasAdminPermission(operator, perm) {
+			return fmt.Errorf("不能授予自己没有的权限: %s", perm)
+		}
+	}
+	return nil
+}
+
+// GetAdminRolesHandler 获取可分配的角色及权限列表
+func GetAdminRolesHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理获取管理员角色列表请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodGet {
+		LogError("请求方法不支持", fmt.Errorf("期望GET方法，实际为%s", r.Method))
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdminPermission(w, r, PermAdminView) {
+		return
+	}
+
+	roles := make([]*AdminRole, 0, len(adminRoles))
+	for _, role := range adminRoles {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"roles":       roles,
+			"permissions": allAdminPermissions,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateAdminRolesHandler 修改已有管理员的角色
+func UpdateAdminRolesHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理修改管理员角色请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdminPermission(w, r, PermAdminManage) {
+		return
+	}
+
+	var req UpdateAdminRolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserId == "" {
+		LogError("解析请求体失败", err)
+		http.Error(w, "缺少userId参数", http.StatusBadRequest)
+		return
+	}
+
+	operator := GetAuthUser(r)
+	adminImp := &dao.AdminImp{}
+	target, err := adminImp.GetAdminByUserId(req.UserId)
+	if err != nil {
+		LogError("获取管理员信息失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "管理员不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	roles, err := normalizeAdminRoles(req.Roles)
+	if err == nil && target.AdminLevel == 2 && operator.AdminLevel != 2 {
+		err = fmt.Errorf("只有超级管理员可以修改超级管理员")
+	}
+	if err == nil {
+		err = checkAdminGrant(operator, target.AdminLevel, req.Roles)
+	}
+	if err == nil {
+		err = adminImp.UpdateAdminRoles(target.UserId, roles)
+	}
+	if err != nil {
+		LogError("修改管理员角色失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	target.AdminRoles = roles
+	LogStep("修改管理员角色成功", map[string]interface{}{
+		"operatorId": operator.UserId,
+		"userId":     target.UserId,
+		"roles":      roles,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"userId":      target.UserId,
+			"roles":       GetAdminRoleNames(target),
+			"permissions": GetAdminPermissions(target),
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"wxcloudrun-golang/config"
 	"wxcloudrun-golang/db"
 	"wxcloudrun-golang/db/dao"
 	"wxcloudrun-golang/db/model"
@@ -21,17 +22,25 @@ type AdminResponse struct {
 
 // AdminLoginRequest 管理员登录请求
 type AdminLoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	TotpCode     string `json:"totpCode"`     // 已启用两步验证时必填（或使用恢复码）
+	RecoveryCode string `json:"recoveryCode"` // 两步验证恢复码
 }
 
 // AdminLoginResponse 管理员登录响应
 type AdminLoginResponse struct {
-	UserId        string `json:"userId"`
-	NickName      string `json:"nickName"`
-	AvatarUrl     string `json:"avatarUrl"`
-	AdminLevel    int    `json:"adminLevel"`
-	AdminUsername string `json:"adminUsername"`
+	UserId             string   `json:"userId"`
+	NickName           string   `json:"nickName"`
+	AvatarUrl          string   `json:"avatarUrl"`
+	AdminLevel         int      `json:"adminLevel"`
+	AdminUsername      string   `json:"adminUsername"`
+	MustChangePassword bool     `json:"mustChangePassword"` // 是否需要先修改密码（临时密码或密码已过期）
+	TotpEnabled        bool     `json:"totpEnabled"`        // 是否已启用两步验证
+	Roles              []string `json:"roles"`              // 角色列表
+	Permissions        []string `json:"permissions"`        // 权限列表，前端据此显示菜单
+	// 登录令牌，仅登录接口返回
+	*AuthTokenPair
 }
 
 // AdminUserInfo 管理员用户信息
@@ -50,31 +59,33 @@ type AdminUserInfo struct {
 
 // AdminOrderInfo 管理员订单信息
 type AdminOrderInfo struct {
-	Id           int32     `json:"id"`
-	OrderNo      string    `json:"orderNo"`
-	UserId       string    `json:"userId"`
-	UserNickName string    `json:"userNickName"`
-	ServiceId    int32     `json:"serviceId"`
-	ServiceName  string    `json:"serviceName"`
-	Amount       float64   `json:"amount"`
-	Status       int       `json:"status"`
-	StatusText   string    `json:"statusText"`
-	CreatedAt    time.Time `json:"createdAt"`
+	Id           int32       `json:"id"`
+	OrderNo      string      `json:"orderNo"`
+	UserId       string      `json:"userId"`
+	UserNickName string      `json:"userNickName"`
+	ServiceId    int32       `json:"serviceId"`
+	ServiceName  string      `json:"serviceName"`
+	Amount       model.Money `json:"amount"`
+	Status       int         `json:"status"`
+	StatusText   string      `json:"statusText"`
+	CaregiverId  int32       `json:"caregiverId"` // 派单的护理员ID，0表示未派单
+	CreatedAt    time.Time   `json:"createdAt"`
 }
 
 // UpdateOrderAmountRequest 修改订单金额请求
 type UpdateOrderAmountRequest struct {
-	OrderId   int32   `json:"orderId"`
-	NewAmount float64 `json:"newAmount"`
-	Reason    string  `json:"reason"`
+	OrderId   int32       `json:"orderId"`
+	NewAmount model.Money `json:"newAmount"`
+	Reason    string      `json:"reason"`
 }
 
 // AdminRefundOrderRequest 管理员退款请求
 type AdminRefundOrderRequest struct {
-	OrderId      int32   `json:"orderId"`
-	RefundAmount float64 `json:"refundAmount"`
-	Reason       string  `json:"reason"`
-	RefundStatus int     `json:"refundStatus"` // 1-退款中，2-已退款
+	OrderId      int32       `json:"orderId"`
+	RefundAmount model.Money `json:"refundAmount"`
+	Reason       string      `json:"reason"`
+	RefundStatus int         `json:"refundStatus"` // 1-退款中，2-已退款
+	Offline      bool        `json:"offline"`      // 已线下退款，退款完成时不再通过支付渠道原路退款
 }
 
 // AdminLoginHandler 管理员登录接口
@@ -114,11 +125,34 @@ func AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 账号或IP处于锁定期时直接拒绝，不再校验密码
+	clientIp := getClientIp(r)
+	if lock := getActiveAdminLoginLock(req.Username, clientIp); lock != nil {
+		LogError("管理员登录被锁定", fmt.Errorf("lockType=%s, lockKey=%s, lockedUntil=%v", lock.LockType, lock.LockKey, lock.LockedUntil))
+		recordAdminLoginFailure(r, req.Username, "", "登录锁定期内尝试登录")
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: fmt.Sprintf("登录失败次数过多，请于%s后再试", lock.LockedUntil.Format("2006-01-02 15:04:05")),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	// 管理员登录
 	adminImp := &dao.AdminImp{}
 	admin, err := adminImp.AdminLogin(req.Username, req.Password)
 	if err != nil {
 		LogError("管理员登录失败", err)
+
+		// 记录失败日志，账号存在时关联管理员ID
+		adminUserId := ""
+		if existing, err := adminImp.GetAdminByUsername(req.Username); err == nil {
+			adminUserId = existing.UserId
+		}
+		delay := recordAdminLoginFailure(r, req.Username, adminUserId, "用户名或密码错误")
+		time.Sleep(delay)
+
 		response := &AdminResponse{
 			Code:     -1,
 			ErrorMsg: "用户名或密码错误",
@@ -128,17 +162,70 @@ func AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 已启用两步验证时需校验验证码或恢复码
+	var stepUpUntil *time.Time
+	if isAdminTotpEnabled(admin.UserId) {
+		if req.TotpCode == "" && req.RecoveryCode == "" {
+			response := &AdminResponse{
+				Code:     -1,
+				ErrorMsg: "请输入两步验证码",
+				Data: map[string]interface{}{
+					"requireTotp": true,
+				},
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		if _, err := verifyAdminSecondFactor(admin.UserId, req.TotpCode, req.RecoveryCode); err != nil {
+			LogError("管理员两步验证失败", err)
+			delay := recordAdminLoginFailure(r, req.Username, admin.UserId, "两步验证码错误")
+			time.Sleep(delay)
+
+			response := &AdminResponse{
+				Code:     -1,
+				ErrorMsg: "验证码错误或已使用",
+				Data: map[string]interface{}{
+					"requireTotp": true,
+				},
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		// 登录时已完成两步验证，新会话直接获得资金操作权限
+		until := time.Now().Add(config.GetAuthConfig().StepUpTTL)
+		stepUpUntil = &until
+	}
+
+	// 登录成功，清零失败次数
+	resetAdminLoginFailures(req.Username, clientIp)
+
 	// 记录登录日志
 	log := &model.AdminLoginLogModel{
 		AdminUserId: admin.UserId,
+		Username:    req.Username,
 		LoginTime:   time.Now(),
-		LoginIp:     r.RemoteAddr,
+		LoginIp:     clientIp,
 		UserAgent:   r.UserAgent(),
 		Status:      1,
 		Remark:      "管理员登录成功",
 	}
 	adminImp.LogAdminLogin(log)
 
+	// 签发登录令牌
+	tokens, err := issueTokenPair(admin.UserId, r, sessionLoginTypeAdmin, stepUpUntil)
+	if err != nil {
+		LogError("签发登录令牌失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "登录失败，请稍后重试",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	// 构建响应数据
 	loginResponse := &AdminLoginResponse{
 		UserId:        admin.UserId,
@@ -146,6 +233,12 @@ func AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
 		AvatarUrl:     admin.AvatarUrl,
 		AdminLevel:    admin.AdminLevel,
 		AdminUsername: admin.AdminUsername,
+		// 密码需修改时仍签发令牌，但除修改密码外的管理接口会被拒绝
+		MustChangePassword: isAdminPasswordExpired(admin),
+		TotpEnabled:        stepUpUntil != nil,
+		Roles:              GetAdminRoleNames(admin),
+		Permissions:        GetAdminPermissions(admin),
+		AuthTokenPair:      tokens,
 	}
 
 	LogStep("管理员登录成功", map[string]interface{}{
@@ -176,14 +269,14 @@ func GetAdminUsersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取管理员用户ID
-	adminUserId := r.URL.Query().Get("adminUserId")
-	if adminUserId == "" {
-		LogError("缺少必要参数", fmt.Errorf("adminUserId参数为空"))
-		http.Error(w, "缺少adminUserId参数", http.StatusBadRequest)
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermUserView) {
 		return
 	}
 
+	// 管理员身份以登录令牌为准
+	adminUserId := GetAuthUserId(r)
+
 	// 获取分页参数
 	page := 1
 	pageSize := 20
@@ -265,14 +358,14 @@ func GetAdminOrdersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取管理员用户ID
-	adminUserId := r.URL.Query().Get("adminUserId")
-	if adminUserId == "" {
-		LogError("缺少必要参数", fmt.Errorf("adminUserId参数为空"))
-		http.Error(w, "缺少adminUserId参数", http.StatusBadRequest)
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermOrderView) {
 		return
 	}
 
+	// 管理员身份以登录令牌为准
+	adminUserId := GetAuthUserId(r)
+
 	// 获取分页参数
 	page := 1
 	pageSize := 20
@@ -328,7 +421,8 @@ func GetAdminOrdersHandler(w http.ResponseWriter, r *http.Request) {
 				ServiceName:  serviceName,
 				Amount:       order.TotalAmount,
 				Status:       order.Status,
-				StatusText:   getOrderStatusText(order.Status),
+				StatusText:   OrderStatusText(order.Status, order.RefundStatus),
+				CaregiverId:  order.CaregiverId,
 				CreatedAt:    order.CreatedAt,
 			}
 			orderList = append(orderList, orderInfo)
@@ -368,13 +462,19 @@ func SetUserAsAdminHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermAdminManage) {
+		return
+	}
+
 	// 解析请求体
 	var req struct {
-		UserId        string `json:"userId"`
-		AdminLevel    int    `json:"adminLevel"`
-		ParentAdminId string `json:"parentAdminId"`
-		AdminUsername string `json:"adminUsername"`
-		AdminPassword string `json:"adminPassword"`
+		UserId        string   `json:"userId"`
+		AdminLevel    int      `json:"adminLevel"`
+		ParentAdminId string   `json:"parentAdminId"`
+		AdminUsername string   `json:"adminUsername"`
+		AdminPassword string   `json:"adminPassword"`
+		Roles         []string `json:"roles"` // 角色列表，见 GetAdminRolesHandler
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -395,8 +495,37 @@ func SetUserAsAdminHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 校验角色，且不能授予超出自己权限的等级和角色
+	adminRoleNames, err := normalizeAdminRoles(req.Roles)
+	if err == nil {
+		err = checkAdminGrant(GetAuthUser(r), req.AdminLevel, req.Roles)
+	}
+	if err != nil {
+		LogError("管理员角色校验失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 校验密码策略并哈希存储
+	passwordHash, err := hashNewAdminPassword(req.AdminPassword, req.AdminUsername)
+	if err != nil {
+		LogError("管理员密码不符合策略", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	// 检查用户是否存在
-	_, err := dao.UserImp.GetUserByUserId(req.UserId)
+	_, err = dao.UserImp.GetUserByUserId(req.UserId)
 	if err != nil {
 		LogError("用户不存在", err)
 		response := &AdminResponse{
@@ -424,10 +553,14 @@ func SetUserAsAdminHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 更新管理员用户名和密码
 	cli := db.Get()
+	// 初始密码由他人设置，首次登录后必须修改
 	updates := map[string]interface{}{
-		"adminUsername": req.AdminUsername,
-		"adminPassword": req.AdminPassword,
-		"updatedAt":     time.Now(),
+		"adminRoles":              adminRoleNames,
+		"adminUsername":           req.AdminUsername,
+		"adminPassword":           passwordHash,
+		"adminPasswordUpdatedAt":  time.Now(),
+		"adminPasswordMustChange": 1,
+		"updatedAt":               time.Now(),
 	}
 	err = cli.Table("Users").Where("userId = ?", req.UserId).Updates(updates).Error
 	if err != nil {
@@ -445,6 +578,7 @@ func SetUserAsAdminHandler(w http.ResponseWriter, r *http.Request) {
 		"userId":        req.UserId,
 		"adminLevel":    req.AdminLevel,
 		"adminUsername": req.AdminUsername,
+		"roles":         adminRoleNames,
 	})
 
 	response := &AdminResponse{
@@ -471,6 +605,11 @@ func RemoveAdminHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermAdminManage) {
+		return
+	}
+
 	// 解析请求体
 	var req struct {
 		UserId string `json:"userId"`
@@ -494,8 +633,20 @@ func RemoveAdminHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 取消管理员权限
+	// 只有超级管理员可以取消超级管理员
 	adminImp := &dao.AdminImp{}
+	if target, err := adminImp.GetAdminByUserId(req.UserId); err == nil && target.AdminLevel == 2 && GetAuthUser(r).AdminLevel != 2 {
+		LogError("取消管理员权限失败", fmt.Errorf("非超级管理员不能取消超级管理员: %s", req.UserId))
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "只有超级管理员可以取消超级管理员",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 取消管理员权限
 	err := adminImp.RemoveAdmin(req.UserId)
 	if err != nil {
 		LogError("取消管理员权限失败", err)
@@ -536,13 +687,8 @@ func CheckAdminStatusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取用户ID参数
-	userId := r.URL.Query().Get("userId")
-	if userId == "" {
-		LogError("缺少必要参数", fmt.Errorf("userId参数为空"))
-		http.Error(w, "缺少userId参数", http.StatusBadRequest)
-		return
-	}
+	// 用户ID以登录令牌为准
+	userId := GetAuthUserId(r)
 
 	// 检查用户是否为管理员
 	adminImp := &dao.AdminImp{}
@@ -562,11 +708,15 @@ func CheckAdminStatusHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 用户是管理员，返回管理员信息
 	adminInfo := &AdminLoginResponse{
-		UserId:        admin.UserId,
-		NickName:      admin.NickName,
-		AvatarUrl:     admin.AvatarUrl,
-		AdminLevel:    admin.AdminLevel,
-		AdminUsername: admin.AdminUsername,
+		UserId:             admin.UserId,
+		NickName:           admin.NickName,
+		AvatarUrl:          admin.AvatarUrl,
+		AdminLevel:         admin.AdminLevel,
+		AdminUsername:      admin.AdminUsername,
+		MustChangePassword: isAdminPasswordExpired(admin),
+		TotpEnabled:        isAdminTotpEnabled(admin.UserId),
+		Roles:              GetAdminRoleNames(admin),
+		Permissions:        GetAdminPermissions(admin),
 	}
 
 	LogStep("检查管理员状态成功", map[string]interface{}{
@@ -600,17 +750,13 @@ func AdminStatsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	adminUserId := r.URL.Query().Get("adminUserId")
-	if adminUserId == "" {
-		response := &AdminResponse{
-			Code:     -1,
-			ErrorMsg: "缺少adminUserId参数",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermStatsView) {
 		return
 	}
 
+	adminUserId := GetAuthUserId(r)
+
 	adminImp := &dao.AdminImp{}
 	admin, err := adminImp.GetAdminByUserId(adminUserId)
 	if err != nil || admin == nil || admin.IsAdmin == 0 {
@@ -627,11 +773,11 @@ func AdminStatsHandler(w http.ResponseWriter, r *http.Request) {
 	var totalUsers int64
 	var totalOrders int64
 	var todayOrders int64
-	var totalAmount float64
-	var paidAmount float64
-	var unpaidAmount float64
-	var refundAmount float64
-	var timeoutUnpaidAmount float64
+	var totalAmount model.Money
+	var paidAmount model.Money
+	var unpaidAmount model.Money
+	var refundAmount model.Money
+	var timeoutUnpaidAmount model.Money
 
 	// 根据管理员级别获取不同的数据
 	if admin.AdminLevel == 2 { // 超级管理员
@@ -698,17 +844,13 @@ func AdminAdminsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	adminUserId := r.URL.Query().Get("adminUserId")
-	if adminUserId == "" {
-		response := &AdminResponse{
-			Code:     -1,
-			ErrorMsg: "缺少adminUserId参数",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermAdminView) {
 		return
 	}
 
+	adminUserId := GetAuthUserId(r)
+
 	adminImp := &dao.AdminImp{}
 	admin, err := adminImp.GetAdminByUserId(adminUserId)
 	if err != nil || admin == nil || admin.IsAdmin == 0 {
@@ -758,10 +900,10 @@ func AdminAdminsHandler(w http.ResponseWriter, r *http.Request) {
 	var adminList []map[string]interface{}
 	for _, admin := range admins {
 		// 统计该管理员推荐码下单的总金额
-		var totalAmount float64
+		var totalAmount model.Money
 		if admin.AdminLevel > 0 { // 只统计管理员
 			dbCli := db.Get()
-			dbCli.Table("Orders").Where("referrerId = ?", admin.UserId).Select("IFNULL(SUM(totalAmount),0)").Scan(&totalAmount)
+			dbCli.Table("Orders").Where("referrerId = ?", admin.UserId).Select("IFNULL(SUM(totalAmount),0)").Row().Scan(&totalAmount)
 		}
 
 		adminInfo := map[string]interface{}{
@@ -773,6 +915,7 @@ func AdminAdminsHandler(w http.ResponseWriter, r *http.Request) {
 			"adminLevel":     admin.AdminLevel,
 			"adminUsername":  admin.AdminUsername,
 			"parentAdminId":  admin.ParentAdminId,
+			"roles":          GetAdminRoleNames(admin),
 			"adminCreatedAt": admin.AdminCreatedAt,
 			"createdAt":      admin.CreatedAt,
 			"totalAmount":    totalAmount, // 添加推荐码下单总金额
@@ -809,13 +952,19 @@ func SetAdminHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermAdminManage) {
+		return
+	}
+
 	// 解析请求体
 	var req struct {
-		UserId        string `json:"userId"`
-		AdminLevel    int    `json:"adminLevel"`
-		ParentAdminId string `json:"parentAdminId"`
-		AdminUsername string `json:"adminUsername"`
-		AdminPassword string `json:"adminPassword"`
+		UserId        string   `json:"userId"`
+		AdminLevel    int      `json:"adminLevel"`
+		ParentAdminId string   `json:"parentAdminId"`
+		AdminUsername string   `json:"adminUsername"`
+		AdminPassword string   `json:"adminPassword"`
+		Roles         []string `json:"roles"` // 角色列表，见 GetAdminRolesHandler
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -840,6 +989,35 @@ func SetAdminHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 校验角色，且不能授予超出自己权限的等级和角色
+	adminRoleNames, err := normalizeAdminRoles(req.Roles)
+	if err == nil {
+		err = checkAdminGrant(GetAuthUser(r), req.AdminLevel, req.Roles)
+	}
+	if err != nil {
+		LogError("管理员角色校验失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 校验密码策略并哈希存储
+	passwordHash, err := hashNewAdminPassword(req.AdminPassword, req.AdminUsername)
+	if err != nil {
+		LogError("管理员密码不符合策略", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	adminImp := &dao.AdminImp{}
 
 	// 检查要设置的用户是否存在
@@ -868,7 +1046,7 @@ func SetAdminHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 设置用户为管理员
-	err := adminImp.SetUserAsAdmin(req.UserId, req.AdminLevel, req.ParentAdminId)
+	err = adminImp.SetUserAsAdmin(req.UserId, req.AdminLevel, req.ParentAdminId)
 	if err != nil {
 		LogError("设置管理员失败", err)
 		response := &AdminResponse{
@@ -881,10 +1059,14 @@ func SetAdminHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 更新用户的用户名和密码
+	// 初始密码由他人设置，首次登录后必须修改
 	updateData := map[string]interface{}{
-		"adminUsername":  req.AdminUsername,
-		"adminPassword":  req.AdminPassword,
-		"adminCreatedAt": time.Now(),
+		"adminRoles":              adminRoleNames,
+		"adminUsername":           req.AdminUsername,
+		"adminPassword":           passwordHash,
+		"adminPasswordUpdatedAt":  time.Now(),
+		"adminPasswordMustChange": 1,
+		"adminCreatedAt":          time.Now(),
 	}
 
 	if err := dbCli.Model(&user).Updates(updateData).Error; err != nil {
@@ -902,6 +1084,7 @@ func SetAdminHandler(w http.ResponseWriter, r *http.Request) {
 		"userId":        req.UserId,
 		"adminLevel":    req.AdminLevel,
 		"adminUsername": req.AdminUsername,
+		"roles":         adminRoleNames,
 	})
 
 	response := &AdminResponse{
@@ -917,22 +1100,6 @@ func SetAdminHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// 获取订单状态文本
-func getOrderStatusText(status int) string {
-	switch status {
-	case 0:
-		return "待支付"
-	case 1:
-		return "已支付"
-	case 2:
-		return "已取消"
-	case 3:
-		return "已完成"
-	default:
-		return "未知"
-	}
-}
-
 // UpdateOrderAmountHandler 修改订单金额接口
 func UpdateOrderAmountHandler(w http.ResponseWriter, r *http.Request) {
 	LogInfo("开始处理修改订单金额请求", map[string]interface{}{
@@ -946,14 +1113,14 @@ func UpdateOrderAmountHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取管理员用户ID
-	adminUserId := r.URL.Query().Get("adminUserId")
-	if adminUserId == "" {
-		LogError("缺少必要参数", fmt.Errorf("adminUserId参数为空"))
-		http.Error(w, "缺少adminUserId参数", http.StatusBadRequest)
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermOrderAmountUpdate) {
 		return
 	}
 
+	// 管理员身份以登录令牌为准
+	adminUserId := GetAuthUserId(r)
+
 	// 解析请求体
 	var req UpdateOrderAmountRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -976,7 +1143,7 @@ func UpdateOrderAmountHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.NewAmount <= 0 {
-		LogError("新金额无效", fmt.Errorf("newAmount=%f", req.NewAmount))
+		LogError("新金额无效", fmt.Errorf("newAmount=%s", req.NewAmount))
 		http.Error(w, "新金额必须大于0", http.StatusBadRequest)
 		return
 	}
@@ -995,18 +1162,6 @@ func UpdateOrderAmountHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 只有超级管理员可以修改订单金额
-	if admin.AdminLevel != 2 {
-		LogError("权限不足", fmt.Errorf("adminLevel=%d, 需要adminLevel=2", admin.AdminLevel))
-		response := &AdminResponse{
-			Code:     -1,
-			ErrorMsg: "只有超级管理员可以修改订单金额",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
 	// 获取订单信息
 	order, err := dao.OrderImp.GetOrderById(req.OrderId)
 	if err != nil {
@@ -1096,14 +1251,14 @@ func AdminRefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取管理员用户ID
-	adminUserId := r.URL.Query().Get("adminUserId")
-	if adminUserId == "" {
-		LogError("缺少必要参数", fmt.Errorf("adminUserId参数为空"))
-		http.Error(w, "缺少adminUserId参数", http.StatusBadRequest)
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermOrderRefund) {
 		return
 	}
 
+	// 管理员身份以登录令牌为准
+	adminUserId := GetAuthUserId(r)
+
 	// 解析请求体
 	var req AdminRefundOrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1127,7 +1282,7 @@ func AdminRefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.RefundAmount <= 0 {
-		LogError("退款金额无效", fmt.Errorf("refundAmount=%f", req.RefundAmount))
+		LogError("退款金额无效", fmt.Errorf("refundAmount=%s", req.RefundAmount))
 		http.Error(w, "退款金额必须大于0", http.StatusBadRequest)
 		return
 	}
@@ -1152,18 +1307,6 @@ func AdminRefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 只有超级管理员可以处理退款
-	if admin.AdminLevel != 2 {
-		LogError("权限不足", fmt.Errorf("adminLevel=%d, 需要adminLevel=2", admin.AdminLevel))
-		response := &AdminResponse{
-			Code:     -1,
-			ErrorMsg: "只有超级管理员可以处理退款",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
 	// 获取订单信息
 	order, err := dao.OrderImp.GetOrderById(req.OrderId)
 	if err != nil {
@@ -1189,7 +1332,7 @@ func AdminRefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查订单状态
-	if order.Status != 1 || order.PayStatus != 1 {
+	if (order.Status != model.OrderStatusPaid && order.Status != model.OrderStatusAssigned) || order.PayStatus != 1 {
 		LogError("订单状态不正确", fmt.Errorf("status=%d, payStatus=%d", order.Status, order.PayStatus))
 		response := &AdminResponse{
 			Code:     -1,
@@ -1202,7 +1345,7 @@ func AdminRefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 检查退款金额不能超过订单金额
 	if req.RefundAmount > order.TotalAmount {
-		LogError("退款金额超过订单金额", fmt.Errorf("refundAmount=%f, totalAmount=%f", req.RefundAmount, order.TotalAmount))
+		LogError("退款金额超过订单金额", fmt.Errorf("refundAmount=%s, totalAmount=%s", req.RefundAmount, order.TotalAmount))
 		response := &AdminResponse{
 			Code:     -1,
 			ErrorMsg: "退款金额不能超过订单金额",
@@ -1212,25 +1355,31 @@ func AdminRefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 更新退款状态
-	if err := dao.OrderImp.UpdateRefundStatus(req.OrderId, req.RefundStatus, req.RefundAmount, req.Reason); err != nil {
-		LogError("处理退款失败", err)
+	// 按状态机更新退款状态：1-退款中对应申请退款，2-已退款对应退款完成
+	event := OrderEventRefundRequest
+	if req.RefundStatus == 2 {
+		event = OrderEventRefund
+	}
+	if !CanTransitionOrder(order, event) {
+		LogError("订单退款状态不正确", fmt.Errorf("status=%d, refundStatus=%d, event=%s", order.Status, order.RefundStatus, event))
 		response := &AdminResponse{
 			Code:     -1,
-			ErrorMsg: "处理退款失败: " + err.Error(),
+			ErrorMsg: "订单当前状态不能进行该退款操作",
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 		return
 	}
 
-	// 如果设置为已退款，同时更新订单状态
-	if req.RefundStatus == 2 {
-		if err := dao.OrderImp.UpdateOrderStatus(req.OrderId, 4); err != nil {
-			LogError("更新订单状态失败", err)
+	// 退款完成前通过支付渠道原路退款，退款单号固定，失败后重试不会重复退款
+	var refundResult *PaymentRefundResult
+	if event == OrderEventRefund && !req.Offline {
+		refundResult, err = refundOrderPayment(order, req.RefundAmount, req.Reason)
+		if err != nil {
+			LogError("支付渠道退款失败", err)
 			response := &AdminResponse{
 				Code:     -1,
-				ErrorMsg: "更新订单状态失败: " + err.Error(),
+				ErrorMsg: "退款失败: " + err.Error(),
 			}
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(response)
@@ -1238,12 +1387,46 @@ func AdminRefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	extra := map[string]interface{}{
+		"refundAmount": req.RefundAmount,
+		"refundReason": req.Reason,
+	}
+	if refundResult != nil {
+		extra["refundNo"] = refundResult.RefundNo
+	}
+	refundStatus := req.RefundStatus
+	if refundResult != nil && refundResult.Status != PaymentRefundSuccess {
+		// 支付渠道受理但尚未到账，订单记为退款中，到账后由定时任务查询退款结果完成退款并作废佣金
+		refundStatus = model.RefundStatusRefunding
+		if order.RefundStatus == model.RefundStatusNone {
+			err = TransitionOrder(order, OrderEventRefundRequest, OrderActorAdmin, adminUserId, req.Reason, extra)
+		} else {
+			var updated bool
+			updated, err = dao.OrderImp.UpdateRefundingOrder(order.Id, extra)
+			if err == nil && !updated {
+				err = errOrderStateChanged
+			}
+		}
+	} else {
+		err = TransitionOrder(order, event, OrderActorAdmin, adminUserId, req.Reason, extra)
+	}
+	if err != nil {
+		LogError("处理退款失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "处理退款失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	LogStep("管理员退款处理成功", map[string]interface{}{
 		"orderId":      req.OrderId,
 		"orderNo":      order.OrderNo,
 		"refundAmount": req.RefundAmount,
 		"reason":       req.Reason,
-		"refundStatus": req.RefundStatus,
+		"refundStatus": refundStatus,
 		"adminId":      adminUserId,
 	})
 
@@ -1254,11 +1437,14 @@ func AdminRefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 			"orderNo":      order.OrderNo,
 			"refundAmount": req.RefundAmount,
 			"reason":       req.Reason,
-			"refundStatus": req.RefundStatus,
+			"refundStatus": refundStatus,
+			"refund":       refundResult,
 			"adminId":      adminUserId,
 			"message": func() string {
-				if req.RefundStatus == 2 {
+				if refundStatus == 2 {
 					return "退款处理成功"
+				} else if refundResult != nil {
+					return "退款已提交，到账后订单自动更新为已退款"
 				} else {
 					return "退款状态更新成功"
 				}
@@ -1276,26 +1462,26 @@ func AdminRefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 
 // AdminServiceInfo 管理员服务信息
 type AdminServiceInfo struct {
-	Id            int32     `json:"id"`
-	Name          string    `json:"name"`
-	Description   string    `json:"description"`
-	Category      string    `json:"category"`
-	Price         float64   `json:"price"`
-	OriginalPrice float64   `json:"originalPrice"`
-	ImageUrl      string    `json:"imageUrl"`
-	Status        int       `json:"status"`
-	StatusText    string    `json:"statusText"`
-	Sort          int       `json:"sort"`
-	CreatedAt     time.Time `json:"createdAt"`
-	UpdatedAt     time.Time `json:"updatedAt"`
+	Id            int32       `json:"id"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	Category      string      `json:"category"`
+	Price         model.Money `json:"price"`
+	OriginalPrice model.Money `json:"originalPrice"`
+	ImageUrl      string      `json:"imageUrl"`
+	Status        int         `json:"status"`
+	StatusText    string      `json:"statusText"`
+	Sort          int         `json:"sort"`
+	CreatedAt     time.Time   `json:"createdAt"`
+	UpdatedAt     time.Time   `json:"updatedAt"`
 }
 
 // UpdateServicePriceRequest 修改服务价格请求
 type UpdateServicePriceRequest struct {
-	ServiceId        int32   `json:"serviceId"`
-	NewPrice         float64 `json:"newPrice"`
-	NewOriginalPrice float64 `json:"newOriginalPrice"`
-	Reason           string  `json:"reason"`
+	ServiceId        int32       `json:"serviceId"`
+	NewPrice         model.Money `json:"newPrice"`
+	NewOriginalPrice model.Money `json:"newOriginalPrice"`
+	Reason           string      `json:"reason"`
 }
 
 // GetAdminServicesHandler 获取管理员服务列表接口
@@ -1311,18 +1497,12 @@ func GetAdminServicesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 读取管理员身份（支持query或header）
-	adminUserId := r.URL.Query().Get("adminUserId")
-	if adminUserId == "" {
-		adminUserId = r.Header.Get("adminUserId")
-	}
-	if adminUserId == "" {
-		LogError("缺少必要参数", fmt.Errorf("adminUserId参数为空"))
-		http.Error(w, "缺少adminUserId参数", http.StatusBadRequest)
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermServiceView) {
 		return
 	}
 
-	// 解析查询参数
+	// 解析查询参数（管理员身份已由鉴权中间件校验）
 	page := 1
 	pageSize := 20
 	if v := r.URL.Query().Get("page"); v != "" {
@@ -1399,17 +1579,14 @@ func UpdateServicePriceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 读取管理员身份（支持query或header）
-	adminUserId := r.URL.Query().Get("adminUserId")
-	if adminUserId == "" {
-		adminUserId = r.Header.Get("adminUserId")
-	}
-	if adminUserId == "" {
-		LogError("缺少必要参数", fmt.Errorf("adminUserId参数为空"))
-		http.Error(w, "缺少adminUserId参数", http.StatusBadRequest)
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermServicePriceUpdate) {
 		return
 	}
 
+	// 管理员身份以登录令牌为准
+	adminUserId := GetAuthUserId(r)
+
 	// 解析请求体
 	var req UpdateServicePriceRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -0,0 +1,643 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+	"wxcloudrun-golang/utils"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// adminRecoveryCodeCount 每次生成的恢复码数量
+const adminRecoveryCodeCount = 10
+
+// AdminTotpCodeRequest 两步验证码请求，code和recoveryCode二选一
+type AdminTotpCodeRequest struct {
+	Code         string `json:"code"`         // 验证器App中的6位验证码
+	RecoveryCode string `json:"recoveryCode"` // 一次性恢复码
+}
+
+// DisableAdminTotpRequest 关闭两步验证请求
+type DisableAdminTotpRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// isAdminTotpEnabled 判断管理员是否已启用两步验证
+func isAdminTotpEnabled(userId string) bool {
+	adminImp := &dao.AdminImp{}
+	totp, err := adminImp.GetAdminTotp(userId)
+	return err == nil && totp != nil && totp.Status == 1
+}
+
+// decryptAdminTotpSecret 解密TOTP密钥；早期版本用令牌签名密钥加密的记录解密后改用TOTP加密密钥重新加密
+func decryptAdminTotpSecret(authConfig *config.AuthConfig, totp *model.AdminTotpModel) (string, error) {
+	secret, err := utils.DecryptSecret(totp.Secret, authConfig.TotpEncryptionKey)
+	if err == nil || authConfig.TotpLegacyKey == "" || authConfig.TotpLegacyKey == authConfig.TotpEncryptionKey {
+		return secret, err
+	}
+	secret, legacyErr := utils.DecryptSecret(totp.Secret, authConfig.TotpLegacyKey)
+	if legacyErr != nil {
+		return "", err
+	}
+
+	encrypted, err := utils.EncryptSecret(secret, authConfig.TotpEncryptionKey)
+	if err == nil {
+		adminImp := &dao.AdminImp{}
+		err = adminImp.UpdateAdminTotpSecret(totp.UserId, totp.Secret, encrypted)
+	}
+	if err != nil {
+		LogError("重新加密两步验证密钥失败", err)
+	} else {
+		LogInfo("两步验证密钥已改用新的加密密钥", map[string]interface{}{
+			"userId": totp.UserId,
+		})
+	}
+	return secret, nil
+}
+
+// verifyAdminSecondFactor 校验TOTP验证码或恢复码，成功时返回所用的验证方式
+func verifyAdminSecondFactor(userId, code, recoveryCode string) (string, error) {
+	adminImp := &dao.AdminImp{}
+	totp, err := adminImp.GetAdminTotp(userId)
+	if err != nil {
+		return "", err
+	}
+	if totp == nil || totp.Status != 1 {
+		return "", fmt.Errorf("未启用两步验证")
+	}
+
+	if code != "" {
+		authConfig := config.GetAuthConfig()
+		secret, err := decryptAdminTotpSecret(authConfig, totp)
+		if err != nil {
+			return "", fmt.Errorf("读取两步验证密钥失败: %v", err)
+		}
+		step, ok := utils.VerifyTOTP(secret, code, time.Now(), authConfig.TotpSkew, totp.LastUsedStep)
+		if !ok {
+			return "", fmt.Errorf("验证码错误或已使用")
+		}
+		// 并发请求使用同一验证码时只有一个能成功
+		used, err := adminImp.UseAdminTotpStep(userId, step)
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			return "", fmt.Errorf("验证码错误或已使用")
+		}
+		return "totp", nil
+	}
+
+	if recoveryCode != "" {
+		used, err := adminImp.UseRecoveryCode(userId, utils.HashRecoveryCode(recoveryCode))
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			return "", fmt.Errorf("恢复码错误或已使用")
+		}
+		remaining, _ := adminImp.CountUnusedRecoveryCodes(userId)
+		LogInfo("管理员使用恢复码", map[string]interface{}{
+			"userId":    userId,
+			"remaining": remaining,
+		})
+		return "recoveryCode", nil
+	}
+
+	return "", fmt.Errorf("请输入两步验证码")
+}
+
+// verifyAdminSessionSecondFactor 已登录会话内校验两步验证码（二次验证、关闭两步验证、重新生成恢复码）
+// 失败次数与登录共用锁定计数，返回的错误信息不暴露验证失败的具体原因，可直接返回给前端
+func verifyAdminSessionSecondFactor(r *http.Request, code, recoveryCode string) (string, error) {
+	admin := GetAuthUser(r)
+	if !isAdminTotpEnabled(admin.UserId) {
+		return "", fmt.Errorf("未启用两步验证")
+	}
+
+	if lock := getActiveAdminLoginLock(admin.AdminUsername, getClientIp(r)); lock != nil {
+		LogError("两步验证被锁定", fmt.Errorf("lockType=%s, lockKey=%s, lockedUntil=%v", lock.LockType, lock.LockKey, lock.LockedUntil))
+		recordAdminSessionFailure(r, admin, "锁定期内尝试两步验证")
+		return "", fmt.Errorf("验证失败次数过多，请于%s后再试", lock.LockedUntil.Format("2006-01-02 15:04:05"))
+	}
+
+	method, err := verifyAdminSecondFactor(admin.UserId, code, recoveryCode)
+	if err != nil {
+		LogError("两步验证码校验失败", err)
+		recordAdminSessionFailure(r, admin, "已登录会话两步验证码错误")
+		return "", fmt.Errorf("验证码错误或已使用")
+	}
+	resetAdminLoginFailures(admin.AdminUsername, getClientIp(r))
+	return method, nil
+}
+
+// recordAdminSessionFailure 记录已登录会话内的验证失败并延迟响应，账号因此被锁定时吊销其全部会话
+func recordAdminSessionFailure(r *http.Request, admin *model.UserModel, remark string) {
+	delay := recordAdminLoginFailure(r, admin.AdminUsername, admin.UserId, remark)
+
+	adminImp := &dao.AdminImp{}
+	if lock, err := adminImp.GetActiveLoginLock(loginLockTypeAccount, admin.AdminUsername); err == nil && lock != nil {
+		revoked, err := dao.AuthImp.RevokeUserSessions(admin.UserId, "两步验证失败次数过多")
+		if err != nil {
+			LogError("吊销管理员会话失败", err)
+		} else {
+			LogInfo("两步验证失败次数过多，已吊销管理员会话", map[string]interface{}{
+				"userId":  admin.UserId,
+				"revoked": revoked,
+			})
+		}
+	}
+
+	time.Sleep(delay)
+}
+
+// generateAdminRecoveryCodes 生成恢复码，返回明文（仅展示一次）和哈希值（用于存储）
+func generateAdminRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(adminRecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, utils.HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// markSessionSteppedUp 标记当前会话已通过两步验证，返回有效期截止时间
+func markSessionSteppedUp(r *http.Request) (time.Time, error) {
+	until := time.Now().Add(config.GetAuthConfig().StepUpTTL)
+	claims := GetAuthClaims(r)
+	if claims == nil {
+		return until, fmt.Errorf("缺少登录会话")
+	}
+	return until, dao.AuthImp.SetSessionStepUp(claims.SessionId, until)
+}
+
+// NewAdminStepUpMiddleware 创建两步验证中间件，用于退款、改价等资金相关接口
+// 需放在NewAdminAuthMiddleware内层，要求管理员已启用两步验证且当前会话在有效期内通过验证
+func NewAdminStepUpMiddleware(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := GetAuthUser(r)
+		claims := GetAuthClaims(r)
+		if user == nil || claims == nil {
+			writeAuthError(w, "请先登录")
+			return
+		}
+
+		if !isAdminTotpEnabled(user.UserId) {
+			LogError("两步验证校验失败", fmt.Errorf("管理员未启用两步验证: %s", user.UserId))
+			response := &AuthResponse{
+				Code:     -1,
+				ErrorMsg: "资金相关操作需要先启用两步验证",
+				Data: map[string]interface{}{
+					"requireTotpSetup": true,
+				},
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+
+		session, err := dao.AuthImp.GetSessionBySessionId(claims.SessionId)
+		if err != nil || session.StepUpUntil == nil || time.Now().After(*session.StepUpUntil) {
+			LogError("两步验证校验失败", fmt.Errorf("会话未通过两步验证或已过期: %s", claims.SessionId))
+			response := &AuthResponse{
+				Code:     -1,
+				ErrorMsg: "请先完成两步验证",
+				Data: map[string]interface{}{
+					"requireStepUp": true,
+				},
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// GetAdminTotpStatusHandler 查询当前管理员的两步验证状态
+func GetAdminTotpStatusHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理查询两步验证状态请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodGet {
+		LogError("请求方法不支持", fmt.Errorf("期望GET方法，实际为%s", r.Method))
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	adminUserId := GetAuthUserId(r)
+	adminImp := &dao.AdminImp{}
+	totp, err := adminImp.GetAdminTotp(adminUserId)
+	if err != nil {
+		LogError("获取两步验证配置失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	data := map[string]interface{}{
+		"enabled": totp != nil && totp.Status == 1,
+	}
+	if totp != nil && totp.Status == 1 {
+		remaining, _ := adminImp.CountUnusedRecoveryCodes(adminUserId)
+		data["enabledAt"] = totp.EnabledAt
+		data["recoveryCodesRemaining"] = remaining
+	}
+	if claims := GetAuthClaims(r); claims != nil {
+		if session, err := dao.AuthImp.GetSessionBySessionId(claims.SessionId); err == nil && session.StepUpUntil != nil && time.Now().Before(*session.StepUpUntil) {
+			data["stepUpUntil"] = session.StepUpUntil
+		}
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: data,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SetupAdminTotpHandler 生成TOTP密钥和绑定二维码，需调用启用接口验证后才生效
+func SetupAdminTotpHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理绑定两步验证请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin := GetAuthUser(r)
+	if isAdminTotpEnabled(admin.UserId) {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "两步验证已启用，如需更换请先关闭",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	authConfig := config.GetAuthConfig()
+	secret, err := utils.GenerateTOTPSecret()
+	var encrypted string
+	if err == nil {
+		encrypted, err = utils.EncryptSecret(secret, authConfig.TotpEncryptionKey)
+	}
+	if err == nil {
+		adminImp := &dao.AdminImp{}
+		err = adminImp.SaveAdminTotpSecret(admin.UserId, encrypted)
+	}
+	if err != nil {
+		LogError("生成两步验证密钥失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "生成两步验证密钥失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	account := admin.AdminUsername
+	if account == "" {
+		account = admin.UserId
+	}
+	uri := utils.TOTPProvisioningURI(authConfig.TotpIssuer, account, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		LogError("生成两步验证二维码失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "生成二维码失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogStep("生成两步验证密钥成功", map[string]interface{}{
+		"userId": admin.UserId,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"secret":     secret,
+			"otpauthUrl": uri,
+			"qrCode":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// EnableAdminTotpHandler 验证首个验证码并启用两步验证，返回恢复码（仅返回一次）
+func EnableAdminTotpHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理启用两步验证请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AdminTotpCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		LogError("解析请求体失败", err)
+		http.Error(w, "缺少验证码", http.StatusBadRequest)
+		return
+	}
+
+	adminUserId := GetAuthUserId(r)
+	adminImp := &dao.AdminImp{}
+	totp, err := adminImp.GetAdminTotp(adminUserId)
+	if err != nil || totp == nil || totp.Status != 0 {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "请先生成两步验证二维码",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	authConfig := config.GetAuthConfig()
+	secret, err := decryptAdminTotpSecret(authConfig, totp)
+	if err != nil {
+		LogError("读取两步验证密钥失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "两步验证密钥无效，请重新生成",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	step, ok := utils.VerifyTOTP(secret, req.Code, time.Now(), authConfig.TotpSkew, totp.LastUsedStep)
+	if !ok {
+		LogError("启用两步验证失败", fmt.Errorf("验证码错误: %s", adminUserId))
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "验证码错误，请确认手机时间准确后重试",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	codes, hashes, err := generateAdminRecoveryCodes()
+	if err == nil {
+		err = adminImp.EnableAdminTotp(adminUserId, step, hashes)
+	}
+	if err != nil {
+		LogError("启用两步验证失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "启用两步验证失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 刚完成验证，当前会话视为已通过两步验证
+	if _, err := markSessionSteppedUp(r); err != nil {
+		LogError("记录两步验证状态失败", err)
+	}
+
+	LogStep("启用两步验证成功", map[string]interface{}{
+		"userId": adminUserId,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"recoveryCodes": codes,
+			"message":       "两步验证已启用，请妥善保存恢复码，每个恢复码只能使用一次",
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DisableAdminTotpHandler 关闭两步验证，需要同时验证密码和验证码（或恢复码）
+func DisableAdminTotpHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理关闭两步验证请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req DisableAdminTotpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		LogError("解析请求体失败", err)
+		http.Error(w, "请求体格式错误", http.StatusBadRequest)
+		return
+	}
+
+	admin := GetAuthUser(r)
+	if !utils.VerifyPassword(req.Password, admin.AdminPassword) {
+		LogError("关闭两步验证失败", fmt.Errorf("密码错误: %s", admin.UserId))
+		recordAdminSessionFailure(r, admin, "关闭两步验证时密码错误")
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "密码错误",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if _, err := verifyAdminSessionSecondFactor(r, req.Code, req.RecoveryCode); err != nil {
+		LogError("关闭两步验证失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	adminImp := &dao.AdminImp{}
+	if err := adminImp.DeleteAdminTotp(admin.UserId); err != nil {
+		LogError("关闭两步验证失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "关闭两步验证失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogStep("关闭两步验证成功", map[string]interface{}{
+		"userId": admin.UserId,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"message": "两步验证已关闭",
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RegenerateAdminRecoveryCodesHandler 重新生成恢复码，旧恢复码全部作废
+func RegenerateAdminRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理重新生成恢复码请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AdminTotpCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		LogError("解析请求体失败", err)
+		http.Error(w, "缺少验证码", http.StatusBadRequest)
+		return
+	}
+
+	// 只接受验证器App中的验证码，避免用旧恢复码换取新恢复码
+	adminUserId := GetAuthUserId(r)
+	if _, err := verifyAdminSessionSecondFactor(r, req.Code, ""); err != nil {
+		LogError("重新生成恢复码失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	codes, hashes, err := generateAdminRecoveryCodes()
+	if err == nil {
+		adminImp := &dao.AdminImp{}
+		err = adminImp.ReplaceRecoveryCodes(adminUserId, hashes)
+	}
+	if err != nil {
+		LogError("重新生成恢复码失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "重新生成恢复码失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogStep("重新生成恢复码成功", map[string]interface{}{
+		"userId": adminUserId,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"recoveryCodes": codes,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// AdminStepUpHandler 对当前会话进行两步验证，通过后在有效期内可调用资金相关接口
+func AdminStepUpHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理两步验证请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AdminTotpCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		LogError("解析请求体失败", err)
+		http.Error(w, "请求体格式错误", http.StatusBadRequest)
+		return
+	}
+
+	adminUserId := GetAuthUserId(r)
+	method, err := verifyAdminSessionSecondFactor(r, req.Code, req.RecoveryCode)
+	if err != nil {
+		LogError("两步验证失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	until, err := markSessionSteppedUp(r)
+	if err != nil {
+		LogError("记录两步验证状态失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "两步验证失败，请稍后重试",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogStep("两步验证成功", map[string]interface{}{
+		"userId": adminUserId,
+		"method": method,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"stepUpUntil": until,
+			"expiresIn":   int64(time.Until(until).Seconds()),
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// authContextKey 请求上下文中的鉴权信息键
+type authContextKey struct{}
+
+// authContext 请求上下文中保存的鉴权信息
+type authContext struct {
+	claims       *AuthClaims
+	user         *model.UserModel
+	adminSession bool // 是否为管理员账号密码登录创建的会话
+}
+
+// NewAuthMiddleware 创建鉴权中间件，校验访问令牌并把当前用户写入请求上下文
+// 未携带令牌时，若开启了云托管身份信任，则按X-WX-OPENID识别用户（此时没有令牌载荷）
+func NewAuthMiddleware(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := extractToken(r)
+		if token == "" {
+			if user := resolveWxCloudUser(r); user != nil {
+				ctx := context.WithValue(r.Context(), authContextKey{}, &authContext{user: user})
+				handler(w, r.WithContext(ctx))
+				return
+			}
+			LogError("鉴权失败", fmt.Errorf("缺少访问令牌: %s", r.URL.Path))
+			writeAuthError(w, "请先登录")
+			return
+		}
+
+		auth, err := authenticateToken(token)
+		if err != nil {
+			LogError("鉴权失败", err)
+			writeAuthError(w, "登录已失效，请重新登录")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authContextKey{}, auth)
+		handler(w, r.WithContext(ctx))
+	}
+}
+
+// authenticateOptional 尝试识别请求中的登录用户，未携带令牌或令牌无效时返回nil（用于不强制登录的接口）
+func authenticateOptional(r *http.Request) *model.UserModel {
+	if auth := authenticateOptionalContext(r); auth != nil {
+		return auth.user
+	}
+	return nil
+}
+
+// authenticateOptionalContext 与authenticateOptional相同，返回完整的鉴权信息（含是否为管理员会话）
+func authenticateOptionalContext(r *http.Request) *authContext {
+	token := extractToken(r)
+	if token == "" {
+		if user := resolveWxCloudUser(r); user != nil {
+			return &authContext{user: user}
+		}
+		return nil
+	}
+	auth, err := authenticateToken(token)
+	if err != nil {
+		return nil
+	}
+	return auth
+}
+
+// authenticateToken 校验访问令牌、会话状态并加载用户
+func authenticateToken(token string) (*authContext, error) {
+	claims, err := VerifyToken(token, tokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	// 校验会话未被吊销
+	session, err := dao.AuthImp.GetSessionBySessionId(claims.SessionId)
+	if err != nil || session.Status != 1 || session.UserId != claims.Subject {
+		return nil, fmt.Errorf("会话不存在或已吊销: %s", claims.SessionId)
+	}
+
+	user, err := dao.UserImp.GetUserByUserId(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("用户不存在: %s", claims.Subject)
+	}
+	if user.MergedInto != "" {
+		return nil, fmt.Errorf("账号已合并: %s", claims.Subject)
+	}
+	if user.DeactivatedAt != nil {
+		return nil, fmt.Errorf("账号已注销: %s", claims.Subject)
+	}
+	return &authContext{
+		claims:       claims,
+		user:         user,
+		adminSession: session.LoginType == sessionLoginTypeAdmin,
+	}, nil
+}
+
+// NewAdminAuthMiddleware 创建管理员鉴权中间件，在登录校验基础上要求当前用户为管理员且密码无需修改
+// 管理后台必须使用账号密码登录签发的令牌，不接受云托管身份请求头
+func NewAdminAuthMiddleware(handler http.HandlerFunc) http.HandlerFunc {
+	return NewAuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		user := GetAuthUser(r)
+		if !IsAdminSession(r) {
+			LogError("管理员鉴权失败", fmt.Errorf("未使用管理员登录令牌: %s", user.UserId))
+			writeAuthError(w, "请使用管理员账号登录")
+			return
+		}
+		if user.IsAdmin != 1 {
+			LogError("管理员鉴权失败", fmt.Errorf("用户不是管理员: %s", user.UserId))
+			response := &AuthResponse{
+				Code:     -1,
+				ErrorMsg: "无管理员权限",
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		if isAdminPasswordExpired(user) {
+			LogError("管理员鉴权失败", fmt.Errorf("管理员密码需修改: %s", user.UserId))
+			response := &AuthResponse{
+				Code:     -1,
+				ErrorMsg: "管理员密码已过期或为临时密码，请先修改密码",
+				Data: map[string]interface{}{
+					"mustChangePassword": true,
+				},
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// IsAdminSession 当前请求是否使用管理员账号密码登录签发的令牌
+// 小程序登录或云托管身份请求头识别的用户即使是管理员也返回false
+func IsAdminSession(r *http.Request) bool {
+	if auth, ok := r.Context().Value(authContextKey{}).(*authContext); ok {
+		return auth.adminSession
+	}
+	return false
+}
+
+// GetAuthUser 获取当前登录用户，未经过鉴权中间件时返回nil
+func GetAuthUser(r *http.Request) *model.UserModel {
+	if auth, ok := r.Context().Value(authContextKey{}).(*authContext); ok {
+		return auth.user
+	}
+	return nil
+}
+
+// GetAuthUserId 获取当前登录用户ID，未经过鉴权中间件时返回空字符串
+func GetAuthUserId(r *http.Request) string {
+	if user := GetAuthUser(r); user != nil {
+		return user.UserId
+	}
+	return ""
+}
+
+// GetAuthClaims 获取当前请求的令牌载荷，通过云托管身份请求头识别的请求返回nil
+func GetAuthClaims(r *http.Request) *AuthClaims {
+	if auth, ok := r.Context().Value(authContextKey{}).(*authContext); ok {
+		return auth.claims
+	}
+	return nil
+}
+
+// sseTokenQueryPath 允许通过token查询参数传递令牌的路径，EventSource无法设置请求头
+const sseTokenQueryPath = "/sse"
+
+// extractToken 从Authorization头中提取令牌；只有SSE连接可使用token查询参数，
+// 其他接口不接受，避免令牌出现在访问日志和浏览器历史中
+func extractToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if strings.HasPrefix(header, "Bearer ") {
+			return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		}
+		return ""
+	}
+	if r.URL.Path == sseTokenQueryPath {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
+
+// writeAuthError 返回401鉴权失败响应
+func writeAuthError(w http.ResponseWriter, msg string) {
+	response := &AuthResponse{
+		Code:     -1,
+		ErrorMsg: msg,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtractToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		target        string
+		authorization string
+		want          string
+	}{
+		{"Bearer请求头", "/api/user/info", "Bearer abc", "abc"},
+		{"非Bearer请求头", "/api/user/info", "Basic abc", ""},
+		{"普通接口不接受查询参数", "/api/user/info?token=abc", "", ""},
+		{"SSE接受查询参数", "/sse?token=abc", "", "abc"},
+		{"SSE优先使用请求头", "/sse?token=abc", "Bearer def", "def"},
+		{"未携带令牌", "/sse", "", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		if tt.authorization != "" {
+			r.Header.Set("Authorization", tt.authorization)
+		}
+		if got := extractToken(r); got != tt.want {
+			t.Errorf("%s: extractToken = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+	"wxcloudrun-golang/utils"
+)
+
+const (
+	tokenTypeAccess  = "access"  // 访问令牌
+	tokenTypeRefresh = "refresh" // 刷新令牌
+)
+
+// 登录会话的登录方式
+const (
+	sessionLoginTypeWechat = "wechat" // 小程序登录
+	sessionLoginTypeAdmin  = "admin"  // 管理员账号密码登录
+)
+
+// AuthResponse 鉴权响应
+type AuthResponse struct {
+	Code     int         `json:"code"`
+	ErrorMsg string      `json:"errorMsg,omitempty"`
+	Data     interface{} `json:"data"`
+}
+
+// AuthClaims 令牌载荷
+type AuthClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"` // 用户ID
+	SessionId string `json:"sid"` // 会话ID
+	TokenId   string `json:"jti"` // 令牌ID
+	TokenType string `json:"typ"` // access 或 refresh
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// AuthTokenPair 登录令牌对
+type AuthTokenPair struct {
+	Token            string `json:"token"`
+	RefreshToken     string `json:"refreshToken"`
+	ExpiresIn        int64  `json:"expiresIn"`        // 访问令牌有效期（秒）
+	RefreshExpiresIn int64  `json:"refreshExpiresIn"` // 刷新令牌有效期（秒）
+}
+
+// RefreshTokenRequest 刷新令牌请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// LogoutRequest 退出登录请求
+type LogoutRequest struct {
+	All bool `json:"all"` // 是否退出该用户的全部会话
+}
+
+// IssueTokenPair 为小程序登录用户创建登录会话并签发访问令牌和刷新令牌
+func IssueTokenPair(userId string, r *http.Request) (*AuthTokenPair, error) {
+	return issueTokenPair(userId, r, sessionLoginTypeWechat, nil)
+}
+
+// issueTokenPair 创建登录会话并签发令牌，stepUpUntil不为空时新会话直接视为已通过两步验证
+// 只有loginType为admin的会话可以访问管理接口
+func issueTokenPair(userId string, r *http.Request, loginType string, stepUpUntil *time.Time) (*AuthTokenPair, error) {
+	authConfig := config.GetAuthConfig()
+	now := time.Now()
+
+	session := &model.AuthSessionModel{
+		SessionId:   utils.GenerateMongoID(),
+		UserId:      userId,
+		RefreshJti:  utils.GenerateMongoID(),
+		ClientIp:    getClientIp(r),
+		UserAgent:   r.UserAgent(),
+		Status:      1,
+		ExpiresAt:   now.Add(authConfig.RefreshTokenTTL),
+		StepUpUntil: stepUpUntil,
+		LoginType:   loginType,
+	}
+	LogDBOperation("创建", "AuthSessions", map[string]interface{}{"userId": userId, "sessionId": session.SessionId})
+	if err := dao.AuthImp.CreateSession(session); err != nil {
+		return nil, fmt.Errorf("创建登录会话失败: %v", err)
+	}
+
+	return signTokenPair(authConfig, userId, session.SessionId, session.RefreshJti, now)
+}
+
+// VerifyToken 校验令牌签名、类型和有效期
+func VerifyToken(token, tokenType string) (*AuthClaims, error) {
+	authConfig := config.GetAuthConfig()
+	if err := authConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("登录令牌密钥配置无效: %v", err)
+	}
+
+	var claims AuthClaims
+	if err := utils.ParseJWT(token, authConfig.TokenSecret, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Issuer != authConfig.Issuer || claims.TokenType != tokenType {
+		return nil, fmt.Errorf("令牌类型无效")
+	}
+	if claims.Subject == "" || claims.SessionId == "" {
+		return nil, fmt.Errorf("令牌缺少用户信息")
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("令牌已过期")
+	}
+	return &claims, nil
+}
+
+// RefreshTokenHandler 刷新令牌接口
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	LogStep("开始处理刷新令牌请求", map[string]string{"method": r.Method, "path": r.URL.Path})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		LogError("请求参数解析失败", fmt.Errorf("缺少refreshToken"))
+		http.Error(w, "缺少refreshToken参数", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := VerifyToken(req.RefreshToken, tokenTypeRefresh)
+	if err != nil {
+		LogError("刷新令牌校验失败", err)
+		writeAuthError(w, "刷新令牌无效或已过期")
+		return
+	}
+
+	session, err := dao.AuthImp.GetSessionBySessionId(claims.SessionId)
+	if err != nil || session.Status != 1 || session.UserId != claims.Subject {
+		LogError("登录会话不存在或已吊销", fmt.Errorf("sessionId=%s", claims.SessionId))
+		writeAuthError(w, "登录已失效，请重新登录")
+		return
+	}
+
+	// 刷新令牌只能使用一次，重复使用说明令牌可能泄露，直接吊销整个会话
+	if session.RefreshJti != claims.TokenId {
+		LogError("检测到刷新令牌重放", fmt.Errorf("sessionId=%s", claims.SessionId))
+		dao.AuthImp.RevokeSession(session.SessionId, "刷新令牌重放")
+		writeAuthError(w, "登录已失效，请重新登录")
+		return
+	}
+
+	authConfig := config.GetAuthConfig()
+	now := time.Now()
+	newJti := utils.GenerateMongoID()
+	rotated, err := dao.AuthImp.RotateRefreshJti(session.SessionId, claims.TokenId, newJti, now.Add(authConfig.RefreshTokenTTL))
+	if err != nil || !rotated {
+		LogError("轮换刷新令牌失败", err)
+		writeAuthError(w, "登录已失效，请重新登录")
+		return
+	}
+
+	tokens, err := signTokenPair(authConfig, session.UserId, session.SessionId, newJti, now)
+	if err != nil {
+		LogError("签发令牌失败", err)
+		response := &AuthResponse{
+			Code:     -1,
+			ErrorMsg: "签发令牌失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("令牌刷新成功", map[string]interface{}{"userId": session.UserId, "sessionId": session.SessionId})
+	response := &AuthResponse{
+		Code: 0,
+		Data: tokens,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// LogoutHandler 退出登录接口，吊销当前会话（all=true时吊销该用户全部会话）
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	LogStep("开始处理退出登录请求", map[string]string{"method": r.Method, "path": r.URL.Path})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req LogoutRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			LogError("请求参数解析失败", err)
+			http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userId := GetAuthUserId(r)
+	claims := GetAuthClaims(r)
+	var err error
+	var revoked int64 = 1
+	if req.All {
+		revoked, err = dao.AuthImp.RevokeUserSessions(userId, "用户退出全部设备")
+	} else if claims != nil {
+		err = dao.AuthImp.RevokeSession(claims.SessionId, "用户退出登录")
+	} else {
+		// 云托管身份请求头识别的请求没有会话可吊销
+		revoked = 0
+	}
+	if err != nil {
+		LogError("吊销会话失败", err)
+		response := &AuthResponse{
+			Code:     -1,
+			ErrorMsg: "退出登录失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("退出登录成功", map[string]interface{}{"userId": userId, "all": req.All, "revoked": revoked})
+	response := &AuthResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"revoked": revoked,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// signTokenPair 签发访问令牌和刷新令牌
+func signTokenPair(authConfig *config.AuthConfig, userId, sessionId, refreshJti string, now time.Time) (*AuthTokenPair, error) {
+	// 未配置密钥时拒绝签发令牌，避免使用公开的默认密钥
+	if err := authConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("登录令牌密钥配置无效: %v", err)
+	}
+	accessClaims := &AuthClaims{
+		Issuer:    authConfig.Issuer,
+		Subject:   userId,
+		SessionId: sessionId,
+		TokenId:   utils.GenerateMongoID(),
+		TokenType: tokenTypeAccess,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(authConfig.AccessTokenTTL).Unix(),
+	}
+	accessToken, err := utils.SignJWT(accessClaims, authConfig.TokenSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshClaims := &AuthClaims{
+		Issuer:    authConfig.Issuer,
+		Subject:   userId,
+		SessionId: sessionId,
+		TokenId:   refreshJti,
+		TokenType: tokenTypeRefresh,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(authConfig.RefreshTokenTTL).Unix(),
+	}
+	refreshToken, err := utils.SignJWT(refreshClaims, authConfig.TokenSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokenPair{
+		Token:            accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        int64(authConfig.AccessTokenTTL / time.Second),
+		RefreshExpiresIn: int64(authConfig.RefreshTokenTTL / time.Second),
+	}, nil
+}
+
+// getClientIp 获取客户端IP
+// X-Forwarded-For最左侧的地址可由客户端任意伪造，只信任由可信代理追加的最后TRUSTED_PROXY_COUNT个地址
+func getClientIp(r *http.Request) string {
+	trustedProxies := config.GetAuthConfig().TrustedProxyCount
+	if trustedProxies > 0 {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return forwardedClientIp(forwarded, trustedProxies)
+		}
+		if realIp := r.Header.Get("X-Real-IP"); realIp != "" {
+			return strings.TrimSpace(realIp)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedClientIp 从X-Forwarded-For中取倒数第trustedProxies个地址，即最外层可信代理看到的客户端地址
+// 地址数少于可信代理层数时取最左侧的地址
+func forwardedClientIp(forwarded string, trustedProxies int) string {
+	hops := strings.Split(forwarded, ",")
+	index := len(hops) - trustedProxies
+	if index < 0 {
+		index = 0
+	}
+	return strings.TrimSpace(hops[index])
+}
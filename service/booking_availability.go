@@ -0,0 +1,242 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// defaultSlotTemplate 未配置任何时间段模板时使用的内置规则：明天起7天内，每天08:00-19:00
+var defaultSlotTemplate = &model.SlotTemplateModel{
+	Name:        "默认",
+	TimeSlots:   "08:00,09:00,10:00,11:00,14:00,15:00,16:00,17:00,18:00,19:00",
+	LeadDays:    1,
+	HorizonDays: 7,
+	Status:      1,
+}
+
+// TimeSlotAvailability 单个时间段的容量和剩余名额
+type TimeSlotAvailability struct {
+	Time      string `json:"time"`
+	Capacity  int    `json:"capacity"`
+	Booked    int    `json:"booked"`
+	Remaining int    `json:"remaining"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"` // 不可预约的原因
+}
+
+// bookingRuleError 预约日期或时间段不符合规则，错误信息可直接返回给用户
+type bookingRuleError struct {
+	message string
+}
+
+func (e *bookingRuleError) Error() string {
+	return e.message
+}
+
+// splitTemplateList 拆分逗号分隔的模板字段
+func splitTemplateList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// resolveSlotTemplate 按服务项目、服务分类、全局的顺序匹配启用的时间段模板，都没有时使用内置规则
+// service为nil时只匹配全局模板
+func resolveSlotTemplate(service *model.ServiceItemModel) (*model.SlotTemplateModel, error) {
+	templates, err := dao.BookingImp.GetSlotTemplates(true)
+	if err != nil {
+		return nil, err
+	}
+
+	var categoryTemplate, globalTemplate *model.SlotTemplateModel
+	for _, template := range templates {
+		switch {
+		case service != nil && template.ServiceId == service.Id:
+			return template, nil
+		case service != nil && template.ServiceId == 0 && template.Category != "" && template.Category == service.Category:
+			if categoryTemplate == nil {
+				categoryTemplate = template
+			}
+		case template.ServiceId == 0 && template.Category == "":
+			if globalTemplate == nil {
+				globalTemplate = template
+			}
+		}
+	}
+	if categoryTemplate != nil {
+		return categoryTemplate, nil
+	}
+	if globalTemplate != nil {
+		return globalTemplate, nil
+	}
+	return defaultSlotTemplate, nil
+}
+
+// checkBookingDate 校验日期是否在模板的预约窗口内且不在不可预约的星期
+func checkBookingDate(template *model.SlotTemplateModel, date string) error {
+	requestDate, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return &bookingRuleError{message: "日期格式错误，请使用YYYY-MM-DD格式"}
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	earliest := today.AddDate(0, 0, template.LeadDays)
+	latest := today.AddDate(0, 0, template.HorizonDays)
+
+	if requestDate.Before(earliest) {
+		if template.LeadDays == 1 {
+			return &bookingRuleError{message: "只能预约明天开始的日期"}
+		}
+		return &bookingRuleError{message: fmt.Sprintf("只能预约%d天后的日期", template.LeadDays)}
+	}
+	if requestDate.After(latest) {
+		return &bookingRuleError{message: fmt.Sprintf("只能预约%d天内的日期", template.HorizonDays)}
+	}
+
+	if weekdays := splitTemplateList(template.Weekdays); len(weekdays) > 0 {
+		weekday := strconv.Itoa(int(requestDate.Weekday()))
+		allowed := false
+		for _, day := range weekdays {
+			if day == weekday {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &bookingRuleError{message: "该日期不提供预约"}
+		}
+	}
+	return nil
+}
+
+// blackoutApplies 判断停约记录是否作用于该服务项目
+func blackoutApplies(blackout *model.BookingBlackoutModel, service *model.ServiceItemModel) bool {
+	if blackout.ServiceId != 0 && (service == nil || blackout.ServiceId != service.Id) {
+		return false
+	}
+	if blackout.Category != "" && (service == nil || blackout.Category != service.Category) {
+		return false
+	}
+	return true
+}
+
+// buildTimeSlotAvailability 按模板计算服务项目某天各时间段的剩余名额，停约和已过时间的时间段不可预约
+// 不校验预约窗口；service为nil时不计算库存，容量按全局默认值
+func buildTimeSlotAvailability(service *model.ServiceItemModel, template *model.SlotTemplateModel, date string) ([]*TimeSlotAvailability, error) {
+	blackouts, err := dao.BookingImp.GetBlackouts(date, date)
+	if err != nil {
+		return nil, err
+	}
+	wholeDayReason := ""
+	slotBlackouts := make(map[string]string)
+	for _, blackout := range blackouts {
+		if !blackoutApplies(blackout, service) {
+			continue
+		}
+		reason := blackout.Reason
+		if reason == "" {
+			reason = "暂停预约"
+		}
+		if blackout.TimeSlot == "" {
+			wholeDayReason = reason
+		} else {
+			slotBlackouts[blackout.TimeSlot] = reason
+		}
+	}
+
+	inventoryBySlot := make(map[string]*model.TimeSlotInventoryModel)
+	if service != nil {
+		inventories, err := dao.BookingImp.GetSlotInventories(service.Id, date)
+		if err != nil {
+			return nil, err
+		}
+		for _, inventory := range inventories {
+			inventoryBySlot[inventory.TimeSlot] = inventory
+		}
+	}
+
+	now := time.Now()
+	defaultCapacity := getServiceSlotCapacity(service)
+	timeSlots := splitTemplateList(template.TimeSlots)
+	slots := make([]*TimeSlotAvailability, 0, len(timeSlots))
+	for _, timeSlot := range timeSlots {
+		slot := &TimeSlotAvailability{Time: timeSlot, Capacity: defaultCapacity}
+		if inventory, ok := inventoryBySlot[timeSlot]; ok {
+			slot.Capacity = inventory.Capacity
+			slot.Booked = inventory.Booked
+		}
+		slot.Remaining = slot.Capacity - slot.Booked
+		if slot.Remaining < 0 {
+			slot.Remaining = 0
+		}
+
+		slotTime, parseErr := time.ParseInLocation("2006-01-02 15:04", date+" "+timeSlot, time.Local)
+		switch {
+		case wholeDayReason != "":
+			slot.Reason = wholeDayReason
+		case slotBlackouts[timeSlot] != "":
+			slot.Reason = slotBlackouts[timeSlot]
+		case parseErr != nil || !slotTime.After(now):
+			slot.Reason = "已过预约时间"
+		case slot.Remaining == 0:
+			slot.Reason = "已约满"
+		}
+		slot.Available = slot.Reason == ""
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+// calculateTimeSlotAvailability 提交订单和查询可预约时间段共用的可预约计算
+// 日期不符合模板规则时返回bookingRuleError
+func calculateTimeSlotAvailability(service *model.ServiceItemModel, date string) ([]*TimeSlotAvailability, error) {
+	template, err := resolveSlotTemplate(service)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBookingDate(template, date); err != nil {
+		return nil, err
+	}
+	return buildTimeSlotAvailability(service, template, date)
+}
+
+// checkTimeSlotBookable 校验服务项目的某个时间段当前是否可以预约
+func checkTimeSlotBookable(service *model.ServiceItemModel, date, timeSlot string) error {
+	slots, err := calculateTimeSlotAvailability(service, date)
+	if err != nil {
+		return err
+	}
+	for _, slot := range slots {
+		if slot.Time != timeSlot {
+			continue
+		}
+		if slot.Reason == "已约满" {
+			return &bookingRuleError{message: "该时间段已约满，请选择其他时间"}
+		}
+		if !slot.Available {
+			return &bookingRuleError{message: "该时间段不可预约：" + slot.Reason}
+		}
+		return nil
+	}
+	return &bookingRuleError{message: "预约时间不在允许的时间段内"}
+}
+
+// getServiceSlotCapacity 服务项目每个时间段的默认容量，未设置时使用全局默认值
+func getServiceSlotCapacity(service *model.ServiceItemModel) int {
+	if service != nil && service.SlotCapacity > 0 {
+		return service.SlotCapacity
+	}
+	return config.GetBookingConfig().DefaultSlotCapacity
+}
@@ -0,0 +1,561 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// SetSlotCapacityRequest 设置时间段容量请求
+// Date、TimeSlot为空时修改服务项目的默认时间段容量
+type SetSlotCapacityRequest struct {
+	ServiceId int32  `json:"serviceId"`
+	Date      string `json:"date"`
+	TimeSlot  string `json:"timeSlot"`
+	Capacity  int    `json:"capacity"`
+}
+
+// GetAdminTimeSlotsHandler 管理员查看服务项目某天的时间段库存
+func GetAdminTimeSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermServiceView) {
+		return
+	}
+
+	var serviceId int32
+	if _, err := fmt.Sscanf(r.URL.Query().Get("serviceId"), "%d", &serviceId); err != nil || serviceId <= 0 {
+		http.Error(w, "无效的服务ID", http.StatusBadRequest)
+		return
+	}
+	date := r.URL.Query().Get("date")
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		http.Error(w, "日期格式错误，请使用YYYY-MM-DD格式", http.StatusBadRequest)
+		return
+	}
+
+	service, err := dao.ServiceImp.GetServiceById(serviceId)
+	if err != nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取服务信息失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	var slots []*TimeSlotAvailability
+	template, err := resolveSlotTemplate(service)
+	if err == nil {
+		slots, err = buildTimeSlotAvailability(service, template, date)
+	}
+	if err != nil {
+		LogError("获取时间段库存失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取时间段库存失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"serviceId":       service.Id,
+			"serviceName":     service.Name,
+			"date":            date,
+			"defaultCapacity": getServiceSlotCapacity(service),
+			"templateId":      template.Id,
+			"templateName":    template.Name,
+			"slots":           slots,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SetSlotCapacityHandler 管理员设置时间段容量
+func SetSlotCapacityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermSlotCapacityUpdate) {
+		return
+	}
+
+	var req SetSlotCapacityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+	if req.ServiceId <= 0 || req.Capacity < 0 {
+		http.Error(w, "缺少必要参数", http.StatusBadRequest)
+		return
+	}
+
+	service, err := dao.ServiceImp.GetServiceById(req.ServiceId)
+	if err != nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取服务信息失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if req.Date == "" && req.TimeSlot == "" {
+		// 修改服务项目的默认容量，只影响之后新产生库存记录的时间段
+		service.SlotCapacity = req.Capacity
+		service.UpdatedAt = time.Now()
+		err = dao.ServiceImp.UpdateService(service)
+	} else {
+		if _, parseErr := time.Parse("2006-01-02 15:04", req.Date+" "+req.TimeSlot); parseErr != nil {
+			http.Error(w, "日期或时间段格式错误", http.StatusBadRequest)
+			return
+		}
+		err = dao.BookingImp.SetSlotCapacity(req.ServiceId, req.Date, req.TimeSlot, req.Capacity)
+	}
+	if err != nil {
+		LogError("设置时间段容量失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "设置时间段容量失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("管理员设置时间段容量", map[string]interface{}{
+		"serviceId": req.ServiceId,
+		"date":      req.Date,
+		"timeSlot":  req.TimeSlot,
+		"capacity":  req.Capacity,
+		"adminId":   GetAuthUserId(r),
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"serviceId": req.ServiceId,
+			"date":      req.Date,
+			"timeSlot":  req.TimeSlot,
+			"capacity":  req.Capacity,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SaveSlotTemplateRequest 新建或修改时间段模板请求，Id为0时新建
+type SaveSlotTemplateRequest struct {
+	Id          int32    `json:"id"`
+	Name        string   `json:"name"`
+	ServiceId   int32    `json:"serviceId"`
+	Category    string   `json:"category"`
+	TimeSlots   []string `json:"timeSlots"`
+	Weekdays    []int    `json:"weekdays"` // 0为周日，空表示每天
+	LeadDays    int      `json:"leadDays"`
+	HorizonDays int      `json:"horizonDays"`
+	Status      *int     `json:"status"` // 不传时为启用
+}
+
+// DeleteBookingRuleRequest 删除时间段模板、停约记录或取消退款政策请求
+type DeleteBookingRuleRequest struct {
+	Id int32 `json:"id"`
+}
+
+// CreateBlackoutRequest 新增停约请求，传EndDate时按天批量新增
+type CreateBlackoutRequest struct {
+	Date      string `json:"date"`
+	EndDate   string `json:"endDate"`
+	ServiceId int32  `json:"serviceId"`
+	Category  string `json:"category"`
+	TimeSlot  string `json:"timeSlot"`
+	Reason    string `json:"reason"`
+}
+
+// maxBlackoutDays 单次最多新增的停约天数
+const maxBlackoutDays = 60
+
+// GetSlotTemplatesHandler 管理员查看时间段模板
+func GetSlotTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermServiceView) {
+		return
+	}
+
+	templates, err := dao.BookingImp.GetSlotTemplates(false)
+	if err != nil {
+		LogError("获取时间段模板失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取时间段模板失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"list":            templates,
+			"defaultTemplate": defaultSlotTemplate,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SaveSlotTemplateHandler 管理员新建或修改时间段模板
+func SaveSlotTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermSlotCapacityUpdate) {
+		return
+	}
+
+	var req SaveSlotTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+
+	template, errMsg := buildSlotTemplate(&req)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	if template.Id > 0 {
+		if _, err := dao.BookingImp.GetSlotTemplateById(template.Id); err != nil {
+			response := &AdminResponse{
+				Code:     -1,
+				ErrorMsg: "时间段模板不存在",
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
+
+	if err := dao.BookingImp.SaveSlotTemplate(template); err != nil {
+		LogError("保存时间段模板失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "保存时间段模板失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("管理员保存时间段模板", map[string]interface{}{
+		"templateId": template.Id,
+		"serviceId":  template.ServiceId,
+		"category":   template.Category,
+		"timeSlots":  template.TimeSlots,
+		"adminId":    GetAuthUserId(r),
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: template,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// buildSlotTemplate 校验请求并转换为模板，校验失败时返回错误信息
+func buildSlotTemplate(req *SaveSlotTemplateRequest) (*model.SlotTemplateModel, string) {
+	if req.ServiceId > 0 && req.Category != "" {
+		return nil, "服务项目和服务分类只能指定一个"
+	}
+	if req.LeadDays < 0 || req.HorizonDays < req.LeadDays {
+		return nil, "可预约天数设置不正确"
+	}
+	status := 1
+	if req.Status != nil {
+		status = *req.Status
+	}
+	if status != 0 && status != 1 {
+		return nil, "无效的状态"
+	}
+
+	seen := make(map[string]bool)
+	var timeSlots []string
+	for _, timeSlot := range req.TimeSlots {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(timeSlot))
+		if err != nil {
+			return nil, "时间段格式错误，请使用HH:MM格式: " + timeSlot
+		}
+		normalized := parsed.Format("15:04")
+		if !seen[normalized] {
+			seen[normalized] = true
+			timeSlots = append(timeSlots, normalized)
+		}
+	}
+	if len(timeSlots) == 0 {
+		return nil, "请至少设置一个时间段"
+	}
+	sort.Strings(timeSlots)
+
+	var weekdays []string
+	for _, day := range req.Weekdays {
+		if day < 0 || day > 6 {
+			return nil, "星期设置不正确，0为周日，1-6为周一至周六"
+		}
+		weekdays = append(weekdays, strconv.Itoa(day))
+	}
+
+	return &model.SlotTemplateModel{
+		Id:          req.Id,
+		Name:        req.Name,
+		ServiceId:   req.ServiceId,
+		Category:    req.Category,
+		TimeSlots:   strings.Join(timeSlots, ","),
+		Weekdays:    strings.Join(weekdays, ","),
+		LeadDays:    req.LeadDays,
+		HorizonDays: req.HorizonDays,
+		Status:      status,
+	}, ""
+}
+
+// DeleteSlotTemplateHandler 管理员删除时间段模板
+func DeleteSlotTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermSlotCapacityUpdate) {
+		return
+	}
+
+	var req DeleteBookingRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Id <= 0 {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+
+	if err := dao.BookingImp.DeleteSlotTemplate(req.Id); err != nil {
+		LogError("删除时间段模板失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "删除时间段模板失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("管理员删除时间段模板", map[string]interface{}{
+		"templateId": req.Id,
+		"adminId":    GetAuthUserId(r),
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{"id": req.Id},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetBlackoutsHandler 管理员查看停约日期，默认返回今天起60天内的记录
+func GetBlackoutsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermServiceView) {
+		return
+	}
+
+	fromDate := r.URL.Query().Get("from")
+	if fromDate == "" {
+		fromDate = time.Now().Format("2006-01-02")
+	}
+	toDate := r.URL.Query().Get("to")
+	if toDate == "" {
+		toDate = time.Now().AddDate(0, 0, maxBlackoutDays).Format("2006-01-02")
+	}
+
+	blackouts, err := dao.BookingImp.GetBlackouts(fromDate, toDate)
+	if err != nil {
+		LogError("获取停约日期失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取停约日期失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"from": fromDate,
+			"to":   toDate,
+			"list": blackouts,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateBlackoutHandler 管理员新增停约日期
+func CreateBlackoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermSlotCapacityUpdate) {
+		return
+	}
+
+	var req CreateBlackoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+
+	startDate, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		http.Error(w, "日期格式错误，请使用YYYY-MM-DD格式", http.StatusBadRequest)
+		return
+	}
+	endDate := startDate
+	if req.EndDate != "" {
+		if endDate, err = time.Parse("2006-01-02", req.EndDate); err != nil || endDate.Before(startDate) {
+			http.Error(w, "结束日期格式错误或早于开始日期", http.StatusBadRequest)
+			return
+		}
+	}
+	if endDate.Sub(startDate) >= maxBlackoutDays*24*time.Hour {
+		http.Error(w, fmt.Sprintf("单次最多设置%d天", maxBlackoutDays), http.StatusBadRequest)
+		return
+	}
+	if req.TimeSlot != "" {
+		if _, err := time.Parse("15:04", req.TimeSlot); err != nil {
+			http.Error(w, "时间段格式错误，请使用HH:MM格式", http.StatusBadRequest)
+			return
+		}
+	}
+
+	adminUserId := GetAuthUserId(r)
+	var blackouts []*model.BookingBlackoutModel
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		blackout := &model.BookingBlackoutModel{
+			Date:      day.Format("2006-01-02"),
+			ServiceId: req.ServiceId,
+			Category:  req.Category,
+			TimeSlot:  req.TimeSlot,
+			Reason:    req.Reason,
+			CreatedBy: adminUserId,
+		}
+		if err := dao.BookingImp.CreateBlackout(blackout); err != nil {
+			LogError("新增停约日期失败", err)
+			response := &AdminResponse{
+				Code:     -1,
+				ErrorMsg: "新增停约日期失败: " + err.Error(),
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		blackouts = append(blackouts, blackout)
+	}
+
+	LogInfo("管理员新增停约日期", map[string]interface{}{
+		"date":      req.Date,
+		"endDate":   req.EndDate,
+		"serviceId": req.ServiceId,
+		"category":  req.Category,
+		"timeSlot":  req.TimeSlot,
+		"adminId":   adminUserId,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{"list": blackouts},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteBlackoutHandler 管理员删除停约日期
+func DeleteBlackoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermSlotCapacityUpdate) {
+		return
+	}
+
+	var req DeleteBookingRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Id <= 0 {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+
+	if err := dao.BookingImp.DeleteBlackout(req.Id); err != nil {
+		LogError("删除停约日期失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "删除停约日期失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("管理员删除停约日期", map[string]interface{}{
+		"blackoutId": req.Id,
+		"adminId":    GetAuthUserId(r),
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{"id": req.Id},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
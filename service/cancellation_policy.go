@@ -0,0 +1,257 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// defaultCancellationPolicy 未配置任何取消退款政策时使用的内置规则：
+// 提前24小时以上取消全额退款，24小时内取消退款50%，服务开始后或护理员签到后不退款
+var defaultCancellationPolicy = &model.CancellationPolicyModel{
+	Name:             "默认",
+	Tiers:            "24:100,0:50",
+	CheckedInPercent: 0,
+	Status:           1,
+}
+
+// CancellationTier 退款档位：距预约开始不少于HoursBefore小时取消时退款Percent%
+type CancellationTier struct {
+	HoursBefore int `json:"hoursBefore"`
+	Percent     int `json:"percent"`
+}
+
+// RefundQuote 按取消政策计算的退款金额，用户确认取消前展示
+type RefundQuote struct {
+	OrderId      int32       `json:"orderId"`
+	OrderNo      string      `json:"orderNo"`
+	TotalAmount  model.Money `json:"totalAmount"`
+	RefundAmount model.Money `json:"refundAmount"`
+	Fee          model.Money `json:"fee"`          // 取消手续费（不退部分）
+	Percent      int         `json:"percent"`      // 退款百分比
+	Rule         string      `json:"rule"`         // 本次命中的规则
+	PolicyName   string      `json:"policyName"`   // 使用的政策名称
+	PolicyRules  []string    `json:"policyRules"`  // 政策的全部规则，用于展示
+	Refundable   bool        `json:"refundable"`   // 是否可以取消退款
+	HoursToStart float64     `json:"hoursToStart"` // 距预约开始的小时数，已开始时为负数
+}
+
+// parseCancellationTiers 解析退款档位，按提前小时数从大到小排序
+func parseCancellationTiers(value string) ([]CancellationTier, error) {
+	seen := make(map[int]bool)
+	var tiers []CancellationTier
+	for _, item := range splitTemplateList(value) {
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("退款档位格式错误，请使用“提前小时数:退款百分比”: %s", item)
+		}
+		hours, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || hours < 0 {
+			return nil, fmt.Errorf("提前小时数必须是非负整数: %s", item)
+		}
+		percent, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("退款百分比必须在0-100之间: %s", item)
+		}
+		if seen[hours] {
+			return nil, fmt.Errorf("提前小时数重复: %d", hours)
+		}
+		seen[hours] = true
+		tiers = append(tiers, CancellationTier{HoursBefore: hours, Percent: percent})
+	}
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("请至少设置一个退款档位")
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].HoursBefore > tiers[j].HoursBefore
+	})
+	return tiers, nil
+}
+
+// formatCancellationTiers 将退款档位转换为数据库存储格式
+func formatCancellationTiers(tiers []CancellationTier) string {
+	items := make([]string, 0, len(tiers))
+	for _, tier := range tiers {
+		items = append(items, fmt.Sprintf("%d:%d", tier.HoursBefore, tier.Percent))
+	}
+	return strings.Join(items, ",")
+}
+
+// resolveCancellationPolicy 按服务项目、服务分类、全局的顺序匹配启用的取消退款政策，都没有时使用内置规则
+// service为nil时只匹配全局政策
+func resolveCancellationPolicy(service *model.ServiceItemModel) (*model.CancellationPolicyModel, error) {
+	policies, err := dao.BookingImp.GetCancellationPolicies(true)
+	if err != nil {
+		return nil, err
+	}
+
+	var categoryPolicy, globalPolicy *model.CancellationPolicyModel
+	for _, policy := range policies {
+		switch {
+		case service != nil && policy.ServiceId == service.Id:
+			return policy, nil
+		case service != nil && policy.ServiceId == 0 && policy.Category != "" && policy.Category == service.Category:
+			if categoryPolicy == nil {
+				categoryPolicy = policy
+			}
+		case policy.ServiceId == 0 && policy.Category == "":
+			if globalPolicy == nil {
+				globalPolicy = policy
+			}
+		}
+	}
+	if categoryPolicy != nil {
+		return categoryPolicy, nil
+	}
+	if globalPolicy != nil {
+		return globalPolicy, nil
+	}
+	return defaultCancellationPolicy, nil
+}
+
+// refundPercentText 退款比例文案
+func refundPercentText(percent int) string {
+	switch percent {
+	case 100:
+		return "全额退款"
+	case 0:
+		return "不退款"
+	default:
+		return fmt.Sprintf("退款%d%%", percent)
+	}
+}
+
+// cancellationTierText 单个退款档位的文案
+func cancellationTierText(tier CancellationTier) string {
+	if tier.HoursBefore == 0 {
+		return "预约开始前取消，" + refundPercentText(tier.Percent)
+	}
+	return fmt.Sprintf("预约开始前%d小时以上取消，%s", tier.HoursBefore, refundPercentText(tier.Percent))
+}
+
+// describeCancellationPolicy 取消退款政策的规则文案，档位无效时返回空
+func describeCancellationPolicy(policy *model.CancellationPolicyModel) []string {
+	tiers, err := parseCancellationTiers(policy.Tiers)
+	if err != nil {
+		return nil
+	}
+	rules := make([]string, 0, len(tiers)+2)
+	for _, tier := range tiers {
+		rules = append(rules, cancellationTierText(tier))
+	}
+	if tiers[len(tiers)-1].HoursBefore > 0 {
+		rules = append(rules, fmt.Sprintf("预约开始前%d小时内取消，不退款", tiers[len(tiers)-1].HoursBefore))
+	} else {
+		rules = append(rules, "预约开始后取消，不退款")
+	}
+	rules = append(rules, "护理员签到后取消，"+refundPercentText(policy.CheckedInPercent))
+	return rules
+}
+
+// QuoteOrderRefund 按订单适用的取消退款政策计算当前取消可退的金额
+// 护理员已签到时使用签到后的比例，否则按距预约开始的时间匹配退款档位
+func QuoteOrderRefund(order *model.OrderModel) (*RefundQuote, error) {
+	if !CanTransitionOrder(order, OrderEventRefundRequest) || order.PayStatus != 1 {
+		return nil, fmt.Errorf("订单当前状态为%s，不能取消退款", OrderStatusText(order.Status, order.RefundStatus))
+	}
+
+	var service *model.ServiceItemModel
+	if found, err := dao.ServiceImp.GetServiceById(order.ServiceId); err == nil {
+		service = found
+	} else {
+		LogError("获取服务信息失败，使用全局取消政策", err)
+	}
+	policy, err := resolveCancellationPolicy(service)
+	if err != nil {
+		return nil, fmt.Errorf("获取取消政策失败: %v", err)
+	}
+
+	checkedIn := false
+	if order.CaregiverId > 0 {
+		if record, err := dao.CaregiverImp.GetServiceRecord(order.Id, order.CaregiverId); err == nil && record.CheckInAt != nil {
+			checkedIn = true
+		}
+	}
+
+	return quoteRefund(order, policy, checkedIn, time.Now())
+}
+
+// quoteRefund 按取消政策计算now时刻取消可退的金额，checkedIn为护理员是否已签到
+func quoteRefund(order *model.OrderModel, policy *model.CancellationPolicyModel, checkedIn bool, now time.Time) (*RefundQuote, error) {
+	tiers, err := parseCancellationTiers(policy.Tiers)
+	if err != nil {
+		return nil, fmt.Errorf("取消政策%s配置错误: %v", policy.Name, err)
+	}
+
+	quote := &RefundQuote{
+		OrderId:     order.Id,
+		OrderNo:     order.OrderNo,
+		TotalAmount: order.TotalAmount,
+		PolicyName:  policy.Name,
+		PolicyRules: describeCancellationPolicy(policy),
+	}
+	// 按精确时间匹配档位，展示时保留一位小数；预约时间格式不正确的历史订单视为尚未开始
+	hoursToStart := math.Inf(1)
+	if start, ok := orderAppointmentStart(order); ok {
+		hoursToStart = start.Sub(now).Hours()
+		quote.HoursToStart = math.Round(hoursToStart*10) / 10
+	}
+
+	switch {
+	case checkedIn:
+		quote.Percent = policy.CheckedInPercent
+		quote.Rule = "护理员签到后取消，" + refundPercentText(policy.CheckedInPercent)
+	default:
+		quote.Rule = "预约开始后取消，不退款"
+		if last := tiers[len(tiers)-1]; last.HoursBefore > 0 {
+			quote.Rule = fmt.Sprintf("预约开始前%d小时内取消，不退款", last.HoursBefore)
+		}
+		for _, tier := range tiers {
+			if hoursToStart >= float64(tier.HoursBefore) {
+				quote.Percent = tier.Percent
+				quote.Rule = cancellationTierText(tier)
+				break
+			}
+		}
+	}
+	quote.RefundAmount = order.TotalAmount.MulRate(float64(quote.Percent) / 100)
+	quote.Fee = order.TotalAmount - quote.RefundAmount
+	quote.Refundable = quote.RefundAmount > 0
+	return quote, nil
+}
+
+// RequestPolicyRefund 用户按取消政策取消已支付订单：计算可退金额并提交退款申请，由管理员完成退款
+// confirmAmount 为用户确认时看到的可退金额，大于0且与当前计算结果不一致时拒绝，避免跨过档位后按旧金额提交
+func RequestPolicyRefund(order *model.OrderModel, actorId, reason string, confirmAmount model.Money) (*RefundQuote, error) {
+	quote, err := QuoteOrderRefund(order)
+	if err != nil {
+		return nil, err
+	}
+	if !quote.Refundable {
+		return quote, fmt.Errorf("根据取消政策（%s），当前取消不可退款，如需帮助请联系客服", quote.Rule)
+	}
+	if confirmAmount > 0 && confirmAmount != quote.RefundAmount {
+		return quote, fmt.Errorf("可退金额已变更为%s元，请确认后重试", quote.RefundAmount)
+	}
+
+	extra := map[string]interface{}{
+		"refundAmount": quote.RefundAmount,
+		"refundReason": reason,
+	}
+	logReason := quote.Rule
+	if reason != "" {
+		logReason = reason + "（" + quote.Rule + "）"
+	}
+	if err := TransitionOrder(order, OrderEventRefundRequest, OrderActorUser, actorId, logReason, extra); err != nil {
+		return quote, err
+	}
+	order.RefundAmount = quote.RefundAmount
+	order.RefundReason = reason
+	return quote, nil
+}
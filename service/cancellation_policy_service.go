@@ -0,0 +1,303 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// SaveCancellationPolicyRequest 新建或修改取消退款政策请求，Id为0时新建
+type SaveCancellationPolicyRequest struct {
+	Id               int32              `json:"id"`
+	Name             string             `json:"name"`
+	ServiceId        int32              `json:"serviceId"`
+	Category         string             `json:"category"`
+	Tiers            []CancellationTier `json:"tiers"`
+	CheckedInPercent int                `json:"checkedInPercent"`
+	Status           *int               `json:"status"` // 不传时为启用
+}
+
+// CancellationPolicyInfo 取消退款政策及其规则文案
+type CancellationPolicyInfo struct {
+	*model.CancellationPolicyModel
+	Rules []string `json:"rules"`
+}
+
+// OrderRefundQuoteHandler 用户取消已支付订单前查看按取消政策可退的金额
+func OrderRefundQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orderId, ok := parseTimelineOrderId(r)
+	if !ok {
+		http.Error(w, "无效的订单ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := dao.OrderImp.GetOrderById(orderId)
+	if err != nil || order == nil {
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	if !requireResourceOwner(w, r, ResourceOrder, order.Id, order.UserId, "") {
+		return
+	}
+
+	quote, err := QuoteOrderRefund(order)
+	if err != nil {
+		LogError("计算可退金额失败", err)
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := &OrderResponse{
+		Code: 0,
+		Data: quote,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// AdminOrderRefundQuoteHandler 管理员查看订单按取消政策可退的金额，用于确定退款金额
+func AdminOrderRefundQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermOrderView) {
+		return
+	}
+
+	orderId, ok := parseTimelineOrderId(r)
+	if !ok {
+		http.Error(w, "无效的订单ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := dao.OrderImp.GetOrderById(orderId)
+	if err != nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	quote, err := QuoteOrderRefund(order)
+	if err != nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: quote,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetCancellationPoliciesHandler 管理员查看取消退款政策
+func GetCancellationPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermServiceView) {
+		return
+	}
+
+	policies, err := dao.BookingImp.GetCancellationPolicies(false)
+	if err != nil {
+		LogError("获取取消退款政策失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取取消退款政策失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	list := make([]*CancellationPolicyInfo, 0, len(policies))
+	for _, policy := range policies {
+		list = append(list, &CancellationPolicyInfo{CancellationPolicyModel: policy, Rules: describeCancellationPolicy(policy)})
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"list": list,
+			"defaultPolicy": &CancellationPolicyInfo{
+				CancellationPolicyModel: defaultCancellationPolicy,
+				Rules:                   describeCancellationPolicy(defaultCancellationPolicy),
+			},
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SaveCancellationPolicyHandler 管理员新建或修改取消退款政策
+func SaveCancellationPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermRefundPolicyUpdate) {
+		return
+	}
+
+	var req SaveCancellationPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+
+	policy, errMsg := buildCancellationPolicy(&req)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	if policy.Id > 0 {
+		if _, err := dao.BookingImp.GetCancellationPolicyById(policy.Id); err != nil {
+			response := &AdminResponse{
+				Code:     -1,
+				ErrorMsg: "取消退款政策不存在",
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
+
+	if err := dao.BookingImp.SaveCancellationPolicy(policy); err != nil {
+		LogError("保存取消退款政策失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "保存取消退款政策失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("管理员保存取消退款政策", map[string]interface{}{
+		"policyId":         policy.Id,
+		"serviceId":        policy.ServiceId,
+		"category":         policy.Category,
+		"tiers":            policy.Tiers,
+		"checkedInPercent": policy.CheckedInPercent,
+		"adminId":          GetAuthUserId(r),
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: &CancellationPolicyInfo{CancellationPolicyModel: policy, Rules: describeCancellationPolicy(policy)},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// buildCancellationPolicy 校验请求并转换为取消退款政策，校验失败时返回错误信息
+func buildCancellationPolicy(req *SaveCancellationPolicyRequest) (*model.CancellationPolicyModel, string) {
+	if req.ServiceId > 0 && req.Category != "" {
+		return nil, "服务项目和服务分类只能指定一个"
+	}
+	if req.CheckedInPercent < 0 || req.CheckedInPercent > 100 {
+		return nil, "签到后退款百分比必须在0-100之间"
+	}
+	status := 1
+	if req.Status != nil {
+		status = *req.Status
+	}
+	if status != 0 && status != 1 {
+		return nil, "无效的状态"
+	}
+
+	// 统一经过解析校验，保证存储格式一致且按提前小时数排序
+	tiers, err := parseCancellationTiers(formatCancellationTiers(req.Tiers))
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	return &model.CancellationPolicyModel{
+		Id:               req.Id,
+		Name:             req.Name,
+		ServiceId:        req.ServiceId,
+		Category:         req.Category,
+		Tiers:            formatCancellationTiers(tiers),
+		CheckedInPercent: req.CheckedInPercent,
+		Status:           status,
+	}, ""
+}
+
+// DeleteCancellationPolicyHandler 管理员删除取消退款政策
+func DeleteCancellationPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermRefundPolicyUpdate) {
+		return
+	}
+
+	var req DeleteBookingRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Id <= 0 {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+
+	if err := dao.BookingImp.DeleteCancellationPolicy(req.Id); err != nil {
+		LogError("删除取消退款政策失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "删除取消退款政策失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("管理员删除取消退款政策", map[string]interface{}{
+		"policyId": req.Id,
+		"adminId":  GetAuthUserId(r),
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{"id": req.Id},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
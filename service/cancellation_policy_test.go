@@ -0,0 +1,120 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"wxcloudrun-golang/db/model"
+)
+
+func TestParseCancellationTiers(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []CancellationTier
+		wantErr bool
+	}{
+		{"24:100,0:50", []CancellationTier{{24, 100}, {0, 50}}, false},
+		{"0:50, 48:100 ,12:80", []CancellationTier{{48, 100}, {12, 80}, {0, 50}}, false},
+		{"6:0", []CancellationTier{{6, 0}}, false},
+		{"", nil, true},
+		{"24", nil, true},
+		{"24:100:1", nil, true},
+		{"-1:100", nil, true},
+		{"a:100", nil, true},
+		{"24:101", nil, true},
+		{"24:-1", nil, true},
+		{"24:100,24:50", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCancellationTiers(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCancellationTiers(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseCancellationTiers(%q) = %v, want %v", tt.value, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseCancellationTiers(%q) = %v, want %v", tt.value, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestQuoteRefund(t *testing.T) {
+	start := time.Date(2026, 3, 10, 8, 0, 0, 0, time.Local)
+	customPolicy := &model.CancellationPolicyModel{
+		Name:             "自定义",
+		Tiers:            "48:100,12:50",
+		CheckedInPercent: 20,
+		Status:           1,
+	}
+
+	tests := []struct {
+		name            string
+		policy          *model.CancellationPolicyModel
+		appointmentTime string
+		checkedIn       bool
+		now             time.Time
+		wantPercent     int
+		wantRefund      model.Money
+		wantHours       float64
+		wantRule        string
+	}{
+		{"默认政策提前24小时以上", defaultCancellationPolicy, "08:00", false, start.Add(-25 * time.Hour), 100, 1999, 25, "预约开始前24小时以上取消，全额退款"},
+		{"默认政策恰好提前24小时", defaultCancellationPolicy, "08:00", false, start.Add(-24 * time.Hour), 100, 1999, 24, "预约开始前24小时以上取消，全额退款"},
+		{"默认政策24小时内差1秒", defaultCancellationPolicy, "08:00", false, start.Add(-24*time.Hour + time.Second), 50, 1000, 24, "预约开始前取消，退款50%"},
+		{"默认政策24小时内显示为24.0", defaultCancellationPolicy, "08:00", false, start.Add(-23*time.Hour - 57*time.Minute), 50, 1000, 24, "预约开始前取消，退款50%"},
+		{"默认政策恰好开始", defaultCancellationPolicy, "08:00", false, start, 50, 1000, 0, "预约开始前取消，退款50%"},
+		{"默认政策开始后1秒", defaultCancellationPolicy, "08:00", false, start.Add(time.Second), 0, 0, 0, "预约开始后取消，不退款"},
+		{"默认政策开始后", defaultCancellationPolicy, "08:00", false, start.Add(2 * time.Hour), 0, 0, -2, "预约开始后取消，不退款"},
+		{"默认政策已签到", defaultCancellationPolicy, "08:00", true, start.Add(-30 * time.Hour), 0, 0, 30, "护理员签到后取消，不退款"},
+		{"自定义政策恰好提前12小时", customPolicy, "08:00", false, start.Add(-12 * time.Hour), 50, 1000, 12, "预约开始前12小时以上取消，退款50%"},
+		{"自定义政策12小时内", customPolicy, "08:00", false, start.Add(-12*time.Hour + time.Minute), 0, 0, 12, "预约开始前12小时内取消，不退款"},
+		{"自定义政策恰好提前48小时", customPolicy, "08:00", false, start.Add(-48 * time.Hour), 100, 1999, 48, "预约开始前48小时以上取消，全额退款"},
+		{"自定义政策已签到", customPolicy, "08:00", true, start.Add(time.Hour), 20, 400, -1, "护理员签到后取消，退款20%"},
+		{"预约时间格式不正确视为未开始", defaultCancellationPolicy, "上午", false, start.Add(time.Hour), 100, 1999, 0, "预约开始前24小时以上取消，全额退款"},
+	}
+
+	for _, tt := range tests {
+		order := &model.OrderModel{
+			Id:              1,
+			OrderNo:         "ORDER1",
+			Status:          model.OrderStatusPaid,
+			PayStatus:       1,
+			TotalAmount:     1999,
+			AppointmentDate: "2026-03-10",
+			AppointmentTime: tt.appointmentTime,
+		}
+		quote, err := quoteRefund(order, tt.policy, tt.checkedIn, tt.now)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if quote.Percent != tt.wantPercent || quote.RefundAmount != tt.wantRefund || quote.HoursToStart != tt.wantHours || quote.Rule != tt.wantRule {
+			t.Errorf("%s: got (percent=%d, refund=%s, hours=%v, rule=%s), want (%d, %s, %v, %s)",
+				tt.name, quote.Percent, quote.RefundAmount, quote.HoursToStart, quote.Rule,
+				tt.wantPercent, tt.wantRefund, tt.wantHours, tt.wantRule)
+		}
+		if quote.Fee != order.TotalAmount-quote.RefundAmount {
+			t.Errorf("%s: fee %s + refund %s != total %s", tt.name, quote.Fee, quote.RefundAmount, order.TotalAmount)
+		}
+		if quote.Refundable != (tt.wantRefund > 0) {
+			t.Errorf("%s: refundable = %v", tt.name, quote.Refundable)
+		}
+	}
+}
+
+func TestQuoteRefundInvalidPolicy(t *testing.T) {
+	order := &model.OrderModel{Status: model.OrderStatusPaid, PayStatus: 1, TotalAmount: 1000}
+	policy := &model.CancellationPolicyModel{Name: "错误", Tiers: "24:150"}
+	if _, err := quoteRefund(order, policy, false, time.Now()); err == nil {
+		t.Error("expected error for invalid tiers")
+	}
+}
@@ -0,0 +1,629 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+
+	"gorm.io/gorm"
+)
+
+// maxServiceNoteLength 单次提交的服务备注最大字数
+const maxServiceNoteLength = 500
+
+// CaregiverJobRequest 护理员操作订单的请求
+type CaregiverJobRequest struct {
+	OrderId   int32    `json:"orderId"`
+	Reason    string   `json:"reason"`    // 拒单原因
+	Latitude  *float64 `json:"latitude"`  // 签到、签退时的纬度（GCJ-02，wx.getLocation返回值）
+	Longitude *float64 `json:"longitude"` // 签到、签退时的经度
+	Note      string   `json:"note"`      // 服务备注
+	FileIds   []int32  `json:"fileIds"`   // 通过 /api/upload 上传的服务照片ID
+}
+
+// CaregiverJobItem 护理员端的订单信息，接单前不返回联系电话和详细地址
+type CaregiverJobItem struct {
+	OrderId          int32      `json:"orderId"`
+	OrderNo          string     `json:"orderNo"`
+	ServiceName      string     `json:"serviceName"`
+	AppointmentDate  string     `json:"appointmentDate"`
+	AppointmentTime  string     `json:"appointmentTime"`
+	Status           int        `json:"status"`
+	StatusText       string     `json:"statusText"`
+	AcceptStatus     int        `json:"acceptStatus"` // 0-待接单，1-已接单，2-已拒单
+	PatientName      string     `json:"patientName"`
+	DiseaseInfo      string     `json:"diseaseInfo"`
+	NeedToiletAssist int        `json:"needToiletAssist"`
+	Remark           string     `json:"remark"`
+	ContactName      string     `json:"contactName"`
+	ContactPhone     string     `json:"contactPhone,omitempty"`
+	City             string     `json:"city"`
+	District         string     `json:"district"`
+	Address          string     `json:"address,omitempty"`
+	Latitude         *float64   `json:"latitude,omitempty"`
+	Longitude        *float64   `json:"longitude,omitempty"`
+	CheckInAt        *time.Time `json:"checkInAt"`
+	CheckOutAt       *time.Time `json:"checkOutAt"`
+	CompletedAt      *time.Time `json:"completedAt"`
+}
+
+// ServicePhoto 服务照片
+type ServicePhoto struct {
+	Id      int32  `json:"id"`
+	FileUrl string `json:"fileUrl"`
+}
+
+// requireCaregiver 校验当前登录用户是否为可接单的护理员，不是时写入403响应
+func requireCaregiver(w http.ResponseWriter, r *http.Request) (*model.CaregiverModel, bool) {
+	userId := GetAuthUserId(r)
+	caregiver, err := dao.CaregiverImp.GetCaregiverByUserId(userId)
+	if err == nil && caregiver.Status != model.CaregiverStatusDisabled {
+		return caregiver, true
+	}
+
+	LogError("非护理员访问护理员接口", fmt.Errorf("userId=%s", userId))
+	response := &OrderResponse{
+		Code:     -1,
+		ErrorMsg: "当前账号不是护理员",
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(response)
+	return nil, false
+}
+
+// getCaregiverJob 获取派给该护理员的订单，订单不存在或未派给该护理员时返回nil
+func getCaregiverJob(caregiver *model.CaregiverModel, orderId int32) *model.OrderModel {
+	if orderId <= 0 {
+		return nil
+	}
+	order, err := dao.OrderImp.GetOrderById(orderId)
+	if err != nil || order.CaregiverId != caregiver.Id {
+		return nil
+	}
+	return order
+}
+
+// buildCaregiverJobItem 组装护理员端的订单信息，record为nil表示尚未接单
+func buildCaregiverJobItem(order *model.OrderModel, record *model.ServiceRecordModel) *CaregiverJobItem {
+	item := &CaregiverJobItem{
+		OrderId:          order.Id,
+		OrderNo:          order.OrderNo,
+		ServiceName:      order.ServiceName,
+		AppointmentDate:  order.AppointmentDate,
+		AppointmentTime:  order.AppointmentTime,
+		Status:           order.Status,
+		StatusText:       OrderStatusText(order.Status, order.RefundStatus),
+		DiseaseInfo:      order.DiseaseInfo,
+		NeedToiletAssist: order.NeedToiletAssist,
+		Remark:           order.Remark,
+	}
+	accepted := false
+	if record != nil {
+		item.AcceptStatus = record.AcceptStatus
+		item.CheckInAt = record.CheckInAt
+		item.CheckOutAt = record.CheckOutAt
+		item.CompletedAt = record.CompletedAt
+		accepted = record.AcceptStatus == model.JobAcceptAccepted
+	}
+
+	if patient, err := dao.UserExtendImp.GetPatientById(order.PatientId); err == nil && patient != nil {
+		item.PatientName = patient.Name
+	}
+	if address, err := dao.UserExtendImp.GetAddressById(order.AddressId); err == nil && address != nil {
+		item.ContactName = address.Name
+		item.City = address.City
+		item.District = address.District
+		// 接单后才返回联系电话和详细地址
+		if accepted {
+			item.ContactPhone = address.Phone
+			item.Address = address.Province + address.City + address.District + address.Address
+			item.Latitude = address.Latitude
+			item.Longitude = address.Longitude
+		}
+	}
+	return item
+}
+
+// distanceMeters 计算两个经纬度之间的球面距离（米）
+func distanceMeters(lat1, lng1, lat2, lng2 float64) int {
+	const earthRadius = 6371000.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return int(math.Round(earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))))
+}
+
+// checkServiceLocation 校验签到、签退位置是否在服务地址附近
+// 地址未保存坐标时无法校验，返回的距离为nil
+func checkServiceLocation(order *model.OrderModel, latitude, longitude float64) (*int, error) {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return nil, fmt.Errorf("无效的位置坐标")
+	}
+	address, err := dao.UserExtendImp.GetAddressById(order.AddressId)
+	if err != nil || address == nil || address.Latitude == nil || address.Longitude == nil {
+		LogStep("服务地址没有坐标，跳过位置校验", map[string]interface{}{
+			"orderNo":   order.OrderNo,
+			"addressId": order.AddressId,
+		})
+		return nil, nil
+	}
+
+	distance := distanceMeters(latitude, longitude, *address.Latitude, *address.Longitude)
+	radius := config.GetCaregiverConfig().CheckInRadiusMeters
+	if distance > radius {
+		return nil, fmt.Errorf("当前位置距服务地址约%d米，超出签到范围（%d米）", distance, radius)
+	}
+	return &distance, nil
+}
+
+// CaregiverJobsHandler 护理员查看派给自己的订单
+// scope=upcoming（默认）为待服务订单，scope=history为已完成订单
+func CaregiverJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caregiver, ok := requireCaregiver(w, r)
+	if !ok {
+		return
+	}
+
+	page := 1
+	pageSize := 20
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		fmt.Sscanf(pageStr, "%d", &page)
+	}
+	if pageSizeStr := r.URL.Query().Get("pageSize"); pageSizeStr != "" {
+		fmt.Sscanf(pageSizeStr, "%d", &pageSize)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 20
+	}
+
+	statuses := []int{model.OrderStatusAssigned}
+	if r.URL.Query().Get("scope") == "history" {
+		statuses = []int{model.OrderStatusCompleted}
+	}
+
+	orders, total, err := dao.CaregiverImp.GetCaregiverOrders(caregiver.Id, statuses, page, pageSize)
+	if err != nil {
+		LogError("获取护理员订单失败", err)
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "获取订单失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	orderIds := make([]int32, 0, len(orders))
+	for _, order := range orders {
+		orderIds = append(orderIds, order.Id)
+	}
+	records, err := dao.CaregiverImp.GetServiceRecordsByOrderIds(caregiver.Id, orderIds)
+	if err != nil {
+		LogError("获取服务记录失败", err)
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "获取服务记录失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	recordByOrder := make(map[int32]*model.ServiceRecordModel)
+	for _, record := range records {
+		recordByOrder[record.OrderId] = record
+	}
+
+	jobs := make([]*CaregiverJobItem, 0, len(orders))
+	for _, order := range orders {
+		jobs = append(jobs, buildCaregiverJobItem(order, recordByOrder[order.Id]))
+	}
+
+	response := &OrderResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"list":     jobs,
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+			"hasMore":  (page * pageSize) < int(total),
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CaregiverJobDetailHandler 护理员查看订单详情和服务记录
+func CaregiverJobDetailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caregiver, ok := requireCaregiver(w, r)
+	if !ok {
+		return
+	}
+
+	orderId, ok := parseTimelineOrderId(r)
+	if !ok {
+		http.Error(w, "无效的订单ID", http.StatusBadRequest)
+		return
+	}
+
+	order := getCaregiverJob(caregiver, orderId)
+	if order == nil {
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	var record *model.ServiceRecordModel
+	if found, err := dao.CaregiverImp.GetServiceRecord(order.Id, caregiver.Id); err == nil {
+		record = found
+	}
+
+	notes := ""
+	photos := []*ServicePhoto{}
+	if record != nil {
+		notes = record.Notes
+		for _, idStr := range splitTemplateList(record.PhotoFileIds) {
+			fileId, err := strconv.Atoi(idStr)
+			if err != nil {
+				continue
+			}
+			if file, err := dao.UploadImp.GetFileById(int32(fileId)); err == nil && file.Status == 1 {
+				photos = append(photos, &ServicePhoto{Id: file.Id, FileUrl: file.FileUrl})
+			}
+		}
+	}
+
+	response := &OrderResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"job":    buildCaregiverJobItem(order, record),
+			"notes":  notes,
+			"photos": photos,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// decodeCaregiverJobRequest 解析护理员操作请求并获取对应订单，失败时已写入响应
+func decodeCaregiverJobRequest(w http.ResponseWriter, r *http.Request) (*model.CaregiverModel, *model.OrderModel, *CaregiverJobRequest, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return nil, nil, nil, false
+	}
+
+	caregiver, ok := requireCaregiver(w, r)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	var req CaregiverJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+
+	order := getCaregiverJob(caregiver, req.OrderId)
+	if order == nil {
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return nil, nil, nil, false
+	}
+	return caregiver, order, &req, true
+}
+
+// writeCaregiverJobError 返回护理员操作失败
+func writeCaregiverJobError(w http.ResponseWriter, action string, err error) {
+	LogError(action+"失败", err)
+	response := &OrderResponse{
+		Code:     -1,
+		ErrorMsg: action + "失败: " + err.Error(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeCaregiverJobResult 返回护理员操作后的订单信息
+func writeCaregiverJobResult(w http.ResponseWriter, caregiver *model.CaregiverModel, order *model.OrderModel) {
+	var record *model.ServiceRecordModel
+	if found, err := dao.CaregiverImp.GetServiceRecord(order.Id, caregiver.Id); err == nil {
+		record = found
+	}
+	response := &OrderResponse{
+		Code: 0,
+		Data: buildCaregiverJobItem(order, record),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CaregiverAcceptJobHandler 护理员接单
+func CaregiverAcceptJobHandler(w http.ResponseWriter, r *http.Request) {
+	caregiver, order, _, ok := decodeCaregiverJobRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := TransitionOrderWith(order, OrderEventAccept, OrderActorCaregiver, caregiver.UserId, "", nil, func(uow *dao.UnitOfWork) error {
+		changed, err := dao.CaregiverImp.SetJobAcceptStatusTx(uow, order.Id, caregiver.Id, model.JobAcceptAccepted, "")
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return fmt.Errorf("已接单，请勿重复操作")
+		}
+		return nil
+	})
+	if err != nil {
+		writeCaregiverJobError(w, "接单", err)
+		return
+	}
+
+	SendSSEMessageToUser(order.UserId, "orderAccepted", map[string]interface{}{
+		"orderId": order.Id,
+		"orderNo": order.OrderNo,
+		"message": fmt.Sprintf("护理员%s已接单", caregiver.Name),
+	})
+	writeCaregiverJobResult(w, caregiver, order)
+}
+
+// CaregiverDeclineJobHandler 护理员拒单，订单退回已支付状态等待重新派单
+func CaregiverDeclineJobHandler(w http.ResponseWriter, r *http.Request) {
+	caregiver, order, req, ok := decodeCaregiverJobRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if record, err := dao.CaregiverImp.GetServiceRecord(order.Id, caregiver.Id); err == nil && record.CheckInAt != nil {
+		writeCaregiverJobError(w, "拒单", fmt.Errorf("已签到的订单不能拒单，请联系调度"))
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	extra := map[string]interface{}{
+		"caregiverId": 0,
+		"assignedAt":  nil,
+	}
+	err := TransitionOrderWith(order, OrderEventDecline, OrderActorCaregiver, caregiver.UserId, reason, extra, func(uow *dao.UnitOfWork) error {
+		_, err := dao.CaregiverImp.SetJobAcceptStatusTx(uow, order.Id, caregiver.Id, model.JobAcceptDeclined, reason)
+		return err
+	})
+	if err != nil {
+		writeCaregiverJobError(w, "拒单", err)
+		return
+	}
+	order.CaregiverId = 0
+	order.AssignedAt = nil
+
+	SendSSEMessageToAdmins(PermOrderDispatch, "caregiverJobDeclined", map[string]interface{}{
+		"orderId":       order.Id,
+		"orderNo":       order.OrderNo,
+		"caregiverId":   caregiver.Id,
+		"caregiverName": caregiver.Name,
+		"reason":        reason,
+		"message":       fmt.Sprintf("护理员%s拒绝了订单%s，请重新派单", caregiver.Name, order.OrderNo),
+	})
+
+	response := &OrderResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"orderId": order.Id,
+			"orderNo": order.OrderNo,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CaregiverCheckInHandler 护理员到达服务地址签到，仅限预约当天
+func CaregiverCheckInHandler(w http.ResponseWriter, r *http.Request) {
+	handleCaregiverCheck(w, r, OrderEventCheckIn)
+}
+
+// CaregiverCheckOutHandler 护理员服务结束签退
+func CaregiverCheckOutHandler(w http.ResponseWriter, r *http.Request) {
+	handleCaregiverCheck(w, r, OrderEventCheckOut)
+}
+
+// handleCaregiverCheck 签到、签退共用处理，校验位置后在同一事务中更新服务记录和订单时间线
+func handleCaregiverCheck(w http.ResponseWriter, r *http.Request, event string) {
+	caregiver, order, req, ok := decodeCaregiverJobRequest(w, r)
+	if !ok {
+		return
+	}
+
+	action := OrderEventText(event)
+	if req.Latitude == nil || req.Longitude == nil {
+		http.Error(w, "缺少位置信息", http.StatusBadRequest)
+		return
+	}
+
+	record, err := dao.CaregiverImp.GetServiceRecord(order.Id, caregiver.Id)
+	if err != nil || record.AcceptStatus != model.JobAcceptAccepted {
+		writeCaregiverJobError(w, action, fmt.Errorf("请先接单"))
+		return
+	}
+	if event == OrderEventCheckIn && order.AppointmentDate != time.Now().Format("2006-01-02") {
+		writeCaregiverJobError(w, action, fmt.Errorf("只能在预约当天签到"))
+		return
+	}
+
+	distance, err := checkServiceLocation(order, *req.Latitude, *req.Longitude)
+	if err != nil {
+		writeCaregiverJobError(w, action, err)
+		return
+	}
+
+	reason := ""
+	if distance != nil {
+		reason = fmt.Sprintf("距服务地址%d米", *distance)
+	}
+	err = TransitionOrderWith(order, event, OrderActorCaregiver, caregiver.UserId, reason, nil, func(uow *dao.UnitOfWork) error {
+		var changed bool
+		var err error
+		if event == OrderEventCheckIn {
+			changed, err = dao.CaregiverImp.CheckInTx(uow, record.Id, *req.Latitude, *req.Longitude, distance)
+			if err == nil && !changed {
+				err = fmt.Errorf("已签到，请勿重复操作")
+			}
+		} else {
+			changed, err = dao.CaregiverImp.CheckOutTx(uow, record.Id, *req.Latitude, *req.Longitude, distance)
+			if err == nil && !changed {
+				err = fmt.Errorf("未签到或已签退")
+			}
+		}
+		return err
+	})
+	if err != nil {
+		writeCaregiverJobError(w, action, err)
+		return
+	}
+
+	LogInfo("护理员"+action, map[string]interface{}{
+		"orderNo":     order.OrderNo,
+		"caregiverId": caregiver.Id,
+		"distance":    distance,
+	})
+	writeCaregiverJobResult(w, caregiver, order)
+}
+
+// CaregiverServiceRecordHandler 护理员提交服务备注和照片，照片需先通过 /api/upload 上传
+func CaregiverServiceRecordHandler(w http.ResponseWriter, r *http.Request) {
+	caregiver, order, req, ok := decodeCaregiverJobRequest(w, r)
+	if !ok {
+		return
+	}
+
+	note := strings.TrimSpace(req.Note)
+	if note == "" && len(req.FileIds) == 0 {
+		http.Error(w, "请填写备注或上传照片", http.StatusBadRequest)
+		return
+	}
+	if len([]rune(note)) > maxServiceNoteLength {
+		http.Error(w, fmt.Sprintf("备注不能超过%d字", maxServiceNoteLength), http.StatusBadRequest)
+		return
+	}
+	if order.Status != model.OrderStatusAssigned {
+		writeCaregiverJobError(w, "提交服务记录", fmt.Errorf("订单当前状态为%s，不能提交服务记录", OrderStatusText(order.Status, order.RefundStatus)))
+		return
+	}
+
+	record, err := dao.CaregiverImp.GetServiceRecord(order.Id, caregiver.Id)
+	if err != nil || record.AcceptStatus != model.JobAcceptAccepted {
+		writeCaregiverJobError(w, "提交服务记录", fmt.Errorf("请先接单"))
+		return
+	}
+
+	existing := make(map[string]bool)
+	for _, idStr := range splitTemplateList(record.PhotoFileIds) {
+		existing[idStr] = true
+	}
+	var newFileIds []string
+	for _, fileId := range req.FileIds {
+		file, err := dao.UploadImp.GetFileById(fileId)
+		if err != nil || file.Status != 1 || file.UserId != caregiver.UserId {
+			http.Error(w, fmt.Sprintf("文件不存在: %d", fileId), http.StatusBadRequest)
+			return
+		}
+		if file.FileType != "image" {
+			http.Error(w, fmt.Sprintf("只能上传图片: %d", fileId), http.StatusBadRequest)
+			return
+		}
+		idStr := strconv.Itoa(int(fileId))
+		if !existing[idStr] {
+			existing[idStr] = true
+			newFileIds = append(newFileIds, idStr)
+		}
+	}
+
+	notes := ""
+	if note != "" {
+		notes = fmt.Sprintf("[%s] %s\n", time.Now().Format("2006-01-02 15:04"), note)
+	}
+	if err := dao.CaregiverImp.AppendServiceRecordNotes(record.Id, notes, strings.Join(newFileIds, ",")); err != nil {
+		writeCaregiverJobError(w, "提交服务记录", err)
+		return
+	}
+
+	LogInfo("护理员提交服务记录", map[string]interface{}{
+		"orderNo":     order.OrderNo,
+		"caregiverId": caregiver.Id,
+		"photoCount":  len(newFileIds),
+	})
+	writeCaregiverJobResult(w, caregiver, order)
+}
+
+// CaregiverCompleteJobHandler 护理员签退后确认服务完成，订单状态变为已完成
+func CaregiverCompleteJobHandler(w http.ResponseWriter, r *http.Request) {
+	caregiver, order, req, ok := decodeCaregiverJobRequest(w, r)
+	if !ok {
+		return
+	}
+
+	record, err := dao.CaregiverImp.GetServiceRecord(order.Id, caregiver.Id)
+	if err == gorm.ErrRecordNotFound || (err == nil && record.AcceptStatus != model.JobAcceptAccepted) {
+		writeCaregiverJobError(w, "完成服务", fmt.Errorf("请先接单"))
+		return
+	}
+	if err != nil {
+		writeCaregiverJobError(w, "完成服务", err)
+		return
+	}
+
+	err = TransitionOrderWith(order, OrderEventComplete, OrderActorCaregiver, caregiver.UserId, strings.TrimSpace(req.Note), nil, func(uow *dao.UnitOfWork) error {
+		changed, err := dao.CaregiverImp.CompleteServiceRecordTx(uow, record.Id)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return fmt.Errorf("请先签退")
+		}
+		return nil
+	})
+	if err != nil {
+		writeCaregiverJobError(w, "完成服务", err)
+		return
+	}
+
+	SendSSEMessageToUser(order.UserId, "orderCompleted", map[string]interface{}{
+		"orderId": order.Id,
+		"orderNo": order.OrderNo,
+		"message": "服务已完成，感谢您的信任",
+	})
+	LogInfo("护理员完成服务", map[string]interface{}{
+		"orderNo":     order.OrderNo,
+		"caregiverId": caregiver.Id,
+	})
+	writeCaregiverJobResult(w, caregiver, order)
+}
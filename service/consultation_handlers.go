@@ -2,7 +2,6 @@ package service
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -17,9 +16,8 @@ func CreateConsultationHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		UserID    interface{} `json:"userId"` // 使用interface{}接受任何类型
-		UserName  string      `json:"userName"`
-		UserPhone string      `json:"userPhone"`
+		UserName  string `json:"userName"`
+		UserPhone string `json:"userPhone"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -33,16 +31,8 @@ func CreateConsultationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 转换UserID为字符串
-	var userID string
-	switch v := req.UserID.(type) {
-	case string:
-		userID = v
-	case int, int32, int64, float32, float64:
-		userID = fmt.Sprintf("%v", v)
-	default:
-		userID = fmt.Sprintf("%v", v)
-	}
+	// 咨询用户以登录令牌为准
+	userID := GetAuthUserId(r)
 
 	// 创建咨询会话
 	consultationService := NewConsultationService()
@@ -86,6 +76,11 @@ func GetConsultationMessagesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取消息
+	// 只能查看自己的咨询，客服可以查看所有咨询
+	if _, ok := authorizeConsultation(w, r, uint(consultationID)); !ok {
+		return
+	}
+
 	consultationService := NewConsultationService()
 	messages, err := consultationService.GetConsultationMessages(uint(consultationID))
 	if err != nil {
@@ -147,6 +142,13 @@ func SendConsultationMessageHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "SenderType is required", http.StatusBadRequest)
 		return
 	}
+	// 只有拥有咨询处理权限的管理员才能以客服身份回复
+	if req.SenderType == "admin" {
+		if !hasAdminSessionPermission(r, PermConsultationReply) {
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
+		}
+	}
 
 	// 转换ConsultationID为uint
 	var consultationID uint
@@ -181,6 +183,13 @@ func SendConsultationMessageHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[DEBUG] 转换后的ConsultationID: %d", consultationID)
 
 	// 发送消息
+	// 用户只能在自己的咨询中发送消息，客服回复已在上面校验权限
+	if req.SenderType != "admin" {
+		if _, ok := authorizeConsultation(w, r, consultationID); !ok {
+			return
+		}
+	}
+
 	consultationService := NewConsultationService()
 	message, err := consultationService.SendMessage(consultationID, req.Content, req.SenderType)
 	if err != nil {
@@ -217,6 +226,11 @@ func GetConsultationStatusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取状态
+	// 只能查看自己的咨询，客服可以查看所有咨询
+	if _, ok := authorizeConsultation(w, r, uint(consultationID)); !ok {
+		return
+	}
+
 	consultationService := NewConsultationService()
 	status, err := consultationService.GetConsultationStatus(uint(consultationID))
 	if err != nil {
@@ -257,6 +271,11 @@ func CloseConsultationHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 关闭咨询会话
+	// 只能关闭自己的咨询，客服可以关闭所有咨询
+	if _, ok := authorizeConsultation(w, r, req.ConsultationID); !ok {
+		return
+	}
+
 	consultationService := NewConsultationService()
 	err := consultationService.CloseConsultation(req.ConsultationID)
 	if err != nil {
@@ -281,6 +300,10 @@ func GetActiveConsultationsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAdminPermission(w, r, PermConsultationReply) {
+		return
+	}
+
 	// 获取活跃咨询
 	consultationService := NewConsultationService()
 	consultations, err := consultationService.GetActiveConsultations()
@@ -306,6 +329,10 @@ func GetConsultationStatsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAdminPermission(w, r, PermConsultationReply) {
+		return
+	}
+
 	// 获取统计信息
 	consultationService := NewConsultationService()
 	stats, err := consultationService.GetConsultationStats()
@@ -329,6 +356,10 @@ func GetUnreadNotificationsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAdminPermission(w, r, PermConsultationReply) {
+		return
+	}
+
 	// 获取未读通知
 	consultationService := NewConsultationService()
 	notifications, err := consultationService.GetUnreadNotifications()
@@ -354,6 +385,10 @@ func MarkNotificationAsReadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAdminPermission(w, r, PermConsultationReply) {
+		return
+	}
+
 	var req struct {
 		NotificationID uint `json:"notificationId"`
 	}
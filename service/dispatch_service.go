@@ -0,0 +1,481 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// SaveCaregiverRequest 新建或修改护理员请求，Id为0时新建
+type SaveCaregiverRequest struct {
+	Id             int32    `json:"id"`
+	UserId         string   `json:"userId"`
+	Name           string   `json:"name"`
+	Phone          string   `json:"phone"`
+	Gender         int      `json:"gender"`
+	Skills         []string `json:"skills"`
+	Regions        []string `json:"regions"`
+	Certifications []string `json:"certifications"`
+	Status         *int     `json:"status"` // 为空时默认在岗
+	Remark         string   `json:"remark"`
+}
+
+// DispatchOrderRequest 派单（改派）请求
+type DispatchOrderRequest struct {
+	OrderId     int32  `json:"orderId"`
+	CaregiverId int32  `json:"caregiverId"`
+	Reason      string `json:"reason"`
+}
+
+// DispatchCandidate 可派单的护理员及匹配情况
+type DispatchCandidate struct {
+	Caregiver       *model.CaregiverModel `json:"caregiver"`
+	SkillMatched    bool                  `json:"skillMatched"`              // 技能覆盖订单的服务分类
+	RegionMatched   bool                  `json:"regionMatched"`             // 服务区域覆盖订单地址
+	Conflict        bool                  `json:"conflict"`                  // 同一时间段已有其他派单
+	ConflictOrderNo string                `json:"conflictOrderNo,omitempty"` // 冲突的订单号
+	Current         bool                  `json:"current"`                   // 是否为订单当前的护理员
+}
+
+// joinCaregiverList 去除空白和重复项后拼接为逗号分隔的字段
+func joinCaregiverList(items []string) string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item != "" && !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+// caregiverListCovers 护理员的技能或区域字段是否覆盖任一给定值，字段为空表示不限
+func caregiverListCovers(list string, values ...string) bool {
+	items := splitTemplateList(list)
+	if len(items) == 0 {
+		return true
+	}
+	for _, item := range items {
+		for _, value := range values {
+			if value != "" && item == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DispatchOrder 将已支付订单派给护理员，或将已派单订单改派给其他护理员
+// 冲突检查和状态变更在同一事务中完成，成功后通知下单用户和相关护理员
+func DispatchOrder(order *model.OrderModel, caregiver *model.CaregiverModel, adminUserId, reason string) error {
+	event := OrderEventAssign
+	if order.Status == model.OrderStatusAssigned {
+		event = OrderEventReassign
+		if order.CaregiverId == caregiver.Id {
+			return fmt.Errorf("订单已派给该护理员")
+		}
+	}
+	if !CanTransitionOrder(order, event) {
+		return fmt.Errorf("订单当前状态为%s，不允许%s", OrderStatusText(order.Status, order.RefundStatus), OrderEventText(event))
+	}
+
+	previousCaregiverId := order.CaregiverId
+	assignedAt := time.Now()
+	extra := map[string]interface{}{
+		"caregiverId": caregiver.Id,
+		"assignedAt":  &assignedAt,
+	}
+	logReason := fmt.Sprintf("护理员：%s(#%d)", caregiver.Name, caregiver.Id)
+	if reason != "" {
+		logReason += "；" + reason
+	}
+
+	err := TransitionOrderWith(order, event, OrderActorAdmin, adminUserId, logReason, extra, func(uow *dao.UnitOfWork) error {
+		return dao.CaregiverImp.CheckCaregiverAvailableTx(uow, caregiver.Id, order.Id, order.AppointmentDate, order.AppointmentTime)
+	})
+	if err != nil {
+		return err
+	}
+	order.CaregiverId = caregiver.Id
+	order.AssignedAt = &assignedAt
+
+	notifyOrderDispatched(order, caregiver, previousCaregiverId)
+	return nil
+}
+
+// notifyOrderDispatched 派单成功后通过SSE通知下单用户、新护理员，改派时同时通知原护理员
+func notifyOrderDispatched(order *model.OrderModel, caregiver *model.CaregiverModel, previousCaregiverId int32) {
+	SendSSEMessageToUser(order.UserId, "orderAssigned", map[string]interface{}{
+		"orderId":         order.Id,
+		"orderNo":         order.OrderNo,
+		"caregiverName":   caregiver.Name,
+		"appointmentDate": order.AppointmentDate,
+		"appointmentTime": order.AppointmentTime,
+		"message":         fmt.Sprintf("已为您安排护理员%s", caregiver.Name),
+	})
+
+	if caregiver.UserId != "" {
+		SendSSEMessageToUser(caregiver.UserId, "caregiverJobAssigned", map[string]interface{}{
+			"orderId":         order.Id,
+			"orderNo":         order.OrderNo,
+			"serviceName":     order.ServiceName,
+			"appointmentDate": order.AppointmentDate,
+			"appointmentTime": order.AppointmentTime,
+			"message":         "您有新的派单，请及时查看",
+		})
+	}
+
+	if previousCaregiverId > 0 {
+		previous, err := dao.CaregiverImp.GetCaregiverById(previousCaregiverId)
+		if err != nil {
+			LogError("获取原护理员失败", err)
+			return
+		}
+		if previous.UserId != "" {
+			SendSSEMessageToUser(previous.UserId, "caregiverJobRevoked", map[string]interface{}{
+				"orderId": order.Id,
+				"orderNo": order.OrderNo,
+				"message": "订单已改派给其他护理员",
+			})
+		}
+	}
+}
+
+// AdminCaregiversHandler 管理员获取护理员列表
+func AdminCaregiversHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermCaregiverView) {
+		return
+	}
+
+	page := 1
+	pageSize := 20
+	status := -1
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		fmt.Sscanf(pageStr, "%d", &page)
+	}
+	if pageSizeStr := r.URL.Query().Get("pageSize"); pageSizeStr != "" {
+		fmt.Sscanf(pageSizeStr, "%d", &pageSize)
+	}
+	if statusStr := r.URL.Query().Get("status"); statusStr != "" {
+		fmt.Sscanf(statusStr, "%d", &status)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	keyword := strings.TrimSpace(r.URL.Query().Get("keyword"))
+
+	caregivers, total, err := dao.CaregiverImp.GetCaregivers(status, keyword, page, pageSize)
+	if err != nil {
+		LogError("获取护理员列表失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取护理员列表失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 确保返回空数组而不是null
+	if caregivers == nil {
+		caregivers = []*model.CaregiverModel{}
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"list":     caregivers,
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+			"hasMore":  (page * pageSize) < int(total),
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SaveCaregiverHandler 管理员新建或修改护理员
+func SaveCaregiverHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermCaregiverManage) {
+		return
+	}
+
+	var req SaveCaregiverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Phone = strings.TrimSpace(req.Phone)
+	if req.Name == "" || req.Phone == "" {
+		http.Error(w, "姓名和手机号不能为空", http.StatusBadRequest)
+		return
+	}
+	if req.Gender < 0 || req.Gender > 2 {
+		http.Error(w, "无效的性别", http.StatusBadRequest)
+		return
+	}
+	status := model.CaregiverStatusActive
+	if req.Status != nil {
+		status = *req.Status
+	}
+	if status != model.CaregiverStatusDisabled && status != model.CaregiverStatusActive && status != model.CaregiverStatusOnLeave {
+		http.Error(w, "无效的状态", http.StatusBadRequest)
+		return
+	}
+
+	if req.Id > 0 {
+		if _, err := dao.CaregiverImp.GetCaregiverById(req.Id); err != nil {
+			response := &AdminResponse{
+				Code:     -1,
+				ErrorMsg: "护理员不存在",
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
+
+	caregiver := &model.CaregiverModel{
+		Id:             req.Id,
+		UserId:         strings.TrimSpace(req.UserId),
+		Name:           req.Name,
+		Phone:          req.Phone,
+		Gender:         req.Gender,
+		Skills:         joinCaregiverList(req.Skills),
+		Regions:        joinCaregiverList(req.Regions),
+		Certifications: joinCaregiverList(req.Certifications),
+		Status:         status,
+		Remark:         req.Remark,
+	}
+	if err := dao.CaregiverImp.SaveCaregiver(caregiver); err != nil {
+		LogError("保存护理员失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "保存护理员失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("管理员保存护理员", map[string]interface{}{
+		"caregiverId": caregiver.Id,
+		"name":        caregiver.Name,
+		"status":      caregiver.Status,
+		"adminId":     GetAuthUserId(r),
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: caregiver,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DispatchCandidatesHandler 管理员查看订单可派单的护理员（在岗护理员及技能、区域、时间冲突情况）
+func DispatchCandidatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermOrderDispatch) {
+		return
+	}
+
+	var orderId int32
+	if _, err := fmt.Sscanf(r.URL.Query().Get("orderId"), "%d", &orderId); err != nil || orderId <= 0 {
+		http.Error(w, "无效的订单ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := dao.OrderImp.GetOrderById(orderId)
+	if err != nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	category := ""
+	if service, err := dao.ServiceImp.GetServiceById(order.ServiceId); err == nil && service != nil {
+		category = service.Category
+	}
+	city, district := "", ""
+	if address, err := dao.UserExtendImp.GetAddressById(order.AddressId); err == nil && address != nil {
+		city, district = address.City, address.District
+	}
+
+	caregivers, _, err := dao.CaregiverImp.GetCaregivers(model.CaregiverStatusActive, "", 1, 500)
+	if err != nil {
+		LogError("获取护理员列表失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取护理员列表失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	busyOrders, err := dao.CaregiverImp.GetAssignedOrdersAt(order.AppointmentDate, order.AppointmentTime)
+	if err != nil {
+		LogError("获取时间段派单失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取时间段派单失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	busyBy := make(map[int32]string)
+	for _, busyOrder := range busyOrders {
+		if busyOrder.Id != order.Id {
+			busyBy[busyOrder.CaregiverId] = busyOrder.OrderNo
+		}
+	}
+
+	candidates := make([]*DispatchCandidate, 0, len(caregivers))
+	for _, caregiver := range caregivers {
+		conflictOrderNo, conflict := busyBy[caregiver.Id]
+		candidates = append(candidates, &DispatchCandidate{
+			Caregiver:       caregiver,
+			SkillMatched:    caregiverListCovers(caregiver.Skills, category),
+			RegionMatched:   caregiverListCovers(caregiver.Regions, city, district),
+			Conflict:        conflict,
+			ConflictOrderNo: conflictOrderNo,
+			Current:         caregiver.Id == order.CaregiverId,
+		})
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"orderId":         order.Id,
+			"orderNo":         order.OrderNo,
+			"status":          order.Status,
+			"statusText":      OrderStatusText(order.Status, order.RefundStatus),
+			"caregiverId":     order.CaregiverId,
+			"appointmentDate": order.AppointmentDate,
+			"appointmentTime": order.AppointmentTime,
+			"category":        category,
+			"candidates":      candidates,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DispatchOrderHandler 管理员派单或改派
+func DispatchOrderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermOrderDispatch) {
+		return
+	}
+
+	var req DispatchOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+	if req.OrderId <= 0 || req.CaregiverId <= 0 {
+		http.Error(w, "缺少必要参数", http.StatusBadRequest)
+		return
+	}
+
+	order, err := dao.OrderImp.GetOrderById(req.OrderId)
+	if err != nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	caregiver, err := dao.CaregiverImp.GetCaregiverById(req.CaregiverId)
+	if err != nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "护理员不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	adminUserId := GetAuthUserId(r)
+	if err := DispatchOrder(order, caregiver, adminUserId, req.Reason); err != nil {
+		errMsg := "派单失败: " + err.Error()
+		if errors.Is(err, dao.ErrCaregiverConflict) || errors.Is(err, dao.ErrCaregiverUnavailable) {
+			errMsg = err.Error()
+		}
+		LogError("派单失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: errMsg,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("管理员派单", map[string]interface{}{
+		"orderNo":     order.OrderNo,
+		"caregiverId": caregiver.Id,
+		"adminId":     adminUserId,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"orderId":       order.Id,
+			"orderNo":       order.OrderNo,
+			"status":        order.Status,
+			"statusText":    OrderStatusText(order.Status, order.RefundStatus),
+			"caregiverId":   caregiver.Id,
+			"caregiverName": caregiver.Name,
+			"assignedAt":    order.AssignedAt,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
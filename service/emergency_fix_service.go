@@ -5,7 +5,6 @@ import (
 	"log"
 	"net/http"
 	"wxcloudrun-golang/db"
-	"wxcloudrun-golang/db/dao"
 	"wxcloudrun-golang/db/model"
 	"wxcloudrun-golang/utils"
 )
@@ -83,56 +82,6 @@ func EmergencyFixUserIdsHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("紧急修复完成，修复了 %d 个用户", fixedCount)
 }
 
-// TestUserInfoHandler 测试用户信息API
-func TestUserInfoHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// 获取测试userId参数
-	userId := r.URL.Query().Get("userId")
-	if userId == "" {
-		userId = "1" // 默认测试userId
-	}
-
-	log.Printf("测试用户信息API，userId: %s", userId)
-
-	// 尝试获取用户信息
-	user, err := dao.UserImp.GetUserByUserId(userId)
-	if err != nil {
-		log.Printf("获取用户信息失败: %v", err)
-		response := &EmergencyFixResponse{
-			Code:     -1,
-			ErrorMsg: "获取用户信息失败: " + err.Error(),
-			Data: map[string]interface{}{
-				"userId": userId,
-				"error":  err.Error(),
-			},
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
-	response := &EmergencyFixResponse{
-		Code: 0,
-		Data: map[string]interface{}{
-			"message": "用户信息获取成功",
-			"user": map[string]interface{}{
-				"id":     user.Id,
-				"userId": user.UserId,
-				"openId": user.OpenId,
-			},
-		},
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-
-	log.Printf("用户信息API测试成功，用户ID: %d, UserId: %s", user.Id, user.UserId)
-}
-
 // GetUserStatusHandler 获取用户状态信息
 func GetUserStatusHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -164,7 +113,6 @@ func GetUserStatusHandler(w http.ResponseWriter, r *http.Request) {
 		userDetails = append(userDetails, map[string]interface{}{
 			"id":     user.Id,
 			"userId": user.UserId,
-			"openId": user.OpenId,
 			"status": func() string {
 				if user.UserId == "" {
 					return "需要修复"
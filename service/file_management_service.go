@@ -59,6 +59,11 @@ func DeleteFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 只能管理自己上传的文件，内容编辑管理员可以管理轮播图等运营素材
+	if !requireResourceOwner(w, r, ResourceFile, file.Id, file.UserId, PermContentEdit) {
+		return
+	}
+
 	// 删除COS中的文件
 	permissionService := NewCOSPermissionService()
 	fileName := file.FileName
@@ -156,6 +161,11 @@ func UpdateFilePermissionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 只能管理自己上传的文件，内容编辑管理员可以管理轮播图等运营素材
+	if !requireResourceOwner(w, r, ResourceFile, file.Id, file.UserId, PermContentEdit) {
+		return
+	}
+
 	// 更新COS对象权限
 	permissionService := NewCOSPermissionService()
 	var updateErr error
@@ -242,6 +252,11 @@ func GetFilePermissionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 只能管理自己上传的文件，内容编辑管理员可以管理轮播图等运营素材
+	if !requireResourceOwner(w, r, ResourceFile, file.Id, file.UserId, PermContentEdit) {
+		return
+	}
+
 	// 获取COS对象权限
 	permissionService := NewCOSPermissionService()
 	acl, err := permissionService.GetObjectACL(file.FileName)
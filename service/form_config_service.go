@@ -0,0 +1,133 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"wxcloudrun-golang/db/dao"
+)
+
+// PublishFormConfigRequest 发布服务表单配置请求
+type PublishFormConfigRequest struct {
+	ServiceId  int32      `json:"serviceId"`
+	FormConfig FormConfig `json:"formConfig"`
+}
+
+// PublishFormConfigHandler 管理员发布服务的新表单配置，版本号加1，已有订单仍记录原版本
+func PublishFormConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermServiceFormUpdate) {
+		return
+	}
+
+	var req PublishFormConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+	if req.ServiceId <= 0 {
+		http.Error(w, "缺少服务ID", http.StatusBadRequest)
+		return
+	}
+	if err := checkFormConfig(&req.FormConfig); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 版本号由服务端维护，不保存客户端传入的值
+	req.FormConfig.Version = 0
+	formConfigJson, _ := json.Marshal(req.FormConfig)
+
+	adminUserId := GetAuthUserId(r)
+	version, err := dao.ServiceImp.PublishFormConfig(req.ServiceId, string(formConfigJson), adminUserId)
+	if err != nil {
+		LogError("发布服务表单配置失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "发布表单配置失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("管理员发布服务表单配置", map[string]interface{}{
+		"serviceId":  req.ServiceId,
+		"version":    version,
+		"fieldCount": len(req.FormConfig.Fields),
+		"adminId":    adminUserId,
+	})
+
+	req.FormConfig.Version = version
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"serviceId":  req.ServiceId,
+			"version":    version,
+			"formConfig": req.FormConfig,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetFormConfigVersionsHandler 管理员查看服务表单配置的历史版本
+func GetFormConfigVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermServiceView) {
+		return
+	}
+
+	var serviceId int32
+	if _, err := fmt.Sscanf(r.URL.Query().Get("serviceId"), "%d", &serviceId); err != nil || serviceId <= 0 {
+		http.Error(w, "无效的服务ID", http.StatusBadRequest)
+		return
+	}
+
+	versions, err := dao.ServiceImp.GetFormConfigVersions(serviceId)
+	if err != nil {
+		LogError("获取表单配置版本失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取表单配置版本失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	list := make([]map[string]interface{}, 0, len(versions))
+	for _, version := range versions {
+		formConfig, err := parseFormConfig(version.FormConfig)
+		if err != nil {
+			LogError("解析历史表单配置失败", err)
+		}
+		list = append(list, map[string]interface{}{
+			"version":    version.Version,
+			"formConfig": formConfig,
+			"createdBy":  version.CreatedBy,
+			"createdAt":  version.CreatedAt,
+		})
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"serviceId": serviceId,
+			"list":      list,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 表单字段类型
+const (
+	FormFieldText     = "text"
+	FormFieldTextarea = "textarea"
+	FormFieldSelect   = "select"
+	FormFieldRadio    = "radio"
+	FormFieldCheckbox = "checkbox"
+	FormFieldDate     = "date"
+	FormFieldTime     = "time"
+	FormFieldNumber   = "number"
+	FormFieldPhone    = "phone"
+	FormFieldEmail    = "email"
+	FormFieldFile     = "file"
+)
+
+// phonePattern 手机号格式（phone类型字段）
+var phonePattern = regexp.MustCompile(`^1\d{10}$`)
+
+// emailPattern 邮箱格式（email类型字段）
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// FormFieldError 单个表单字段的校验错误
+type FormFieldError struct {
+	Field   string `json:"field"`
+	Label   string `json:"label"`
+	Message string `json:"message"`
+}
+
+// UnmarshalJSON 兼容早期配置中直接写成字符串的选项，如 "options":["居家照护","医院陪诊"]
+func (o *FormOption) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		o.Label = value
+		o.Value = value
+		return nil
+	}
+	type formOption FormOption
+	var option formOption
+	if err := json.Unmarshal(data, &option); err != nil {
+		return err
+	}
+	*o = FormOption(option)
+	return nil
+}
+
+// fieldLabel 字段显示名称，未配置label时使用name
+func (f *FormField) fieldLabel() string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return f.Name
+}
+
+// hasOption 判断value是否为字段的可选值
+func (f *FormField) hasOption(value string) bool {
+	for _, option := range f.Options {
+		if option.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFormConfig 解析服务的表单配置，未配置时返回nil
+func parseFormConfig(raw string) (*FormConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var config FormConfig
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// checkFormConfig 发布表单配置前校验：字段名唯一、类型有效、选择类字段有选项、正则可以编译
+func checkFormConfig(config *FormConfig) error {
+	seen := make(map[string]bool)
+	for i := range config.Fields {
+		field := &config.Fields[i]
+		if strings.TrimSpace(field.Name) == "" {
+			return fmt.Errorf("第%d个字段缺少name", i+1)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("字段名重复: %s", field.Name)
+		}
+		seen[field.Name] = true
+
+		switch field.Type {
+		case FormFieldText, FormFieldTextarea, FormFieldDate, FormFieldTime, FormFieldNumber, FormFieldPhone, FormFieldEmail, FormFieldFile:
+		case FormFieldSelect, FormFieldRadio, FormFieldCheckbox:
+			if len(field.Options) == 0 {
+				return fmt.Errorf("字段%s缺少选项", field.fieldLabel())
+			}
+		default:
+			return fmt.Errorf("字段%s的类型无效: %s", field.fieldLabel(), field.Type)
+		}
+
+		if field.Validation != "" {
+			if _, err := regexp.Compile(field.Validation); err != nil {
+				return fmt.Errorf("字段%s的校验规则不是有效的正则表达式: %v", field.fieldLabel(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// formValueString 将表单值转换为字符串，数字按原样输出；不是字符串或数字时返回false
+func formValueString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// isEmptyFormValue 判断表单值是否为空（未填写）
+func isEmptyFormValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+// validateFormData 按服务的表单配置校验提交的表单数据，返回每个字段的错误
+// 必填、选项、日期和数字类型、正则规则逐项校验，配置外的字段不校验
+func validateFormData(config *FormConfig, data map[string]interface{}) []*FormFieldError {
+	var fieldErrors []*FormFieldError
+	for i := range config.Fields {
+		field := &config.Fields[i]
+		if message := validateFormField(field, data[field.Name]); message != "" {
+			fieldErrors = append(fieldErrors, &FormFieldError{
+				Field:   field.Name,
+				Label:   field.fieldLabel(),
+				Message: message,
+			})
+		}
+	}
+	return fieldErrors
+}
+
+// validateFormField 校验单个字段，通过时返回空字符串
+func validateFormField(field *FormField, value interface{}) string {
+	label := field.fieldLabel()
+	if isEmptyFormValue(value) {
+		if !field.Required {
+			return ""
+		}
+		switch field.Type {
+		case FormFieldSelect, FormFieldRadio, FormFieldCheckbox, FormFieldDate, FormFieldTime:
+			return "请选择" + label
+		case FormFieldFile:
+			return "请上传" + label
+		default:
+			return "请填写" + label
+		}
+	}
+
+	switch field.Type {
+	case FormFieldCheckbox:
+		values, ok := value.([]interface{})
+		if !ok {
+			return label + "格式错误"
+		}
+		for _, item := range values {
+			str, ok := formValueString(item)
+			if !ok || !field.hasOption(str) {
+				return fmt.Sprintf("%s包含无效选项: %v", label, item)
+			}
+		}
+		return ""
+	case FormFieldFile:
+		// 文件字段为上传接口返回的文件ID或地址，单个或多个
+		switch value.(type) {
+		case string, float64, json.Number, []interface{}:
+			return ""
+		default:
+			return label + "格式错误"
+		}
+	}
+
+	str, ok := formValueString(value)
+	if !ok {
+		return label + "格式错误"
+	}
+	switch field.Type {
+	case FormFieldSelect, FormFieldRadio:
+		if !field.hasOption(str) {
+			return label + "不是有效的选项"
+		}
+	case FormFieldDate:
+		if _, err := time.Parse("2006-01-02", str); err != nil {
+			return label + "日期格式错误，请使用YYYY-MM-DD格式"
+		}
+	case FormFieldTime:
+		if _, err := time.Parse("15:04", str); err != nil {
+			return label + "时间格式错误，请使用HH:MM格式"
+		}
+	case FormFieldNumber:
+		if _, err := strconv.ParseFloat(str, 64); err != nil {
+			return label + "必须是数字"
+		}
+	case FormFieldPhone:
+		if !phonePattern.MatchString(str) {
+			return label + "格式不正确"
+		}
+	case FormFieldEmail:
+		if !emailPattern.MatchString(str) {
+			return label + "格式不正确"
+		}
+	}
+
+	if field.Validation != "" {
+		pattern, err := regexp.Compile(field.Validation)
+		if err != nil {
+			// 配置错误不应阻止用户下单，发布时已校验，这里只记录
+			LogError("表单字段校验规则无效", fmt.Errorf("field=%s, validation=%s: %v", field.Name, field.Validation, err))
+			return ""
+		}
+		if !pattern.MatchString(str) {
+			return label + "格式不正确"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testFormConfig = `{
+	"version": 3,
+	"fields": [
+		{"name": "patientName", "label": "患者姓名", "type": "text", "required": true},
+		{"name": "remark", "type": "textarea"},
+		{"name": "serviceType", "label": "服务类型", "type": "select", "required": true, "options": ["居家照护", "医院陪诊"]},
+		{"name": "gender", "label": "性别", "type": "radio", "options": [{"label": "男", "value": "1"}, {"label": "女", "value": "2"}]},
+		{"name": "needs", "label": "护理需求", "type": "checkbox", "options": ["助浴", "助餐", "陪护"]},
+		{"name": "visitDate", "label": "就诊日期", "type": "date", "required": true},
+		{"name": "visitTime", "label": "就诊时间", "type": "time"},
+		{"name": "age", "label": "年龄", "type": "number"},
+		{"name": "contactPhone", "label": "联系电话", "type": "phone", "required": true},
+		{"name": "email", "label": "邮箱", "type": "email"},
+		{"name": "report", "label": "检查报告", "type": "file"},
+		{"name": "idCard", "label": "身份证号", "type": "text", "validation": "^\\d{17}[\\dXx]$"}
+	]
+}`
+
+func TestValidateFormData(t *testing.T) {
+	config, err := parseFormConfig(testFormConfig)
+	if err != nil {
+		t.Fatalf("parseFormConfig: %v", err)
+	}
+	if err := checkFormConfig(config); err != nil {
+		t.Fatalf("checkFormConfig: %v", err)
+	}
+
+	valid := `{"patientName": "张三", "serviceType": "医院陪诊", "visitDate": "2026-03-10", "contactPhone": "13800138000"}`
+
+	tests := []struct {
+		name  string
+		data  string
+		wants map[string]string // 字段名 -> 错误信息，为空表示校验通过
+	}{
+		{"只填必填项", valid, nil},
+		{"全部字段合法", `{
+			"patientName": "张三", "remark": "无", "serviceType": "居家照护", "gender": "2",
+			"needs": ["助浴", "陪护"], "visitDate": "2026-03-10", "visitTime": "09:30", "age": 78,
+			"contactPhone": "13800138000", "email": "a@b.cn", "report": ["file1", "file2"],
+			"idCard": "44030119900101123X", "extra": {"any": "value"}
+		}`, nil},
+		{"缺少全部必填项", `{}`, map[string]string{
+			"patientName":  "请填写患者姓名",
+			"serviceType":  "请选择服务类型",
+			"visitDate":    "请选择就诊日期",
+			"contactPhone": "请填写联系电话",
+		}},
+		{"必填项只有空白", `{"patientName": "  ", "serviceType": "医院陪诊", "visitDate": "2026-03-10", "contactPhone": "13800138000"}`, map[string]string{
+			"patientName": "请填写患者姓名",
+		}},
+		{"选项不存在", `{"patientName": "张三", "serviceType": "上门理发", "gender": "男", "visitDate": "2026-03-10", "contactPhone": "13800138000"}`, map[string]string{
+			"serviceType": "服务类型不是有效的选项",
+			"gender":      "性别不是有效的选项",
+		}},
+		{"多选包含无效选项", `{"patientName": "张三", "serviceType": "医院陪诊", "needs": ["助浴", "理发"], "visitDate": "2026-03-10", "contactPhone": "13800138000"}`, map[string]string{
+			"needs": "护理需求包含无效选项: 理发",
+		}},
+		{"多选不是数组", `{"patientName": "张三", "serviceType": "医院陪诊", "needs": "助浴", "visitDate": "2026-03-10", "contactPhone": "13800138000"}`, map[string]string{
+			"needs": "护理需求格式错误",
+		}},
+		{"空多选视为未填写", `{"patientName": "张三", "serviceType": "医院陪诊", "needs": [], "visitDate": "2026-03-10", "contactPhone": "13800138000"}`, nil},
+		{"日期和时间格式错误", `{"patientName": "张三", "serviceType": "医院陪诊", "visitDate": "2026/03/10", "visitTime": "9点", "contactPhone": "13800138000"}`, map[string]string{
+			"visitDate": "就诊日期日期格式错误，请使用YYYY-MM-DD格式",
+			"visitTime": "就诊时间时间格式错误，请使用HH:MM格式",
+		}},
+		{"数字字段接受数字字符串", `{"patientName": "张三", "serviceType": "医院陪诊", "visitDate": "2026-03-10", "age": "78", "contactPhone": "13800138000"}`, nil},
+		{"数字字段不是数字", `{"patientName": "张三", "serviceType": "医院陪诊", "visitDate": "2026-03-10", "age": "七十八", "contactPhone": "13800138000"}`, map[string]string{
+			"age": "年龄必须是数字",
+		}},
+		{"手机号和邮箱格式错误", `{"patientName": "张三", "serviceType": "医院陪诊", "visitDate": "2026-03-10", "contactPhone": "1380013800", "email": "ab.cn"}`, map[string]string{
+			"contactPhone": "联系电话格式不正确",
+			"email":        "邮箱格式不正确",
+		}},
+		{"手机号为数字", `{"patientName": "张三", "serviceType": "医院陪诊", "visitDate": "2026-03-10", "contactPhone": 13800138000}`, nil},
+		{"文件字段格式错误", `{"patientName": "张三", "serviceType": "医院陪诊", "visitDate": "2026-03-10", "contactPhone": "13800138000", "report": {"id": 1}}`, map[string]string{
+			"report": "检查报告格式错误",
+		}},
+		{"正则校验未通过", `{"patientName": "张三", "serviceType": "医院陪诊", "visitDate": "2026-03-10", "contactPhone": "13800138000", "idCard": "12345"}`, map[string]string{
+			"idCard": "身份证号格式不正确",
+		}},
+		{"文本字段不是字符串", `{"patientName": ["张三"], "serviceType": "医院陪诊", "visitDate": "2026-03-10", "contactPhone": "13800138000"}`, map[string]string{
+			"patientName": "患者姓名格式错误",
+		}},
+	}
+
+	for _, tt := range tests {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.data), &data); err != nil {
+			t.Fatalf("%s: invalid test data: %v", tt.name, err)
+		}
+		got := map[string]string{}
+		for _, fieldError := range validateFormData(config, data) {
+			got[fieldError.Field] = fieldError.Message
+		}
+		if len(got) != len(tt.wants) {
+			t.Errorf("%s: got errors %v, want %v", tt.name, got, tt.wants)
+			continue
+		}
+		for field, want := range tt.wants {
+			if got[field] != want {
+				t.Errorf("%s: field %s error = %q, want %q", tt.name, field, got[field], want)
+			}
+		}
+	}
+}
+
+func TestValidateFormDataErrorOrder(t *testing.T) {
+	config, err := parseFormConfig(testFormConfig)
+	if err != nil {
+		t.Fatalf("parseFormConfig: %v", err)
+	}
+	// 错误按配置中的字段顺序返回，提交订单时提示第一个错误
+	fieldErrors := validateFormData(config, map[string]interface{}{})
+	want := []string{"patientName", "serviceType", "visitDate", "contactPhone"}
+	if len(fieldErrors) != len(want) {
+		t.Fatalf("got %d errors, want %d", len(fieldErrors), len(want))
+	}
+	for i, field := range want {
+		if fieldErrors[i].Field != field {
+			t.Errorf("error %d field = %s, want %s", i, fieldErrors[i].Field, field)
+		}
+	}
+	if fieldErrors[0].Label != "患者姓名" {
+		t.Errorf("label = %s, want 患者姓名", fieldErrors[0].Label)
+	}
+}
+
+func TestCheckFormConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"合法配置", testFormConfig, false},
+		{"缺少字段名", `{"fields": [{"name": " ", "type": "text"}]}`, true},
+		{"字段名重复", `{"fields": [{"name": "a", "type": "text"}, {"name": "a", "type": "number"}]}`, true},
+		{"类型无效", `{"fields": [{"name": "a", "type": "slider"}]}`, true},
+		{"选择字段缺少选项", `{"fields": [{"name": "a", "type": "radio"}]}`, true},
+		{"正则无效", `{"fields": [{"name": "a", "type": "text", "validation": "[a-"}]}`, true},
+	}
+	for _, tt := range tests {
+		config, err := parseFormConfig(tt.config)
+		if err != nil {
+			t.Fatalf("%s: parseFormConfig: %v", tt.name, err)
+		}
+		if err := checkFormConfig(config); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkFormConfig error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
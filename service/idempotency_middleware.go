@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// 幂等请求头
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 128
+)
+
+// 需要幂等保护的接口标识
+const (
+	IdempotencyScopeOrderSubmit = "order.submit"
+	IdempotencyScopeOrderPay    = "order.pay"
+)
+
+// idempotencyRecorder 记录处理器写出的响应，用于保存快照
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       *bytes.Buffer
+}
+
+// WriteHeader 记录状态码
+func (r *idempotencyRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write 记录响应体
+func (r *idempotencyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// NewIdempotencyMiddleware 创建幂等中间件，需放在鉴权中间件之内
+// 请求携带Idempotency-Key时，同一用户在该接口重复使用相同的键会直接返回首次的响应，不会再次执行处理器；
+// 未携带时按原逻辑处理
+func NewIdempotencyMiddleware(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+		if key == "" {
+			handler(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, fmt.Sprintf("Idempotency-Key不能超过%d个字符", maxIdempotencyKeyLength), http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "读取请求体失败", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		idempotencyConfig := config.GetIdempotencyConfig()
+		hash := sha256.Sum256([]byte(r.Method + " " + r.URL.Path + "\n" + string(body)))
+		record := &model.IdempotencyKeyModel{
+			UserId:      GetAuthUserId(r),
+			Scope:       scope,
+			IdemKey:     key,
+			RequestHash: hex.EncodeToString(hash[:]),
+			ExpiresAt:   time.Now().Add(idempotencyConfig.KeyTTL),
+		}
+
+		existing, acquired, err := dao.IdempotencyImp.AcquireKey(record, idempotencyConfig.ProcessingLease)
+		if err != nil {
+			// 无法确认是否重复提交时不执行处理器，避免产生重复订单
+			LogError("占用幂等键失败", err)
+			writeIdempotencyError(w, http.StatusServiceUnavailable, "系统繁忙，请稍后重试")
+			return
+		}
+
+		if !acquired {
+			if existing.RequestHash != record.RequestHash {
+				LogError("幂等键重复使用", fmt.Errorf("scope=%s, key=%s", scope, key))
+				writeIdempotencyError(w, http.StatusUnprocessableEntity, "Idempotency-Key已用于其他请求")
+				return
+			}
+			if existing.Status != model.IdempotencyStatusCompleted {
+				// 租约未到期，首次请求仍在处理；到期后相同请求会接管该键重新处理
+				writeIdempotencyError(w, http.StatusConflict, "请求正在处理中，请勿重复提交")
+				return
+			}
+
+			LogStep("重放幂等请求响应", map[string]interface{}{
+				"scope":  scope,
+				"key":    key,
+				"userId": existing.UserId,
+			})
+			if existing.ResponseStatus == http.StatusOK {
+				w.Header().Set("Content-Type", "application/json")
+			} else {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			}
+			w.Header().Set(idempotencyReplayedHeader, "true")
+			w.WriteHeader(existing.ResponseStatus)
+			w.Write([]byte(existing.ResponseBody))
+			return
+		}
+
+		recorder := &idempotencyRecorder{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+			body:           &bytes.Buffer{},
+		}
+		completed := false
+		defer func() {
+			// 处理器出错（5xx或panic）时释放键，允许客户端用相同的键重试
+			if !completed {
+				if err := dao.IdempotencyImp.ReleaseKey(record); err != nil {
+					LogError("释放幂等键失败", err)
+				}
+			}
+		}()
+
+		handler(recorder, r)
+
+		if recorder.statusCode >= http.StatusInternalServerError {
+			return
+		}
+		// 处理器已执行成功，即使快照保存失败也不释放键，宁可让重试返回处理中也不重复执行
+		completed = true
+		if err := dao.IdempotencyImp.CompleteKey(record, recorder.statusCode, recorder.body.String()); err != nil {
+			LogError("保存幂等响应失败", err)
+		}
+	}
+}
+
+// writeIdempotencyError 返回幂等校验错误
+func writeIdempotencyError(w http.ResponseWriter, statusCode int, msg string) {
+	response := &OrderResponse{
+		Code:     -1,
+		ErrorMsg: msg,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
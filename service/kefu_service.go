@@ -54,6 +54,7 @@ func SendMessageHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
 		return
 	}
+	req.UserId = GetAuthUserId(r) // 发送用户以登录令牌为准，忽略请求体中的userId
 
 	LogStep("解析发送消息请求参数", map[string]interface{}{
 		"userId":     req.UserId,
@@ -63,8 +64,8 @@ func SendMessageHandler(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// 验证参数
-	if req.UserId == "" || req.Content == "" {
-		LogError("缺少必要参数", fmt.Errorf("userId=%s, content=%s", req.UserId, req.Content))
+	if req.Content == "" {
+		LogError("缺少必要参数", fmt.Errorf("content=%s", req.Content))
 		http.Error(w, "缺少必要参数", http.StatusBadRequest)
 		return
 	}
@@ -6,9 +6,34 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 )
 
+// sensitiveFieldNames 日志中需要脱敏的字段名（密码、令牌、两步验证密钥和验证码）
+const sensitiveFieldNames = `\w*[Pp]assword|token|refreshToken|secret|otpauthUrl|qrCode|totpCode|code|smsCode|recoveryCode`
+
+// sensitiveFieldPattern 日志中需要脱敏的JSON字段
+var sensitiveFieldPattern = regexp.MustCompile(`"(` + sensitiveFieldNames + `)"\s*:\s*"[^"]*"`)
+
+// sensitiveQueryPattern 日志中需要脱敏的查询参数（如SSE连接的token）
+var sensitiveQueryPattern = regexp.MustCompile(`(^|&)(` + sensitiveFieldNames + `)=[^&]*`)
+
+// sensitiveArrayPattern 日志中需要脱敏的JSON数组字段（恢复码）
+var sensitiveArrayPattern = regexp.MustCompile(`"(recoveryCodes)"\s*:\s*\[[^\]]*\]`)
+
+// maskSensitiveFields 脱敏日志内容中的密码和令牌字段
+func maskSensitiveFields(content string) string {
+	content = sensitiveFieldPattern.ReplaceAllString(content, `"$1":"******"`)
+	return sensitiveArrayPattern.ReplaceAllString(content, `"$1":["******"]`)
+}
+
+// maskSensitiveQuery 脱敏日志中查询字符串的密码和令牌参数
+func maskSensitiveQuery(rawQuery string) string {
+	return sensitiveQueryPattern.ReplaceAllString(rawQuery, "${1}${2}=******")
+}
+
 // LogMiddleware 日志中间件
 type LogMiddleware struct {
 	handler http.HandlerFunc
@@ -28,14 +53,14 @@ func NewLogMiddleware(handler http.HandlerFunc) http.HandlerFunc {
 
 		// 记录请求参数
 		if r.Method == "GET" {
-			log.Printf("[API] 查询参数: %s", r.URL.RawQuery)
+			log.Printf("[API] 查询参数: %s", maskSensitiveQuery(r.URL.RawQuery))
 		} else if r.Method == "POST" {
 			// 读取请求体
 			body, err := io.ReadAll(r.Body)
 			if err != nil {
 				log.Printf("[API] 读取请求体失败: %v", err)
 			} else {
-				log.Printf("[API] 请求体: %s", string(body))
+				log.Printf("[API] 请求体: %s", maskSensitiveFields(string(body)))
 				// 重新设置请求体，因为已经被读取了
 				// 使用更可靠的方式重新设置请求体
 				r.Body = io.NopCloser(bytes.NewReader(body))
@@ -54,7 +79,12 @@ func NewLogMiddleware(handler http.HandlerFunc) http.HandlerFunc {
 		// 记录响应
 		duration := time.Since(startTime)
 		log.Printf("[API] 响应状态: %d", responseRecorder.statusCode)
-		log.Printf("[API] 响应体: %s", responseRecorder.body.String())
+		if strings.HasPrefix(responseRecorder.Header().Get("Content-Disposition"), "attachment") {
+			// 附件下载（如个人数据导出）不记录内容
+			log.Printf("[API] 响应体: 附件，%d字节", responseRecorder.body.Len())
+		} else {
+			log.Printf("[API] 响应体: %s", maskSensitiveFields(responseRecorder.body.String()))
+		}
 		log.Printf("[API] 请求处理完成，耗时: %v", duration)
 	}
 }
@@ -0,0 +1,41 @@
+package service
+
+import "testing"
+
+func TestMaskSensitiveQuery(t *testing.T) {
+	tests := []struct {
+		rawQuery string
+		want     string
+	}{
+		{"", ""},
+		{"page=1&pageSize=10", "page=1&pageSize=10"},
+		{"token=abc.def.ghi", "token=******"},
+		{"userId=u1&token=abc&page=2", "userId=u1&token=******&page=2"},
+		{"code=123456&newPassword=secret", "code=******&newPassword=******"},
+		{"refreshToken=x&smsCode=1234", "refreshToken=******&smsCode=******"},
+		{"mytoken=abc", "mytoken=abc"},
+		{"tokenType=access", "tokenType=access"},
+	}
+	for _, tt := range tests {
+		if got := maskSensitiveQuery(tt.rawQuery); got != tt.want {
+			t.Errorf("maskSensitiveQuery(%q) = %q, want %q", tt.rawQuery, got, tt.want)
+		}
+	}
+}
+
+func TestMaskSensitiveFields(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`{"username":"admin","password":"Admin@2024"}`, `{"username":"admin","password":"******"}`},
+		{`{"token":"a.b.c","refreshToken":"d.e.f"}`, `{"token":"******","refreshToken":"******"}`},
+		{`{"recoveryCodes":["abcde-12345","fghij-67890"]}`, `{"recoveryCodes":["******"]}`},
+		{`{"orderNo":"ORDER1"}`, `{"orderNo":"ORDER1"}`},
+	}
+	for _, tt := range tests {
+		if got := maskSensitiveFields(tt.content); got != tt.want {
+			t.Errorf("maskSensitiveFields(%s) = %s, want %s", tt.content, got, tt.want)
+		}
+	}
+}
@@ -211,3 +211,44 @@ func (m *MigrationService) GetUserByUserId(userId string) (*model.UserModel, err
 func (m *MigrationService) GetUserById(id int32) (*model.UserModel, error) {
 	return dao.UserImp.GetUserById(id)
 }
+
+// HashAdminPasswords 将历史明文存储的管理员密码迁移为哈希存储
+// 已是哈希格式的密码会被跳过，可重复执行；迁移后的账号下次登录必须修改密码
+func (m *MigrationService) HashAdminPasswords() (int, error) {
+	log.Println("开始迁移管理员明文密码...")
+
+	var admins []*model.UserModel
+	cli := db.Get()
+	err := cli.Table("Users").Where("adminPassword IS NOT NULL AND adminPassword <> ''").Find(&admins).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询管理员失败: %v", err)
+	}
+
+	migrated := 0
+	for _, admin := range admins {
+		if utils.IsPasswordHash(admin.AdminPassword) {
+			continue
+		}
+
+		passwordHash, err := utils.HashPassword(admin.AdminPassword)
+		if err != nil {
+			return migrated, fmt.Errorf("哈希管理员 %s 的密码失败: %v", admin.UserId, err)
+		}
+
+		// 明文密码可能已经泄露，迁移后要求管理员修改密码
+		err = cli.Table("Users").Where("id = ? AND adminPassword = ?", admin.Id, admin.AdminPassword).
+			Updates(map[string]interface{}{
+				"adminPassword":           passwordHash,
+				"adminPasswordMustChange": 1,
+			}).Error
+		if err != nil {
+			return migrated, fmt.Errorf("更新管理员 %s 的密码失败: %v", admin.UserId, err)
+		}
+
+		migrated++
+		log.Printf("管理员 %s 的密码已迁移为哈希存储", admin.UserId)
+	}
+
+	log.Printf("管理员密码迁移完成，共迁移 %d 个账号", migrated)
+	return migrated, nil
+}
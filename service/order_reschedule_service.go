@@ -0,0 +1,361 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// RescheduleOrderRequest 改约请求
+type RescheduleOrderRequest struct {
+	OrderId         int32  `json:"orderId"`
+	AppointmentDate string `json:"appointmentDate"` // 新的预约日期
+	AppointmentTime string `json:"appointmentTime"` // 新的预约时间段
+	Reason          string `json:"reason"`
+	Override        bool   `json:"override"` // 仅管理员接口有效：跳过改约截止时间和次数限制
+}
+
+// orderAppointmentStart 订单预约的开始时间，预约时间格式不正确时返回false
+func orderAppointmentStart(order *model.OrderModel) (time.Time, bool) {
+	start, err := time.ParseInLocation("2006-01-02 15:04", order.AppointmentDate+" "+order.AppointmentTime, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+// checkRescheduleRules 校验改约截止时间和改约次数，管理员跳过规则时不调用
+func checkRescheduleRules(order *model.OrderModel) error {
+	bookingConfig := config.GetBookingConfig()
+	if start, ok := orderAppointmentStart(order); ok && time.Until(start) < bookingConfig.RescheduleCutoff {
+		return fmt.Errorf("距预约时间不足%d小时，不能改约，请联系客服", int(bookingConfig.RescheduleCutoff.Hours()))
+	}
+	if bookingConfig.MaxReschedules > 0 && order.RescheduleCount >= bookingConfig.MaxReschedules {
+		return fmt.Errorf("每个订单最多改约%d次，请联系客服", bookingConfig.MaxReschedules)
+	}
+	return nil
+}
+
+// RescheduleOrder 将已支付（已派单）订单改约到新的日期和时间段，并写入改约记录
+// 新时间段按预约规则和库存校验；override为true时跳过改约截止时间和次数限制（仅管理员）
+// 已派单的订单保留原护理员，护理员在新时间段已有其他订单时改约失败，需先改派
+func RescheduleOrder(order *model.OrderModel, toDate, toTime, actorType, actorId, reason string, override bool) error {
+	if toDate == order.AppointmentDate && toTime == order.AppointmentTime {
+		return fmt.Errorf("新的预约时间与原预约时间相同")
+	}
+	if !CanTransitionOrder(order, OrderEventReschedule) {
+		return fmt.Errorf("订单当前状态为%s，不允许改约", OrderStatusText(order.Status, order.RefundStatus))
+	}
+	if order.CaregiverId > 0 {
+		if record, err := dao.CaregiverImp.GetServiceRecord(order.Id, order.CaregiverId); err == nil && record.CheckInAt != nil {
+			return fmt.Errorf("护理员已签到，不能改约")
+		}
+	}
+	if !override {
+		if err := checkRescheduleRules(order); err != nil {
+			return err
+		}
+	}
+
+	service, err := dao.ServiceImp.GetServiceById(order.ServiceId)
+	if err != nil {
+		return fmt.Errorf("获取服务信息失败: %v", err)
+	}
+	if err := checkTimeSlotBookable(service, toDate, toTime); err != nil {
+		return err
+	}
+
+	maxCount := config.GetBookingConfig().MaxReschedules
+	if override {
+		maxCount = 0
+	}
+	history := &model.OrderRescheduleModel{
+		OrderId:   order.Id,
+		OrderNo:   order.OrderNo,
+		FromDate:  order.AppointmentDate,
+		FromTime:  order.AppointmentTime,
+		ToDate:    toDate,
+		ToTime:    toTime,
+		ActorType: actorType,
+		ActorId:   actorId,
+		Reason:    reason,
+		Override:  override,
+	}
+	logReason := fmt.Sprintf("%s %s → %s %s", history.FromDate, history.FromTime, toDate, toTime)
+	if reason != "" {
+		logReason += "；" + reason
+	}
+
+	err = TransitionOrderWith(order, OrderEventReschedule, actorType, actorId, logReason, nil, func(uow *dao.UnitOfWork) error {
+		changed, err := dao.OrderImp.RescheduleOrderTx(uow, order, history, getServiceSlotCapacity(service), maxCount)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return errOrderStateChanged
+		}
+		if order.CaregiverId > 0 {
+			return dao.CaregiverImp.CheckCaregiverAvailableTx(uow, order.CaregiverId, order.Id, toDate, toTime)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, dao.ErrCaregiverConflict) {
+			return fmt.Errorf("护理员在新时间段已有其他订单，请先改派后再改约")
+		}
+		return err
+	}
+
+	order.AppointmentDate = toDate
+	order.AppointmentTime = toTime
+	order.RescheduleCount++
+	notifyOrderRescheduled(order, history)
+	return nil
+}
+
+// notifyOrderRescheduled 改约成功后通过SSE通知下单用户、已派单的护理员和管理员
+func notifyOrderRescheduled(order *model.OrderModel, history *model.OrderRescheduleModel) {
+	data := map[string]interface{}{
+		"orderId":         order.Id,
+		"orderNo":         order.OrderNo,
+		"fromDate":        history.FromDate,
+		"fromTime":        history.FromTime,
+		"appointmentDate": order.AppointmentDate,
+		"appointmentTime": order.AppointmentTime,
+		"message":         fmt.Sprintf("订单预约时间已改为%s %s", order.AppointmentDate, order.AppointmentTime),
+	}
+	if history.ActorType != OrderActorUser {
+		SendSSEMessageToUser(order.UserId, "orderRescheduled", data)
+	} else {
+		SendSSEMessageToAdmins(PermOrderView, "orderRescheduled", data)
+	}
+
+	if order.CaregiverId > 0 {
+		caregiver, err := dao.CaregiverImp.GetCaregiverById(order.CaregiverId)
+		if err != nil {
+			LogError("获取护理员失败", err)
+			return
+		}
+		if caregiver.UserId != "" {
+			SendSSEMessageToUser(caregiver.UserId, "caregiverJobRescheduled", data)
+		}
+	}
+}
+
+// writeRescheduleResult 返回改约后的订单预约信息
+func writeRescheduleResult(w http.ResponseWriter, order *model.OrderModel) {
+	response := &OrderResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"orderId":         order.Id,
+			"orderNo":         order.OrderNo,
+			"appointmentDate": order.AppointmentDate,
+			"appointmentTime": order.AppointmentTime,
+			"rescheduleCount": order.RescheduleCount,
+			"status":          order.Status,
+			"statusText":      OrderStatusText(order.Status, order.RefundStatus),
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RescheduleOrderHandler 用户改约自己的订单
+func RescheduleOrderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RescheduleOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+	if req.OrderId <= 0 || req.AppointmentDate == "" || req.AppointmentTime == "" {
+		http.Error(w, "缺少必要参数", http.StatusBadRequest)
+		return
+	}
+
+	userId := GetAuthUserId(r)
+	order, err := dao.OrderImp.GetOrderById(req.OrderId)
+	if err != nil || order == nil {
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	if !requireResourceOwner(w, r, ResourceOrder, order.Id, order.UserId, "") {
+		return
+	}
+
+	// 用户不能跳过改约规则
+	if err := RescheduleOrder(order, req.AppointmentDate, req.AppointmentTime, OrderActorUser, userId, strings.TrimSpace(req.Reason), false); err != nil {
+		LogError("改约失败", err)
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "改约失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	writeRescheduleResult(w, order)
+}
+
+// AdminRescheduleOrderHandler 管理员改约订单，override为true时跳过改约截止时间和次数限制
+func AdminRescheduleOrderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermOrderReschedule) {
+		return
+	}
+
+	var req RescheduleOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+	if req.OrderId <= 0 || req.AppointmentDate == "" || req.AppointmentTime == "" {
+		http.Error(w, "缺少必要参数", http.StatusBadRequest)
+		return
+	}
+
+	order, err := dao.OrderImp.GetOrderById(req.OrderId)
+	if err != nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	adminUserId := GetAuthUserId(r)
+	if err := RescheduleOrder(order, req.AppointmentDate, req.AppointmentTime, OrderActorAdmin, adminUserId, strings.TrimSpace(req.Reason), req.Override); err != nil {
+		LogError("管理员改约失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "改约失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogInfo("管理员改约订单", map[string]interface{}{
+		"orderNo":  order.OrderNo,
+		"adminId":  adminUserId,
+		"override": req.Override,
+	})
+	writeRescheduleResult(w, order)
+}
+
+// writeOrderReschedules 返回订单的改约记录和当前改约规则
+func writeOrderReschedules(w http.ResponseWriter, order *model.OrderModel) {
+	reschedules, err := dao.OrderImp.GetOrderReschedules(order.Id)
+	if err != nil {
+		LogError("获取改约记录失败", err)
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "获取改约记录失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	bookingConfig := config.GetBookingConfig()
+	response := &OrderResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"orderId":         order.Id,
+			"orderNo":         order.OrderNo,
+			"rescheduleCount": order.RescheduleCount,
+			"maxReschedules":  bookingConfig.MaxReschedules,
+			"cutoffHours":     int(bookingConfig.RescheduleCutoff.Hours()),
+			"canReschedule":   CanTransitionOrder(order, OrderEventReschedule) && checkRescheduleRules(order) == nil,
+			"list":            reschedules,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// OrderReschedulesHandler 用户查看订单的改约记录
+func OrderReschedulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orderId, ok := parseTimelineOrderId(r)
+	if !ok {
+		http.Error(w, "无效的订单ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := dao.OrderImp.GetOrderById(orderId)
+	if err != nil || order == nil {
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	if !requireResourceOwner(w, r, ResourceOrder, order.Id, order.UserId, "") {
+		return
+	}
+
+	writeOrderReschedules(w, order)
+}
+
+// AdminOrderReschedulesHandler 管理员查看订单的改约记录
+func AdminOrderReschedulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermOrderView) {
+		return
+	}
+
+	orderId, ok := parseTimelineOrderId(r)
+	if !ok {
+		http.Error(w, "无效的订单ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := dao.OrderImp.GetOrderById(orderId)
+	if err != nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	writeOrderReschedules(w, order)
+}
@@ -30,30 +30,34 @@ type SubmitOrderRequest struct {
 	AppointmentTime  string                 `json:"appointmentTime"` // 预约时间
 	Quantity         int                    `json:"quantity"`
 	FormData         map[string]interface{} `json:"formData"`
+	FormVersion      int                    `json:"formVersion"` // 填写时的表单配置版本，可不传
 	ReferrerId       int32                  `json:"referrerId,omitempty"`
 	Remark           string                 `json:"remark"`
 	DiseaseInfo      string                 `json:"diseaseInfo"`      // 既往病史
 	NeedToiletAssist string                 `json:"needToiletAssist"` // 是否需要助排二便
 }
 
+// maxOrderQuantity 单笔订单最大购买数量
+const maxOrderQuantity = 99
+
 // PayOrderRequest 支付订单请求
 type PayOrderRequest struct {
 	OrderId   int32  `json:"orderId"`
 	PayMethod string `json:"payMethod"` // wechat, alipay
-	OpenID    string `json:"openId"`    // 用户openID
 }
 
 // CancelOrderRequest 取消订单请求
 type CancelOrderRequest struct {
-	OrderId int32  `json:"orderId"`
-	Reason  string `json:"reason"`
+	OrderId      int32       `json:"orderId"`
+	Reason       string      `json:"reason"`
+	RefundAmount model.Money `json:"refundAmount"` // 取消已支付订单时用户确认的可退金额，可不传
 }
 
-// RefundOrderRequest 退款订单请求
+// RefundOrderRequest 退款订单请求，退款金额按取消政策计算
 type RefundOrderRequest struct {
-	OrderId      int32   `json:"orderId"`
-	RefundAmount float64 `json:"refundAmount"`
-	Reason       string  `json:"reason"`
+	OrderId      int32       `json:"orderId"`
+	RefundAmount model.Money `json:"refundAmount"` // 用户确认的可退金额，与当前计算结果不一致时拒绝，可不传
+	Reason       string      `json:"reason"`
 }
 
 // OrderListRequest 订单列表请求
@@ -65,23 +69,23 @@ type OrderListRequest struct {
 
 // OrderListItem 订单列表项（增强版）
 type OrderListItem struct {
-	Id              int32     `json:"id"`
-	OrderNo         string    `json:"orderNo"`
-	ServiceName     string    `json:"serviceName"`     // 服务名称
-	ServiceTitle    string    `json:"serviceTitle"`    // 服务标题
-	AppointmentDate string    `json:"appointmentDate"` // 预约日期
-	AppointmentTime string    `json:"appointmentTime"` // 预约时间
-	ConsultTime     string    `json:"consultTime"`     // 服务沟通时间（从formData中提取）
-	Price           float64   `json:"price"`           // 服务单价
-	TotalAmount     float64   `json:"totalAmount"`     // 订单金额
-	Status          int       `json:"status"`          // 订单状态
-	PayStatus       int       `json:"payStatus"`       // 支付状态
-	CreatedAt       time.Time `json:"createdAt"`       // 创建时间
-	StatusText      string    `json:"statusText"`      // 状态文本
-	PayStatusText   string    `json:"payStatusText"`   // 支付状态文本
-	FormattedAmount string    `json:"formattedAmount"` // 格式化金额
-	FormattedDate   string    `json:"formattedDate"`   // 格式化日期
-	Amount          float64   `json:"amount"`          // 兼容字段
+	Id              int32       `json:"id"`
+	OrderNo         string      `json:"orderNo"`
+	ServiceName     string      `json:"serviceName"`     // 服务名称
+	ServiceTitle    string      `json:"serviceTitle"`    // 服务标题
+	AppointmentDate string      `json:"appointmentDate"` // 预约日期
+	AppointmentTime string      `json:"appointmentTime"` // 预约时间
+	ConsultTime     string      `json:"consultTime"`     // 服务沟通时间（从formData中提取）
+	Price           model.Money `json:"price"`           // 服务单价
+	TotalAmount     model.Money `json:"totalAmount"`     // 订单金额
+	Status          int         `json:"status"`          // 订单状态
+	PayStatus       int         `json:"payStatus"`       // 支付状态
+	CreatedAt       time.Time   `json:"createdAt"`       // 创建时间
+	StatusText      string      `json:"statusText"`      // 状态文本
+	PayStatusText   string      `json:"payStatusText"`   // 支付状态文本
+	FormattedAmount string      `json:"formattedAmount"` // 格式化金额
+	FormattedDate   string      `json:"formattedDate"`   // 格式化日期
+	Amount          model.Money `json:"amount"`          // 兼容字段
 }
 
 // OrderListResponse 订单列表响应
@@ -112,6 +116,7 @@ func SubmitOrderHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
 		return
 	}
+	req.UserId = GetAuthUserId(r) // 下单用户以登录令牌为准，忽略请求体中的userId
 
 	LogStep("解析提交订单请求参数", map[string]interface{}{
 		"userId":          req.UserId,
@@ -139,60 +144,21 @@ func SubmitOrderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 验证预约时间是否在允许范围内（明天开始，未来7天）
-	appointmentDateTime, err := time.Parse("2006-01-02 15:04", req.AppointmentDate+" "+req.AppointmentTime)
-	if err != nil {
-		LogError("预约时间格式错误", err)
-		http.Error(w, "预约时间格式错误", http.StatusBadRequest)
-		return
-	}
-
-	tomorrow := time.Now().AddDate(0, 0, 1)
-	tomorrow = time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, tomorrow.Location())
-
-	maxDate := time.Now().AddDate(0, 0, 7)
-	maxDate = time.Date(maxDate.Year(), maxDate.Month(), maxDate.Day(), 23, 59, 59, 0, maxDate.Location())
-
-	if appointmentDateTime.Before(tomorrow) {
-		LogError("预约时间过早", fmt.Errorf("appointmentDateTime=%v, tomorrow=%v", appointmentDateTime, tomorrow))
-		http.Error(w, "预约时间不能早于明天", http.StatusBadRequest)
+	// 验证购买数量，金额按单价×数量计算，必须在下单前拦截非正数和超大数量
+	if req.Quantity < 1 || req.Quantity > maxOrderQuantity {
+		LogError("购买数量无效", fmt.Errorf("quantity=%d", req.Quantity))
+		http.Error(w, fmt.Sprintf("购买数量必须在1到%d之间", maxOrderQuantity), http.StatusBadRequest)
 		return
 	}
 
-	if appointmentDateTime.After(maxDate) {
-		LogError("预约时间过晚", fmt.Errorf("appointmentDateTime=%v, maxDate=%v", appointmentDateTime, maxDate))
-		http.Error(w, "预约时间不能超过7天后", http.StatusBadRequest)
+	// 就诊人和服务地址必须属于下单用户
+	if _, ok := authorizePatient(w, r, req.PatientId); !ok {
 		return
 	}
-
-	// 验证时间槽是否在允许范围内
-	allowedTimeSlots := []string{
-		"08:00", "09:00", "10:00", "11:00",
-		"14:00", "15:00", "16:00", "17:00", "18:00", "19:00",
-	}
-
-	isValidTimeSlot := false
-	for _, slot := range allowedTimeSlots {
-		if req.AppointmentTime == slot {
-			isValidTimeSlot = true
-			break
-		}
-	}
-
-	if !isValidTimeSlot {
-		LogError("预约时间不在允许的时间段内", fmt.Errorf("appointmentTime=%s, allowedSlots=%v", req.AppointmentTime, allowedTimeSlots))
-		http.Error(w, "预约时间不在允许的时间段内", http.StatusBadRequest)
+	if _, ok := authorizeAddress(w, r, req.AddressId); !ok {
 		return
 	}
 
-	LogStep("预约时间验证通过", map[string]interface{}{
-		"appointmentDateTime": appointmentDateTime,
-		"tomorrow":            tomorrow,
-		"maxDate":             maxDate,
-		"appointmentTime":     req.AppointmentTime,
-		"allowedTimeSlots":    allowedTimeSlots,
-	})
-
 	// 获取服务信息
 	LogStep("开始查询服务信息", map[string]interface{}{
 		"serviceId": req.ServiceId,
@@ -216,6 +182,74 @@ func SubmitOrderHandler(w http.ResponseWriter, r *http.Request) {
 		"price":       service.Price,
 	})
 
+	// 按服务的表单配置校验表单数据，错误按字段返回
+	formConfig, err := parseFormConfig(service.FormConfig)
+	if err != nil {
+		LogError("解析服务表单配置失败", err)
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "服务表单配置有误，请联系客服",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	formVersion := 0
+	if formConfig != nil {
+		formVersion = service.FormVersion
+		if req.FormVersion > 0 && req.FormVersion != formVersion {
+			LogStep("表单配置版本已变更", map[string]interface{}{
+				"serviceId":      service.Id,
+				"submitVersion":  req.FormVersion,
+				"currentVersion": formVersion,
+			})
+			response := &OrderResponse{
+				Code:     -1,
+				ErrorMsg: "服务表单已更新，请刷新后重新填写",
+				Data:     map[string]interface{}{"formVersion": formVersion},
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		if fieldErrors := validateFormData(formConfig, req.FormData); len(fieldErrors) > 0 {
+			LogStep("表单数据校验未通过", map[string]interface{}{
+				"serviceId":   service.Id,
+				"fieldErrors": fieldErrors,
+			})
+			response := &OrderResponse{
+				Code:     -1,
+				ErrorMsg: fieldErrors[0].Message,
+				Data:     map[string]interface{}{"fieldErrors": fieldErrors},
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
+
+	// 按时间段模板、停约日期和库存校验预约时间（提交时仍会原子占用名额）
+	if err := checkTimeSlotBookable(service, req.AppointmentDate, req.AppointmentTime); err != nil {
+		if ruleErr, ok := err.(*bookingRuleError); ok {
+			LogError("预约时间不可预约", err)
+			http.Error(w, ruleErr.Error(), http.StatusBadRequest)
+			return
+		}
+		LogError("校验预约时间失败", err)
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "校验预约时间失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogStep("预约时间验证通过", map[string]interface{}{
+		"appointmentDate": req.AppointmentDate,
+		"appointmentTime": req.AppointmentTime,
+	})
+
 	// 生成订单号
 	orderNo := generateOrderNo()
 	LogStep("生成订单号", map[string]interface{}{
@@ -223,18 +257,18 @@ func SubmitOrderHandler(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// 计算总金额
-	totalAmount := service.Price * float64(req.Quantity)
+	totalAmount := service.Price * model.Money(req.Quantity)
 	LogStep("计算订单金额", map[string]interface{}{
 		"unitPrice":   service.Price,
 		"quantity":    req.Quantity,
 		"totalAmount": totalAmount,
 	})
 
-	// 计算佣金（示例：5%）
-	commission := totalAmount * 0.05
+	// 计算佣金，按分四舍五入
+	commission := calculateCommission(totalAmount)
 	LogStep("计算佣金", map[string]interface{}{
 		"commission": commission,
-		"rate":       0.05,
+		"rate":       CommissionRate,
 	})
 
 	// 转换表单数据为JSON
@@ -280,6 +314,7 @@ func SubmitOrderHandler(w http.ResponseWriter, r *http.Request) {
 		Quantity:         req.Quantity,
 		TotalAmount:      totalAmount,
 		FormData:         string(formDataJson),
+		FormVersion:      formVersion,
 		Status:           0,            // 待支付
 		PayStatus:        0,            // 未支付
 		PayDeadline:      &payDeadline, // 支付截止时间
@@ -294,7 +329,21 @@ func SubmitOrderHandler(w http.ResponseWriter, r *http.Request) {
 		"totalAmount": order.TotalAmount,
 	})
 
-	if err := dao.OrderImp.CreateOrder(order); err != nil {
+	if err := dao.OrderImp.CreateOrder(order, getServiceSlotCapacity(service)); err != nil {
+		if err == dao.ErrTimeSlotFull {
+			LogStep("预约时间段已约满", map[string]interface{}{
+				"serviceId":       req.ServiceId,
+				"appointmentDate": req.AppointmentDate,
+				"appointmentTime": req.AppointmentTime,
+			})
+			response := &OrderResponse{
+				Code:     -1,
+				ErrorMsg: "该时间段已约满，请选择其他时间",
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
 		LogError("数据库创建订单失败", err)
 		response := &OrderResponse{
 			Code:     -1,
@@ -368,6 +417,9 @@ func PayOrderHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
+	if !requireResourceOwner(w, r, ResourceOrder, order.Id, order.UserId, "") {
+		return
+	}
 
 	// 检查订单状态
 	if order.Status != 0 {
@@ -380,8 +432,20 @@ func PayOrderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 生成微信支付参数
-	paymentParams, err := GenerateWechatPayParams(order, req.OpenID)
+	// 付款人以登录用户的小程序openId为准，不接受客户端传入
+	payerOpenId := getPayerOpenId(GetAuthUser(r))
+	if payerOpenId == "" {
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "当前账号未绑定小程序，无法发起支付",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 由当前支付渠道预下单，生成小程序支付参数
+	paymentParams, err := GetPaymentProvider().Prepay(order, payerOpenId)
 	if err != nil {
 		response := &OrderResponse{
 			Code:     -1,
@@ -405,10 +469,33 @@ func PayOrderHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// PayConfirmHandler 支付确认接口
+// getPayerOpenId 获取用户在小程序下的openId，用于JSAPI下单；公众号和H5登录的用户openId属于其他应用，按身份表查找小程序身份
+func getPayerOpenId(user *model.UserModel) string {
+	if user == nil {
+		return ""
+	}
+	identities, err := dao.UserIdentityImp.GetIdentitiesByUserId(user.UserId)
+	if err != nil {
+		LogError("获取用户身份失败", err)
+		return ""
+	}
+	for _, identity := range identities {
+		if identity.Source == model.IdentitySourceMiniProgram && identity.OpenId != "" {
+			return identity.OpenId
+		}
+	}
+	// 身份表上线前注册的用户没有身份记录，openId即小程序openId
+	if len(identities) == 0 {
+		return user.OpenId
+	}
+	return ""
+}
+
+// PayConfirmHandler 支付结果查询接口：小程序支付完成后查询订单是否已支付
+// 订单只由支付结果通知或向支付渠道主动查询的结果变更为已支付，不信任客户端
 func PayConfirmHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		http.Error(w, "只支持GET或POST请求", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -426,15 +513,6 @@ func PayConfirmHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		TransactionId string `json:"transactionId"`
-		PayMethod     string `json:"payMethod"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
-		return
-	}
-
 	// 获取订单信息
 	order, err := dao.OrderImp.GetOrderById(int32(orderId))
 	if err != nil {
@@ -446,68 +524,40 @@ func PayConfirmHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
-
-	// 检查订单状态
-	if order.Status != 0 {
-		response := &OrderResponse{
-			Code:     -1,
-			ErrorMsg: "订单状态不正确",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+	if !requireResourceOwner(w, r, ResourceOrder, order.Id, order.UserId, "") {
 		return
 	}
 
-	// 生成交易号
-	transactionId := req.TransactionId
-	if transactionId == "" {
-		transactionId = generateTransactionId()
-	}
-
-	// 更新支付状态
-	payTime := time.Now()
-	if err := dao.OrderImp.UpdatePayStatus(int32(orderId), 1, &payTime, transactionId); err != nil {
-		response := &OrderResponse{
-			Code:     -1,
-			ErrorMsg: "更新支付状态失败: " + err.Error(),
+	// 支付通知可能延迟或丢失，待支付订单向支付渠道主动查询一次
+	if order.PayStatus != 1 && order.Status == model.OrderStatusPending {
+		settled, err := syncOrderPayment(order)
+		if err != nil {
+			LogError("查询支付结果失败", fmt.Errorf("orderNo=%s: %v", order.OrderNo, err))
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
-	// 更新订单状态为已支付
-	if err := dao.OrderImp.UpdateOrderStatus(int32(orderId), 1); err != nil {
-		response := &OrderResponse{
-			Code:     -1,
-			ErrorMsg: "更新订单状态失败: " + err.Error(),
+		if settled {
+			if latest, err := dao.OrderImp.GetOrderById(order.Id); err == nil {
+				order = latest
+			}
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-		return
 	}
 
-	// 如果有推荐人，创建佣金记录
-	if order.ReferrerId > 0 && order.Commission > 0 {
-		commission := &model.CommissionModel{
-			UserId:  fmt.Sprintf("%d", order.ReferrerId), // 将int32转换为string
-			OrderId: order.Id,
-			OrderNo: order.OrderNo,
-			Amount:  order.Commission,
-			Rate:    0.05, // 5%
-			Status:  0,    // 待结算
-		}
-		dao.ReferralImp.CreateCommission(commission)
+	paid := order.PayStatus == 1
+	data := map[string]interface{}{
+		"orderId":    order.Id,
+		"orderNo":    order.OrderNo,
+		"paid":       paid,
+		"payStatus":  order.PayStatus,
+		"status":     order.Status,
+		"statusText": OrderStatusText(order.Status, order.RefundStatus),
+	}
+	if paid {
+		data["transactionId"] = order.TransactionId
+		data["payTime"] = order.PayTime
 	}
 
 	response := &OrderResponse{
 		Code: 0,
-		Data: map[string]interface{}{
-			"orderId":       order.Id,
-			"orderNo":       order.OrderNo,
-			"transactionId": transactionId,
-			"payTime":       payTime,
-		},
+		Data: data,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -589,6 +639,9 @@ func CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
+	if !requireResourceOwner(w, r, ResourceOrder, order.Id, order.UserId, "") {
+		return
+	}
 
 	LogStep("获取订单信息成功", map[string]interface{}{
 		"orderId": order.Id,
@@ -597,12 +650,61 @@ func CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
 		"userId":  order.UserId,
 	})
 
+	// 已支付（已派单）的订单按取消政策计算退款并提交退款申请
+	if order.Status == model.OrderStatusPaid || order.Status == model.OrderStatusAssigned {
+		quote, err := RequestPolicyRefund(order, GetAuthUserId(r), req.Reason, req.RefundAmount)
+		if err != nil {
+			LogError("取消已支付订单失败", err)
+			response := &OrderResponse{
+				Code:     -1,
+				ErrorMsg: "取消订单失败: " + err.Error(),
+				Data:     quote,
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+
+		LogStep("已支付订单取消申请成功", map[string]interface{}{
+			"orderId":      orderId,
+			"orderNo":      order.OrderNo,
+			"refundAmount": quote.RefundAmount,
+			"percent":      quote.Percent,
+		})
+
+		response := &OrderResponse{
+			Code: 0,
+			Data: map[string]interface{}{
+				"refundAmount": quote.RefundAmount,
+				"fee":          quote.Fee,
+				"percent":      quote.Percent,
+				"rule":         quote.Rule,
+				"message":      fmt.Sprintf("取消申请已提交，%s元将在审核后原路退回", quote.RefundAmount),
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	// 检查订单状态
-	if order.Status != 0 {
+	if !CanTransitionOrder(order, OrderEventCancel) {
 		LogError("订单状态不正确", fmt.Errorf("期望状态0，实际状态%d", order.Status))
 		response := &OrderResponse{
 			Code:     -1,
-			ErrorMsg: "订单状态不正确，只有待支付的订单可以取消",
+			ErrorMsg: "订单状态不正确，只有待支付或已支付的订单可以取消",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 先关闭支付单，用户已完成支付时不再取消，订单按支付结果结算
+	if err := closeOrderPayment(order); err != nil {
+		LogError("订单已支付，无法直接取消", fmt.Errorf("orderNo=%s", order.OrderNo))
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "订单已支付，请刷新后申请取消",
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
@@ -610,7 +712,7 @@ func CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 更新订单状态为已取消
-	if err := dao.OrderImp.UpdateOrderStatus(int32(orderId), 3); err != nil {
+	if err := TransitionOrder(order, OrderEventCancel, OrderActorUser, GetAuthUserId(r), req.Reason, nil); err != nil {
 		LogError("更新订单状态失败", err)
 		response := &OrderResponse{
 			Code:     -1,
@@ -676,15 +778,10 @@ func RefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 		"reason":       req.Reason,
 	})
 
-	// 验证退款金额
-	if req.RefundAmount <= 0 {
-		LogError("退款金额无效", fmt.Errorf("refundAmount=%f", req.RefundAmount))
-		response := &OrderResponse{
-			Code:     -1,
-			ErrorMsg: "退款金额必须大于0",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+	// 退款金额按取消政策计算，refundAmount 为用户确认时看到的金额（可不传）
+	if req.RefundAmount < 0 {
+		LogError("退款金额无效", fmt.Errorf("refundAmount=%s", req.RefundAmount))
+		http.Error(w, "退款金额无效", http.StatusBadRequest)
 		return
 	}
 
@@ -711,9 +808,12 @@ func RefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
+	if !requireResourceOwner(w, r, ResourceOrder, order.Id, order.UserId, "") {
+		return
+	}
 
 	// 检查订单状态
-	if order.Status != 1 || order.PayStatus != 1 {
+	if (order.Status != model.OrderStatusPaid && order.Status != model.OrderStatusAssigned) || order.PayStatus != 1 {
 		LogError("订单状态不正确", fmt.Errorf("status=%d, payStatus=%d", order.Status, order.PayStatus))
 		response := &OrderResponse{
 			Code:     -1,
@@ -724,18 +824,6 @@ func RefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 检查退款金额不能超过订单金额
-	if req.RefundAmount > order.TotalAmount {
-		LogError("退款金额超过订单金额", fmt.Errorf("refundAmount=%f, totalAmount=%f", req.RefundAmount, order.TotalAmount))
-		response := &OrderResponse{
-			Code:     -1,
-			ErrorMsg: "退款金额不能超过订单金额",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
 	// 检查是否已经申请过退款
 	if order.RefundStatus > 0 {
 		LogError("订单已申请退款", fmt.Errorf("refundStatus=%d", order.RefundStatus))
@@ -748,12 +836,14 @@ func RefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 更新退款状态
-	if err := dao.OrderImp.UpdateRefundStatus(int32(orderId), 1, req.RefundAmount, req.Reason); err != nil {
+	// 按取消政策计算退款金额，更新退款状态为退款中
+	quote, err := RequestPolicyRefund(order, GetAuthUserId(r), req.Reason, req.RefundAmount)
+	if err != nil {
 		LogError("申请退款失败", err)
 		response := &OrderResponse{
 			Code:     -1,
 			ErrorMsg: "申请退款失败: " + err.Error(),
+			Data:     quote,
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
@@ -763,7 +853,8 @@ func RefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 	LogStep("退款申请成功", map[string]interface{}{
 		"orderId":      orderId,
 		"orderNo":      order.OrderNo,
-		"refundAmount": req.RefundAmount,
+		"refundAmount": quote.RefundAmount,
+		"percent":      quote.Percent,
 		"reason":       req.Reason,
 	})
 
@@ -772,7 +863,10 @@ func RefundOrderHandler(w http.ResponseWriter, r *http.Request) {
 		Data: map[string]interface{}{
 			"orderId":      orderId,
 			"orderNo":      order.OrderNo,
-			"refundAmount": req.RefundAmount,
+			"refundAmount": quote.RefundAmount,
+			"fee":          quote.Fee,
+			"percent":      quote.Percent,
+			"rule":         quote.Rule,
 			"reason":       req.Reason,
 			"message":      "退款申请提交成功",
 		},
@@ -793,15 +887,8 @@ func OrderListHandler(w http.ResponseWriter, r *http.Request) {
 	pageSizeStr := r.URL.Query().Get("pageSize")
 	statusStr := r.URL.Query().Get("status") // 新增状态筛选参数
 
-	// 获取用户ID参数
-	userIdStr := r.URL.Query().Get("userId")
-	if userIdStr == "" {
-		http.Error(w, "缺少userId参数", http.StatusBadRequest)
-		return
-	}
-
-	// 直接使用字符串类型的userId
-	userId := userIdStr
+	// 用户ID以登录令牌为准
+	userId := GetAuthUserId(r)
 
 	// 设置默认值
 	page := 1
@@ -852,6 +939,8 @@ func OrderListHandler(w http.ResponseWriter, r *http.Request) {
 			status = 3
 		case "refunded", "4":
 			status = 4
+		case "assigned", "5":
+			status = 5
 		default:
 			LogStep("状态值不匹配，返回空列表", map[string]interface{}{
 				"statusStr": statusStr,
@@ -982,6 +1071,8 @@ func OrderListHandler(w http.ResponseWriter, r *http.Request) {
 			statusText = "已取消"
 		case 4:
 			statusText = "已退款"
+		case 5:
+			statusText = "已派单"
 		}
 
 		// 支付状态文本映射
@@ -991,7 +1082,7 @@ func OrderListHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// 格式化金额
-		formattedAmount := fmt.Sprintf("¥%.2f", order.TotalAmount)
+		formattedAmount := "¥" + order.TotalAmount.String()
 
 		// 格式化日期 - 使用 UTC 时间，让前端处理时区转换
 		formattedDate := order.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
@@ -1098,6 +1189,9 @@ func OrderDetailHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
+	if !requireResourceOwner(w, r, ResourceOrder, order.OrderNo, order.UserId, "") {
+		return
+	}
 
 	LogStep("订单详情查询成功", map[string]interface{}{
 		"orderId":     order.Id,
@@ -1133,7 +1227,7 @@ func OrderDetailHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 格式化价格
-	detailResponse.FormattedPrice = fmt.Sprintf("%.2f", order.Price)
+	detailResponse.FormattedPrice = order.Price.String()
 	detailResponse.ServiceTitle = order.ServiceName
 
 	LogStep("订单详情增强信息", map[string]interface{}{
@@ -1157,31 +1251,28 @@ func generateOrderNo() string {
 	return fmt.Sprintf("ORDER%d%d%d", now.Year(), now.Month(), now.Day()) + fmt.Sprintf("%06d", rand.Intn(999999))
 }
 
-// 生成交易号
-func generateTransactionId() string {
-	now := time.Now()
-	return fmt.Sprintf("TXN%d%d%d", now.Year(), now.Month(), now.Day()) + fmt.Sprintf("%06d", rand.Intn(999999))
-}
-
 // 生成微信支付参数
 func generateWechatPayParams(order *model.OrderModel, payMethod string) (map[string]interface{}, error) {
 	// 从请求中获取openID，这里需要修改PayOrderHandler来传递openID
 	// 暂时使用模拟的openID
 	openID := "mock_openid_" + order.UserId
 
-	// 调用微信支付服务生成支付参数
-	return GenerateWechatPayParams(order, openID)
+	// 调用当前支付渠道生成支付参数
+	return GetPaymentProvider().Prepay(order, openID)
 }
 
 // GetAvailableTimeSlotsRequest 获取可用时间槽请求
 type GetAvailableTimeSlotsRequest struct {
-	Date string `json:"date"` // 日期格式：YYYY-MM-DD
+	Date      string `json:"date"`      // 日期格式：YYYY-MM-DD
+	ServiceId int32  `json:"serviceId"` // 服务项目ID，不传时不计算剩余名额（兼容旧版本）
 }
 
 // GetAvailableTimeSlotsResponse 获取可用时间槽响应
 type GetAvailableTimeSlotsResponse struct {
-	Date      string   `json:"date"`
-	TimeSlots []string `json:"timeSlots"`
+	Date      string                  `json:"date"`
+	ServiceId int32                   `json:"serviceId,omitempty"`
+	TimeSlots []string                `json:"timeSlots"` // 仍有名额的时间槽
+	Slots     []*TimeSlotAvailability `json:"slots,omitempty"`
 }
 
 // GetAvailableTimeSlotsHandler 获取可用时间槽接口
@@ -1205,7 +1296,8 @@ func GetAvailableTimeSlotsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	LogStep("解析获取可用时间槽请求参数", map[string]interface{}{
-		"date": req.Date,
+		"date":      req.Date,
+		"serviceId": req.ServiceId,
 	})
 
 	// 验证日期格式
@@ -1215,55 +1307,64 @@ func GetAvailableTimeSlotsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 解析日期
-	requestDate, err := time.Parse("2006-01-02", req.Date)
-	if err != nil {
-		LogError("日期格式错误", err)
-		http.Error(w, "日期格式错误，请使用YYYY-MM-DD格式", http.StatusBadRequest)
-		return
+	// 未传服务项目时按全局模板计算，不计算剩余名额（兼容旧版本）
+	var service *model.ServiceItemModel
+	if req.ServiceId > 0 {
+		var err error
+		service, err = dao.ServiceImp.GetServiceById(req.ServiceId)
+		if err != nil {
+			LogError("获取服务信息失败", err)
+			response := &OrderResponse{
+				Code:     -1,
+				ErrorMsg: "获取服务信息失败: " + err.Error(),
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
 	}
 
-	// 验证日期范围（明天开始，未来7天）
-	tomorrow := time.Now().AddDate(0, 0, 1)
-	tomorrow = time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, tomorrow.Location())
-
-	maxDate := time.Now().AddDate(0, 0, 7)
-	maxDate = time.Date(maxDate.Year(), maxDate.Month(), maxDate.Day(), 23, 59, 59, 0, maxDate.Location())
-
-	requestDateTime := time.Date(requestDate.Year(), requestDate.Month(), requestDate.Day(), 0, 0, 0, 0, requestDate.Location())
-
-	if requestDateTime.Before(tomorrow) {
-		LogError("请求日期过早", fmt.Errorf("requestDate=%v, tomorrow=%v", requestDateTime, tomorrow))
-		http.Error(w, "只能查询明天开始的日期", http.StatusBadRequest)
+	// 按时间段模板、停约日期和库存计算可预约时间段
+	slots, err := calculateTimeSlotAvailability(service, req.Date)
+	if err != nil {
+		if ruleErr, ok := err.(*bookingRuleError); ok {
+			LogError("预约日期不符合规则", err)
+			http.Error(w, ruleErr.Error(), http.StatusBadRequest)
+			return
+		}
+		LogError("获取时间段库存失败", err)
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "获取可用时间槽失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
 		return
 	}
 
-	if requestDateTime.After(maxDate) {
-		LogError("请求日期过晚", fmt.Errorf("requestDate=%v, maxDate=%v", requestDateTime, maxDate))
-		http.Error(w, "只能查询7天内的日期", http.StatusBadRequest)
-		return
+	availableTimeSlots := []string{}
+	for _, slot := range slots {
+		if slot.Available {
+			availableTimeSlots = append(availableTimeSlots, slot.Time)
+		}
 	}
-
-	// 定义允许的时间槽
-	allowedTimeSlots := []string{
-		"08:00", "09:00", "10:00", "11:00",
-		"14:00", "15:00", "16:00", "17:00", "18:00", "19:00",
+	if service == nil {
+		slots = nil
 	}
 
-	// TODO: 这里可以添加业务逻辑来检查哪些时间段已被预约
-	// 例如：查询数据库中该日期的已预约时间段，然后从允许的时间槽中排除
-	// 目前返回所有允许的时间槽
-
 	LogStep("获取可用时间槽成功", map[string]interface{}{
 		"date":      req.Date,
-		"timeSlots": allowedTimeSlots,
+		"serviceId": req.ServiceId,
+		"timeSlots": availableTimeSlots,
 	})
 
 	response := &OrderResponse{
 		Code: 0,
 		Data: &GetAvailableTimeSlotsResponse{
 			Date:      req.Date,
-			TimeSlots: allowedTimeSlots,
+			ServiceId: req.ServiceId,
+			TimeSlots: availableTimeSlots,
+			Slots:     slots,
 		},
 	}
 
@@ -1272,6 +1373,6 @@ func GetAvailableTimeSlotsHandler(w http.ResponseWriter, r *http.Request) {
 
 	LogInfo("获取可用时间槽成功", map[string]interface{}{
 		"date":      req.Date,
-		"timeSlots": allowedTimeSlots,
+		"timeSlots": availableTimeSlots,
 	})
 }
@@ -0,0 +1,328 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// 订单状态事件
+const (
+	OrderEventCreate        = "create"         // 创建订单
+	OrderEventPay           = "pay"            // 支付成功
+	OrderEventCancel        = "cancel"         // 取消订单
+	OrderEventExpire        = "expire"         // 超时未支付自动取消
+	OrderEventReschedule    = "reschedule"     // 改约预约时间
+	OrderEventAssign        = "assign"         // 派单给护理员
+	OrderEventReassign      = "reassign"       // 改派护理员
+	OrderEventAccept        = "accept"         // 护理员接单
+	OrderEventDecline       = "decline"        // 护理员拒单，订单退回待派单
+	OrderEventCheckIn       = "check_in"       // 护理员到达签到
+	OrderEventCheckOut      = "check_out"      // 护理员服务结束签退
+	OrderEventComplete      = "complete"       // 服务完成
+	OrderEventRefundRequest = "refund_request" // 申请退款
+	OrderEventRefund        = "refund"         // 退款完成
+)
+
+// 订单状态变更的操作方
+const (
+	OrderActorUser      = "user"
+	OrderActorAdmin     = "admin"
+	OrderActorSystem    = "system"
+	OrderActorWechatPay = "wechat_pay"
+	OrderActorMockPay   = "mock_pay" // 本地模拟支付
+	OrderActorCaregiver = "caregiver"
+)
+
+// errOrderStateChanged 订单状态已被其他请求变更（条件更新未命中）
+var errOrderStateChanged = errors.New("订单状态已变更，请刷新后重试")
+
+// orderStatusUnchanged 迁移后保持原订单状态（如申请退款、改派）
+const orderStatusUnchanged = -1
+
+// orderTransition 一条合法的状态迁移
+type orderTransition struct {
+	FromStatus       []int // 允许的变更前订单状态
+	FromRefundStatus []int // 允许的变更前退款状态
+	ToStatus         int   // 为orderStatusUnchanged时保持原状态
+	ToRefundStatus   int
+	ReleaseSlot      bool // 是否释放订单占用的预约时间段名额
+}
+
+// orderTransitions 订单状态机：事件 -> 合法迁移
+// 未在此表中列出的变更一律视为非法
+var orderTransitions = map[string]orderTransition{
+	OrderEventPay: {
+		FromStatus:       []int{model.OrderStatusPending},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         model.OrderStatusPaid,
+		ToRefundStatus:   model.RefundStatusNone,
+	},
+	OrderEventCancel: {
+		FromStatus:       []int{model.OrderStatusPending},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         model.OrderStatusCancelled,
+		ToRefundStatus:   model.RefundStatusNone,
+		ReleaseSlot:      true,
+	},
+	OrderEventExpire: {
+		FromStatus:       []int{model.OrderStatusPending},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         model.OrderStatusCancelled,
+		ToRefundStatus:   model.RefundStatusNone,
+		ReleaseSlot:      true,
+	},
+	OrderEventReschedule: {
+		FromStatus:       []int{model.OrderStatusPaid, model.OrderStatusAssigned},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         orderStatusUnchanged,
+		ToRefundStatus:   model.RefundStatusNone,
+	},
+	OrderEventAssign: {
+		FromStatus:       []int{model.OrderStatusPaid},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         model.OrderStatusAssigned,
+		ToRefundStatus:   model.RefundStatusNone,
+	},
+	OrderEventReassign: {
+		FromStatus:       []int{model.OrderStatusAssigned},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         orderStatusUnchanged,
+		ToRefundStatus:   model.RefundStatusNone,
+	},
+	OrderEventAccept: {
+		FromStatus:       []int{model.OrderStatusAssigned},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         orderStatusUnchanged,
+		ToRefundStatus:   model.RefundStatusNone,
+	},
+	OrderEventDecline: {
+		FromStatus:       []int{model.OrderStatusAssigned},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         model.OrderStatusPaid,
+		ToRefundStatus:   model.RefundStatusNone,
+	},
+	OrderEventCheckIn: {
+		FromStatus:       []int{model.OrderStatusAssigned},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         orderStatusUnchanged,
+		ToRefundStatus:   model.RefundStatusNone,
+	},
+	OrderEventCheckOut: {
+		FromStatus:       []int{model.OrderStatusAssigned},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         orderStatusUnchanged,
+		ToRefundStatus:   model.RefundStatusNone,
+	},
+	OrderEventComplete: {
+		FromStatus:       []int{model.OrderStatusPaid, model.OrderStatusAssigned},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         model.OrderStatusCompleted,
+		ToRefundStatus:   model.RefundStatusNone,
+	},
+	OrderEventRefundRequest: {
+		FromStatus:       []int{model.OrderStatusPaid, model.OrderStatusAssigned},
+		FromRefundStatus: []int{model.RefundStatusNone},
+		ToStatus:         orderStatusUnchanged,
+		ToRefundStatus:   model.RefundStatusRefunding,
+	},
+	OrderEventRefund: {
+		FromStatus:       []int{model.OrderStatusPaid, model.OrderStatusAssigned},
+		FromRefundStatus: []int{model.RefundStatusNone, model.RefundStatusRefunding},
+		ToStatus:         model.OrderStatusRefunded,
+		ToRefundStatus:   model.RefundStatusRefunded,
+		ReleaseSlot:      true,
+	},
+}
+
+// CanTransitionOrder 判断订单当前状态下是否允许发生该事件
+func CanTransitionOrder(order *model.OrderModel, event string) bool {
+	transition, ok := orderTransitions[event]
+	if !ok {
+		return false
+	}
+	statusAllowed := false
+	for _, status := range transition.FromStatus {
+		if order.Status == status {
+			statusAllowed = true
+			break
+		}
+	}
+	if !statusAllowed {
+		return false
+	}
+	for _, refundStatus := range transition.FromRefundStatus {
+		if order.RefundStatus == refundStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionOrder 按状态机变更订单状态，并在同一事务中写入状态变更记录
+// extra 为随状态一起更新的字段（如支付时间、退款金额），可为nil
+// 成功后会同步更新传入的order对象
+func TransitionOrder(order *model.OrderModel, event, actorType, actorId, reason string, extra map[string]interface{}) error {
+	return TransitionOrderWith(order, event, actorType, actorId, reason, extra, nil)
+}
+
+// TransitionOrderWith 与TransitionOrder相同，额外在同一事务中执行then（如创建佣金），then返回错误时状态变更一并回滚
+// 退款完成时会在同一事务中作废该订单待结算的佣金
+func TransitionOrderWith(order *model.OrderModel, event, actorType, actorId, reason string, extra map[string]interface{}, then func(uow *dao.UnitOfWork) error) error {
+	if !CanTransitionOrder(order, event) {
+		return fmt.Errorf("订单当前状态为%s，不允许%s", OrderStatusText(order.Status, order.RefundStatus), OrderEventText(event))
+	}
+	transition := orderTransitions[event]
+	toStatus := transition.ToStatus
+	if toStatus == orderStatusUnchanged {
+		toStatus = order.Status
+	}
+
+	updates := map[string]interface{}{}
+	for key, value := range extra {
+		updates[key] = value
+	}
+	updates["status"] = toStatus
+	updates["refundStatus"] = transition.ToRefundStatus
+	if event == OrderEventPay {
+		updates["payStatus"] = 1
+	}
+	if event == OrderEventRefund {
+		updates["refundTime"] = time.Now()
+	}
+
+	fromStatus := order.Status
+	statusLog := &model.OrderStatusLogModel{
+		OrderId:      order.Id,
+		OrderNo:      order.OrderNo,
+		Event:        event,
+		FromStatus:   &fromStatus,
+		ToStatus:     toStatus,
+		RefundStatus: transition.ToRefundStatus,
+		ActorType:    actorType,
+		ActorId:      actorId,
+		Reason:       reason,
+	}
+
+	err := dao.RunInTransaction(func(uow *dao.UnitOfWork) error {
+		changed, err := dao.OrderImp.TransitionOrderTx(uow, order.Id, order.Status, order.RefundStatus, updates, statusLog, transition.ReleaseSlot)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return errOrderStateChanged
+		}
+		if event == OrderEventRefund {
+			if _, err := dao.ReferralImp.CancelOrderCommissionsTx(uow, order.Id); err != nil {
+				return fmt.Errorf("作废订单佣金失败: %v", err)
+			}
+		}
+		if then != nil {
+			return then(uow)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	LogInfo("订单状态变更", map[string]interface{}{
+		"orderNo":    order.OrderNo,
+		"event":      event,
+		"fromStatus": fromStatus,
+		"toStatus":   toStatus,
+		"actorType":  actorType,
+		"actorId":    actorId,
+	})
+
+	order.Status = toStatus
+	order.RefundStatus = transition.ToRefundStatus
+	if event == OrderEventPay {
+		order.PayStatus = 1
+	}
+	return nil
+}
+
+// ConfirmOrderPayment 确认订单支付：状态变更为已支付，有推荐人时在同一事务中创建佣金记录
+// 订单已被其他请求确认时返回错误，不会重复创建佣金
+func ConfirmOrderPayment(order *model.OrderModel, actorType, actorId string, extra map[string]interface{}) error {
+	return TransitionOrderWith(order, OrderEventPay, actorType, actorId, "", extra, func(uow *dao.UnitOfWork) error {
+		if order.ReferrerId <= 0 || order.Commission <= 0 {
+			return nil
+		}
+		commission := &model.CommissionModel{
+			UserId:  fmt.Sprintf("%d", order.ReferrerId), // 将int32转换为string
+			OrderId: order.Id,
+			OrderNo: order.OrderNo,
+			Amount:  order.Commission,
+			Rate:    CommissionRate,
+			Status:  model.CommissionStatusPending,
+		}
+		if err := dao.ReferralImp.CreateCommissionTx(uow, commission); err != nil {
+			return fmt.Errorf("创建佣金记录失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// OrderStatusText 订单状态文案，退款中的已支付（已派单）订单显示为退款中
+func OrderStatusText(status, refundStatus int) string {
+	if (status == model.OrderStatusPaid || status == model.OrderStatusAssigned) && refundStatus == model.RefundStatusRefunding {
+		return "退款中"
+	}
+	switch status {
+	case model.OrderStatusPending:
+		return "待支付"
+	case model.OrderStatusPaid:
+		return "已支付"
+	case model.OrderStatusCompleted:
+		return "已完成"
+	case model.OrderStatusCancelled:
+		return "已取消"
+	case model.OrderStatusRefunded:
+		return "已退款"
+	case model.OrderStatusAssigned:
+		return "已派单"
+	default:
+		return "未知状态"
+	}
+}
+
+// OrderEventText 订单事件文案
+func OrderEventText(event string) string {
+	switch event {
+	case OrderEventCreate:
+		return "创建订单"
+	case OrderEventPay:
+		return "支付"
+	case OrderEventCancel:
+		return "取消订单"
+	case OrderEventExpire:
+		return "超时取消"
+	case OrderEventReschedule:
+		return "改约"
+	case OrderEventAssign:
+		return "派单"
+	case OrderEventReassign:
+		return "改派"
+	case OrderEventAccept:
+		return "护理员接单"
+	case OrderEventDecline:
+		return "护理员拒单"
+	case OrderEventCheckIn:
+		return "到达签到"
+	case OrderEventCheckOut:
+		return "服务签退"
+	case OrderEventComplete:
+		return "完成服务"
+	case OrderEventRefundRequest:
+		return "申请退款"
+	case OrderEventRefund:
+		return "退款"
+	default:
+		return event
+	}
+}
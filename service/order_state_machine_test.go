@@ -0,0 +1,114 @@
+package service
+
+import (
+	"testing"
+
+	"wxcloudrun-golang/db/model"
+)
+
+var allOrderStatuses = []int{
+	model.OrderStatusPending,
+	model.OrderStatusPaid,
+	model.OrderStatusCompleted,
+	model.OrderStatusCancelled,
+	model.OrderStatusRefunded,
+	model.OrderStatusAssigned,
+}
+
+var allRefundStatuses = []int{
+	model.RefundStatusNone,
+	model.RefundStatusRefunding,
+	model.RefundStatusRefunded,
+}
+
+// orderState 订单状态和退款状态的组合
+type orderState struct {
+	status       int
+	refundStatus int
+}
+
+func TestCanTransitionOrder(t *testing.T) {
+	pending := orderState{model.OrderStatusPending, model.RefundStatusNone}
+	paid := orderState{model.OrderStatusPaid, model.RefundStatusNone}
+	assigned := orderState{model.OrderStatusAssigned, model.RefundStatusNone}
+	paidRefunding := orderState{model.OrderStatusPaid, model.RefundStatusRefunding}
+	assignedRefunding := orderState{model.OrderStatusAssigned, model.RefundStatusRefunding}
+
+	// 每个事件允许的全部状态组合，其余组合都必须被拒绝
+	tests := []struct {
+		event   string
+		allowed []orderState
+	}{
+		{OrderEventPay, []orderState{pending}},
+		{OrderEventCancel, []orderState{pending}},
+		{OrderEventExpire, []orderState{pending}},
+		{OrderEventReschedule, []orderState{paid, assigned}},
+		{OrderEventAssign, []orderState{paid}},
+		{OrderEventReassign, []orderState{assigned}},
+		{OrderEventAccept, []orderState{assigned}},
+		{OrderEventDecline, []orderState{assigned}},
+		{OrderEventCheckIn, []orderState{assigned}},
+		{OrderEventCheckOut, []orderState{assigned}},
+		{OrderEventComplete, []orderState{paid, assigned}},
+		{OrderEventRefundRequest, []orderState{paid, assigned}},
+		{OrderEventRefund, []orderState{paid, assigned, paidRefunding, assignedRefunding}},
+		{OrderEventCreate, nil},
+		{"unknown", nil},
+	}
+
+	for _, tt := range tests {
+		allowed := map[orderState]bool{}
+		for _, state := range tt.allowed {
+			allowed[state] = true
+		}
+		for _, status := range allOrderStatuses {
+			for _, refundStatus := range allRefundStatuses {
+				state := orderState{status, refundStatus}
+				order := &model.OrderModel{Status: status, RefundStatus: refundStatus}
+				if got := CanTransitionOrder(order, tt.event); got != allowed[state] {
+					t.Errorf("CanTransitionOrder(status=%d, refundStatus=%d, %s) = %v, want %v",
+						status, refundStatus, tt.event, got, allowed[state])
+				}
+			}
+		}
+	}
+}
+
+func TestOrderTransitionsTargetState(t *testing.T) {
+	tests := []struct {
+		event          string
+		toStatus       int
+		toRefundStatus int
+		releaseSlot    bool
+	}{
+		{OrderEventPay, model.OrderStatusPaid, model.RefundStatusNone, false},
+		{OrderEventCancel, model.OrderStatusCancelled, model.RefundStatusNone, true},
+		{OrderEventExpire, model.OrderStatusCancelled, model.RefundStatusNone, true},
+		{OrderEventReschedule, orderStatusUnchanged, model.RefundStatusNone, false},
+		{OrderEventAssign, model.OrderStatusAssigned, model.RefundStatusNone, false},
+		{OrderEventReassign, orderStatusUnchanged, model.RefundStatusNone, false},
+		{OrderEventAccept, orderStatusUnchanged, model.RefundStatusNone, false},
+		{OrderEventDecline, model.OrderStatusPaid, model.RefundStatusNone, false},
+		{OrderEventCheckIn, orderStatusUnchanged, model.RefundStatusNone, false},
+		{OrderEventCheckOut, orderStatusUnchanged, model.RefundStatusNone, false},
+		{OrderEventComplete, model.OrderStatusCompleted, model.RefundStatusNone, false},
+		{OrderEventRefundRequest, orderStatusUnchanged, model.RefundStatusRefunding, false},
+		{OrderEventRefund, model.OrderStatusRefunded, model.RefundStatusRefunded, true},
+	}
+
+	if len(tests) != len(orderTransitions) {
+		t.Fatalf("orderTransitions has %d events, test covers %d", len(orderTransitions), len(tests))
+	}
+	for _, tt := range tests {
+		transition, ok := orderTransitions[tt.event]
+		if !ok {
+			t.Errorf("event %s missing from orderTransitions", tt.event)
+			continue
+		}
+		if transition.ToStatus != tt.toStatus || transition.ToRefundStatus != tt.toRefundStatus || transition.ReleaseSlot != tt.releaseSlot {
+			t.Errorf("%s: got (status=%d, refundStatus=%d, releaseSlot=%v), want (%d, %d, %v)",
+				tt.event, transition.ToStatus, transition.ToRefundStatus, transition.ReleaseSlot,
+				tt.toStatus, tt.toRefundStatus, tt.releaseSlot)
+		}
+	}
+}
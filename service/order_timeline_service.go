@@ -0,0 +1,178 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// OrderTimelineItem 订单状态时间线条目
+type OrderTimelineItem struct {
+	Event         string    `json:"event"`
+	EventText     string    `json:"eventText"`
+	FromStatus    *int      `json:"fromStatus"`
+	ToStatus      int       `json:"toStatus"`
+	RefundStatus  int       `json:"refundStatus"`
+	StatusText    string    `json:"statusText"`
+	ActorType     string    `json:"actorType"`
+	ActorId       string    `json:"actorId,omitempty"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"createdAt"`
+	FormattedDate string    `json:"formattedDate"`
+}
+
+// buildOrderTimeline 查询订单状态变更记录并转换为时间线，includeActorId为false时隐藏操作人ID
+func buildOrderTimeline(order *model.OrderModel, includeActorId bool) ([]*OrderTimelineItem, error) {
+	logs, err := dao.OrderImp.GetOrderStatusLogs(order.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := make([]*OrderTimelineItem, 0, len(logs))
+	for _, statusLog := range logs {
+		item := &OrderTimelineItem{
+			Event:         statusLog.Event,
+			EventText:     OrderEventText(statusLog.Event),
+			FromStatus:    statusLog.FromStatus,
+			ToStatus:      statusLog.ToStatus,
+			RefundStatus:  statusLog.RefundStatus,
+			StatusText:    OrderStatusText(statusLog.ToStatus, statusLog.RefundStatus),
+			ActorType:     statusLog.ActorType,
+			Reason:        statusLog.Reason,
+			CreatedAt:     statusLog.CreatedAt,
+			FormattedDate: statusLog.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		}
+		if includeActorId {
+			item.ActorId = statusLog.ActorId
+		}
+		timeline = append(timeline, item)
+	}
+	return timeline, nil
+}
+
+// parseTimelineOrderId 解析时间线接口的orderId参数
+func parseTimelineOrderId(r *http.Request) (int32, bool) {
+	var orderId int32
+	orderIdStr := r.URL.Query().Get("orderId")
+	if orderIdStr == "" {
+		return 0, false
+	}
+	if _, err := fmt.Sscanf(orderIdStr, "%d", &orderId); err != nil || orderId <= 0 {
+		return 0, false
+	}
+	return orderId, true
+}
+
+// OrderTimelineHandler 用户查看订单状态时间线
+func OrderTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orderId, ok := parseTimelineOrderId(r)
+	if !ok {
+		http.Error(w, "无效的订单ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := dao.OrderImp.GetOrderById(orderId)
+	if err != nil || order == nil {
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	if !requireResourceOwner(w, r, ResourceOrder, order.Id, order.UserId, "") {
+		return
+	}
+
+	timeline, err := buildOrderTimeline(order, false)
+	if err != nil {
+		LogError("获取订单时间线失败", err)
+		response := &OrderResponse{
+			Code:     -1,
+			ErrorMsg: "获取订单时间线失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := &OrderResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"orderId":    order.Id,
+			"orderNo":    order.OrderNo,
+			"status":     order.Status,
+			"statusText": OrderStatusText(order.Status, order.RefundStatus),
+			"timeline":   timeline,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// AdminOrderTimelineHandler 管理员查看订单状态时间线（含操作人）
+func AdminOrderTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 校验管理员权限
+	if !requireAdminPermission(w, r, PermOrderView) {
+		return
+	}
+
+	orderId, ok := parseTimelineOrderId(r)
+	if !ok {
+		http.Error(w, "无效的订单ID", http.StatusBadRequest)
+		return
+	}
+
+	order, err := dao.OrderImp.GetOrderById(orderId)
+	if err != nil || order == nil {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "订单不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	timeline, err := buildOrderTimeline(order, true)
+	if err != nil {
+		LogError("获取订单时间线失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取订单时间线失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"orderId":      order.Id,
+			"orderNo":      order.OrderNo,
+			"userId":       order.UserId,
+			"status":       order.Status,
+			"refundStatus": order.RefundStatus,
+			"statusText":   OrderStatusText(order.Status, order.RefundStatus),
+			"timeline":     timeline,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
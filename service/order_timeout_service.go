@@ -31,6 +31,8 @@ func (s *OrderTimeoutService) Start() {
 			select {
 			case <-s.ticker.C:
 				s.checkAndCancelExpiredOrders()
+				s.syncRefundingOrders()
+				s.cleanupExpiredIdempotencyKeys()
 			case <-s.done:
 				return
 			}
@@ -65,18 +67,54 @@ func (s *OrderTimeoutService) checkAndCancelExpiredOrders() {
 
 	log.Printf("发现 %d 个超时订单", len(expiredOrders))
 
-	// 批量取消超时订单
-	affectedRows, err := dao.OrderImp.BatchCancelExpiredOrders()
+	// 逐个按状态机取消超时订单，已被支付或取消的订单会被跳过
+	cancelled := 0
+	for _, order := range expiredOrders {
+		// 先关闭支付单，用户已完成支付而通知未到达时按支付结果结算，不再取消
+		if err := closeOrderPayment(order); err != nil {
+			log.Printf("订单 %s 已支付，跳过超时取消", order.OrderNo)
+			continue
+		}
+		if err := TransitionOrder(order, OrderEventExpire, OrderActorSystem, "", "超时未支付", nil); err != nil {
+			log.Printf("订单 %s 超时取消失败: %v", order.OrderNo, err)
+			continue
+		}
+		cancelled++
+		log.Printf("订单 %s 因超时未支付已自动取消", order.OrderNo)
+	}
+
+	log.Printf("成功取消 %d 个超时订单", cancelled)
+}
+
+// syncRefundingOrders 查询退款中订单的退款结果，退款到账的订单完成退款
+func (s *OrderTimeoutService) syncRefundingOrders() {
+	refundingOrders, err := dao.OrderImp.GetRefundingOrders()
 	if err != nil {
-		log.Printf("批量取消超时订单失败: %v", err)
+		log.Printf("获取退款中订单失败: %v", err)
 		return
 	}
 
-	log.Printf("成功取消 %d 个超时订单", affectedRows)
+	for _, order := range refundingOrders {
+		refunded, err := syncOrderRefund(order)
+		if err != nil {
+			log.Printf("订单 %s 查询退款结果失败: %v", order.OrderNo, err)
+			continue
+		}
+		if refunded {
+			log.Printf("订单 %s 退款已到账，订单已更新为已退款", order.OrderNo)
+		}
+	}
+}
 
-	// 记录取消的订单详情
-	for _, order := range expiredOrders {
-		log.Printf("订单 %s 因超时未支付已自动取消", order.OrderNo)
+// cleanupExpiredIdempotencyKeys 清理过期的幂等键和响应快照
+func (s *OrderTimeoutService) cleanupExpiredIdempotencyKeys() {
+	deleted, err := dao.IdempotencyImp.DeleteExpiredKeys(1000)
+	if err != nil {
+		log.Printf("清理过期幂等键失败: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("清理过期幂等键 %d 条", deleted)
 	}
 }
 
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/model"
+)
+
+// 模拟支付单状态，与微信支付的trade_state保持一致
+const (
+	mockTradeNotPay   = "NOTPAY"
+	mockTradeSuccess  = "SUCCESS"
+	mockTradePayError = "PAYERROR"
+	mockTradeClosed   = "CLOSED"
+)
+
+// mockPaymentProvider 本地模拟支付，不访问网络：预下单后按配置延迟发送支付成功或失败的回调
+// 只能在开发模式（APP_DEV_MODE=true）下创建，见config.PaymentConfig.Validate
+type mockPaymentProvider struct {
+	result      string
+	notifyDelay time.Duration
+
+	mutex    sync.Mutex
+	payments map[string]*mockPayment
+}
+
+// mockPayment 模拟支付单，只保存在内存中，服务重启后丢失
+type mockPayment struct {
+	orderNo       string
+	amount        model.Money
+	paidAmount    model.Money
+	state         string
+	transactionId string
+	payTime       time.Time
+	refunds       map[string]*PaymentRefundResult
+}
+
+// mockPayNotifyRequest 手动触发模拟支付回调（POST /api/admin/payment/mock-notify）
+type mockPayNotifyRequest struct {
+	OrderNo string       `json:"orderNo"`
+	Result  string       `json:"result"` // success（默认）或fail
+	Amount  *model.Money `json:"amount"` // 模拟实付金额，为空时按订单金额，用于测试金额不一致
+}
+
+// newMockPaymentProvider 创建模拟支付渠道
+func newMockPaymentProvider(mockConfig *config.MockPayConfig) *mockPaymentProvider {
+	LogInfo("已启用模拟支付，不会真实扣款，禁止用于生产环境", map[string]interface{}{
+		"result":      mockConfig.Result,
+		"notifyDelay": mockConfig.NotifyDelay.String(),
+	})
+	return &mockPaymentProvider{
+		result:      mockConfig.Result,
+		notifyDelay: mockConfig.NotifyDelay,
+		payments:    make(map[string]*mockPayment),
+	}
+}
+
+// Name 渠道名称
+func (p *mockPaymentProvider) Name() string {
+	return config.PaymentProviderMock
+}
+
+// Prepay 创建模拟支付单并按配置安排延迟回调，返回的参数不能用于wx.requestPayment
+func (p *mockPaymentProvider) Prepay(order *model.OrderModel, openID string) (map[string]interface{}, error) {
+	p.mutex.Lock()
+	payment := p.payments[order.OrderNo]
+	if payment != nil && payment.state == mockTradeSuccess {
+		p.mutex.Unlock()
+		return nil, fmt.Errorf("订单已支付")
+	}
+	if payment != nil && payment.state == mockTradeClosed {
+		p.mutex.Unlock()
+		return nil, fmt.Errorf("订单已关闭")
+	}
+	p.payments[order.OrderNo] = &mockPayment{
+		orderNo: order.OrderNo,
+		amount:  order.TotalAmount,
+		state:   mockTradeNotPay,
+	}
+	p.mutex.Unlock()
+
+	orderNo := order.OrderNo
+	result := p.result
+	if result == config.MockPayResultSuccess || result == config.MockPayResultFail {
+		time.AfterFunc(p.notifyDelay, func() {
+			p.deliverNotify(orderNo, result)
+		})
+	}
+
+	LogStep("模拟支付预下单", map[string]interface{}{
+		"orderNo":     orderNo,
+		"amount":      order.TotalAmount,
+		"result":      result,
+		"notifyDelay": p.notifyDelay.String(),
+	})
+
+	return map[string]interface{}{
+		"timeStamp":  fmt.Sprintf("%d", time.Now().Unix()),
+		"nonceStr":   generateNonceStr(),
+		"package":    "prepay_id=mock_" + orderNo,
+		"signType":   "MOCK",
+		"paySign":    "MOCK",
+		"mock":       true,
+		"mockResult": result,
+	}, nil
+}
+
+// deliverNotify 模拟支付平台的异步回调：支付成功时直接结算订单，支付失败时只更新模拟支付单
+func (p *mockPaymentProvider) deliverNotify(orderNo, result string) {
+	if result == config.MockPayResultFail {
+		p.fail(orderNo)
+		return
+	}
+	notification, err := p.pay(orderNo, nil)
+	if err != nil {
+		LogInfo("模拟支付回调跳过", map[string]interface{}{
+			"orderNo": orderNo,
+			"reason":  err.Error(),
+		})
+		return
+	}
+	if err := SettleOrderPayment(notification); err != nil {
+		LogError("模拟支付回调结算订单失败", fmt.Errorf("orderNo=%s: %v", orderNo, err))
+	}
+}
+
+// pay 将模拟支付单置为支付成功，返回待结算的支付信息；已支付时返回原交易，便于测试重复通知
+func (p *mockPaymentProvider) pay(orderNo string, paidAmount *model.Money) (*PaymentNotification, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// 只结算通过本渠道预下单的支付单，服务重启后内存中的支付单丢失，需要重新发起支付
+	payment := p.payments[orderNo]
+	if payment == nil {
+		return nil, fmt.Errorf("支付单不存在: %s", orderNo)
+	}
+
+	switch payment.state {
+	case mockTradeSuccess:
+		return payment.notification(), nil
+	case mockTradeClosed:
+		return nil, fmt.Errorf("支付单已关闭")
+	}
+
+	payment.state = mockTradeSuccess
+	payment.transactionId = fmt.Sprintf("MOCK%s%06d", time.Now().Format("20060102150405"), rand.Intn(1000000))
+	payment.payTime = time.Now()
+	payment.paidAmount = payment.amount
+	if paidAmount != nil {
+		payment.paidAmount = *paidAmount
+	}
+	LogInfo("模拟支付成功", map[string]interface{}{
+		"orderNo":       orderNo,
+		"transactionId": payment.transactionId,
+		"paidAmount":    payment.paidAmount,
+	})
+	return payment.notification(), nil
+}
+
+// fail 将未支付的模拟支付单置为支付失败，订单保持待支付，用户可重新支付
+func (p *mockPaymentProvider) fail(orderNo string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if payment := p.payments[orderNo]; payment != nil && payment.state == mockTradeNotPay {
+		payment.state = mockTradePayError
+	}
+	LogInfo("模拟支付失败", map[string]interface{}{
+		"orderNo": orderNo,
+	})
+}
+
+// notification 支付成功的模拟支付单对应的结算信息
+func (m *mockPayment) notification() *PaymentNotification {
+	return &PaymentNotification{
+		OrderNo:       m.orderNo,
+		TransactionId: m.transactionId,
+		PaidAmount:    m.paidAmount,
+		PayTime:       m.payTime,
+		PayMethod:     config.PaymentProviderMock,
+	}
+}
+
+// Query 查询模拟支付单
+func (p *mockPaymentProvider) Query(orderNo string) (*PaymentNotification, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	payment := p.payments[orderNo]
+	if payment == nil || payment.state != mockTradeSuccess {
+		return nil, nil
+	}
+	return payment.notification(), nil
+}
+
+// Close 关闭模拟支付单，关闭后延迟回调不再结算订单
+func (p *mockPaymentProvider) Close(orderNo string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	payment := p.payments[orderNo]
+	if payment == nil {
+		return nil
+	}
+	if payment.state == mockTradeSuccess {
+		return errPaymentAlreadyPaid
+	}
+	payment.state = mockTradeClosed
+	return nil
+}
+
+// Refund 模拟退款，已支付的模拟支付单立即退款成功
+func (p *mockPaymentProvider) Refund(request *PaymentRefundRequest) (*PaymentRefundResult, error) {
+	if request.RefundAmount <= 0 || request.RefundAmount > request.TotalAmount {
+		return nil, fmt.Errorf("退款金额无效: %s", request.RefundAmount)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	// 与支付平台一致，没有对应的支付单时拒绝退款；服务重启后内存中的支付单丢失，需改为线下退款
+	payment := p.payments[request.OrderNo]
+	if payment == nil {
+		return nil, fmt.Errorf("支付单不存在: %s", request.OrderNo)
+	}
+	if payment.state != mockTradeSuccess {
+		return nil, fmt.Errorf("支付单未支付，无法退款")
+	}
+	if payment.refunds == nil {
+		payment.refunds = make(map[string]*PaymentRefundResult)
+	}
+	if result, ok := payment.refunds[request.RefundNo]; ok {
+		return result, nil
+	}
+
+	result := &PaymentRefundResult{
+		RefundNo: request.RefundNo,
+		RefundId: fmt.Sprintf("MOCKREFUND%s%06d", time.Now().Format("20060102150405"), rand.Intn(1000000)),
+		Status:   PaymentRefundSuccess,
+	}
+	payment.refunds[request.RefundNo] = result
+	return result, nil
+}
+
+// QueryRefund 查询模拟退款，模拟退款申请后立即成功
+func (p *mockPaymentProvider) QueryRefund(refundNo string) (*PaymentRefundResult, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, payment := range p.payments {
+		if result, ok := payment.refunds[refundNo]; ok {
+			return result, nil
+		}
+	}
+	return nil, nil
+}
+
+// ParseNotify 模拟支付不接受支付通知接口的回调，避免任何人通过公开的通知地址把订单结算为已支付
+func (p *mockPaymentProvider) ParseNotify(r *http.Request) (*PaymentNotification, error) {
+	return nil, fmt.Errorf("模拟支付请通过/api/admin/payment/mock-notify触发回调")
+}
+
+// trigger 手动触发模拟支付结果，支付成功时返回待结算的支付信息
+func (p *mockPaymentProvider) trigger(req *mockPayNotifyRequest) (*PaymentNotification, error) {
+	LogStep("手动触发模拟支付回调", map[string]interface{}{
+		"orderNo": req.OrderNo,
+		"result":  req.Result,
+		"amount":  req.Amount,
+	})
+
+	if req.Result == config.MockPayResultFail {
+		p.fail(req.OrderNo)
+		return nil, nil
+	}
+	return p.pay(req.OrderNo, req.Amount)
+}
+
+// AckNotify 应答模拟支付回调
+func (p *mockPaymentProvider) AckNotify(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code":    "FAIL",
+			"message": err.Error(),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":    "SUCCESS",
+		"message": "成功",
+	})
+}
+
+// MockPaymentNotifyHandler 手动触发模拟支付回调接口（运维接口，仅超级管理员，且只在启用模拟支付时可用）
+// 可用于测试支付失败、重复通知和金额不一致
+func MockPaymentNotifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	provider, ok := GetPaymentProvider().(*mockPaymentProvider)
+	if !ok {
+		http.Error(w, "当前未启用模拟支付", http.StatusNotFound)
+		return
+	}
+
+	var req mockPayNotifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+	if req.OrderNo == "" {
+		http.Error(w, "缺少订单号", http.StatusBadRequest)
+		return
+	}
+
+	notification, err := provider.trigger(&req)
+	if err == nil && notification != nil {
+		err = SettleOrderPayment(notification)
+	}
+	if err != nil {
+		LogError("模拟支付回调失败", fmt.Errorf("orderNo=%s: %v", req.OrderNo, err))
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"orderNo": req.OrderNo,
+			"settled": notification != nil,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
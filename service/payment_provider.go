@@ -0,0 +1,293 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/model"
+)
+
+// PaymentProvider 支付渠道接口，新增渠道时实现该接口并在newPaymentProvider中注册
+type PaymentProvider interface {
+	// Name 渠道名称，支付成功后记录为订单的支付方式
+	Name() string
+	// Prepay 预下单，返回小程序调起支付所需的参数
+	Prepay(order *model.OrderModel, openID string) (map[string]interface{}, error)
+	// Query 按商户订单号查询支付结果，未支付时返回nil
+	Query(orderNo string) (*PaymentNotification, error)
+	// Close 关闭未支付的支付单，订单取消后用户无法再完成支付；已支付时返回errPaymentAlreadyPaid
+	Close(orderNo string) error
+	// Refund 申请退款，同一退款单号重复申请不会重复退款
+	Refund(request *PaymentRefundRequest) (*PaymentRefundResult, error)
+	// QueryRefund 按商户退款单号查询退款结果，退款单不存在时返回nil
+	QueryRefund(refundNo string) (*PaymentRefundResult, error)
+	// ParseNotify 验证并解析支付结果通知，不需要结算（如支付失败）时返回nil
+	ParseNotify(r *http.Request) (*PaymentNotification, error)
+	// AckNotify 应答支付结果通知，err不为nil时要求支付平台稍后重新通知
+	AckNotify(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// PaymentRefundRequest 申请退款参数
+type PaymentRefundRequest struct {
+	OrderNo      string      // 商户订单号
+	RefundNo     string      // 商户退款单号
+	TotalAmount  model.Money // 订单金额
+	RefundAmount model.Money // 退款金额
+	Reason       string      // 退款原因
+}
+
+// PaymentRefundResult 申请退款结果
+type PaymentRefundResult struct {
+	RefundNo string `json:"refundNo"` // 商户退款单号
+	RefundId string `json:"refundId"` // 支付平台退款单号
+	Status   string `json:"status"`   // 退款状态：SUCCESS-退款成功，PROCESSING-退款处理中
+}
+
+// 退款状态
+const (
+	PaymentRefundSuccess    = "SUCCESS"
+	PaymentRefundProcessing = "PROCESSING"
+)
+
+// errPaymentAlreadyPaid 关闭支付单时支付平台返回订单已支付
+var errPaymentAlreadyPaid = errors.New("订单已支付")
+
+var (
+	paymentProviderMutex sync.Mutex
+	paymentProvider      PaymentProvider
+)
+
+// GetPaymentProvider 获取支付渠道，未设置时按PAYMENT_PROVIDER配置创建
+func GetPaymentProvider() PaymentProvider {
+	paymentProviderMutex.Lock()
+	defer paymentProviderMutex.Unlock()
+	if paymentProvider == nil {
+		paymentProvider = newPaymentProvider(config.GetPaymentConfig())
+	}
+	return paymentProvider
+}
+
+// SetPaymentProvider 替换支付渠道（测试时注入模拟实现），传nil时恢复默认实现
+func SetPaymentProvider(provider PaymentProvider) {
+	paymentProviderMutex.Lock()
+	defer paymentProviderMutex.Unlock()
+	paymentProvider = provider
+}
+
+// newPaymentProvider 根据配置创建支付渠道，未知配置按微信支付处理；非开发模式下不创建模拟支付
+func newPaymentProvider(paymentConfig *config.PaymentConfig) PaymentProvider {
+	switch paymentConfig.Provider {
+	case config.PaymentProviderMock:
+		if err := paymentConfig.Validate(); err != nil {
+			LogError("支付渠道配置无效", fmt.Errorf("%v，使用微信支付", err))
+			return &wechatPaymentProvider{wechatConfig: paymentConfig.WechatPay}
+		}
+		return newMockPaymentProvider(&paymentConfig.Mock)
+	case config.PaymentProviderWechat, "":
+		return &wechatPaymentProvider{wechatConfig: paymentConfig.WechatPay}
+	default:
+		LogError("支付渠道配置无效", fmt.Errorf("PAYMENT_PROVIDER=%s，使用微信支付", paymentConfig.Provider))
+		return &wechatPaymentProvider{wechatConfig: paymentConfig.WechatPay}
+	}
+}
+
+// wechatPaymentProvider 微信支付，按WECHAT_PAY_API_VERSION使用v2或APIv3接口
+type wechatPaymentProvider struct {
+	wechatConfig config.WechatPayConfig
+}
+
+// Name 渠道名称
+func (p *wechatPaymentProvider) Name() string {
+	return config.PaymentProviderWechat
+}
+
+// Prepay 统一下单（v2）或JSAPI下单（v3）
+func (p *wechatPaymentProvider) Prepay(order *model.OrderModel, openID string) (map[string]interface{}, error) {
+	return GenerateWechatPayParams(order, openID)
+}
+
+// Query 查询订单
+func (p *wechatPaymentProvider) Query(orderNo string) (*PaymentNotification, error) {
+	if p.wechatConfig.IsV3() {
+		return queryWechatPayV3Order(&p.wechatConfig, orderNo)
+	}
+	return queryWechatPayV2Order(&p.wechatConfig, orderNo)
+}
+
+// Close 关闭订单
+func (p *wechatPaymentProvider) Close(orderNo string) error {
+	if p.wechatConfig.IsV3() {
+		return closeWechatPayV3Order(&p.wechatConfig, orderNo)
+	}
+	return closeWechatPayV2Order(&p.wechatConfig, orderNo)
+}
+
+// Refund 申请退款
+func (p *wechatPaymentProvider) Refund(request *PaymentRefundRequest) (*PaymentRefundResult, error) {
+	if p.wechatConfig.IsV3() {
+		return refundWechatPayV3(&p.wechatConfig, request)
+	}
+	return refundWechatPayV2(&p.wechatConfig, request)
+}
+
+// QueryRefund 查询退款
+func (p *wechatPaymentProvider) QueryRefund(refundNo string) (*PaymentRefundResult, error) {
+	if p.wechatConfig.IsV3() {
+		return queryWechatPayV3Refund(&p.wechatConfig, refundNo)
+	}
+	return queryWechatPayV2Refund(&p.wechatConfig, refundNo)
+}
+
+// ParseNotify 解析支付结果通知，APIv3通知按请求头识别，切换版本期间v2下单的订单仍可能收到v2通知
+func (p *wechatPaymentProvider) ParseNotify(r *http.Request) (*PaymentNotification, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		LogError("读取支付通知失败", err)
+		return nil, fmt.Errorf("读取请求失败")
+	}
+	if isWechatPayV3Notify(r) {
+		return parseWechatPayV3Notify(&p.wechatConfig, r.Header, body)
+	}
+	return parseWechatPayV2Notify(&p.wechatConfig, body)
+}
+
+// AckNotify 应答支付结果通知，v2返回XML，v3返回JSON和HTTP状态码
+func (p *wechatPaymentProvider) AckNotify(w http.ResponseWriter, r *http.Request, err error) {
+	if isWechatPayV3Notify(r) {
+		if err != nil {
+			writeWechatPayV3NotifyResponse(w, http.StatusInternalServerError, "FAIL", err.Error())
+			return
+		}
+		writeWechatPayV3NotifyResponse(w, http.StatusOK, "SUCCESS", "成功")
+		return
+	}
+	if err != nil {
+		writeWechatPayNotifyResponse(w, "FAIL", err.Error())
+		return
+	}
+	writeWechatPayNotifyResponse(w, "SUCCESS", "OK")
+}
+
+// PaymentNotifyHandler 支付结果通知接口：由当前支付渠道验签解析后按商户订单号结算订单，重复通知幂等处理
+func PaymentNotifyHandler(w http.ResponseWriter, r *http.Request) {
+	provider := GetPaymentProvider()
+	LogStep("收到支付结果通知", map[string]interface{}{
+		"provider": provider.Name(),
+	})
+
+	notification, err := provider.ParseNotify(r)
+	if err != nil {
+		provider.AckNotify(w, r, err)
+		return
+	}
+	if notification != nil {
+		if err := SettleOrderPayment(notification); err != nil {
+			LogError("支付通知结算订单失败", err)
+			provider.AckNotify(w, r, err)
+			return
+		}
+	}
+
+	provider.AckNotify(w, r, nil)
+	LogStep("支付通知处理完成", nil)
+}
+
+// syncOrderPayment 主动向支付渠道查询待支付订单的支付结果，已支付时结算订单，用于支付通知延迟或丢失的情况
+// 返回订单是否已结算
+func syncOrderPayment(order *model.OrderModel) (bool, error) {
+	notification, err := GetPaymentProvider().Query(order.OrderNo)
+	if err != nil || notification == nil {
+		return false, err
+	}
+	if err := SettleOrderPayment(notification); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// closeOrderPayment 取消待支付订单前关闭支付渠道的支付单，避免用户在订单取消后仍完成支付
+// 支付渠道返回已支付时查询并结算订单后返回errPaymentAlreadyPaid，其他失败只记录日志，不阻止取消
+func closeOrderPayment(order *model.OrderModel) error {
+	err := GetPaymentProvider().Close(order.OrderNo)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, errPaymentAlreadyPaid) {
+		LogError("关闭支付单失败", fmt.Errorf("orderNo=%s: %v", order.OrderNo, err))
+	}
+
+	// 关闭失败时查询一次，用户可能已完成支付而通知尚未到达
+	settled, syncErr := syncOrderPayment(order)
+	if syncErr != nil {
+		LogError("查询支付结果失败", fmt.Errorf("orderNo=%s: %v", order.OrderNo, syncErr))
+	}
+	if settled || errors.Is(err, errPaymentAlreadyPaid) {
+		return errPaymentAlreadyPaid
+	}
+	return nil
+}
+
+// orderRefundNo 订单的商户退款单号，按订单号生成
+func orderRefundNo(order *model.OrderModel) string {
+	return "R" + order.OrderNo
+}
+
+// refundOrderPayment 通过支付渠道原路退款，退款单号按订单号生成，重复提交不会重复退款
+// 返回的退款状态为PROCESSING时退款尚未到账，需由syncOrderRefund确认结果后再完成退款
+func refundOrderPayment(order *model.OrderModel, refundAmount model.Money, reason string) (*PaymentRefundResult, error) {
+	provider := GetPaymentProvider()
+	if order.PayMethod != "" && order.PayMethod != provider.Name() {
+		return nil, fmt.Errorf("订单通过%s支付，与当前支付渠道%s不一致", order.PayMethod, provider.Name())
+	}
+	result, err := provider.Refund(&PaymentRefundRequest{
+		OrderNo:      order.OrderNo,
+		RefundNo:     orderRefundNo(order),
+		TotalAmount:  order.TotalAmount,
+		RefundAmount: refundAmount,
+		Reason:       reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	LogInfo("支付渠道退款申请成功", map[string]interface{}{
+		"orderNo":      order.OrderNo,
+		"refundNo":     result.RefundNo,
+		"refundId":     result.RefundId,
+		"status":       result.Status,
+		"refundAmount": refundAmount,
+		"provider":     provider.Name(),
+	})
+	return result, nil
+}
+
+// syncOrderRefund 向支付渠道查询退款中订单的退款结果，退款成功时完成退款（订单变更为已退款并作废佣金）
+// 返回订单是否已完成退款
+func syncOrderRefund(order *model.OrderModel) (bool, error) {
+	if order.RefundNo == "" || order.RefundStatus != model.RefundStatusRefunding {
+		return false, nil
+	}
+	provider := GetPaymentProvider()
+	result, err := provider.QueryRefund(order.RefundNo)
+	if err != nil || result == nil || result.Status != PaymentRefundSuccess {
+		return false, err
+	}
+
+	actor := OrderActorWechatPay
+	if provider.Name() == config.PaymentProviderMock {
+		actor = OrderActorMockPay
+	}
+	if err := TransitionOrder(order, OrderEventRefund, actor, "", order.RefundReason, nil); err != nil {
+		return false, err
+	}
+	LogInfo("支付渠道退款已到账", map[string]interface{}{
+		"orderNo":      order.OrderNo,
+		"refundNo":     result.RefundNo,
+		"refundId":     result.RefundId,
+		"refundAmount": order.RefundAmount,
+	})
+	return true, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// PaymentNotification 支付平台回调中与订单结算相关的字段
+type PaymentNotification struct {
+	OrderNo       string      // 商户订单号（out_trade_no）
+	TransactionId string      // 支付平台交易号
+	PaidAmount    model.Money // 实际支付金额
+	PayTime       time.Time   // 支付完成时间
+	PayMethod     string      // 支付方式，即支付渠道名称，如wechat、mock
+}
+
+// SettleOrderPayment 按支付平台的回调结算订单：校验金额后将待支付订单变更为已支付，同一事务中创建佣金
+// 同一交易重复通知时直接返回成功；返回错误表示需要支付平台稍后重试
+// 订单已取消或已用其他交易支付等无法自动处理的情况只记录日志并通知管理员，不再要求重试
+func SettleOrderPayment(notification *PaymentNotification) error {
+	order, err := dao.OrderImp.GetOrderByOrderNo(notification.OrderNo)
+	if err != nil {
+		return fmt.Errorf("订单不存在: %s", notification.OrderNo)
+	}
+
+	if notification.PaidAmount != order.TotalAmount {
+		err := fmt.Errorf("支付金额%s元与订单金额%s元不一致", notification.PaidAmount, order.TotalAmount)
+		LogError("支付回调金额校验失败", fmt.Errorf("orderNo=%s, transactionId=%s: %v", order.OrderNo, notification.TransactionId, err))
+		notifyPaymentAbnormal(order, notification, err.Error())
+		return err
+	}
+
+	if order.PayStatus == 1 {
+		return checkSettledPayment(order, notification)
+	}
+
+	if !CanTransitionOrder(order, OrderEventPay) {
+		// 订单已超时取消等情况下用户仍完成了支付，需要人工退款
+		reason := fmt.Sprintf("订单当前状态为%s，收到支付成功通知，需人工处理退款", OrderStatusText(order.Status, order.RefundStatus))
+		LogError("支付回调订单状态异常", fmt.Errorf("orderNo=%s, transactionId=%s: %s", order.OrderNo, notification.TransactionId, reason))
+		notifyPaymentAbnormal(order, notification, reason)
+		return nil
+	}
+
+	payTime := notification.PayTime
+	extra := map[string]interface{}{
+		"payTime":       &payTime,
+		"transactionId": notification.TransactionId,
+		"payMethod":     notification.PayMethod,
+	}
+	actor := OrderActorWechatPay
+	if notification.PayMethod == config.PaymentProviderMock {
+		actor = OrderActorMockPay
+	}
+	if err := ConfirmOrderPayment(order, actor, notification.TransactionId, extra); err != nil {
+		if !errors.Is(err, errOrderStateChanged) {
+			return err
+		}
+		// 并发的重复通知已经完成结算
+		latest, getErr := dao.OrderImp.GetOrderById(order.Id)
+		if getErr != nil {
+			return getErr
+		}
+		if latest.PayStatus == 1 {
+			return checkSettledPayment(latest, notification)
+		}
+		return err
+	}
+
+	order.PayTime = &payTime
+	order.TransactionId = notification.TransactionId
+	order.PayMethod = notification.PayMethod
+	LogInfo("支付回调结算订单成功", map[string]interface{}{
+		"orderNo":       order.OrderNo,
+		"transactionId": notification.TransactionId,
+		"amount":        notification.PaidAmount,
+	})
+	notifyOrderPaid(order)
+	return nil
+}
+
+// checkSettledPayment 订单已支付时处理重复通知：同一交易视为成功，不同交易说明重复支付，通知管理员处理
+func checkSettledPayment(order *model.OrderModel, notification *PaymentNotification) error {
+	if order.TransactionId == notification.TransactionId {
+		LogStep("重复的支付通知，订单已结算", map[string]interface{}{
+			"orderNo":       order.OrderNo,
+			"transactionId": notification.TransactionId,
+		})
+		return nil
+	}
+	reason := fmt.Sprintf("订单已通过交易%s支付，又收到交易%s的支付成功通知，需人工处理退款", order.TransactionId, notification.TransactionId)
+	LogError("订单重复支付", fmt.Errorf("orderNo=%s: %s", order.OrderNo, reason))
+	notifyPaymentAbnormal(order, notification, reason)
+	return nil
+}
+
+// notifyOrderPaid 支付结算成功后通过SSE通知下单用户和管理员
+func notifyOrderPaid(order *model.OrderModel) {
+	data := map[string]interface{}{
+		"orderId":     order.Id,
+		"orderNo":     order.OrderNo,
+		"totalAmount": order.TotalAmount,
+		"status":      order.Status,
+		"statusText":  OrderStatusText(order.Status, order.RefundStatus),
+		"message":     "订单支付成功",
+	}
+	SendSSEMessageToUser(order.UserId, "orderPaid", data)
+	// 订单金额只推送给可查看营收的管理员，调度等其他需要及时派单的管理员收到不含金额的消息
+	SendSSEMessageToAdmins(PermStatsView, "orderPaid", data)
+	dispatchData := map[string]interface{}{}
+	for key, value := range data {
+		if key != "totalAmount" {
+			dispatchData[key] = value
+		}
+	}
+	sendSSEMessageToAdminsMatching("orderPaid", dispatchData, func(admin *model.UserModel) bool {
+		return HasAdminPermission(admin, PermOrderDispatch) && !HasAdminPermission(admin, PermStatsView)
+	})
+}
+
+// notifyPaymentAbnormal 支付回调无法自动结算时通知管理员
+func notifyPaymentAbnormal(order *model.OrderModel, notification *PaymentNotification, reason string) {
+	SendSSEMessageToAdmins(PermOrderRefund, "paymentAbnormal", map[string]interface{}{
+		"orderId":       order.Id,
+		"orderNo":       order.OrderNo,
+		"transactionId": notification.TransactionId,
+		"paidAmount":    notification.PaidAmount,
+		"totalAmount":   order.TotalAmount,
+		"message":       reason,
+	})
+}
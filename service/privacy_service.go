@@ -0,0 +1,617 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// AccountDeletionRequest 申请注销账号请求
+type AccountDeletionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReviewAccountDeletionRequest 审核注销申请请求
+type ReviewAccountDeletionRequest struct {
+	Id      int32  `json:"id"`
+	Approve bool   `json:"approve"` // true-通过并匿名化，false-驳回
+	Remark  string `json:"remark"`
+}
+
+// userDataArchive 导出文件内容
+type userDataArchive struct {
+	UserId     string              `json:"userId"`
+	ExportedAt time.Time           `json:"exportedAt"`
+	Data       *dao.UserDataExport `json:"data"`
+}
+
+// unfinishedBusinessNames 未完结业务的中文说明
+var unfinishedBusinessNames = map[string]string{
+	"paidOrders":      "进行中的订单",
+	"refundingOrders": "退款中的订单",
+	"pendingCashouts": "处理中的提现",
+}
+
+// checkUnfinishedBusiness 检查用户是否有未完结的订单、退款、提现，有则返回说明
+func checkUnfinishedBusiness(userId string) (string, error) {
+	counts, err := dao.PrivacyImp.CountUnfinishedBusiness(userId)
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, key := range []string{"paidOrders", "refundingOrders", "pendingCashouts"} {
+		if counts[key] > 0 {
+			parts = append(parts, fmt.Sprintf("%s%d笔", unfinishedBusinessNames[key], counts[key]))
+		}
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+	return "存在" + strings.Join(parts, "、") + "，请处理完成后再注销", nil
+}
+
+// ExportUserDataHandler 导出当前用户的全部个人数据，format=json（默认）或zip，以附件形式下载
+func ExportUserDataHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理导出个人数据请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodGet {
+		LogError("请求方法不支持", fmt.Errorf("期望GET方法，实际为%s", r.Method))
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		http.Error(w, "format只支持json或zip", http.StatusBadRequest)
+		return
+	}
+
+	userId := GetAuthUserId(r)
+	export, err := dao.PrivacyImp.GetUserDataExport(userId)
+	if err != nil {
+		LogError("导出个人数据失败", err)
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: "导出个人数据失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	now := time.Now()
+	archive := &userDataArchive{
+		UserId:     userId,
+		ExportedAt: now,
+		Data:       export,
+	}
+	fileName := fmt.Sprintf("user-data-%s-%s", userId, now.Format("20060102150405"))
+
+	var body []byte
+	var contentType string
+	if format == "zip" {
+		body, err = buildUserDataZip(archive)
+		contentType = "application/zip"
+		fileName += ".zip"
+	} else {
+		body, err = json.MarshalIndent(archive, "", "  ")
+		contentType = "application/json"
+		fileName += ".json"
+	}
+	if err != nil {
+		LogError("生成导出文件失败", err)
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: "导出个人数据失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 只记录导出行为，不记录导出内容
+	LogStep("个人数据导出成功", map[string]interface{}{
+		"userId":        userId,
+		"format":        format,
+		"size":          len(body),
+		"patients":      len(export.Patients),
+		"orders":        len(export.Orders),
+		"consultations": len(export.Consultations),
+		"files":         len(export.Files),
+	})
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(body)
+}
+
+// buildUserDataZip 把导出数据按类别拆成多个JSON文件打包
+func buildUserDataZip(archive *userDataArchive) ([]byte, error) {
+	data := archive.Data
+	entries := []struct {
+		name  string
+		value interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"identities.json", data.Identities},
+		{"patients.json", data.Patients},
+		{"addresses.json", data.Addresses},
+		{"orders.json", data.Orders},
+		{"consultations.json", data.Consultations},
+		{"kefu_messages.json", data.KefuMessages},
+		{"files.json", data.Files},
+		{"referral.json", data.Referral},
+		{"commissions.json", data.Commissions},
+		{"cashouts.json", data.Cashouts},
+		{"account_deletion_requests.json", data.Deletions},
+	}
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	readme := fmt.Sprintf("用户ID: %s\n导出时间: %s\n每个JSON文件对应一类个人数据，files.json中的fileUrl为已上传文件的下载地址。\n",
+		archive.UserId, archive.ExportedAt.Format("2006-01-02 15:04:05"))
+	f, err := zw.Create("README.txt")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write([]byte(readme)); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		content, err := json.MarshalIndent(entry.value, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		f, err := zw.Create(entry.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GetAccountDeletionStatusHandler 查询当前用户待处理的注销申请
+func GetAccountDeletionStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		LogError("请求方法不支持", fmt.Errorf("期望GET方法，实际为%s", r.Method))
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	request, err := dao.PrivacyImp.GetPendingDeletionRequest(GetAuthUserId(r))
+	if err != nil {
+		LogError("查询注销申请失败", err)
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: "查询注销申请失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := &UserResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"request": request,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RequestAccountDeletionHandler 申请注销账号，进入冷静期，冷静期内可撤销，期满后由管理员审核
+func RequestAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理注销账号申请", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AccountDeletionRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			LogError("解析请求体失败", err)
+			http.Error(w, "请求体格式错误", http.StatusBadRequest)
+			return
+		}
+	}
+
+	user := GetAuthUser(r)
+	if user.IsAdmin == 1 {
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: "管理员账号不能注销，请先取消管理员身份",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	pending, err := dao.PrivacyImp.GetPendingDeletionRequest(user.UserId)
+	if err != nil {
+		LogError("查询注销申请失败", err)
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: "申请注销失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	if pending != nil {
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: "已有待处理的注销申请",
+			Data: map[string]interface{}{
+				"request": pending,
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	msg, err := checkUnfinishedBusiness(user.UserId)
+	if err != nil || msg != "" {
+		if err != nil {
+			LogError("查询未完结业务失败", err)
+			msg = "申请注销失败"
+		}
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: msg,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	request := &model.AccountDeletionRequestModel{
+		UserId:          user.UserId,
+		Reason:          strings.TrimSpace(req.Reason),
+		Status:          model.AccountDeletionStatusPending,
+		CoolingOffUntil: time.Now().Add(config.GetPrivacyConfig().DeletionCoolingOff),
+	}
+	LogDBOperation("创建", "account_deletion_requests", request)
+	if err := dao.PrivacyImp.CreateDeletionRequest(request); err != nil {
+		LogError("创建注销申请失败", err)
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: "申请注销失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogStep("注销申请已提交", map[string]interface{}{
+		"userId":          user.UserId,
+		"requestId":       request.Id,
+		"coolingOffUntil": request.CoolingOffUntil,
+	})
+
+	response := &UserResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"request": request,
+			"message": fmt.Sprintf("注销申请已提交，%s前可随时撤销，冷静期结束后将由工作人员审核", request.CoolingOffUntil.Format("2006-01-02 15:04")),
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CancelAccountDeletionHandler 撤销待处理的注销申请
+func CancelAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理撤销注销申请", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userId := GetAuthUserId(r)
+	pending, err := dao.PrivacyImp.GetPendingDeletionRequest(userId)
+	if err == nil && pending == nil {
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: "没有待处理的注销申请",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	var ok bool
+	if err == nil {
+		ok, err = dao.PrivacyImp.CancelDeletionRequest(pending.Id)
+	}
+	if err != nil || !ok {
+		LogError("撤销注销申请失败", err)
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: "撤销注销申请失败，申请可能已被处理",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	LogStep("注销申请已撤销", map[string]interface{}{
+		"userId":    userId,
+		"requestId": pending.Id,
+	})
+
+	response := &UserResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"message": "注销申请已撤销",
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAccountDeletionRequestsHandler 注销申请审核队列，默认返回待审核的申请，按冷静期截止时间排序
+func GetAccountDeletionRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理获取注销申请列表请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodGet {
+		LogError("请求方法不支持", fmt.Errorf("期望GET方法，实际为%s", r.Method))
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdminPermission(w, r, PermUserDeletionReview) {
+		return
+	}
+
+	status := model.AccountDeletionStatusPending
+	page := 1
+	pageSize := 20
+	if statusStr := r.URL.Query().Get("status"); statusStr != "" {
+		if _, err := fmt.Sscanf(statusStr, "%d", &status); err != nil {
+			status = model.AccountDeletionStatusPending
+		}
+	}
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if _, err := fmt.Sscanf(pageStr, "%d", &page); err != nil || page < 1 {
+			page = 1
+		}
+	}
+	if pageSizeStr := r.URL.Query().Get("pageSize"); pageSizeStr != "" {
+		if _, err := fmt.Sscanf(pageSizeStr, "%d", &pageSize); err != nil || pageSize < 1 {
+			pageSize = 20
+		}
+	}
+
+	requests, total, err := dao.PrivacyImp.GetDeletionRequests(status, page, pageSize)
+	if err != nil {
+		LogError("获取注销申请列表失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取注销申请列表失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	now := time.Now()
+	list := []map[string]interface{}{}
+	for _, request := range requests {
+		item := map[string]interface{}{
+			"id":              request.Id,
+			"userId":          request.UserId,
+			"reason":          request.Reason,
+			"status":          request.Status,
+			"coolingOffUntil": request.CoolingOffUntil,
+			"coolingOffEnded": !now.Before(request.CoolingOffUntil),
+			"reviewerId":      request.ReviewerId,
+			"reviewRemark":    request.ReviewRemark,
+			"reviewedAt":      request.ReviewedAt,
+			"createdAt":       request.CreatedAt,
+		}
+		if user, err := dao.UserImp.GetUserByUserId(request.UserId); err == nil {
+			item["nickName"] = user.NickName
+			item["phone"] = user.Phone
+		}
+		list = append(list, item)
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"list":     list,
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ReviewAccountDeletionHandler 审核注销申请：通过时匿名化个人信息（需冷静期已结束），驳回时需填写原因
+func ReviewAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理审核注销申请请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdminPermission(w, r, PermUserDeletionReview) {
+		return
+	}
+	reviewer := GetAuthUser(r)
+
+	var req ReviewAccountDeletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Id == 0 {
+		LogError("解析请求体失败", err)
+		http.Error(w, "缺少id参数", http.StatusBadRequest)
+		return
+	}
+	req.Remark = strings.TrimSpace(req.Remark)
+
+	request, err := dao.PrivacyImp.GetDeletionRequestById(req.Id)
+	if err != nil {
+		LogError("获取注销申请失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "注销申请不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	if request.Status != model.AccountDeletionStatusPending {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "注销申请不是待审核状态",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if !req.Approve {
+		if req.Remark == "" {
+			http.Error(w, "驳回时必须填写remark", http.StatusBadRequest)
+			return
+		}
+		ok, err := dao.PrivacyImp.RejectDeletionRequest(request.Id, reviewer.UserId, req.Remark)
+		if err != nil || !ok {
+			LogError("驳回注销申请失败", err)
+			response := &AdminResponse{
+				Code:     -1,
+				ErrorMsg: "驳回注销申请失败，申请可能已被处理",
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		LogStep("注销申请已驳回", map[string]interface{}{
+			"reviewerId": reviewer.UserId,
+			"requestId":  request.Id,
+			"userId":     request.UserId,
+		})
+		response := &AdminResponse{
+			Code: 0,
+			Data: map[string]interface{}{
+				"message": "已驳回注销申请",
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if time.Now().Before(request.CoolingOffUntil) {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: fmt.Sprintf("冷静期未结束，%s后才能审核通过", request.CoolingOffUntil.Format("2006-01-02 15:04")),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 冷静期内可能产生了新的订单或提现
+	msg, err := checkUnfinishedBusiness(request.UserId)
+	if err != nil || msg != "" {
+		if err != nil {
+			LogError("查询未完结业务失败", err)
+			msg = "审核注销申请失败"
+		}
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: msg,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	files, err := dao.PrivacyImp.AnonymizeUser(request, reviewer.UserId, req.Remark)
+	if err != nil {
+		LogError("匿名化用户失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "注销失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 删除对象存储中的文件，失败只记录日志（数据库记录已标记删除）
+	deletedFiles := 0
+	if len(files) > 0 {
+		permissionService := NewCOSPermissionService()
+		for _, file := range files {
+			if err := permissionService.DeleteObject(file.FileName); err != nil {
+				LogError("删除COS文件失败", err)
+				continue
+			}
+			deletedFiles++
+		}
+	}
+
+	LogStep("账号注销完成", map[string]interface{}{
+		"reviewerId":   reviewer.UserId,
+		"requestId":    request.Id,
+		"userId":       request.UserId,
+		"files":        len(files),
+		"deletedFiles": deletedFiles,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"message":      "账号已注销，个人信息已匿名化",
+			"deletedFiles": deletedFiles,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
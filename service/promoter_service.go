@@ -22,57 +22,57 @@ type PromoterResponse struct {
 
 // PromoterInfo 推广员信息
 type PromoterInfo struct {
-	UserId       string  `json:"userId"`
-	PromoterCode string  `json:"promoterCode"` // 六位推广码
-	NickName     string  `json:"nickName"`
-	AvatarUrl    string  `json:"avatarUrl"`
-	QrCodeUrl    string  `json:"qrCodeUrl"`
-	TotalIncome  float64 `json:"totalIncome"`
-	TodayIncome  float64 `json:"todayIncome"`
-	MonthIncome  float64 `json:"monthIncome"`
-	TotalOrders  int     `json:"totalOrders"`
-	TodayOrders  int     `json:"todayOrders"`
-	MonthOrders  int     `json:"monthOrders"`
+	UserId       string      `json:"userId"`
+	PromoterCode string      `json:"promoterCode"` // 六位推广码
+	NickName     string      `json:"nickName"`
+	AvatarUrl    string      `json:"avatarUrl"`
+	QrCodeUrl    string      `json:"qrCodeUrl"`
+	TotalIncome  model.Money `json:"totalIncome"`
+	TodayIncome  model.Money `json:"todayIncome"`
+	MonthIncome  model.Money `json:"monthIncome"`
+	TotalOrders  int         `json:"totalOrders"`
+	TodayOrders  int         `json:"todayOrders"`
+	MonthOrders  int         `json:"monthOrders"`
 }
 
 // CommissionInfo 佣金信息
 type CommissionInfo struct {
-	Id          int32      `json:"id"`
-	OrderId     int32      `json:"orderId"`
-	OrderNo     string     `json:"orderNo"`
-	Amount      float64    `json:"amount"`
-	Rate        float64    `json:"rate"`
-	Status      int        `json:"status"`
-	StatusText  string     `json:"statusText"`
-	CashoutTime *time.Time `json:"cashoutTime"`
-	CreatedAt   time.Time  `json:"createdAt"`
+	Id          int32       `json:"id"`
+	OrderId     int32       `json:"orderId"`
+	OrderNo     string      `json:"orderNo"`
+	Amount      model.Money `json:"amount"`
+	Rate        float64     `json:"rate"`
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	CashoutTime *time.Time  `json:"cashoutTime"`
+	CreatedAt   time.Time   `json:"createdAt"`
 }
 
 // CashoutInfo 提现信息
 type CashoutInfo struct {
-	Id          int32      `json:"id"`
-	Amount      float64    `json:"amount"`
-	Method      string     `json:"method"`
-	MethodText  string     `json:"methodText"`
-	Account     string     `json:"account"`
-	Status      int        `json:"status"`
-	StatusText  string     `json:"statusText"`
-	Remark      string     `json:"remark"`
-	ProcessTime *time.Time `json:"processTime"`
-	CreatedAt   time.Time  `json:"createdAt"`
+	Id          int32       `json:"id"`
+	Amount      model.Money `json:"amount"`
+	Method      string      `json:"method"`
+	MethodText  string      `json:"methodText"`
+	Account     string      `json:"account"`
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	Remark      string      `json:"remark"`
+	ProcessTime *time.Time  `json:"processTime"`
+	CreatedAt   time.Time   `json:"createdAt"`
 }
 
 // PromoterStats 推广统计
 type PromoterStats struct {
-	TotalIncome     float64 `json:"totalIncome"`
-	TodayIncome     float64 `json:"todayIncome"`
-	MonthIncome     float64 `json:"monthIncome"`
-	TotalOrders     int     `json:"totalOrders"`
-	TodayOrders     int     `json:"todayOrders"`
-	MonthOrders     int     `json:"monthOrders"`
-	PendingAmount   float64 `json:"pendingAmount"`
-	SettledAmount   float64 `json:"settledAmount"`
-	WithdrawnAmount float64 `json:"withdrawnAmount"`
+	TotalIncome     model.Money `json:"totalIncome"`
+	TodayIncome     model.Money `json:"todayIncome"`
+	MonthIncome     model.Money `json:"monthIncome"`
+	TotalOrders     int         `json:"totalOrders"`
+	TodayOrders     int         `json:"todayOrders"`
+	MonthOrders     int         `json:"monthOrders"`
+	PendingAmount   model.Money `json:"pendingAmount"`
+	SettledAmount   model.Money `json:"settledAmount"`
+	WithdrawnAmount model.Money `json:"withdrawnAmount"`
 }
 
 // GetPromoterInfoHandler 获取推广员信息接口
@@ -88,18 +88,12 @@ func GetPromoterInfoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取用户ID参数
-	userIdStr := r.URL.Query().Get("userId")
+	// 用户ID以登录令牌为准
+	userIdStr := GetAuthUserId(r)
 	LogStep("解析请求参数", map[string]interface{}{
 		"userId": userIdStr,
 	})
 
-	if userIdStr == "" {
-		LogError("缺少必要参数", fmt.Errorf("userId参数为空"))
-		http.Error(w, "缺少userId参数", http.StatusBadRequest)
-		return
-	}
-
 	// 获取用户信息
 	user, err := dao.UserImp.GetUserByUserId(userIdStr)
 	if err != nil {
@@ -219,12 +213,8 @@ func GetCommissionListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取用户ID参数
-	userIdStr := r.URL.Query().Get("userId")
-	if userIdStr == "" {
-		http.Error(w, "缺少userId参数", http.StatusBadRequest)
-		return
-	}
+	// 用户ID以登录令牌为准
+	userIdStr := GetAuthUserId(r)
 
 	// 获取分页参数
 	page := 1
@@ -305,12 +295,8 @@ func GetCashoutListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取用户ID参数
-	userIdStr := r.URL.Query().Get("userId")
-	if userIdStr == "" {
-		http.Error(w, "缺少userId参数", http.StatusBadRequest)
-		return
-	}
+	// 用户ID以登录令牌为准
+	userIdStr := GetAuthUserId(r)
 
 	// 获取分页参数
 	page := 1
@@ -393,6 +379,11 @@ func getPromoterStats(userId string) (*PromoterStats, error) {
 	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 
 	for _, commission := range commissions {
+		// 订单退款后作废的佣金不计入收入
+		if commission.Status == model.CommissionStatusCancelled {
+			continue
+		}
+
 		// 总收入和订单数
 		stats.TotalIncome += commission.Amount
 		stats.TotalOrders++
@@ -424,14 +415,14 @@ func getPromoterStats(userId string) (*PromoterStats, error) {
 }
 
 // 获取可提现金额
-func getAvailableCashoutAmount(userId string) (float64, error) {
+func getAvailableCashoutAmount(userId string) (model.Money, error) {
 	// 获取已结算但未提现的佣金总额
 	commissions, _, err := dao.CommissionImp.GetCommissionsByUserId(userId, 1, 1000)
 	if err != nil {
 		return 0, err
 	}
 
-	var availableAmount float64
+	var availableAmount model.Money
 	for _, commission := range commissions {
 		if commission.Status == 1 { // 已结算
 			availableAmount += commission.Amount
@@ -450,6 +441,8 @@ func getCommissionStatusText(status int) string {
 		return "已结算"
 	case 2:
 		return "已提现"
+	case 3:
+		return "已取消"
 	default:
 		return "未知"
 	}
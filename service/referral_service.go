@@ -9,6 +9,15 @@ import (
 	"wxcloudrun-golang/db/model"
 )
 
+// CommissionRate 推荐佣金比例：订单金额的5%
+// 佣金在下单时按订单金额（分）× CommissionRate计算并随订单保存，结果四舍五入到分（0.5分进位，见model.Money.MulRate）
+const CommissionRate = 0.05
+
+// calculateCommission 计算订单的推荐佣金
+func calculateCommission(totalAmount model.Money) model.Money {
+	return totalAmount.MulRate(CommissionRate)
+}
+
 // ReferralResponse 推荐响应
 type ReferralResponse struct {
 	Code     int         `json:"code"`
@@ -21,22 +30,22 @@ type ReferralReportResponse struct {
 	Referrer        *model.UserModel         `json:"referrer"`
 	Referrals       []*model.ReferralModel   `json:"referrals"`
 	Commissions     []*model.CommissionModel `json:"commissions"`
-	TotalCommission float64                  `json:"totalCommission"`
+	TotalCommission model.Money              `json:"totalCommission"`
 }
 
 // ReferralConfigResponse 推荐配置响应
 type ReferralConfigResponse struct {
-	CommissionRate float64 `json:"commissionRate"` // 佣金比例
-	MinCashout     float64 `json:"minCashout"`     // 最低提现金额
-	Rules          string  `json:"rules"`          // 规则说明
+	CommissionRate float64     `json:"commissionRate"` // 佣金比例
+	MinCashout     model.Money `json:"minCashout"`     // 最低提现金额
+	Rules          string      `json:"rules"`          // 规则说明
 }
 
 // ApplyCashoutRequest 申请提现请求
 type ApplyCashoutRequest struct {
-	UserId  string  `json:"userId"`
-	Amount  float64 `json:"amount"`
-	Method  string  `json:"method"` // wechat, alipay, bank
-	Account string  `json:"account"`
+	UserId  string      `json:"userId"`
+	Amount  model.Money `json:"amount"`
+	Method  string      `json:"method"` // wechat, alipay, bank
+	Account string      `json:"account"`
 }
 
 // ReferralQrCodeHandler 获取用户专属推广二维码接口
@@ -46,15 +55,8 @@ func ReferralQrCodeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取用户ID参数
-	userIdStr := r.URL.Query().Get("userId")
-	if userIdStr == "" {
-		http.Error(w, "缺少userId参数", http.StatusBadRequest)
-		return
-	}
-
-	// 直接使用字符串类型的userId
-	userId := userIdStr
+	// 用户ID以登录令牌为准
+	userId := GetAuthUserId(r)
 
 	// 获取或创建推荐关系
 	referral, err := dao.ReferralImp.GetReferralByUserId(userId)
@@ -95,15 +97,8 @@ func ReferralReportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取用户ID参数
-	userIdStr := r.URL.Query().Get("userId")
-	if userIdStr == "" {
-		http.Error(w, "缺少userId参数", http.StatusBadRequest)
-		return
-	}
-
-	// 直接使用字符串类型的userId
-	userId := userIdStr
+	// 用户ID以登录令牌为准
+	userId := GetAuthUserId(r)
 
 	// 获取推荐关系
 	referral, err := dao.ReferralImp.GetReferralByUserId(userId)
@@ -148,7 +143,7 @@ func ReferralReportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 计算总佣金
-	var totalCommission float64
+	var totalCommission model.Money
 	for _, commission := range commissions {
 		if commission.Status == 1 { // 已结算
 			totalCommission += commission.Amount
@@ -176,14 +171,14 @@ func ReferralConfigHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	config := &ReferralConfigResponse{
-		CommissionRate: 0.05, // 5%佣金比例
+		CommissionRate: CommissionRate,
 		MinCashout:     10.0, // 最低提现10元
-		Rules: `推荐返佣规则：
-1. 成功推荐好友注册并下单，可获得订单金额5%的佣金
+		Rules: fmt.Sprintf(`推荐返佣规则：
+1. 成功推荐好友注册并下单，可获得订单金额%g%%的佣金
 2. 佣金在订单完成后自动结算
 3. 累计佣金达到10元后可申请提现
 4. 提现支持微信、支付宝、银行卡等方式
-5. 提现申请将在1-3个工作日内处理完成`,
+5. 提现申请将在1-3个工作日内处理完成`, CommissionRate*100),
 	}
 
 	response := &ReferralResponse{
@@ -206,15 +201,16 @@ func ApplyCashoutHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
 		return
 	}
+	req.UserId = GetAuthUserId(r) // 提现用户以登录令牌为准，忽略请求体中的userId
 
 	// 验证参数
-	if req.UserId == "" || req.Amount <= 0 || req.Method == "" || req.Account == "" {
+	if req.Amount <= 0 || req.Method == "" || req.Account == "" {
 		http.Error(w, "缺少必要参数", http.StatusBadRequest)
 		return
 	}
 
 	// 检查最低提现金额
-	if req.Amount < 10.0 {
+	if req.Amount < model.MoneyFromYuan(10) {
 		response := &ReferralResponse{
 			Code:     -1,
 			ErrorMsg: "提现金额不能少于10元",
@@ -237,7 +233,7 @@ func ApplyCashoutHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 计算可提现金额
-	var availableAmount float64
+	var availableAmount model.Money
 	for _, commission := range commissions {
 		if commission.Status == 1 { // 已结算且未提现
 			availableAmount += commission.Amount
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+
+	"wxcloudrun-golang/db/model"
+)
+
+func TestCalculateCommission(t *testing.T) {
+	tests := []struct {
+		totalAmount model.Money
+		want        model.Money
+	}{
+		{0, 0},
+		{10000, 500},
+		{1999, 100}, // 99.95分进位
+		{1990, 100}, // 99.5分进位
+		{1989, 99},  // 99.45分舍去
+		{10, 1},     // 0.5分进位
+		{9, 0},      // 0.45分舍去
+	}
+	for _, tt := range tests {
+		if got := calculateCommission(tt.totalAmount); got != tt.want {
+			t.Errorf("calculateCommission(%s) = %s, want %s", tt.totalAmount, got, tt.want)
+		}
+	}
+}
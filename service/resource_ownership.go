@@ -0,0 +1,110 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// 归属校验的资源类型，用于越权访问日志
+const (
+	ResourceOrder        = "order"
+	ResourcePatient      = "patient"
+	ResourceAddress      = "address"
+	ResourceFile         = "file"
+	ResourceConsultation = "consultation"
+)
+
+// ownsResource 判断当前登录用户是否为资源所有者，所有者为空的资源不属于任何用户
+func ownsResource(r *http.Request, ownerId string) bool {
+	userId := GetAuthUserId(r)
+	return userId != "" && ownerId == userId
+}
+
+// hasAdminSessionPermission 当前请求是否为拥有指定权限的管理员会话
+// 用户接口中的管理员特权只对管理员账号密码登录的会话生效，小程序登录的管理员按普通用户处理
+func hasAdminSessionPermission(r *http.Request, permission string) bool {
+	return IsAdminSession(r) && HasAdminPermission(GetAuthUser(r), permission)
+}
+
+// requireResourceOwner 校验当前登录用户是否为资源所有者，不是时记录越权访问并返回403
+// adminPermission 不为空时，使用管理员令牌且拥有该权限的管理员也可以访问（如客服处理他人的咨询）
+func requireResourceOwner(w http.ResponseWriter, r *http.Request, resource string, resourceId interface{}, ownerId string, adminPermission string) bool {
+	if ownsResource(r, ownerId) {
+		return true
+	}
+	if adminPermission != "" && hasAdminSessionPermission(r, adminPermission) {
+		LogInfo("管理员访问用户资源", map[string]interface{}{
+			"userId":     GetAuthUserId(r),
+			"resource":   resource,
+			"resourceId": resourceId,
+			"ownerId":    ownerId,
+			"permission": adminPermission,
+		})
+		return true
+	}
+
+	LogError("越权访问资源", fmt.Errorf("userId=%s, resource=%s, resourceId=%v, ownerId=%s, method=%s, path=%s",
+		GetAuthUserId(r), resource, resourceId, ownerId, r.Method, r.URL.Path))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":     -1,
+		"errorMsg": "无权访问该资源",
+	})
+	return false
+}
+
+// writeResourceNotFound 资源不存在（或已删除）时的响应
+func writeResourceNotFound(w http.ResponseWriter, errorMsg string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":     -1,
+		"errorMsg": errorMsg,
+	})
+}
+
+// authorizePatient 获取当前用户的就诊人，不存在或不属于当前用户时写入响应并返回false
+func authorizePatient(w http.ResponseWriter, r *http.Request, patientId int32) (*model.PatientModel, bool) {
+	patient, err := dao.UserExtendImp.GetPatientById(patientId)
+	if err != nil {
+		LogError("获取就诊人失败", err)
+		writeResourceNotFound(w, "就诊人不存在")
+		return nil, false
+	}
+	if !requireResourceOwner(w, r, ResourcePatient, patientId, patient.UserId, "") {
+		return nil, false
+	}
+	return patient, true
+}
+
+// authorizeAddress 获取当前用户的地址，不存在或不属于当前用户时写入响应并返回false
+func authorizeAddress(w http.ResponseWriter, r *http.Request, addressId int32) (*model.UserAddressModel, bool) {
+	address, err := dao.UserExtendImp.GetAddressById(addressId)
+	if err != nil {
+		LogError("获取地址失败", err)
+		writeResourceNotFound(w, "地址不存在")
+		return nil, false
+	}
+	if !requireResourceOwner(w, r, ResourceAddress, addressId, address.UserId, "") {
+		return nil, false
+	}
+	return address, true
+}
+
+// authorizeConsultation 获取当前用户的咨询会话，拥有咨询处理权限的客服可以访问所有会话
+func authorizeConsultation(w http.ResponseWriter, r *http.Request, consultationId uint) (*model.Consultation, bool) {
+	consultation, err := (&dao.ConsultationDAO{}).GetConsultationByID(consultationId)
+	if err != nil {
+		LogError("获取咨询会话失败", err)
+		writeResourceNotFound(w, "咨询会话不存在")
+		return nil, false
+	}
+	if !requireResourceOwner(w, r, ResourceConsultation, consultationId, consultation.UserID, PermConsultationReply) {
+		return nil, false
+	}
+	return consultation, true
+}
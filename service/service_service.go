@@ -36,18 +36,19 @@ type ServiceListResponse struct {
 
 // FormConfig 表单配置
 type FormConfig struct {
-	Fields []FormField `json:"fields"`
+	Version int         `json:"version"` // 配置版本，提交订单时通过formVersion带回
+	Fields  []FormField `json:"fields"`
 }
 
 // FormField 表单字段
 type FormField struct {
 	Name        string       `json:"name"`
 	Label       string       `json:"label"`
-	Type        string       `json:"type"` // text, textarea, select, radio, checkbox, date, file
+	Type        string       `json:"type"` // text, textarea, select, radio, checkbox, date, time, number, phone, email, file
 	Required    bool         `json:"required"`
 	Placeholder string       `json:"placeholder"`
 	Options     []FormOption `json:"options,omitempty"`
-	Validation  string       `json:"validation,omitempty"`
+	Validation  string       `json:"validation,omitempty"` // 正则表达式，提交订单时校验
 }
 
 // FormOption 表单选项
@@ -336,6 +337,8 @@ func ServiceFormConfigHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	formConfig.Version = service.FormVersion
+
 	response := &ServiceResponse{
 		Code: 0,
 		Data: formConfig,
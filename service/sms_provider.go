@@ -0,0 +1,231 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wxcloudrun-golang/config"
+)
+
+// SMSProvider 短信服务商接口，新增服务商时实现该接口并在newSMSProvider中注册
+type SMSProvider interface {
+	// Name 服务商名称，记录在验证码表中便于排查
+	Name() string
+	// SendCode 发送验证码短信，ttlMinutes为验证码有效期（分钟）
+	SendCode(phone, code string, ttlMinutes int) error
+}
+
+var (
+	smsProviderMutex sync.Mutex
+	smsProvider      SMSProvider
+)
+
+// GetSMSProvider 获取短信服务商，未设置时按SMS_PROVIDER配置创建，配置无效时返回错误
+func GetSMSProvider() (SMSProvider, error) {
+	smsProviderMutex.Lock()
+	defer smsProviderMutex.Unlock()
+	if smsProvider == nil {
+		provider, err := newSMSProvider(config.GetSMSConfig())
+		if err != nil {
+			return nil, err
+		}
+		smsProvider = provider
+	}
+	return smsProvider, nil
+}
+
+// SetSMSProvider 替换短信服务商（测试时注入模拟实现），传nil时恢复默认实现
+func SetSMSProvider(provider SMSProvider) {
+	smsProviderMutex.Lock()
+	defer smsProviderMutex.Unlock()
+	smsProvider = provider
+}
+
+// newSMSProvider 根据配置创建短信服务商
+// log会把验证码明文打印到日志，只允许在开发模式下使用；开发模式未配置时默认使用log
+func newSMSProvider(smsConfig *config.SMSConfig) (SMSProvider, error) {
+	switch smsConfig.Provider {
+	case "tencent":
+		return &tencentSMSProvider{
+			secretId:   smsConfig.SecretId,
+			secretKey:  smsConfig.SecretKey,
+			region:     smsConfig.Region,
+			sdkAppId:   smsConfig.SdkAppId,
+			signName:   smsConfig.SignName,
+			templateId: smsConfig.TemplateId,
+			httpClient: &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	case "log", "":
+		if !config.IsDevMode() {
+			if smsConfig.Provider == "" {
+				return nil, fmt.Errorf("未配置SMS_PROVIDER")
+			}
+			return nil, fmt.Errorf("SMS_PROVIDER=log仅允许在开发模式（APP_DEV_MODE=true）下使用")
+		}
+		return &logSMSProvider{}, nil
+	default:
+		return nil, fmt.Errorf("不支持的短信服务商: SMS_PROVIDER=%s", smsConfig.Provider)
+	}
+}
+
+// logSMSProvider 只打印日志不发送短信，仅用于开发和测试环境
+type logSMSProvider struct{}
+
+// Name 服务商名称
+func (p *logSMSProvider) Name() string {
+	return "log"
+}
+
+// SendCode 把验证码打印到日志
+func (p *logSMSProvider) SendCode(phone, code string, ttlMinutes int) error {
+	LogInfo("短信验证码（未实际发送）", map[string]interface{}{
+		"phone":      phone,
+		"code":       code,
+		"ttlMinutes": ttlMinutes,
+	})
+	return nil
+}
+
+const (
+	tencentSMSHost    = "sms.tencentcloudapi.com"
+	tencentSMSService = "sms"
+	tencentSMSVersion = "2021-01-11"
+)
+
+// tencentSMSProvider 腾讯云短信，使用TC3-HMAC-SHA256签名调用SendSms接口
+type tencentSMSProvider struct {
+	secretId   string
+	secretKey  string
+	region     string
+	sdkAppId   string
+	signName   string
+	templateId string
+	httpClient *http.Client
+}
+
+// tencentSMSResponse SendSms接口响应
+type tencentSMSResponse struct {
+	Response struct {
+		SendStatusSet []struct {
+			SerialNo    string `json:"SerialNo"`
+			PhoneNumber string `json:"PhoneNumber"`
+			Code        string `json:"Code"`
+			Message     string `json:"Message"`
+		} `json:"SendStatusSet"`
+		Error *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+		RequestId string `json:"RequestId"`
+	} `json:"Response"`
+}
+
+// Name 服务商名称
+func (p *tencentSMSProvider) Name() string {
+	return "tencent"
+}
+
+// SendCode 调用腾讯云SendSms接口发送验证码
+func (p *tencentSMSProvider) SendCode(phone, code string, ttlMinutes int) error {
+	if p.secretId == "" || p.secretKey == "" || p.sdkAppId == "" || p.signName == "" || p.templateId == "" {
+		return fmt.Errorf("腾讯云短信配置不完整")
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"PhoneNumberSet":   []string{"+86" + phone},
+		"SmsSdkAppId":      p.sdkAppId,
+		"SignName":         p.signName,
+		"TemplateId":       p.templateId,
+		"TemplateParamSet": []string{code, strconv.Itoa(ttlMinutes)},
+	})
+
+	req, err := http.NewRequest(http.MethodPost, "https://"+tencentSMSHost, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建短信请求失败: %v", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Host", tencentSMSHost)
+	req.Header.Set("X-TC-Action", "SendSms")
+	req.Header.Set("X-TC-Version", tencentSMSVersion)
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-TC-Region", p.region)
+	req.Header.Set("Authorization", p.authorization(payload, timestamp))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("短信请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取短信响应失败: %v", err)
+	}
+
+	var smsResp tencentSMSResponse
+	if err := json.Unmarshal(body, &smsResp); err != nil {
+		return fmt.Errorf("解析短信响应失败: %v", err)
+	}
+	if smsResp.Response.Error != nil {
+		return fmt.Errorf("短信发送失败: %s %s", smsResp.Response.Error.Code, smsResp.Response.Error.Message)
+	}
+	for _, status := range smsResp.Response.SendStatusSet {
+		if status.Code != "Ok" {
+			return fmt.Errorf("短信发送失败: %s %s", status.Code, status.Message)
+		}
+	}
+	return nil
+}
+
+// authorization 计算TC3-HMAC-SHA256签名
+func (p *tencentSMSProvider) authorization(payload []byte, timestamp int64) string {
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+	signedHeaders := "content-type;host"
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		"content-type:application/json; charset=utf-8\nhost:" + tencentSMSHost + "\n",
+		signedHeaders,
+		sha256Hex(payload),
+	}, "\n")
+
+	credentialScope := date + "/" + tencentSMSService + "/tc3_request"
+	stringToSign := strings.Join([]string{
+		"TC3-HMAC-SHA256",
+		strconv.FormatInt(timestamp, 10),
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	secretDate := hmacSHA256([]byte("TC3"+p.secretKey), date)
+	secretService := hmacSHA256(secretDate, tencentSMSService)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+
+	return fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.secretId, credentialScope, signedHeaders, signature)
+}
+
+// sha256Hex 计算SHA-256十六进制摘要
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 计算HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
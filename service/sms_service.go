@@ -0,0 +1,262 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// 短信验证码使用场景
+const (
+	SmsSceneBindPhone  = "bind_phone"  // 绑定手机号（用户拒绝微信手机号授权时的备选方式）
+	SmsSceneAdminLogin = "admin_login" // 管理员手机号登录（预留）
+)
+
+// smsSendableScenes 允许通过发送接口直接申请验证码的场景及是否需要登录
+var smsSendableScenes = map[string]bool{
+	SmsSceneBindPhone: true,
+}
+
+// mainlandPhonePattern 中国大陆手机号
+var mainlandPhonePattern = regexp.MustCompile(`^1[3-9]\d{9}$`)
+
+// SendSMSCodeRequest 发送短信验证码请求
+type SendSMSCodeRequest struct {
+	Phone string `json:"phone"`
+	Scene string `json:"scene"`
+}
+
+// SMSCodeError 短信验证码业务错误，Message可直接展示给用户
+type SMSCodeError struct {
+	Message    string
+	RetryAfter int // 需要等待的秒数，0表示无需等待
+}
+
+func (e *SMSCodeError) Error() string {
+	return e.Message
+}
+
+// IsValidMainlandPhone 校验中国大陆手机号格式
+func IsValidMainlandPhone(phone string) bool {
+	return mainlandPhonePattern.MatchString(phone)
+}
+
+// SendSMSCode 生成并发送验证码，按手机号和IP限流，同一场景下旧验证码作废
+func SendSMSCode(phone, scene, clientIp string) error {
+	smsConfig := config.GetSMSConfig()
+	now := time.Now()
+
+	if !IsValidMainlandPhone(phone) {
+		return &SMSCodeError{Message: "手机号格式不正确"}
+	}
+
+	// 同一手机号发送间隔
+	latest, err := dao.SmsImp.GetLatestCode(phone, scene)
+	if err != nil {
+		return fmt.Errorf("查询验证码失败: %v", err)
+	}
+	if latest != nil {
+		if wait := latest.CreatedAt.Add(smsConfig.ResendInterval).Sub(now); wait > 0 {
+			return &SMSCodeError{Message: "验证码发送过于频繁，请稍后再试", RetryAfter: int(wait.Seconds()) + 1}
+		}
+	}
+
+	// 同一手机号每日上限
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if count, err := dao.SmsImp.CountCodesByPhoneSince(phone, dayStart); err != nil {
+		return fmt.Errorf("统计验证码发送次数失败: %v", err)
+	} else if smsConfig.MaxPerPhonePerDay > 0 && count >= int64(smsConfig.MaxPerPhonePerDay) {
+		return &SMSCodeError{Message: "该手机号今日获取验证码次数已达上限"}
+	}
+
+	// 同一IP每小时上限
+	if clientIp != "" {
+		if count, err := dao.SmsImp.CountCodesByIpSince(clientIp, now.Add(-time.Hour)); err != nil {
+			return fmt.Errorf("统计验证码发送次数失败: %v", err)
+		} else if smsConfig.MaxPerIpPerHour > 0 && count >= int64(smsConfig.MaxPerIpPerHour) {
+			return &SMSCodeError{Message: "获取验证码过于频繁，请稍后再试"}
+		}
+	}
+
+	provider, err := GetSMSProvider()
+	if err != nil {
+		LogError("短信服务商配置无效", err)
+		return fmt.Errorf("短信服务暂不可用")
+	}
+
+	code, err := generateSMSCode(smsConfig.CodeLength)
+	if err != nil {
+		return err
+	}
+
+	if err := dao.SmsImp.InvalidateCodes(phone, scene); err != nil {
+		return fmt.Errorf("作废旧验证码失败: %v", err)
+	}
+
+	record := &model.SmsCodeModel{
+		Phone:     phone,
+		Scene:     scene,
+		CodeHash:  hashSMSCode(phone, scene, code),
+		ClientIp:  clientIp,
+		Provider:  provider.Name(),
+		Status:    0,
+		ExpiresAt: now.Add(smsConfig.CodeTTL),
+	}
+	if err := dao.SmsImp.CreateCode(record); err != nil {
+		return fmt.Errorf("保存验证码失败: %v", err)
+	}
+
+	if err := provider.SendCode(phone, code, int(smsConfig.CodeTTL.Minutes())); err != nil {
+		// 发送失败的验证码作废，避免占用发送间隔
+		dao.SmsImp.InvalidateCodes(phone, scene)
+		return fmt.Errorf("发送短信失败: %v", err)
+	}
+
+	LogStep("短信验证码发送成功", map[string]interface{}{
+		"phone":    phone,
+		"scene":    scene,
+		"provider": provider.Name(),
+	})
+	return nil
+}
+
+// VerifySMSCode 校验验证码，校验成功后验证码立即失效；错误次数超过上限时验证码作废
+func VerifySMSCode(phone, scene, code string) error {
+	smsConfig := config.GetSMSConfig()
+
+	record, err := dao.SmsImp.GetLatestCode(phone, scene)
+	if err != nil {
+		return fmt.Errorf("查询验证码失败: %v", err)
+	}
+	if record == nil || time.Now().After(record.ExpiresAt) {
+		return &SMSCodeError{Message: "验证码已过期，请重新获取"}
+	}
+
+	// 比对前先占用一次校验次数，条件更新保证并发猜测时总次数不超过上限
+	allowed, err := dao.SmsImp.IncrementAttempts(record.Id, smsConfig.MaxVerifyAttempts)
+	if err != nil {
+		return fmt.Errorf("更新验证码校验次数失败: %v", err)
+	}
+	if !allowed {
+		dao.SmsImp.InvalidateCodes(phone, scene)
+		return &SMSCodeError{Message: "验证码错误次数过多，请重新获取"}
+	}
+
+	expected := hashSMSCode(phone, scene, strings.TrimSpace(code))
+	if !hmac.Equal([]byte(expected), []byte(record.CodeHash)) {
+		if smsConfig.MaxVerifyAttempts > 0 && record.Attempts+1 >= smsConfig.MaxVerifyAttempts {
+			dao.SmsImp.InvalidateCodes(phone, scene)
+			return &SMSCodeError{Message: "验证码错误次数过多，请重新获取"}
+		}
+		return &SMSCodeError{Message: "验证码错误"}
+	}
+
+	used, err := dao.SmsImp.MarkCodeUsed(record.Id)
+	if err != nil {
+		return fmt.Errorf("更新验证码状态失败: %v", err)
+	}
+	if !used {
+		return &SMSCodeError{Message: "验证码已使用，请重新获取"}
+	}
+	return nil
+}
+
+// generateSMSCode 生成指定位数的数字验证码
+func generateSMSCode(length int) (string, error) {
+	if length <= 0 {
+		length = 6
+	}
+	digits := make([]byte, length)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", fmt.Errorf("生成验证码失败: %v", err)
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return string(digits), nil
+}
+
+// hashSMSCode 计算验证码哈希，绑定手机号和场景，防止跨场景复用
+func hashSMSCode(phone, scene, code string) string {
+	mac := hmac.New(sha256.New, []byte(config.GetAuthConfig().TokenSecret))
+	mac.Write([]byte(phone + "|" + scene + "|" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SendSMSCodeHandler 发送短信验证码接口
+func SendSMSCodeHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理发送短信验证码请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SendSMSCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		LogError("请求参数解析失败", err)
+		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
+		return
+	}
+	req.Phone = strings.TrimSpace(req.Phone)
+	if req.Scene == "" {
+		req.Scene = SmsSceneBindPhone
+	}
+
+	requireLogin, ok := smsSendableScenes[req.Scene]
+	if !ok {
+		LogError("不支持的验证码场景", fmt.Errorf("scene=%s", req.Scene))
+		http.Error(w, "不支持的验证码场景", http.StatusBadRequest)
+		return
+	}
+	if requireLogin && authenticateOptional(r) == nil {
+		writeAuthError(w, "请先登录")
+		return
+	}
+
+	err := SendSMSCode(req.Phone, req.Scene, getClientIp(r))
+	if err != nil {
+		LogError("发送短信验证码失败", err)
+		response := &UserResponse{
+			Code:     -1,
+			ErrorMsg: "发送验证码失败，请稍后重试",
+		}
+		if smsErr, ok := err.(*SMSCodeError); ok {
+			response.ErrorMsg = smsErr.Message
+			if smsErr.RetryAfter > 0 {
+				response.Data = map[string]interface{}{"retryAfter": smsErr.RetryAfter}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	smsConfig := config.GetSMSConfig()
+	response := &UserResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"expiresIn":  int(smsConfig.CodeTTL.Seconds()),
+			"retryAfter": int(smsConfig.ResendInterval.Seconds()),
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
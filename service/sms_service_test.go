@@ -0,0 +1,253 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// fakeSMSProvider 模拟短信服务商，记录发送的验证码
+type fakeSMSProvider struct {
+	err   error
+	sent  []string
+	codes []string
+}
+
+func (p *fakeSMSProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeSMSProvider) SendCode(phone, code string, ttlMinutes int) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.sent = append(p.sent, phone)
+	p.codes = append(p.codes, code)
+	return nil
+}
+
+// useFakeSMS 替换短信数据和服务商，返回恢复函数
+func useFakeSMS(provider SMSProvider) (*fakeSmsDao, func()) {
+	original := dao.SmsImp
+	smsCodes := &fakeSmsDao{}
+	dao.SmsImp = smsCodes
+	SetSMSProvider(provider)
+	return smsCodes, func() {
+		dao.SmsImp = original
+		SetSMSProvider(nil)
+	}
+}
+
+// seedSMSCode 写入一条未使用的验证码
+func seedSMSCode(smsCodes *fakeSmsDao, phone, code string, expiresAt time.Time) *model.SmsCodeModel {
+	record := &model.SmsCodeModel{
+		Phone:     phone,
+		Scene:     SmsSceneBindPhone,
+		CodeHash:  hashSMSCode(phone, SmsSceneBindPhone, code),
+		ExpiresAt: expiresAt,
+	}
+	smsCodes.CreateCode(record)
+	return record
+}
+
+// smsErrorMessage 返回短信业务错误的提示，非业务错误返回空
+func smsErrorMessage(err error) string {
+	if smsErr, ok := err.(*SMSCodeError); ok {
+		return smsErr.Message
+	}
+	return ""
+}
+
+func TestSendSMSCode(t *testing.T) {
+	const phone = "13700137000"
+
+	t.Run("发送成功只保存哈希", func(t *testing.T) {
+		provider := &fakeSMSProvider{}
+		smsCodes, restore := useFakeSMS(provider)
+		defer restore()
+
+		if err := SendSMSCode(phone, SmsSceneBindPhone, "10.0.0.1"); err != nil {
+			t.Fatalf("SendSMSCode: %v", err)
+		}
+		if len(provider.codes) != 1 || len(provider.codes[0]) != config.GetSMSConfig().CodeLength {
+			t.Fatalf("发送的验证码 = %v", provider.codes)
+		}
+		record, _ := smsCodes.GetLatestCode(phone, SmsSceneBindPhone)
+		if record == nil || record.CodeHash != hashSMSCode(phone, SmsSceneBindPhone, provider.codes[0]) || record.Provider != "fake" {
+			t.Errorf("保存的验证码 = %+v", record)
+		}
+		if record != nil && record.CodeHash == provider.codes[0] {
+			t.Errorf("不应保存验证码明文")
+		}
+	})
+
+	tests := []struct {
+		name     string
+		phone    string
+		prepare  func(smsCodes *fakeSmsDao)
+		provider *fakeSMSProvider
+		wantMsg  string
+		wantErr  bool
+	}{
+		{
+			name:     "手机号格式错误",
+			phone:    "12345",
+			provider: &fakeSMSProvider{},
+			wantMsg:  "手机号格式不正确",
+		},
+		{
+			name:  "发送间隔内重复发送",
+			phone: phone,
+			prepare: func(smsCodes *fakeSmsDao) {
+				seedSMSCode(smsCodes, phone, "111111", time.Now().Add(5*time.Minute))
+			},
+			provider: &fakeSMSProvider{},
+			wantMsg:  "验证码发送过于频繁，请稍后再试",
+		},
+		{
+			name:  "手机号每日上限",
+			phone: phone,
+			prepare: func(smsCodes *fakeSmsDao) {
+				for i := 0; i < config.GetSMSConfig().MaxPerPhonePerDay; i++ {
+					seedSMSCode(smsCodes, phone, "111111", time.Now().Add(5*time.Minute))
+				}
+				// 发送间隔已过，只触发每日上限
+				for _, code := range smsCodes.codes {
+					code.CreatedAt = time.Now().Add(-config.GetSMSConfig().ResendInterval - time.Second)
+				}
+			},
+			provider: &fakeSMSProvider{},
+			wantMsg:  "该手机号今日获取验证码次数已达上限",
+		},
+		{
+			name:  "IP每小时上限",
+			phone: phone,
+			prepare: func(smsCodes *fakeSmsDao) {
+				for i := 0; i < config.GetSMSConfig().MaxPerIpPerHour; i++ {
+					smsCodes.CreateCode(&model.SmsCodeModel{
+						Phone:    fmt.Sprintf("1390013%04d", i),
+						Scene:    SmsSceneBindPhone,
+						ClientIp: "10.0.0.1",
+						Status:   2,
+					})
+				}
+			},
+			provider: &fakeSMSProvider{},
+			wantMsg:  "获取验证码过于频繁，请稍后再试",
+		},
+		{
+			name:     "服务商发送失败",
+			phone:    phone,
+			provider: &fakeSMSProvider{err: fmt.Errorf("余额不足")},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		smsCodes, restore := useFakeSMS(tt.provider)
+		if tt.prepare != nil {
+			tt.prepare(smsCodes)
+		}
+		err := SendSMSCode(tt.phone, SmsSceneBindPhone, "10.0.0.1")
+		restore()
+
+		if err == nil {
+			t.Errorf("%s: 期望发送失败", tt.name)
+			continue
+		}
+		if got := smsErrorMessage(err); got != tt.wantMsg {
+			t.Errorf("%s: 错误提示 = %q, want %q", tt.name, got, tt.wantMsg)
+		}
+		if tt.wantErr {
+			// 发送失败的验证码作废，不能用于校验
+			if record, _ := smsCodes.GetLatestCode(tt.phone, SmsSceneBindPhone); record != nil {
+				t.Errorf("%s: 发送失败的验证码未作废", tt.name)
+			}
+		} else if len(tt.provider.sent) != 0 {
+			t.Errorf("%s: 不应调用服务商发送短信", tt.name)
+		}
+	}
+}
+
+func TestVerifySMSCode(t *testing.T) {
+	const (
+		phone = "13700137000"
+		code  = "123456"
+	)
+	maxAttempts := config.GetSMSConfig().MaxVerifyAttempts
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		attempts  int
+		input     string
+		wantMsg   string
+	}{
+		{"验证码正确", time.Now().Add(5 * time.Minute), 0, code, ""},
+		{"忽略首尾空格", time.Now().Add(5 * time.Minute), 0, " " + code + " ", ""},
+		{"验证码错误", time.Now().Add(5 * time.Minute), 0, "654321", "验证码错误"},
+		{"最后一次机会输错后作废", time.Now().Add(5 * time.Minute), maxAttempts - 1, "654321", "验证码错误次数过多，请重新获取"},
+		{"次数用完后正确验证码也不能使用", time.Now().Add(5 * time.Minute), maxAttempts, code, "验证码错误次数过多，请重新获取"},
+		{"验证码已过期", time.Now().Add(-time.Second), 0, code, "验证码已过期，请重新获取"},
+	}
+
+	for _, tt := range tests {
+		smsCodes, restore := useFakeSMS(&fakeSMSProvider{})
+		record := seedSMSCode(smsCodes, phone, code, tt.expiresAt)
+		smsCodes.find(record.Id).Attempts = tt.attempts
+
+		err := VerifySMSCode(phone, SmsSceneBindPhone, tt.input)
+		restore()
+
+		if tt.wantMsg == "" {
+			if err != nil {
+				t.Errorf("%s: VerifySMSCode = %v", tt.name, err)
+			}
+			if smsCodes.find(record.Id).Status != 1 {
+				t.Errorf("%s: 校验成功后验证码应标记为已使用", tt.name)
+			}
+			continue
+		}
+		if got := smsErrorMessage(err); got != tt.wantMsg {
+			t.Errorf("%s: 错误提示 = %q, want %q", tt.name, got, tt.wantMsg)
+		}
+		if tt.wantMsg == "验证码错误次数过多，请重新获取" && smsCodes.find(record.Id).Status != 2 {
+			t.Errorf("%s: 次数用完后验证码应作废", tt.name)
+		}
+	}
+}
+
+func TestVerifySMSCodeConcurrentAttempts(t *testing.T) {
+	const phone = "13700137000"
+	maxAttempts := config.GetSMSConfig().MaxVerifyAttempts
+
+	smsCodes, restore := useFakeSMS(&fakeSMSProvider{})
+	defer restore()
+	record := seedSMSCode(smsCodes, phone, "123456", time.Now().Add(5*time.Minute))
+
+	// 并发猜测时每次校验都要先占用次数，总校验次数不能超过上限
+	var wg sync.WaitGroup
+	for i := 0; i < maxAttempts*4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			VerifySMSCode(phone, SmsSceneBindPhone, fmt.Sprintf("%06d", 200000+i))
+		}(i)
+	}
+	wg.Wait()
+
+	smsCodes.mu.Lock()
+	attempts := smsCodes.find(record.Id).Attempts
+	smsCodes.mu.Unlock()
+	if attempts > maxAttempts {
+		t.Errorf("校验次数 = %d, 超过上限 %d", attempts, maxAttempts)
+	}
+	if err := VerifySMSCode(phone, SmsSceneBindPhone, "123456"); err == nil {
+		t.Errorf("次数用完后正确验证码不应校验成功")
+	}
+}
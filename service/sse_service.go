@@ -7,21 +7,38 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"wxcloudrun-golang/db/model"
 )
 
 // SSEManager 管理SSE连接
 type SSEManager struct {
-	clients    map[chan string]bool
+	clients    map[chan string]*SSEClient
 	broadcast  chan []byte
-	register   chan chan string
+	targeted   chan *sseTargetedMessage
+	register   chan *SSEClient
 	unregister chan chan string
 	mutex      sync.RWMutex
 }
 
+// SSEClient SSE客户端连接信息，携带有效令牌连接时记录用户身份，用于定向推送
+type SSEClient struct {
+	ch     chan string
+	UserId string
+	Admin  *model.UserModel // 使用管理员登录令牌连接时记录管理员信息，按连接时的权限过滤管理员消息
+}
+
+// sseTargetedMessage 定向推送消息
+type sseTargetedMessage struct {
+	message []byte
+	match   func(client *SSEClient) bool
+}
+
 var SSEManagerInstance = &SSEManager{
-	clients:    make(map[chan string]bool),
+	clients:    make(map[chan string]*SSEClient),
 	broadcast:  make(chan []byte),
-	register:   make(chan chan string),
+	targeted:   make(chan *sseTargetedMessage),
+	register:   make(chan *SSEClient),
 	unregister: make(chan chan string),
 }
 
@@ -30,29 +47,42 @@ func (manager *SSEManager) Start() {
 		select {
 		case client := <-manager.register:
 			manager.mutex.Lock()
-			manager.clients[client] = true
+			manager.clients[client.ch] = client
 			manager.mutex.Unlock()
 			log.Println("SSE客户端已连接")
 
 		case client := <-manager.unregister:
 			manager.mutex.Lock()
-			delete(manager.clients, client)
-			close(client)
+			if _, ok := manager.clients[client]; ok {
+				delete(manager.clients, client)
+				close(client)
+			}
 			manager.mutex.Unlock()
 			log.Println("SSE客户端已断开")
 
 		case message := <-manager.broadcast:
-			manager.mutex.RLock()
-			for client := range manager.clients {
-				select {
-				case client <- string(message):
-				default:
-					// 客户端可能已断开，移除它
-					delete(manager.clients, client)
-					close(client)
-				}
-			}
-			manager.mutex.RUnlock()
+			manager.send(message, nil)
+
+		case targeted := <-manager.targeted:
+			manager.send(targeted.message, targeted.match)
+		}
+	}
+}
+
+// send 向匹配的客户端发送消息，match为nil时发送给所有客户端
+func (manager *SSEManager) send(message []byte, match func(client *SSEClient) bool) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	for ch, client := range manager.clients {
+		if match != nil && !match(client) {
+			continue
+		}
+		select {
+		case ch <- string(message):
+		default:
+			// 客户端可能已断开，移除它
+			delete(manager.clients, ch)
+			close(ch)
 		}
 	}
 }
@@ -66,9 +96,16 @@ func SSEHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
 
-	// 创建客户端通道
-	clientChan := make(chan string)
-	SSEManagerInstance.register <- clientChan
+	// 创建客户端通道，携带有效令牌时记录用户身份
+	clientChan := make(chan string, 16)
+	client := &SSEClient{ch: clientChan}
+	if auth := authenticateOptionalContext(r); auth != nil {
+		client.UserId = auth.user.UserId
+		if auth.adminSession && auth.user.IsAdmin == 1 {
+			client.Admin = auth.user
+		}
+	}
+	SSEManagerInstance.register <- client
 
 	// 确保在函数结束时清理客户端
 	defer func() {
@@ -94,7 +131,10 @@ func SSEHandler(w http.ResponseWriter, r *http.Request) {
 
 	for {
 		select {
-		case message := <-clientChan:
+		case message, ok := <-clientChan:
+			if !ok {
+				return
+			}
 			fmt.Fprintf(w, "data: %s\n\n", message)
 			w.(http.Flusher).Flush()
 
@@ -126,6 +166,45 @@ func BroadcastSSEMessage(messageType string, data interface{}) {
 	SSEManagerInstance.broadcast <- messageBytes
 }
 
+// SendSSEMessageToUser 向指定用户的SSE连接推送消息
+func SendSSEMessageToUser(userId string, messageType string, data interface{}) {
+	sendTargetedSSEMessage(messageType, data, func(client *SSEClient) bool {
+		return client.UserId == userId
+	})
+}
+
+// SendSSEMessageToSuperAdmins 向所有在线超级管理员推送消息
+func SendSSEMessageToSuperAdmins(messageType string, data interface{}) {
+	sendTargetedSSEMessage(messageType, data, func(client *SSEClient) bool {
+		return client.Admin != nil && client.Admin.AdminLevel == 2
+	})
+}
+
+// SendSSEMessageToAdmins 向拥有指定权限的在线管理员推送消息，消息中包含金额等敏感信息时应使用对应的查看权限
+func SendSSEMessageToAdmins(permission string, messageType string, data interface{}) {
+	sendSSEMessageToAdminsMatching(messageType, data, func(admin *model.UserModel) bool {
+		return HasAdminPermission(admin, permission)
+	})
+}
+
+// sendSSEMessageToAdminsMatching 向满足条件的在线管理员推送消息
+func sendSSEMessageToAdminsMatching(messageType string, data interface{}, match func(admin *model.UserModel) bool) {
+	sendTargetedSSEMessage(messageType, data, func(client *SSEClient) bool {
+		return client.Admin != nil && match(client.Admin)
+	})
+}
+
+// sendTargetedSSEMessage 向匹配的SSE连接推送消息
+func sendTargetedSSEMessage(messageType string, data interface{}, match func(client *SSEClient) bool) {
+	message := map[string]interface{}{
+		"type":      messageType,
+		"data":      data,
+		"timestamp": time.Now().Unix(),
+	}
+	messageBytes, _ := json.Marshal(message)
+	SSEManagerInstance.targeted <- &sseTargetedMessage{message: messageBytes, match: match}
+}
+
 // BroadcastSSEOrderUpdate 广播SSE订单更新
 func BroadcastSSEOrderUpdate(orderID string, status string, amount float64) {
 	update := map[string]interface{}{
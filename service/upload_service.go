@@ -101,7 +101,7 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// 获取其他参数
-	userIdStr := r.FormValue("userId")
+	userIdStr := GetAuthUserId(r) // 上传用户以登录令牌为准
 	category := r.FormValue("category")
 	description := r.FormValue("description")
 
@@ -111,15 +111,6 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 		"description": description,
 	})
 
-	// 验证用户ID
-	userId, err := strconv.Atoi(userIdStr)
-	if err != nil {
-		LogError("无效的用户ID", err)
-		http.Error(w, "无效的用户ID", http.StatusBadRequest)
-		return
-	}
-	LogStep("用户ID验证成功", map[string]interface{}{"userId": userId})
-
 	// 验证文件
 	LogStep("开始验证文件", map[string]interface{}{
 		"fileName":    header.Filename,
@@ -411,8 +402,8 @@ func GetFileListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取查询参数
-	userIdStr := r.URL.Query().Get("userId")
+	// 获取查询参数，只能查询当前登录用户的文件
+	userIdStr := GetAuthUserId(r)
 	category := r.URL.Query().Get("category")
 	limitStr := r.URL.Query().Get("limit")
 
@@ -432,22 +423,21 @@ func GetFileListHandler(w http.ResponseWriter, r *http.Request) {
 	var files []*model.FileModel
 	var err error
 
-	if userIdStr != "" {
-		// 根据用户ID查询
-		LogStep("根据用户ID查询文件", map[string]interface{}{"userId": userIdStr, "limit": limit})
-		LogDBOperation("查询", "files", map[string]interface{}{"userId": userIdStr, "limit": limit})
-		files, err = dao.UploadImp.GetFilesByUserId(userIdStr, limit)
-		LogDBResult("查询", "files", files, err)
-	} else if category != "" {
-		// 根据分类查询
-		LogStep("根据分类查询文件", map[string]interface{}{"category": category, "limit": limit})
-		LogDBOperation("查询", "files", map[string]interface{}{"category": category, "limit": limit})
-		files, err = dao.UploadImp.GetFilesByCategory(category, limit)
-		LogDBResult("查询", "files", files, err)
-	} else {
-		LogError("缺少查询参数", fmt.Errorf("userId和category参数都为空"))
-		http.Error(w, "缺少查询参数", http.StatusBadRequest)
-		return
+	// 根据用户ID查询
+	LogStep("根据用户ID查询文件", map[string]interface{}{"userId": userIdStr, "limit": limit})
+	LogDBOperation("查询", "files", map[string]interface{}{"userId": userIdStr, "limit": limit})
+	files, err = dao.UploadImp.GetFilesByUserId(userIdStr, limit)
+	LogDBResult("查询", "files", files, err)
+
+	// 按分类筛选
+	if err == nil && category != "" {
+		filtered := make([]*model.FileModel, 0, len(files))
+		for _, file := range files {
+			if file.Category == category {
+				filtered = append(filtered, file)
+			}
+		}
+		files = filtered
 	}
 
 	if err != nil {
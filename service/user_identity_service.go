@@ -0,0 +1,374 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+
+	"gorm.io/gorm"
+)
+
+// maxMergeChainDepth 跟随合并链查找保留账号的最大层数
+const maxMergeChainDepth = 5
+
+// MergeUsersRequest 合并账号请求
+type MergeUsersRequest struct {
+	SourceUserId string `json:"sourceUserId"` // 被合并的重复账号
+	TargetUserId string `json:"targetUserId"` // 保留的账号
+	Reason       string `json:"reason"`
+}
+
+// resolveUserByWxIdentity 根据openId/unionId识别用户，依次查找：已登记的身份、Users表中的openId、相同unionId的账号
+// 找到的账号已被合并时返回保留账号；都找不到时返回gorm.ErrRecordNotFound
+func resolveUserByWxIdentity(openId, unionId string) (*model.UserModel, error) {
+	identity, err := dao.UserIdentityImp.GetIdentityByOpenId(openId)
+	if err == nil {
+		user, err := dao.UserImp.GetUserByUserId(identity.UserId)
+		if err != nil {
+			return nil, err
+		}
+		return followMergedUser(user)
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	// 身份表上线前注册的用户只有Users.openId，识别后补录身份
+	user, err := dao.UserImp.GetUserByOpenId(openId)
+	if err == nil {
+		user, err = followMergedUser(user)
+		if err != nil {
+			return nil, err
+		}
+		bindUserIdentity(user, openId, unionId, model.IdentitySourceMiniProgram)
+		return user, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	if unionId == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	// 同一开放平台下的其他应用已注册过，把当前openId关联到该账号
+	identity, err = dao.UserIdentityImp.GetIdentityByUnionId(unionId)
+	if err == nil {
+		user, err = dao.UserImp.GetUserByUserId(identity.UserId)
+	} else if err == gorm.ErrRecordNotFound {
+		user, err = dao.UserImp.GetUserByUnionId(unionId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err = followMergedUser(user)
+	if err != nil {
+		return nil, err
+	}
+	LogStep("通过unionId识别到已有账号", map[string]string{"openId": openId, "userId": user.UserId})
+	bindUserIdentity(user, openId, unionId, model.IdentitySourceMiniProgram)
+	return user, nil
+}
+
+// followMergedUser 账号已被合并时沿合并链返回保留账号
+func followMergedUser(user *model.UserModel) (*model.UserModel, error) {
+	for i := 0; i < maxMergeChainDepth && user.MergedInto != ""; i++ {
+		next, err := dao.UserImp.GetUserByUserId(user.MergedInto)
+		if err != nil {
+			return nil, fmt.Errorf("获取合并后的账号失败: %v", err)
+		}
+		user = next
+	}
+	if user.MergedInto != "" {
+		return nil, fmt.Errorf("账号合并链过长: %s", user.UserId)
+	}
+	return user, nil
+}
+
+// bindUserIdentity 登记用户身份，失败只记录日志（并发登录时可能已被登记）
+func bindUserIdentity(user *model.UserModel, openId, unionId, source string) {
+	identity := &model.UserIdentityModel{
+		UserId:  user.UserId,
+		OpenId:  openId,
+		UnionId: unionId,
+		Source:  source,
+	}
+	if source == model.IdentitySourceMiniProgram {
+		identity.AppId = config.GetWxConfig().AppID
+	}
+	LogDBOperation("创建", "user_identities", identity)
+	if err := dao.UserIdentityImp.CreateIdentity(identity); err != nil {
+		LogError("登记用户身份失败", err)
+	}
+}
+
+// GetUserDuplicatesHandler 查找与指定用户unionId或手机号相同的其他账号，以及该用户已登记的身份
+func GetUserDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理查找重复账号请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodGet {
+		LogError("请求方法不支持", fmt.Errorf("期望GET方法，实际为%s", r.Method))
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdminPermission(w, r, PermUserMerge) {
+		return
+	}
+
+	userId := r.URL.Query().Get("userId")
+	if userId == "" {
+		http.Error(w, "缺少userId参数", http.StatusBadRequest)
+		return
+	}
+
+	user, err := dao.UserImp.GetUserByUserId(userId)
+	if err != nil {
+		LogError("获取用户失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "用户不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	candidates, err := dao.UserImp.GetUsersByUnionIdOrPhone(user.UnionId, user.Phone)
+	if err != nil {
+		LogError("查找重复账号失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "查找重复账号失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	duplicates := []*AdminUserInfo{}
+	for _, candidate := range candidates {
+		if candidate.UserId == user.UserId {
+			continue
+		}
+		duplicates = append(duplicates, &AdminUserInfo{
+			UserId:    candidate.UserId,
+			NickName:  candidate.NickName,
+			AvatarUrl: candidate.AvatarUrl,
+			Phone:     candidate.Phone,
+			IsAdmin:   candidate.IsAdmin,
+			CreatedAt: candidate.CreatedAt,
+		})
+	}
+
+	identities, err := dao.UserIdentityImp.GetIdentitiesByUserId(user.UserId)
+	if err != nil {
+		LogError("获取用户身份失败", err)
+	}
+	if identities == nil {
+		identities = []*model.UserIdentityModel{}
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"userId":     user.UserId,
+			"mergedInto": user.MergedInto,
+			"identities": identities,
+			"duplicates": duplicates,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// MergeUsersHandler 把重复账号合并到保留账号，迁移订单、就诊人、地址、推荐关系、佣金、提现和文件
+func MergeUsersHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理合并账号请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodPost {
+		LogError("请求方法不支持", fmt.Errorf("期望POST方法，实际为%s", r.Method))
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdminPermission(w, r, PermUserMerge) {
+		return
+	}
+	operator := GetAuthUser(r)
+
+	var req MergeUsersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		LogError("解析请求体失败", err)
+		http.Error(w, "请求体格式错误", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.SourceUserId == "" || req.TargetUserId == "" || req.Reason == "" {
+		http.Error(w, "缺少sourceUserId、targetUserId或reason参数", http.StatusBadRequest)
+		return
+	}
+
+	if req.SourceUserId == req.TargetUserId {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "不能合并到同一个账号",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	source, err := dao.UserImp.GetUserByUserId(req.SourceUserId)
+	if err != nil {
+		LogError("获取被合并用户失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "被合并的账号不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	target, err := dao.UserImp.GetUserByUserId(req.TargetUserId)
+	if err != nil {
+		LogError("获取保留用户失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "保留的账号不存在",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	if source.MergedInto != "" || target.MergedInto != "" {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "账号已被合并，不能再次合并",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	// 管理员账号带有权限和登录凭据，不允许被合并掉
+	if source.IsAdmin == 1 {
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "被合并的账号是管理员，请先取消其管理员身份",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	mergeLog := &model.UserMergeLogModel{
+		OperatorId: operator.UserId,
+		Reason:     req.Reason,
+	}
+	summary, err := dao.UserIdentityImp.MergeUsers(source, target, mergeLog)
+	if err != nil {
+		LogError("合并账号失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "合并账号失败: " + err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 被合并账号的登录全部失效，之后用其openId登录会进入保留账号
+	revoked, err := dao.AuthImp.RevokeUserSessions(source.UserId, "账号已合并")
+	if err != nil {
+		LogError("吊销被合并账号会话失败", err)
+	}
+
+	LogStep("账号合并成功", map[string]interface{}{
+		"operatorId":      operator.UserId,
+		"sourceUserId":    source.UserId,
+		"targetUserId":    target.UserId,
+		"summary":         summary,
+		"revokedSessions": revoked,
+	})
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"mergeLogId":   mergeLog.Id,
+			"sourceUserId": source.UserId,
+			"targetUserId": target.UserId,
+			"summary":      summary,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetUserMergeLogsHandler 分页获取账号合并记录
+func GetUserMergeLogsHandler(w http.ResponseWriter, r *http.Request) {
+	LogInfo("开始处理获取账号合并记录请求", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	if r.Method != http.MethodGet {
+		LogError("请求方法不支持", fmt.Errorf("期望GET方法，实际为%s", r.Method))
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdminPermission(w, r, PermUserMerge) {
+		return
+	}
+
+	page := 1
+	pageSize := 20
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if _, err := fmt.Sscanf(pageStr, "%d", &page); err != nil || page < 1 {
+			page = 1
+		}
+	}
+	if pageSizeStr := r.URL.Query().Get("pageSize"); pageSizeStr != "" {
+		if _, err := fmt.Sscanf(pageSizeStr, "%d", &pageSize); err != nil || pageSize < 1 {
+			pageSize = 20
+		}
+	}
+
+	logs, total, err := dao.UserIdentityImp.GetMergeLogs(page, pageSize)
+	if err != nil {
+		LogError("获取账号合并记录失败", err)
+		response := &AdminResponse{
+			Code:     -1,
+			ErrorMsg: "获取账号合并记录失败",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	if logs == nil {
+		logs = []*model.UserMergeLogModel{}
+	}
+
+	response := &AdminResponse{
+		Code: 0,
+		Data: map[string]interface{}{
+			"list":     logs,
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
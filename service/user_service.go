@@ -7,8 +7,10 @@ import (
 	"strconv"
 	"time"
 
+	"wxcloudrun-golang/config"
 	"wxcloudrun-golang/db/dao"
 	"wxcloudrun-golang/db/model"
+	"wxcloudrun-golang/utils"
 )
 
 // UserResponse 用户响应
@@ -33,16 +35,17 @@ type UserInfo struct {
 	Language  string `json:"language"`
 }
 
-// BindPhoneRequest 绑定手机号请求
+// BindPhoneRequest 绑定手机号请求，支持小程序getPhoneNumber的两种返回方式，用户拒绝授权时可使用短信验证码
 type BindPhoneRequest struct {
-	UserId string `json:"userId"`
-	Phone  string `json:"phone"`
-	Code   string `json:"code"` // 验证码
+	Code          string `json:"code"`          // getPhoneNumber返回的动态令牌（基础库2.21.2及以上）
+	EncryptedData string `json:"encryptedData"` // 旧版getPhoneNumber返回的加密数据
+	Iv            string `json:"iv"`            // 加密算法的初始向量
+	Phone         string `json:"phone"`         // 短信验证方式：用户填写的手机号
+	SmsCode       string `json:"smsCode"`       // 短信验证方式：短信验证码（scene=bind_phone）
 }
 
 // UpdateUserInfoRequest 更新用户信息请求
 type UpdateUserInfoRequest struct {
-	UserId    string `json:"userId"`
 	NickName  string `json:"nickName,omitempty"`
 	AvatarUrl string `json:"avatarUrl,omitempty"`
 	Gender    int    `json:"gender,omitempty"`
@@ -50,21 +53,21 @@ type UpdateUserInfoRequest struct {
 
 // AddressRequest 地址请求
 type AddressRequest struct {
-	Id        int32  `json:"id,omitempty"`
-	UserId    string `json:"userId"`
-	Name      string `json:"name"`
-	Phone     string `json:"phone"`
-	Province  string `json:"province"`
-	City      string `json:"city"`
-	District  string `json:"district"`
-	Address   string `json:"address"`
-	IsDefault bool   `json:"isDefault"`
+	Id        int32    `json:"id,omitempty"`
+	Name      string   `json:"name"`
+	Phone     string   `json:"phone"`
+	Province  string   `json:"province"`
+	City      string   `json:"city"`
+	District  string   `json:"district"`
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude"`  // 纬度，可选
+	Longitude *float64 `json:"longitude"` // 经度，可选
+	IsDefault bool     `json:"isDefault"`
 }
 
 // PatientRequest 就诊人请求
 type PatientRequest struct {
 	Id        int32  `json:"id,omitempty"`
-	UserId    string `json:"userId"`
 	Name      string `json:"name"`
 	IdCard    string `json:"idCard"`
 	Phone     string `json:"phone"`
@@ -161,18 +164,12 @@ func GetUserInfoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取用户ID参数
-	userIdStr := r.URL.Query().Get("userId")
+	// 用户ID以登录令牌为准
+	userIdStr := GetAuthUserId(r)
 	LogStep("解析请求参数", map[string]interface{}{
 		"userId": userIdStr,
 	})
 
-	if userIdStr == "" {
-		LogError("缺少必要参数", fmt.Errorf("userId参数为空"))
-		http.Error(w, "缺少userId参数", http.StatusBadRequest)
-		return
-	}
-
 	LogStep("开始查询用户信息", map[string]interface{}{
 		"userId": userIdStr,
 	})
@@ -229,7 +226,7 @@ func GetUserInfoHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// BindPhoneHandler 用户绑定手机号接口
+// BindPhoneHandler 绑定手机号接口，手机号来自微信getPhoneNumber或经过短信验证码校验，不接受客户端直接提交
 func BindPhoneHandler(w http.ResponseWriter, r *http.Request) {
 	LogInfo("开始处理绑定手机号请求", map[string]interface{}{
 		"method": r.Method,
@@ -248,45 +245,28 @@ func BindPhoneHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
 		return
 	}
+	userId := GetAuthUserId(r)
 
 	LogStep("解析绑定手机号请求参数", map[string]interface{}{
-		"userId": req.UserId,
-		"phone":  req.Phone,
-		"code":   req.Code,
+		"userId":           userId,
+		"hasCode":          req.Code != "",
+		"hasEncryptedData": req.EncryptedData != "",
+		"phone":            req.Phone,
 	})
 
 	// 验证参数
-	if req.UserId == "" || req.Phone == "" || req.Code == "" {
-		LogError("缺少必要参数", fmt.Errorf("userId=%s, phone=%s, code=%s", req.UserId, req.Phone, req.Code))
+	if req.Code == "" && (req.EncryptedData == "" || req.Iv == "") && (req.Phone == "" || req.SmsCode == "") {
+		LogError("缺少必要参数", fmt.Errorf("code、encryptedData/iv、phone/smsCode均为空"))
 		http.Error(w, "缺少必要参数", http.StatusBadRequest)
 		return
 	}
 
-	// 这里应该验证验证码，简化处理
-	LogStep("开始验证验证码", map[string]interface{}{
-		"inputCode":    req.Code,
-		"expectedCode": "123456",
-	})
-
-	if req.Code != "123456" {
-		LogError("验证码错误", fmt.Errorf("输入验证码=%s，期望验证码=123456", req.Code))
-		response := &UserResponse{
-			Code:     -1,
-			ErrorMsg: "验证码错误",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
-	LogStep("验证码验证成功", nil)
-
 	// 获取用户信息
 	LogStep("开始查询用户信息", map[string]interface{}{
-		"userId": req.UserId,
+		"userId": userId,
 	})
 
-	user, err := dao.UserImp.GetUserByUserId(req.UserId)
+	user, err := dao.UserImp.GetUserByUserId(userId)
 	if err != nil {
 		LogError("数据库查询用户信息失败", err)
 		response := &UserResponse{
@@ -298,20 +278,46 @@ func BindPhoneHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	LogStep("用户信息查询成功", map[string]interface{}{
-		"userId":   user.UserId,
-		"nickName": user.NickName,
-		"oldPhone": user.Phone,
-	})
+	var phone string
+	if req.Phone != "" && req.SmsCode != "" {
+		// 用户拒绝微信授权时，使用短信验证码校验手机号
+		if err := VerifySMSCode(req.Phone, SmsSceneBindPhone, req.SmsCode); err != nil {
+			LogError("短信验证码校验失败", err)
+			response := &UserResponse{
+				Code:     -1,
+				ErrorMsg: "验证码校验失败，请重试",
+			}
+			if smsErr, ok := err.(*SMSCodeError); ok {
+				response.ErrorMsg = smsErr.Message
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		phone = req.Phone
+	} else {
+		// 从微信获取手机号
+		phone, err = resolveWxPhoneNumber(user, &req)
+		if err != nil {
+			LogError("获取微信手机号失败", err)
+			response := &UserResponse{
+				Code:     -1,
+				ErrorMsg: "获取手机号失败，请重试",
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
 
 	// 更新用户手机号
 	LogStep("开始更新用户手机号", map[string]interface{}{
 		"userId":   user.UserId,
 		"oldPhone": user.Phone,
-		"newPhone": req.Phone,
+		"newPhone": phone,
 	})
 
-	user.Phone = req.Phone
+	user.Phone = phone
 	user.UpdatedAt = time.Now()
 
 	if err := dao.UserImp.UpdateUser(user); err != nil {
@@ -327,14 +333,14 @@ func BindPhoneHandler(w http.ResponseWriter, r *http.Request) {
 
 	LogStep("用户手机号更新成功", map[string]interface{}{
 		"userId":   user.UserId,
-		"newPhone": req.Phone,
+		"newPhone": phone,
 	})
 
 	response := &UserResponse{
 		Code: 0,
 		Data: map[string]interface{}{
-			"userId":  req.UserId,
-			"phone":   req.Phone,
+			"userId":  userId,
+			"phone":   phone,
 			"message": "手机号绑定成功",
 		},
 	}
@@ -342,11 +348,51 @@ func BindPhoneHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 
 	LogInfo("手机号绑定成功", map[string]interface{}{
-		"userId": req.UserId,
-		"phone":  req.Phone,
+		"userId": userId,
+		"phone":  phone,
 	})
 }
 
+// resolveWxPhoneNumber 获取用户的微信手机号：优先使用code换取，否则用session_key解密encryptedData
+func resolveWxPhoneNumber(user *model.UserModel, req *BindPhoneRequest) (string, error) {
+	var info *WxPhoneInfo
+	if req.Code != "" {
+		LogStep("使用code换取微信手机号", map[string]interface{}{"userId": user.UserId})
+		phoneInfo, err := GetWxAPIClient().GetPhoneNumber(req.Code)
+		if err != nil {
+			return "", err
+		}
+		info = phoneInfo
+	} else {
+		LogStep("解密微信手机号数据", map[string]interface{}{"userId": user.UserId})
+		if user.SessionKey == "" {
+			return "", fmt.Errorf("session_key为空，请重新登录")
+		}
+		plaintext, err := utils.DecryptWxData(user.SessionKey, req.EncryptedData, req.Iv)
+		if err != nil {
+			return "", err
+		}
+		info = &WxPhoneInfo{}
+		if err := json.Unmarshal(plaintext, info); err != nil {
+			return "", fmt.Errorf("解析手机号数据失败: %v", err)
+		}
+		// 校验数据水印，防止使用其他小程序的数据
+		if info.Watermark.AppId != config.GetWxConfig().AppID {
+			return "", fmt.Errorf("手机号数据水印appid不匹配: %s", info.Watermark.AppId)
+		}
+	}
+
+	// 国内手机号不带区号存储，境外手机号保留区号
+	phone := info.PurePhoneNumber
+	if info.CountryCode != "" && info.CountryCode != "86" && info.PhoneNumber != "" {
+		phone = info.PhoneNumber
+	}
+	if phone == "" {
+		return "", fmt.Errorf("微信未返回手机号")
+	}
+	return phone, nil
+}
+
 // AddressHandler 地址管理接口
 func AddressHandler(w http.ResponseWriter, r *http.Request) {
 	LogInfo("开始处理地址管理请求", map[string]interface{}{
@@ -377,17 +423,11 @@ func AddressHandler(w http.ResponseWriter, r *http.Request) {
 func handleGetAddresses(w http.ResponseWriter, r *http.Request) {
 	LogStep("开始获取地址列表", nil)
 
-	userIdStr := r.URL.Query().Get("userId")
+	userIdStr := GetAuthUserId(r)
 	LogStep("解析请求参数", map[string]interface{}{
 		"userId": userIdStr,
 	})
 
-	if userIdStr == "" {
-		LogError("缺少必要参数", fmt.Errorf("userId参数为空"))
-		http.Error(w, "缺少userId参数", http.StatusBadRequest)
-		return
-	}
-
 	LogStep("开始查询地址列表", map[string]interface{}{
 		"userId": userIdStr,
 	})
@@ -432,9 +472,10 @@ func handleCreateAddress(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
 		return
 	}
+	userId := GetAuthUserId(r)
 
 	LogStep("解析创建地址请求参数", map[string]interface{}{
-		"userId":    req.UserId,
+		"userId":    userId,
 		"name":      req.Name,
 		"phone":     req.Phone,
 		"province":  req.Province,
@@ -444,13 +485,15 @@ func handleCreateAddress(w http.ResponseWriter, r *http.Request) {
 	})
 
 	address := &model.UserAddressModel{
-		UserId:    req.UserId,
+		UserId:    userId,
 		Name:      req.Name,
 		Phone:     req.Phone,
 		Province:  req.Province,
 		City:      req.City,
 		District:  req.District,
 		Address:   req.Address,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
 		IsDefault: 0,
 	}
 
@@ -483,10 +526,10 @@ func handleCreateAddress(w http.ResponseWriter, r *http.Request) {
 	// 如果设置为默认地址
 	if req.IsDefault {
 		LogStep("设置默认地址", map[string]interface{}{
-			"userId":    req.UserId,
+			"userId":    userId,
 			"addressId": address.Id,
 		})
-		dao.UserExtendImp.SetDefaultAddress(req.UserId, address.Id)
+		dao.UserExtendImp.SetDefaultAddress(userId, address.Id)
 		LogStep("默认地址设置完成", nil)
 	}
 
@@ -511,16 +554,19 @@ func handleUpdateAddress(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
 		return
 	}
+	userId := GetAuthUserId(r)
 
 	address := &model.UserAddressModel{
 		Id:        req.Id,
-		UserId:    req.UserId,
+		UserId:    userId,
 		Name:      req.Name,
 		Phone:     req.Phone,
 		Province:  req.Province,
 		City:      req.City,
 		District:  req.District,
 		Address:   req.Address,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
 		IsDefault: 0,
 	}
 
@@ -528,6 +574,11 @@ func handleUpdateAddress(w http.ResponseWriter, r *http.Request) {
 		address.IsDefault = 1
 	}
 
+	// 只能修改自己的地址
+	if _, ok := authorizeAddress(w, r, req.Id); !ok {
+		return
+	}
+
 	if err := dao.UserExtendImp.UpdateAddress(address); err != nil {
 		response := &UserResponse{
 			Code:     -1,
@@ -540,7 +591,7 @@ func handleUpdateAddress(w http.ResponseWriter, r *http.Request) {
 
 	// 如果设置为默认地址
 	if req.IsDefault {
-		dao.UserExtendImp.SetDefaultAddress(req.UserId, address.Id)
+		dao.UserExtendImp.SetDefaultAddress(userId, address.Id)
 	}
 
 	response := &UserResponse{
@@ -565,6 +616,11 @@ func handleDeleteAddress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 只能删除自己的地址
+	if _, ok := authorizeAddress(w, r, int32(addressId)); !ok {
+		return
+	}
+
 	if err := dao.UserExtendImp.DeleteAddress(int32(addressId)); err != nil {
 		response := &UserResponse{
 			Code:     -1,
@@ -601,11 +657,7 @@ func PatientHandler(w http.ResponseWriter, r *http.Request) {
 
 // handleGetPatients 获取就诊人列表
 func handleGetPatients(w http.ResponseWriter, r *http.Request) {
-	userIdStr := r.URL.Query().Get("userId")
-	if userIdStr == "" {
-		http.Error(w, "缺少userId参数", http.StatusBadRequest)
-		return
-	}
+	userIdStr := GetAuthUserId(r)
 
 	patients, err := dao.UserExtendImp.GetPatientsByUserId(userIdStr)
 	if err != nil {
@@ -638,9 +690,10 @@ func handleCreatePatient(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
 		return
 	}
+	userId := GetAuthUserId(r)
 
 	patient := &model.PatientModel{
-		UserId:    req.UserId,
+		UserId:    userId,
 		Name:      req.Name,
 		IdCard:    req.IdCard,
 		Phone:     req.Phone,
@@ -666,7 +719,7 @@ func handleCreatePatient(w http.ResponseWriter, r *http.Request) {
 
 	// 如果设置为默认就诊人
 	if req.IsDefault {
-		dao.UserExtendImp.SetDefaultPatient(req.UserId, patient.Id)
+		dao.UserExtendImp.SetDefaultPatient(userId, patient.Id)
 	}
 
 	response := &UserResponse{
@@ -684,10 +737,11 @@ func handleUpdatePatient(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
 		return
 	}
+	userId := GetAuthUserId(r)
 
 	patient := &model.PatientModel{
 		Id:        req.Id,
-		UserId:    req.UserId,
+		UserId:    userId,
 		Name:      req.Name,
 		IdCard:    req.IdCard,
 		Phone:     req.Phone,
@@ -701,6 +755,11 @@ func handleUpdatePatient(w http.ResponseWriter, r *http.Request) {
 		patient.IsDefault = 1
 	}
 
+	// 只能修改自己的就诊人
+	if _, ok := authorizePatient(w, r, req.Id); !ok {
+		return
+	}
+
 	if err := dao.UserExtendImp.UpdatePatient(patient); err != nil {
 		response := &UserResponse{
 			Code:     -1,
@@ -713,7 +772,7 @@ func handleUpdatePatient(w http.ResponseWriter, r *http.Request) {
 
 	// 如果设置为默认就诊人
 	if req.IsDefault {
-		dao.UserExtendImp.SetDefaultPatient(req.UserId, patient.Id)
+		dao.UserExtendImp.SetDefaultPatient(userId, patient.Id)
 	}
 
 	response := &UserResponse{
@@ -738,6 +797,11 @@ func handleDeletePatient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 只能删除自己的就诊人
+	if _, ok := authorizePatient(w, r, int32(patientId)); !ok {
+		return
+	}
+
 	if err := dao.UserExtendImp.DeletePatient(int32(patientId)); err != nil {
 		response := &UserResponse{
 			Code:     -1,
@@ -775,16 +839,17 @@ func UpdateUserInfoHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "请求参数解析失败", http.StatusBadRequest)
 		return
 	}
+	userId := GetAuthUserId(r)
 
 	LogStep("解析更新用户信息请求参数", map[string]interface{}{
-		"userId":    req.UserId,
+		"userId":    userId,
 		"nickName":  req.NickName,
 		"avatarUrl": req.AvatarUrl,
 		"gender":    req.Gender,
 	})
 
 	// 验证用户是否存在
-	user, err := dao.UserImp.GetUserByUserId(req.UserId)
+	user, err := dao.UserImp.GetUserByUserId(userId)
 	if err != nil {
 		LogError("查询用户失败", err)
 		response := &UserResponse{
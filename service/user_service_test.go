@@ -0,0 +1,405 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/dao"
+	"wxcloudrun-golang/db/model"
+)
+
+// fakeWxAPIClient 模拟微信开放接口，记录换取手机号时收到的code
+type fakeWxAPIClient struct {
+	phoneInfo  *WxPhoneInfo
+	err        error
+	phoneCodes []string
+}
+
+func (c *fakeWxAPIClient) Code2Session(code string) (*WxLoginResponse, error) {
+	return nil, fmt.Errorf("未模拟Code2Session")
+}
+
+func (c *fakeWxAPIClient) GetPhoneNumber(code string) (*WxPhoneInfo, error) {
+	c.phoneCodes = append(c.phoneCodes, code)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.phoneInfo, nil
+}
+
+// fakeUserDao 内存中的用户数据，只实现绑定手机号用到的方法
+type fakeUserDao struct {
+	dao.UserInterface
+	user    *model.UserModel
+	updated []*model.UserModel
+}
+
+func (d *fakeUserDao) GetUserByUserId(userId string) (*model.UserModel, error) {
+	if d.user == nil || d.user.UserId != userId {
+		return nil, fmt.Errorf("record not found")
+	}
+	user := *d.user
+	return &user, nil
+}
+
+func (d *fakeUserDao) UpdateUser(user *model.UserModel) error {
+	saved := *user
+	d.updated = append(d.updated, &saved)
+	return nil
+}
+
+// fakeSmsDao 内存中的短信验证码数据
+type fakeSmsDao struct {
+	mu     sync.Mutex
+	codes  []*model.SmsCodeModel
+	nextId int32
+}
+
+func (d *fakeSmsDao) CreateCode(code *model.SmsCodeModel) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextId++
+	code.Id = d.nextId
+	code.CreatedAt = time.Now()
+	saved := *code
+	d.codes = append(d.codes, &saved)
+	return nil
+}
+
+func (d *fakeSmsDao) GetLatestCode(phone, scene string) (*model.SmsCodeModel, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := len(d.codes) - 1; i >= 0; i-- {
+		code := d.codes[i]
+		if code.Phone == phone && code.Scene == scene && code.Status == 0 {
+			latest := *code
+			return &latest, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *fakeSmsDao) CountCodesByPhoneSince(phone string, since time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var count int64
+	for _, code := range d.codes {
+		if code.Phone == phone && !code.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (d *fakeSmsDao) CountCodesByIpSince(clientIp string, since time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var count int64
+	for _, code := range d.codes {
+		if code.ClientIp == clientIp && !code.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (d *fakeSmsDao) IncrementAttempts(id int32, maxAttempts int) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	code := d.find(id)
+	if code == nil || (maxAttempts > 0 && code.Attempts >= maxAttempts) {
+		return false, nil
+	}
+	code.Attempts++
+	return true, nil
+}
+
+func (d *fakeSmsDao) MarkCodeUsed(id int32) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	code := d.find(id)
+	if code == nil || code.Status != 0 {
+		return false, nil
+	}
+	now := time.Now()
+	code.Status = 1
+	code.UsedAt = &now
+	return true, nil
+}
+
+func (d *fakeSmsDao) InvalidateCodes(phone, scene string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, code := range d.codes {
+		if code.Phone == phone && code.Scene == scene && code.Status == 0 {
+			code.Status = 2
+		}
+	}
+	return nil
+}
+
+// find 按ID查找验证码，调用方需持有锁
+func (d *fakeSmsDao) find(id int32) *model.SmsCodeModel {
+	for _, code := range d.codes {
+		if code.Id == id {
+			return code
+		}
+	}
+	return nil
+}
+
+// encryptWxData 按微信encryptedData的格式（AES-128-CBC，PKCS#7填充）加密，返回base64编码的密文
+func encryptWxData(t *testing.T, sessionKey, iv string, plaintext []byte) string {
+	key, _ := base64.StdEncoding.DecodeString(sessionKey)
+	ivBytes, _ := base64.StdEncoding.DecodeString(iv)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("创建加密实例失败: %v", err)
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, ivBytes).CryptBlocks(ciphertext, padded)
+	return base64.StdEncoding.EncodeToString(ciphertext)
+}
+
+// wxPhoneData 生成微信手机号解密后的明文
+func wxPhoneData(t *testing.T, purePhone, appId string) []byte {
+	info := &WxPhoneInfo{
+		PhoneNumber:     purePhone,
+		PurePhoneNumber: purePhone,
+		CountryCode:     "86",
+	}
+	info.Watermark.AppId = appId
+	info.Watermark.Timestamp = time.Now().Unix()
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("序列化手机号数据失败: %v", err)
+	}
+	return data
+}
+
+func TestBindPhoneHandler(t *testing.T) {
+	const (
+		userId     = "u_bind_phone"
+		sessionKey = "MDEyMzQ1Njc4OWFiY2RlZg=="
+		iv         = "ZmVkY2JhOTg3NjU0MzIxMA=="
+		smsPhone   = "13700137000"
+		smsCode    = "123456"
+	)
+	appId := config.GetWxConfig().AppID
+	validEncrypted := encryptWxData(t, sessionKey, iv, wxPhoneData(t, "13900139000", appId))
+	otherAppEncrypted := encryptWxData(t, sessionKey, iv, wxPhoneData(t, "13900139000", "wx_other_app"))
+
+	tests := []struct {
+		name       string
+		req        BindPhoneRequest
+		sessionKey string
+		wx         *fakeWxAPIClient
+		wantStatus int
+		wantCode   int
+		wantPhone  string // 期望写入的手机号，为空表示不应更新用户
+		wantWxCode string // 期望传给微信接口的code
+		wantErrMsg string
+	}{
+		{
+			name:       "code换取国内手机号",
+			req:        BindPhoneRequest{Code: "phone_code"},
+			wx:         &fakeWxAPIClient{phoneInfo: &WxPhoneInfo{PhoneNumber: "13800138000", PurePhoneNumber: "13800138000", CountryCode: "86"}},
+			wantStatus: http.StatusOK,
+			wantPhone:  "13800138000",
+			wantWxCode: "phone_code",
+		},
+		{
+			name:       "code换取境外手机号保留区号",
+			req:        BindPhoneRequest{Code: "phone_code"},
+			wx:         &fakeWxAPIClient{phoneInfo: &WxPhoneInfo{PhoneNumber: "+85261234567", PurePhoneNumber: "61234567", CountryCode: "852"}},
+			wantStatus: http.StatusOK,
+			wantPhone:  "+85261234567",
+			wantWxCode: "phone_code",
+		},
+		{
+			name:       "code换取失败",
+			req:        BindPhoneRequest{Code: "bad_code"},
+			wx:         &fakeWxAPIClient{err: fmt.Errorf("invalid code")},
+			wantStatus: http.StatusOK,
+			wantCode:   -1,
+			wantWxCode: "bad_code",
+			wantErrMsg: "获取手机号失败，请重试",
+		},
+		{
+			name:       "微信未返回手机号",
+			req:        BindPhoneRequest{Code: "phone_code"},
+			wx:         &fakeWxAPIClient{phoneInfo: &WxPhoneInfo{}},
+			wantStatus: http.StatusOK,
+			wantCode:   -1,
+			wantWxCode: "phone_code",
+			wantErrMsg: "获取手机号失败，请重试",
+		},
+		{
+			name:       "encryptedData水印匹配",
+			req:        BindPhoneRequest{EncryptedData: validEncrypted, Iv: iv},
+			sessionKey: sessionKey,
+			wx:         &fakeWxAPIClient{},
+			wantStatus: http.StatusOK,
+			wantPhone:  "13900139000",
+		},
+		{
+			name:       "encryptedData水印appid不匹配",
+			req:        BindPhoneRequest{EncryptedData: otherAppEncrypted, Iv: iv},
+			sessionKey: sessionKey,
+			wx:         &fakeWxAPIClient{},
+			wantStatus: http.StatusOK,
+			wantCode:   -1,
+			wantErrMsg: "获取手机号失败，请重试",
+		},
+		{
+			name:       "encryptedData缺少session_key",
+			req:        BindPhoneRequest{EncryptedData: validEncrypted, Iv: iv},
+			wx:         &fakeWxAPIClient{},
+			wantStatus: http.StatusOK,
+			wantCode:   -1,
+			wantErrMsg: "获取手机号失败，请重试",
+		},
+		{
+			name:       "encryptedData使用错误的session_key",
+			req:        BindPhoneRequest{EncryptedData: validEncrypted, Iv: iv},
+			sessionKey: "ZmVkY2JhOTg3NjU0MzIxMA==",
+			wx:         &fakeWxAPIClient{},
+			wantStatus: http.StatusOK,
+			wantCode:   -1,
+			wantErrMsg: "获取手机号失败，请重试",
+		},
+		{
+			name:       "短信验证码正确",
+			req:        BindPhoneRequest{Phone: smsPhone, SmsCode: smsCode},
+			wx:         &fakeWxAPIClient{},
+			wantStatus: http.StatusOK,
+			wantPhone:  smsPhone,
+		},
+		{
+			name:       "短信验证码错误",
+			req:        BindPhoneRequest{Phone: smsPhone, SmsCode: "654321"},
+			wx:         &fakeWxAPIClient{},
+			wantStatus: http.StatusOK,
+			wantCode:   -1,
+			wantErrMsg: "验证码错误",
+		},
+		{
+			name:       "缺少必要参数",
+			req:        BindPhoneRequest{Phone: smsPhone},
+			wx:         &fakeWxAPIClient{},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	originalUserImp, originalSmsImp := dao.UserImp, dao.SmsImp
+	defer func() {
+		dao.UserImp, dao.SmsImp = originalUserImp, originalSmsImp
+		SetWxAPIClient(nil)
+	}()
+
+	for _, tt := range tests {
+		user := &model.UserModel{UserId: userId, SessionKey: tt.sessionKey}
+		users := &fakeUserDao{user: user}
+		smsCodes := &fakeSmsDao{}
+		smsCodes.CreateCode(&model.SmsCodeModel{
+			Phone:     smsPhone,
+			Scene:     SmsSceneBindPhone,
+			CodeHash:  hashSMSCode(smsPhone, SmsSceneBindPhone, smsCode),
+			ExpiresAt: time.Now().Add(5 * time.Minute),
+		})
+		dao.UserImp, dao.SmsImp = users, smsCodes
+		SetWxAPIClient(tt.wx)
+
+		body, _ := json.Marshal(tt.req)
+		r := httptest.NewRequest(http.MethodPost, "/api/user/bindPhone", bytes.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), authContextKey{}, &authContext{user: user}))
+		w := httptest.NewRecorder()
+		BindPhoneHandler(w, r)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantWxCode != "" && (len(tt.wx.phoneCodes) != 1 || tt.wx.phoneCodes[0] != tt.wantWxCode) {
+			t.Errorf("%s: 微信接口收到的code = %v, want [%s]", tt.name, tt.wx.phoneCodes, tt.wantWxCode)
+		}
+		if tt.wantWxCode == "" && len(tt.wx.phoneCodes) != 0 {
+			t.Errorf("%s: 不应调用微信换取手机号接口", tt.name)
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		var resp UserResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s: 解析响应失败: %v", tt.name, err)
+			continue
+		}
+		if resp.Code != tt.wantCode || resp.ErrorMsg != tt.wantErrMsg {
+			t.Errorf("%s: code = %d, errorMsg = %q, want %d, %q", tt.name, resp.Code, resp.ErrorMsg, tt.wantCode, tt.wantErrMsg)
+		}
+		if tt.wantPhone == "" {
+			if len(users.updated) != 0 {
+				t.Errorf("%s: 失败时不应更新用户手机号", tt.name)
+			}
+			continue
+		}
+		if len(users.updated) != 1 || users.updated[0].Phone != tt.wantPhone {
+			t.Errorf("%s: 更新的用户 = %+v, want phone %s", tt.name, users.updated, tt.wantPhone)
+		}
+	}
+}
+
+func TestBindPhoneHandlerSMSCodeSingleUse(t *testing.T) {
+	const phone = "13700137000"
+
+	originalUserImp, originalSmsImp := dao.UserImp, dao.SmsImp
+	defer func() {
+		dao.UserImp, dao.SmsImp = originalUserImp, originalSmsImp
+	}()
+
+	user := &model.UserModel{UserId: "u_bind_phone"}
+	users := &fakeUserDao{user: user}
+	smsCodes := &fakeSmsDao{}
+	smsCodes.CreateCode(&model.SmsCodeModel{
+		Phone:     phone,
+		Scene:     SmsSceneBindPhone,
+		CodeHash:  hashSMSCode(phone, SmsSceneBindPhone, "123456"),
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+	})
+	dao.UserImp, dao.SmsImp = users, smsCodes
+
+	bind := func() *UserResponse {
+		body, _ := json.Marshal(BindPhoneRequest{Phone: phone, SmsCode: "123456"})
+		r := httptest.NewRequest(http.MethodPost, "/api/user/bindPhone", bytes.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), authContextKey{}, &authContext{user: user}))
+		w := httptest.NewRecorder()
+		BindPhoneHandler(w, r)
+		resp := &UserResponse{}
+		json.Unmarshal(w.Body.Bytes(), resp)
+		return resp
+	}
+
+	if resp := bind(); resp.Code != 0 {
+		t.Fatalf("首次绑定失败: %+v", resp)
+	}
+	if resp := bind(); resp.Code != -1 || resp.ErrorMsg != "验证码已过期，请重新获取" {
+		t.Errorf("验证码重复使用: code = %d, errorMsg = %q", resp.Code, resp.ErrorMsg)
+	}
+	if len(users.updated) != 1 {
+		t.Errorf("用户手机号更新次数 = %d, want 1", len(users.updated))
+	}
+}
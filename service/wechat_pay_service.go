@@ -1,15 +1,19 @@
 package service
 
 import (
+	"bytes"
 	"crypto/md5"
+	"crypto/tls"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"wxcloudrun-golang/config"
@@ -41,6 +45,17 @@ type WechatPayResponse struct {
 	Sign       string `xml:"sign"`
 }
 
+// 微信支付v2接口
+const wechatPayV2RequestTimeout = 10 * time.Second
+
+var wechatPayV2HTTPClient = &http.Client{Timeout: wechatPayV2RequestTimeout}
+
+// 仿真测试系统的沙箱签名密钥，按商户号缓存
+var (
+	wechatPaySandboxKeyMutex sync.Mutex
+	wechatPaySandboxKeys     = make(map[string]string)
+)
+
 // WechatPayNotifyResponse 微信支付通知响应
 type WechatPayNotifyResponse struct {
 	ReturnCode string `xml:"return_code"`
@@ -60,12 +75,24 @@ func GenerateWechatPayParams(order *model.OrderModel, openID string) (map[string
 	paymentConfig := config.GetPaymentConfig()
 	wechatConfig := paymentConfig.WechatPay
 
+	// 按配置选择APIv3（JSON、RSA签名）或v2（XML、MD5签名）下单
+	if wechatConfig.IsV3() {
+		return generateWechatPayV3Params(order, openID, &wechatConfig)
+	}
+
 	// 验证配置
 	if wechatConfig.MchID == "" || wechatConfig.MchKey == "" {
 		LogError("微信支付配置不完整", fmt.Errorf("商户号或商户密钥未配置"))
 		return nil, fmt.Errorf("微信支付配置不完整")
 	}
 
+	// 仿真测试系统使用沙箱密钥签名
+	signKey, err := wechatPayV2SignKey(&wechatConfig)
+	if err != nil {
+		LogError("获取微信支付签名密钥失败", err)
+		return nil, fmt.Errorf("获取微信支付签名密钥失败: %v", err)
+	}
+
 	// 生成随机字符串
 	nonceStr := generateNonceStr()
 
@@ -76,15 +103,15 @@ func GenerateWechatPayParams(order *model.OrderModel, openID string) (map[string
 		NonceStr:       nonceStr,
 		Body:           fmt.Sprintf("订单支付-%s", order.ServiceName),
 		OutTradeNo:     order.OrderNo,
-		TotalFee:       int(order.TotalAmount * 100), // 转换为分
-		SpbillCreateIP: "127.0.0.1",                  // 客户端IP，实际应该从请求中获取
+		TotalFee:       int(order.TotalAmount.Cents()), // 金额以分存储
+		SpbillCreateIP: "127.0.0.1",                    // 客户端IP，实际应该从请求中获取
 		NotifyURL:      wechatConfig.NotifyURL,
 		TradeType:      "JSAPI", // 小程序支付
 		OpenID:         openID,
 	}
 
 	// 生成签名
-	request.Sign = generateWechatPaySign(request, wechatConfig.MchKey)
+	request.Sign = generateWechatPaySign(request, signKey)
 
 	LogStep("微信支付请求参数构建完成", map[string]interface{}{
 		"appID":      request.AppID,
@@ -113,7 +140,7 @@ func GenerateWechatPayParams(order *model.OrderModel, openID string) (map[string
 	}
 
 	// 生成小程序支付参数
-	payParams := generateMiniProgramPayParams(response.PrepayID, wechatConfig.AppID, wechatConfig.MchKey)
+	payParams := generateMiniProgramPayParams(response.PrepayID, wechatConfig.AppID, signKey)
 
 	LogStep("微信支付参数生成成功", map[string]interface{}{
 		"prepayID":  response.PrepayID,
@@ -127,10 +154,7 @@ func GenerateWechatPayParams(order *model.OrderModel, openID string) (map[string
 func callWechatPayUnifiedOrder(request *WechatPayRequest) (*WechatPayResponse, error) {
 	// 确定API地址
 	paymentConfig := config.GetPaymentConfig()
-	apiURL := "https://api.mch.weixin.qq.com/pay/unifiedorder"
-	if paymentConfig.WechatPay.Environment == "sandbox" {
-		apiURL = "https://api.mch.weixin.qq.com/sandboxnew/pay/unifiedorder"
-	}
+	apiURL := wechatPayV2URL(&paymentConfig.WechatPay, "/pay/unifiedorder")
 
 	// 将请求转换为XML
 	xmlData, err := xml.Marshal(request)
@@ -170,6 +194,239 @@ func callWechatPayUnifiedOrder(request *WechatPayRequest) (*WechatPayResponse, e
 	return &response, nil
 }
 
+// wechatPayV2URL 返回v2接口地址，仿真测试系统（sandbox）的接口在/sandboxnew下且不需要商户证书
+func wechatPayV2URL(wechatConfig *config.WechatPayConfig, apiPath string) string {
+	if wechatConfig.Environment == "sandbox" {
+		return wechatPayV3BaseURL + "/sandboxnew" + strings.TrimPrefix(apiPath, "/secapi")
+	}
+	return wechatPayV3BaseURL + apiPath
+}
+
+// wechatPayV2SignKey 返回v2签名密钥：正式环境为商户密钥，仿真测试系统为通过getsignkey获取的沙箱密钥
+func wechatPayV2SignKey(wechatConfig *config.WechatPayConfig) (string, error) {
+	if wechatConfig.MchID == "" || wechatConfig.MchKey == "" {
+		return "", fmt.Errorf("商户号或商户密钥未配置")
+	}
+	if wechatConfig.Environment != "sandbox" {
+		return wechatConfig.MchKey, nil
+	}
+
+	wechatPaySandboxKeyMutex.Lock()
+	defer wechatPaySandboxKeyMutex.Unlock()
+	if key, ok := wechatPaySandboxKeys[wechatConfig.MchID]; ok {
+		return key, nil
+	}
+
+	params := map[string]string{
+		"mch_id":    wechatConfig.MchID,
+		"nonce_str": generateNonceStr(),
+	}
+	params["sign"] = generateWechatPaySign(params, wechatConfig.MchKey)
+	resp, err := wechatPayV2HTTPClient.Post(wechatPayV3BaseURL+"/sandboxnew/pay/getsignkey", "application/xml", bytes.NewReader(encodeWechatPayXML(params)))
+	if err != nil {
+		return "", fmt.Errorf("获取沙箱密钥失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %v", err)
+	}
+	result, err := parseWechatPayXML(body)
+	if err != nil {
+		return "", fmt.Errorf("XML解析失败: %v", err)
+	}
+	if result["return_code"] != "SUCCESS" || result["sandbox_signkey"] == "" {
+		return "", fmt.Errorf("获取沙箱密钥失败: %s", result["return_msg"])
+	}
+	wechatPaySandboxKeys[wechatConfig.MchID] = result["sandbox_signkey"]
+	return result["sandbox_signkey"], nil
+}
+
+// encodeWechatPayXML 将请求参数编码为v2接口的XML报文
+func encodeWechatPayXML(params map[string]string) []byte {
+	var keys []string
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("<xml>")
+	for _, k := range keys {
+		buf.WriteString("<" + k + ">")
+		xml.EscapeText(&buf, []byte(params[k]))
+		buf.WriteString("</" + k + ">")
+	}
+	buf.WriteString("</xml>")
+	return buf.Bytes()
+}
+
+// callWechatPayV2 调用v2接口：补充商户号、随机串和签名，校验通信结果和应答签名，业务结果由调用方判断
+func callWechatPayV2(wechatConfig *config.WechatPayConfig, client *http.Client, apiPath string, params map[string]string) (map[string]string, error) {
+	signKey, err := wechatPayV2SignKey(wechatConfig)
+	if err != nil {
+		return nil, err
+	}
+	params["appid"] = wechatConfig.AppID
+	params["mch_id"] = wechatConfig.MchID
+	params["nonce_str"] = generateNonceStr()
+	params["sign"] = generateWechatPaySign(params, signKey)
+
+	apiURL := wechatPayV2URL(wechatConfig, apiPath)
+	LogStep("发送微信支付请求", map[string]interface{}{
+		"url":        apiURL,
+		"outTradeNo": params["out_trade_no"],
+	})
+
+	resp, err := client.Post(apiURL, "application/xml", bytes.NewReader(encodeWechatPayXML(params)))
+	if err != nil {
+		return nil, fmt.Errorf("HTTP请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+
+	result, err := parseWechatPayXML(body)
+	if err != nil {
+		return nil, fmt.Errorf("XML解析失败: %v", err)
+	}
+	LogStep("收到微信支付响应", map[string]interface{}{
+		"url":        apiURL,
+		"statusCode": resp.StatusCode,
+		"resultCode": result["result_code"],
+		"errCode":    result["err_code"],
+	})
+	if result["return_code"] != "SUCCESS" {
+		return nil, fmt.Errorf("微信支付返回错误: %s", result["return_msg"])
+	}
+	if sign := result["sign"]; sign != "" && generateWechatPaySign(result, signKey) != sign {
+		return nil, fmt.Errorf("微信支付应答验签失败")
+	}
+	return result, nil
+}
+
+// wechatPayV2CertClient 申请退款需要使用商户API证书的双向TLS客户端
+func wechatPayV2CertClient(wechatConfig *config.WechatPayConfig) (*http.Client, error) {
+	if wechatConfig.CertPath == "" || wechatConfig.KeyPath == "" {
+		return nil, fmt.Errorf("申请退款需要配置商户API证书和私钥")
+	}
+	cert, err := tls.LoadX509KeyPair(wechatConfig.CertPath, wechatConfig.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载商户API证书失败: %v", err)
+	}
+	return &http.Client{
+		Timeout: wechatPayV2RequestTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		},
+	}, nil
+}
+
+// queryWechatPayV2Order 按商户订单号查询v2支付订单，未支付或订单不存在时返回nil
+func queryWechatPayV2Order(wechatConfig *config.WechatPayConfig, orderNo string) (*PaymentNotification, error) {
+	result, err := callWechatPayV2(wechatConfig, wechatPayV2HTTPClient, "/pay/orderquery", map[string]string{
+		"out_trade_no": orderNo,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result["result_code"] != "SUCCESS" {
+		if result["err_code"] == "ORDERNOTEXIST" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询订单失败: %s %s", result["err_code"], result["err_code_des"])
+	}
+	if result["trade_state"] != "SUCCESS" {
+		return nil, nil
+	}
+	return wechatPayV2PaymentNotification(result)
+}
+
+// closeWechatPayV2Order 关闭v2支付订单，订单不存在或已关闭视为成功
+func closeWechatPayV2Order(wechatConfig *config.WechatPayConfig, orderNo string) error {
+	result, err := callWechatPayV2(wechatConfig, wechatPayV2HTTPClient, "/pay/closeorder", map[string]string{
+		"out_trade_no": orderNo,
+	})
+	if err != nil {
+		return err
+	}
+	if result["result_code"] == "SUCCESS" {
+		return nil
+	}
+	switch result["err_code"] {
+	case "ORDERNOTEXIST", "ORDERCLOSED":
+		return nil
+	case "ORDERPAID":
+		return errPaymentAlreadyPaid
+	}
+	return fmt.Errorf("关闭订单失败: %s %s", result["err_code"], result["err_code_des"])
+}
+
+// refundWechatPayV2 申请v2退款，同一退款单号重复申请不会重复退款
+func refundWechatPayV2(wechatConfig *config.WechatPayConfig, request *PaymentRefundRequest) (*PaymentRefundResult, error) {
+	client := wechatPayV2HTTPClient
+	if wechatConfig.Environment != "sandbox" {
+		certClient, err := wechatPayV2CertClient(wechatConfig)
+		if err != nil {
+			return nil, err
+		}
+		client = certClient
+	}
+	result, err := callWechatPayV2(wechatConfig, client, "/secapi/pay/refund", map[string]string{
+		"out_trade_no":  request.OrderNo,
+		"out_refund_no": request.RefundNo,
+		"total_fee":     strconv.FormatInt(request.TotalAmount.Cents(), 10),
+		"refund_fee":    strconv.FormatInt(request.RefundAmount.Cents(), 10),
+		"refund_desc":   request.Reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result["result_code"] != "SUCCESS" {
+		return nil, fmt.Errorf("申请退款失败: %s %s", result["err_code"], result["err_code_des"])
+	}
+	// v2退款申请受理后异步处理，结果以退款通知或退款查询为准
+	return &PaymentRefundResult{
+		RefundNo: request.RefundNo,
+		RefundId: result["refund_id"],
+		Status:   PaymentRefundProcessing,
+	}, nil
+}
+
+// queryWechatPayV2Refund 查询v2退款结果，退款单不存在时返回nil
+func queryWechatPayV2Refund(wechatConfig *config.WechatPayConfig, refundNo string) (*PaymentRefundResult, error) {
+	result, err := callWechatPayV2(wechatConfig, wechatPayV2HTTPClient, "/pay/refundquery", map[string]string{
+		"out_refund_no": refundNo,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result["result_code"] != "SUCCESS" {
+		if result["err_code"] == "REFUNDNOTEXIST" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询退款失败: %s %s", result["err_code"], result["err_code_des"])
+	}
+	// 按退款单号查询时只返回该笔退款，状态为refund_status_0
+	switch result["refund_status_0"] {
+	case "SUCCESS":
+		return &PaymentRefundResult{
+			RefundNo: refundNo,
+			RefundId: result["refund_id_0"],
+			Status:   PaymentRefundSuccess,
+		}, nil
+	case "PROCESSING":
+		return &PaymentRefundResult{
+			RefundNo: refundNo,
+			RefundId: result["refund_id_0"],
+			Status:   PaymentRefundProcessing,
+		}, nil
+	}
+	return nil, fmt.Errorf("退款失败，退款状态: %s", result["refund_status_0"])
+}
+
 // generateMiniProgramPayParams 生成小程序支付参数
 func generateMiniProgramPayParams(prepayID, appID, mchKey string) map[string]interface{} {
 	timeStamp := strconv.FormatInt(time.Now().Unix(), 10)
@@ -268,73 +525,119 @@ func generateNonceStr() string {
 	return string(b)
 }
 
-// HandleWechatPayNotify 处理微信支付通知
-func HandleWechatPayNotify(w http.ResponseWriter, r *http.Request) {
-	LogStep("收到微信支付通知", nil)
+// parseWechatPayXML 解析微信支付的XML报文（<xml>下一层的字段）为键值对
+// encoding/xml 不支持直接解析到map
+func parseWechatPayXML(body []byte) (map[string]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	params := make(map[string]string)
+	depth := 0
+	var key string
+	var value strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				key = t.Name.Local
+				value.Reset()
+			}
+		case xml.CharData:
+			if depth == 2 {
+				value.Write(t)
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				params[key] = strings.TrimSpace(value.String())
+			}
+			depth--
+		}
+	}
+	if len(params) == 0 {
+		return nil, fmt.Errorf("报文为空")
+	}
+	return params, nil
+}
 
-	// 读取请求体
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		LogError("读取支付通知失败", err)
-		http.Error(w, "读取请求失败", http.StatusBadRequest)
-		return
+// writeWechatPayNotifyResponse 应答微信支付通知，返回FAIL时微信会稍后重新通知
+func writeWechatPayNotifyResponse(w http.ResponseWriter, returnCode, returnMsg string) {
+	response := &WechatPayNotifyResponse{
+		ReturnCode: returnCode,
+		ReturnMsg:  returnMsg,
 	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(response)
+}
 
+// parseWechatPayV2Notify 解析v2支付结果通知：验签后返回待结算的支付信息，支付未成功时返回nil
+func parseWechatPayV2Notify(wechatConfig *config.WechatPayConfig, body []byte) (*PaymentNotification, error) {
 	LogStep("支付通知内容", map[string]interface{}{
 		"body": string(body),
 	})
 
 	// 解析XML
-	var notifyData map[string]string
-	if err := xml.Unmarshal(body, &notifyData); err != nil {
+	notifyData, err := parseWechatPayXML(body)
+	if err != nil {
 		LogError("解析支付通知XML失败", err)
-		http.Error(w, "XML解析失败", http.StatusBadRequest)
-		return
+		return nil, fmt.Errorf("XML解析失败")
 	}
 
-	// 验证签名
-	paymentConfig := config.GetPaymentConfig()
-	expectedSign := generateWechatPaySign(notifyData, paymentConfig.WechatPay.MchKey)
+	// 验证签名，未配置商户密钥时无法验签，拒绝处理
+	signKey, err := wechatPayV2SignKey(wechatConfig)
+	if err != nil {
+		LogError("支付通知验签失败", err)
+		return nil, fmt.Errorf("商户配置错误")
+	}
+	expectedSign := generateWechatPaySign(notifyData, signKey)
 	if expectedSign != notifyData["sign"] {
 		LogError("支付通知签名验证失败", fmt.Errorf("expected: %s, actual: %s", expectedSign, notifyData["sign"]))
-		http.Error(w, "签名验证失败", http.StatusBadRequest)
-		return
+		return nil, fmt.Errorf("签名验证失败")
 	}
 
-	// 检查支付结果
+	// 检查支付结果，支付失败的通知无需结算
 	if notifyData["return_code"] != "SUCCESS" || notifyData["result_code"] != "SUCCESS" {
 		LogError("支付失败", fmt.Errorf("return_code: %s, result_code: %s", notifyData["return_code"], notifyData["result_code"]))
-		// 返回成功响应给微信
-		response := &WechatPayNotifyResponse{
-			ReturnCode: "SUCCESS",
-			ReturnMsg:  "OK",
-		}
-		w.Header().Set("Content-Type", "application/xml")
-		xml.NewEncoder(w).Encode(response)
-		return
+		return nil, nil
 	}
 
-	// 处理支付成功
-	orderNo := notifyData["out_trade_no"]
-	transactionId := notifyData["transaction_id"]
-	totalFee := notifyData["total_fee"]
-
 	LogStep("支付成功", map[string]interface{}{
-		"orderNo":       orderNo,
-		"transactionId": transactionId,
-		"totalFee":      totalFee,
+		"orderNo":       notifyData["out_trade_no"],
+		"transactionId": notifyData["transaction_id"],
+		"totalFee":      notifyData["total_fee"],
 	})
+	return wechatPayV2PaymentNotification(notifyData)
+}
 
-	// TODO: 更新订单状态
-	// 这里应该调用订单服务更新订单状态为已支付
+// wechatPayV2PaymentNotification 将v2支付通知或订单查询结果转换为结算信息
+func wechatPayV2PaymentNotification(data map[string]string) (*PaymentNotification, error) {
+	orderNo := data["out_trade_no"]
+	transactionId := data["transaction_id"]
+	totalFee := data["total_fee"]
+
+	// total_fee 单位为分
+	fee, err := strconv.ParseInt(totalFee, 10, 64)
+	if err != nil || orderNo == "" || transactionId == "" {
+		LogError("支付通知参数错误", fmt.Errorf("out_trade_no=%s, transaction_id=%s, total_fee=%s", orderNo, transactionId, totalFee))
+		return nil, fmt.Errorf("参数错误")
+	}
 
-	// 返回成功响应给微信
-	response := &WechatPayNotifyResponse{
-		ReturnCode: "SUCCESS",
-		ReturnMsg:  "OK",
+	// time_end 为北京时间 yyyyMMddHHmmss，缺失或格式错误时使用当前时间
+	payTime := time.Now()
+	if parsed, err := time.ParseInLocation("20060102150405", data["time_end"], time.Local); err == nil {
+		payTime = parsed
 	}
-	w.Header().Set("Content-Type", "application/xml")
-	xml.NewEncoder(w).Encode(response)
 
-	LogStep("支付通知处理完成", nil)
+	return &PaymentNotification{
+		OrderNo:       orderNo,
+		TransactionId: transactionId,
+		PaidAmount:    model.Money(fee),
+		PayTime:       payTime,
+		PayMethod:     config.PaymentProviderWechat,
+	}, nil
 }
@@ -0,0 +1,738 @@
+package service
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/model"
+)
+
+// 微信支付APIv3
+const (
+	wechatPayV3BaseURL        = "https://api.mch.weixin.qq.com"
+	wechatPayV3AuthSchema     = "WECHATPAY2-SHA256-RSA2048"
+	wechatPayV3MaxClockSkew   = 5 * time.Minute  // 应答和通知签名时间戳允许的偏差
+	wechatPayV3CertRefresh    = 12 * time.Hour   // 平台证书定期更新间隔
+	wechatPayV3CertMinRefresh = 1 * time.Minute  // 遇到未知证书序列号时两次下载的最小间隔
+	wechatPayV3RequestTimeout = 10 * time.Second // 请求微信支付接口的超时时间
+)
+
+var wechatPayV3HTTPClient = &http.Client{Timeout: wechatPayV3RequestTimeout}
+
+// wechatPayV3Resource APIv3的加密数据（平台证书、支付通知），使用AEAD_AES_256_GCM加密
+type wechatPayV3Resource struct {
+	Algorithm      string `json:"algorithm"`
+	Ciphertext     string `json:"ciphertext"`
+	AssociatedData string `json:"associated_data"`
+	Nonce          string `json:"nonce"`
+	OriginalType   string `json:"original_type"`
+}
+
+// wechatPayV3Amount 订单金额，单位为分
+type wechatPayV3Amount struct {
+	Total      int64  `json:"total"`
+	PayerTotal int64  `json:"payer_total,omitempty"`
+	Currency   string `json:"currency"`
+}
+
+// wechatPayV3Payer 支付者
+type wechatPayV3Payer struct {
+	OpenID string `json:"openid"`
+}
+
+// wechatPayV3JSAPIRequest JSAPI/小程序下单请求
+type wechatPayV3JSAPIRequest struct {
+	AppID       string            `json:"appid"`
+	MchID       string            `json:"mchid"`
+	Description string            `json:"description"`
+	OutTradeNo  string            `json:"out_trade_no"`
+	NotifyURL   string            `json:"notify_url"`
+	Amount      wechatPayV3Amount `json:"amount"`
+	Payer       wechatPayV3Payer  `json:"payer"`
+}
+
+// wechatPayV3JSAPIResponse JSAPI/小程序下单应答
+type wechatPayV3JSAPIResponse struct {
+	PrepayID string `json:"prepay_id"`
+}
+
+// wechatPayV3ErrorResponse APIv3错误应答
+type wechatPayV3ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error 微信支付返回的业务错误，调用方可按错误码判断
+func (e *wechatPayV3ErrorResponse) Error() string {
+	return fmt.Sprintf("微信支付返回错误: %s %s", e.Code, e.Message)
+}
+
+// isWechatPayV3Error 判断err是否为指定错误码的APIv3错误应答
+func isWechatPayV3Error(err error, code string) bool {
+	var errResp *wechatPayV3ErrorResponse
+	return errors.As(err, &errResp) && errResp.Code == code
+}
+
+// wechatPayV3RefundAmount 退款金额，单位为分
+type wechatPayV3RefundAmount struct {
+	Refund   int64  `json:"refund"`
+	Total    int64  `json:"total"`
+	Currency string `json:"currency"`
+}
+
+// wechatPayV3RefundRequest 申请退款请求
+type wechatPayV3RefundRequest struct {
+	OutTradeNo  string                  `json:"out_trade_no"`
+	OutRefundNo string                  `json:"out_refund_no"`
+	Reason      string                  `json:"reason,omitempty"`
+	Amount      wechatPayV3RefundAmount `json:"amount"`
+}
+
+// wechatPayV3RefundResponse 申请退款应答
+type wechatPayV3RefundResponse struct {
+	RefundID    string `json:"refund_id"`
+	OutRefundNo string `json:"out_refund_no"`
+	Status      string `json:"status"`
+}
+
+// wechatPayV3CertificatesResponse 下载平台证书应答
+type wechatPayV3CertificatesResponse struct {
+	Data []struct {
+		SerialNo           string              `json:"serial_no"`
+		EffectiveTime      string              `json:"effective_time"`
+		ExpireTime         string              `json:"expire_time"`
+		EncryptCertificate wechatPayV3Resource `json:"encrypt_certificate"`
+	} `json:"data"`
+}
+
+// wechatPayV3Notification 支付结果通知
+type wechatPayV3Notification struct {
+	ID           string              `json:"id"`
+	CreateTime   string              `json:"create_time"`
+	EventType    string              `json:"event_type"`
+	ResourceType string              `json:"resource_type"`
+	Resource     wechatPayV3Resource `json:"resource"`
+	Summary      string              `json:"summary"`
+}
+
+// wechatPayV3Transaction 支付通知解密后的交易信息
+type wechatPayV3Transaction struct {
+	AppID          string            `json:"appid"`
+	MchID          string            `json:"mchid"`
+	OutTradeNo     string            `json:"out_trade_no"`
+	TransactionID  string            `json:"transaction_id"`
+	TradeType      string            `json:"trade_type"`
+	TradeState     string            `json:"trade_state"`
+	TradeStateDesc string            `json:"trade_state_desc"`
+	SuccessTime    string            `json:"success_time"`
+	Amount         wechatPayV3Amount `json:"amount"`
+	Payer          wechatPayV3Payer  `json:"payer"`
+}
+
+// wechatPayMerchantKey 商户API私钥及证书序列号，按私钥路径缓存
+type wechatPayMerchantKey struct {
+	keyPath  string
+	key      *rsa.PrivateKey
+	serialNo string
+}
+
+var (
+	wechatPayMerchantKeyMutex sync.Mutex
+	wechatPayMerchantKeyCache *wechatPayMerchantKey
+)
+
+// wechatPayPlatformCerts 微信支付平台证书缓存，按证书序列号索引
+type wechatPayPlatformCerts struct {
+	mutex     sync.Mutex
+	certs     map[string]*x509.Certificate
+	fetchedAt time.Time
+}
+
+var wechatPayPlatformCertCache = &wechatPayPlatformCerts{}
+
+// readPEMBlock 读取PEM文件中的第一个数据块
+func readPEMBlock(path string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s不是有效的PEM文件", path)
+	}
+	return block, nil
+}
+
+// loadWechatPayMerchantKey 加载商户API私钥，证书序列号未配置时从商户API证书读取
+func loadWechatPayMerchantKey(wechatConfig *config.WechatPayConfig) (*wechatPayMerchantKey, error) {
+	wechatPayMerchantKeyMutex.Lock()
+	defer wechatPayMerchantKeyMutex.Unlock()
+
+	if cached := wechatPayMerchantKeyCache; cached != nil && cached.keyPath == wechatConfig.KeyPath &&
+		(wechatConfig.MchSerialNo == "" || cached.serialNo == wechatConfig.MchSerialNo) {
+		return cached, nil
+	}
+
+	if wechatConfig.KeyPath == "" {
+		return nil, fmt.Errorf("未配置商户API私钥路径")
+	}
+	block, err := readPEMBlock(wechatConfig.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取商户API私钥失败: %v", err)
+	}
+	var privateKey *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("商户API私钥不是RSA私钥")
+		}
+		privateKey = rsaKey
+	} else if rsaKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		privateKey = rsaKey
+	} else {
+		return nil, fmt.Errorf("解析商户API私钥失败: %v", err)
+	}
+
+	serialNo := wechatConfig.MchSerialNo
+	if serialNo == "" {
+		if wechatConfig.CertPath == "" {
+			return nil, fmt.Errorf("未配置商户API证书序列号或证书路径")
+		}
+		block, err := readPEMBlock(wechatConfig.CertPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取商户API证书失败: %v", err)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析商户API证书失败: %v", err)
+		}
+		serialNo = fmt.Sprintf("%X", cert.SerialNumber)
+	}
+
+	wechatPayMerchantKeyCache = &wechatPayMerchantKey{
+		keyPath:  wechatConfig.KeyPath,
+		key:      privateKey,
+		serialNo: serialNo,
+	}
+	return wechatPayMerchantKeyCache, nil
+}
+
+// signWechatPayV3 使用商户私钥对消息做SHA256-RSA签名，返回Base64编码的签名
+func signWechatPayV3(privateKey *rsa.PrivateKey, message string) (string, error) {
+	hashed := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// buildWechatPayV3Authorization 生成APIv3请求的Authorization头
+// 签名串：请求方法\nURL路径\n时间戳\n随机串\n请求报文主体\n
+func buildWechatPayV3Authorization(wechatConfig *config.WechatPayConfig, method, urlPath string, body []byte) (string, error) {
+	merchantKey, err := loadWechatPayMerchantKey(wechatConfig)
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceStr := generateNonceStr()
+	message := method + "\n" + urlPath + "\n" + timestamp + "\n" + nonceStr + "\n" + string(body) + "\n"
+	signature, err := signWechatPayV3(merchantKey.key, message)
+	if err != nil {
+		return "", fmt.Errorf("请求签名失败: %v", err)
+	}
+	return fmt.Sprintf(`%s mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		wechatPayV3AuthSchema, wechatConfig.MchID, nonceStr, signature, timestamp, merchantKey.serialNo), nil
+}
+
+// sendWechatPayV3Request 发送签名后的APIv3请求，返回应答头和应答报文，不校验应答签名
+func sendWechatPayV3Request(wechatConfig *config.WechatPayConfig, method, urlPath string, payload interface{}) (http.Header, []byte, error) {
+	var body []byte
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("JSON序列化失败: %v", err)
+		}
+		body = data
+	}
+
+	authorization, err := buildWechatPayV3Authorization(wechatConfig, method, urlPath, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequest(method, wechatPayV3BaseURL+urlPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "wxcloudrun-golang")
+
+	LogStep("发送微信支付APIv3请求", map[string]interface{}{
+		"method": method,
+		"path":   urlPath,
+	})
+
+	resp, err := wechatPayV3HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("HTTP请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+
+	LogStep("收到微信支付APIv3响应", map[string]interface{}{
+		"statusCode": resp.StatusCode,
+		"requestId":  resp.Header.Get("Request-ID"),
+	})
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp wechatPayV3ErrorResponse
+		if err := json.Unmarshal(respBody, &errResp); err == nil && errResp.Code != "" {
+			return nil, nil, &errResp
+		}
+		return nil, nil, fmt.Errorf("微信支付返回HTTP %d: %s", resp.StatusCode, string(respBody))
+	}
+	return resp.Header, respBody, nil
+}
+
+// callWechatPayV3 调用APIv3接口并使用平台证书校验应答签名
+func callWechatPayV3(wechatConfig *config.WechatPayConfig, method, urlPath string, payload interface{}) ([]byte, error) {
+	header, body, err := sendWechatPayV3Request(wechatConfig, method, urlPath, payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyWechatPayV3Signature(wechatConfig, header, body); err != nil {
+		return nil, fmt.Errorf("微信支付应答验签失败: %v", err)
+	}
+	return body, nil
+}
+
+// decryptWechatPayV3Resource 使用APIv3密钥以AEAD_AES_256_GCM解密平台证书或通知数据
+func decryptWechatPayV3Resource(apiV3Key string, resource *wechatPayV3Resource) ([]byte, error) {
+	if len(apiV3Key) != 32 {
+		return nil, fmt.Errorf("APIv3密钥长度必须为32个字符")
+	}
+	if resource.Algorithm != "" && resource.Algorithm != "AEAD_AES_256_GCM" {
+		return nil, fmt.Errorf("不支持的加密算法: %s", resource.Algorithm)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(resource.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("密文Base64解码失败: %v", err)
+	}
+	block, err := aes.NewCipher([]byte(apiV3Key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(resource.Nonce))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, []byte(resource.Nonce), ciphertext, []byte(resource.AssociatedData))
+	if err != nil {
+		return nil, fmt.Errorf("解密失败: %v", err)
+	}
+	return plaintext, nil
+}
+
+// downloadWechatPayPlatformCerts 下载并解密平台证书，使用下载到的证书校验本次应答的签名
+func downloadWechatPayPlatformCerts(wechatConfig *config.WechatPayConfig) (map[string]*x509.Certificate, error) {
+	header, body, err := sendWechatPayV3Request(wechatConfig, http.MethodGet, "/v3/certificates", nil)
+	if err != nil {
+		return nil, err
+	}
+	var certsResp wechatPayV3CertificatesResponse
+	if err := json.Unmarshal(body, &certsResp); err != nil {
+		return nil, fmt.Errorf("解析平台证书应答失败: %v", err)
+	}
+
+	certs := make(map[string]*x509.Certificate)
+	for _, item := range certsResp.Data {
+		plaintext, err := decryptWechatPayV3Resource(wechatConfig.APIv3Key, &item.EncryptCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("解密平台证书%s失败: %v", item.SerialNo, err)
+		}
+		block, _ := pem.Decode(plaintext)
+		if block == nil {
+			return nil, fmt.Errorf("平台证书%s不是有效的PEM格式", item.SerialNo)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析平台证书%s失败: %v", item.SerialNo, err)
+		}
+		certs[item.SerialNo] = cert
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("未获取到平台证书")
+	}
+
+	cert, ok := certs[header.Get("Wechatpay-Serial")]
+	if !ok {
+		return nil, fmt.Errorf("平台证书应答的签名证书%s不在下载结果中", header.Get("Wechatpay-Serial"))
+	}
+	if err := checkWechatPayV3Signature(cert, header, body); err != nil {
+		return nil, fmt.Errorf("平台证书应答验签失败: %v", err)
+	}
+
+	LogInfo("微信支付平台证书已更新", map[string]interface{}{
+		"count": len(certs),
+	})
+	return certs, nil
+}
+
+// getWechatPayPlatformCert 按序列号获取平台证书，缓存过期或遇到新序列号时重新下载
+func getWechatPayPlatformCert(wechatConfig *config.WechatPayConfig, serialNo string) (*x509.Certificate, error) {
+	cache := wechatPayPlatformCertCache
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cert, ok := cache.certs[serialNo]
+	age := time.Since(cache.fetchedAt)
+	if ok && age < wechatPayV3CertRefresh {
+		return cert, nil
+	}
+	// 未知序列号不能频繁触发下载，避免伪造的通知拖慢服务
+	if !ok && cache.certs != nil && age < wechatPayV3CertMinRefresh {
+		return nil, fmt.Errorf("未知的平台证书序列号: %s", serialNo)
+	}
+
+	certs, err := downloadWechatPayPlatformCerts(wechatConfig)
+	if err != nil {
+		if ok {
+			// 定期更新失败时继续使用已缓存的证书
+			LogError("更新微信支付平台证书失败", err)
+			return cert, nil
+		}
+		return nil, err
+	}
+	cache.certs = certs
+	cache.fetchedAt = time.Now()
+
+	if cert, ok := certs[serialNo]; ok {
+		return cert, nil
+	}
+	return nil, fmt.Errorf("未知的平台证书序列号: %s", serialNo)
+}
+
+// checkWechatPayV3Signature 使用平台证书校验应答或通知的签名
+// 验签串：时间戳\n随机串\n报文主体\n
+func checkWechatPayV3Signature(cert *x509.Certificate, header http.Header, body []byte) error {
+	timestamp := header.Get("Wechatpay-Timestamp")
+	nonce := header.Get("Wechatpay-Nonce")
+	signature := header.Get("Wechatpay-Signature")
+	if timestamp == "" || nonce == "" || signature == "" {
+		return fmt.Errorf("缺少签名信息")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的时间戳: %s", timestamp)
+	}
+	skew := time.Since(time.Unix(seconds, 0))
+	if skew > wechatPayV3MaxClockSkew || skew < -wechatPayV3MaxClockSkew {
+		return fmt.Errorf("签名时间戳已过期: %s", timestamp)
+	}
+
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("平台证书不是RSA证书")
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("签名Base64解码失败: %v", err)
+	}
+	hashed := sha256.Sum256([]byte(timestamp + "\n" + nonce + "\n" + string(body) + "\n"))
+	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signatureBytes)
+}
+
+// verifyWechatPayV3Signature 按Wechatpay-Serial获取平台证书并校验签名
+func verifyWechatPayV3Signature(wechatConfig *config.WechatPayConfig, header http.Header, body []byte) error {
+	serialNo := header.Get("Wechatpay-Serial")
+	if serialNo == "" {
+		return fmt.Errorf("缺少平台证书序列号")
+	}
+	cert, err := getWechatPayPlatformCert(wechatConfig, serialNo)
+	if err != nil {
+		return err
+	}
+	return checkWechatPayV3Signature(cert, header, body)
+}
+
+// checkWechatPayV3Config 校验APIv3必需的配置
+func checkWechatPayV3Config(wechatConfig *config.WechatPayConfig) error {
+	if wechatConfig.MchID == "" || wechatConfig.KeyPath == "" || wechatConfig.APIv3Key == "" {
+		return fmt.Errorf("微信支付APIv3配置不完整")
+	}
+	return nil
+}
+
+// generateWechatPayV3Params 通过APIv3的JSAPI下单接口生成小程序支付参数
+func generateWechatPayV3Params(order *model.OrderModel, openID string, wechatConfig *config.WechatPayConfig) (map[string]interface{}, error) {
+	if err := checkWechatPayV3Config(wechatConfig); err != nil {
+		LogError("微信支付APIv3配置不完整", fmt.Errorf("商户号、商户API私钥或APIv3密钥未配置"))
+		return nil, err
+	}
+
+	request := &wechatPayV3JSAPIRequest{
+		AppID:       wechatConfig.AppID,
+		MchID:       wechatConfig.MchID,
+		Description: fmt.Sprintf("订单支付-%s", order.ServiceName),
+		OutTradeNo:  order.OrderNo,
+		NotifyURL:   wechatConfig.NotifyURL,
+		Amount: wechatPayV3Amount{
+			Total:    order.TotalAmount.Cents(), // 金额以分存储
+			Currency: "CNY",
+		},
+		Payer: wechatPayV3Payer{OpenID: openID},
+	}
+
+	body, err := callWechatPayV3(wechatConfig, http.MethodPost, "/v3/pay/transactions/jsapi", request)
+	if err != nil {
+		LogError("调用微信支付APIv3下单失败", err)
+		return nil, fmt.Errorf("调用微信支付接口失败: %v", err)
+	}
+	var response wechatPayV3JSAPIResponse
+	if err := json.Unmarshal(body, &response); err != nil || response.PrepayID == "" {
+		return nil, fmt.Errorf("微信支付下单应答无效: %s", string(body))
+	}
+
+	merchantKey, err := loadWechatPayMerchantKey(wechatConfig)
+	if err != nil {
+		return nil, err
+	}
+	timeStamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceStr := generateNonceStr()
+	packageStr := "prepay_id=" + response.PrepayID
+	// 小程序调起支付签名串：appId\n时间戳\n随机串\n订单详情扩展字符串\n
+	paySign, err := signWechatPayV3(merchantKey.key, wechatConfig.AppID+"\n"+timeStamp+"\n"+nonceStr+"\n"+packageStr+"\n")
+	if err != nil {
+		return nil, fmt.Errorf("生成支付签名失败: %v", err)
+	}
+
+	LogStep("微信支付APIv3参数生成成功", map[string]interface{}{
+		"prepayID":  response.PrepayID,
+		"timeStamp": timeStamp,
+	})
+
+	return map[string]interface{}{
+		"timeStamp": timeStamp,
+		"nonceStr":  nonceStr,
+		"package":   packageStr,
+		"signType":  "RSA",
+		"paySign":   paySign,
+	}, nil
+}
+
+// isWechatPayV3Notify 判断是否为APIv3的支付通知（JSON报文，签名在请求头中）
+func isWechatPayV3Notify(r *http.Request) bool {
+	return r.Header.Get("Wechatpay-Signature") != ""
+}
+
+// writeWechatPayV3NotifyResponse 应答APIv3支付通知，非2xx状态码时微信会稍后重新通知
+func writeWechatPayV3NotifyResponse(w http.ResponseWriter, statusCode int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(&wechatPayV3ErrorResponse{
+		Code:    code,
+		Message: message,
+	})
+}
+
+// parseWechatPayV3Notify 解析APIv3支付通知：验签、解密后返回待结算的支付信息，非支付成功通知返回nil
+func parseWechatPayV3Notify(wechatConfig *config.WechatPayConfig, header http.Header, body []byte) (*PaymentNotification, error) {
+	if err := checkWechatPayV3Config(wechatConfig); err != nil {
+		LogError("支付通知验签失败", err)
+		return nil, fmt.Errorf("商户配置错误")
+	}
+	if err := verifyWechatPayV3Signature(wechatConfig, header, body); err != nil {
+		LogError("支付通知签名验证失败", err)
+		return nil, fmt.Errorf("签名验证失败")
+	}
+
+	var notification wechatPayV3Notification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		LogError("解析支付通知失败", err)
+		return nil, fmt.Errorf("报文格式错误")
+	}
+
+	LogStep("收到微信支付APIv3通知", map[string]interface{}{
+		"id":        notification.ID,
+		"eventType": notification.EventType,
+		"summary":   notification.Summary,
+	})
+
+	if notification.EventType != "TRANSACTION.SUCCESS" {
+		return nil, nil
+	}
+
+	plaintext, err := decryptWechatPayV3Resource(wechatConfig.APIv3Key, &notification.Resource)
+	if err != nil {
+		LogError("解密支付通知失败", err)
+		return nil, fmt.Errorf("解密失败")
+	}
+	var transaction wechatPayV3Transaction
+	if err := json.Unmarshal(plaintext, &transaction); err != nil {
+		LogError("解析支付通知交易信息失败", err)
+		return nil, fmt.Errorf("报文格式错误")
+	}
+
+	LogStep("支付通知交易信息", map[string]interface{}{
+		"orderNo":       transaction.OutTradeNo,
+		"transactionId": transaction.TransactionID,
+		"tradeState":    transaction.TradeState,
+		"total":         transaction.Amount.Total,
+	})
+
+	if transaction.MchID != wechatConfig.MchID {
+		LogError("支付通知商户号不一致", fmt.Errorf("mchid=%s", transaction.MchID))
+		return nil, fmt.Errorf("商户号不一致")
+	}
+	if transaction.TradeState != "SUCCESS" {
+		return nil, nil
+	}
+	return wechatPayV3PaymentNotification(&transaction), nil
+}
+
+// wechatPayV3PaymentNotification 将APIv3的交易信息转换为结算信息
+// amount.total 为订单金额，payer_total 为扣除优惠后用户实付金额，按订单金额校验
+func wechatPayV3PaymentNotification(transaction *wechatPayV3Transaction) *PaymentNotification {
+	payTime := time.Now()
+	if parsed, err := time.Parse(time.RFC3339, transaction.SuccessTime); err == nil {
+		payTime = parsed
+	}
+	return &PaymentNotification{
+		OrderNo:       transaction.OutTradeNo,
+		TransactionId: transaction.TransactionID,
+		PaidAmount:    model.Money(transaction.Amount.Total),
+		PayTime:       payTime,
+		PayMethod:     config.PaymentProviderWechat,
+	}
+}
+
+// queryWechatPayV3Order 按商户订单号查询APIv3支付订单，未支付或订单不存在时返回nil
+func queryWechatPayV3Order(wechatConfig *config.WechatPayConfig, orderNo string) (*PaymentNotification, error) {
+	if err := checkWechatPayV3Config(wechatConfig); err != nil {
+		return nil, err
+	}
+	urlPath := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(orderNo) + "?mchid=" + url.QueryEscape(wechatConfig.MchID)
+	body, err := callWechatPayV3(wechatConfig, http.MethodGet, urlPath, nil)
+	if err != nil {
+		if isWechatPayV3Error(err, "ORDER_NOT_EXIST") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var transaction wechatPayV3Transaction
+	if err := json.Unmarshal(body, &transaction); err != nil {
+		return nil, fmt.Errorf("解析订单查询结果失败: %v", err)
+	}
+	if transaction.TradeState != "SUCCESS" {
+		return nil, nil
+	}
+	return wechatPayV3PaymentNotification(&transaction), nil
+}
+
+// closeWechatPayV3Order 关闭APIv3支付订单，订单不存在视为成功
+func closeWechatPayV3Order(wechatConfig *config.WechatPayConfig, orderNo string) error {
+	if err := checkWechatPayV3Config(wechatConfig); err != nil {
+		return err
+	}
+	urlPath := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(orderNo) + "/close"
+	_, err := callWechatPayV3(wechatConfig, http.MethodPost, urlPath, map[string]string{"mchid": wechatConfig.MchID})
+	switch {
+	case err == nil, isWechatPayV3Error(err, "ORDER_NOT_EXIST"):
+		return nil
+	case isWechatPayV3Error(err, "ORDERPAID"):
+		return errPaymentAlreadyPaid
+	}
+	return err
+}
+
+// refundWechatPayV3 申请APIv3退款，同一退款单号重复申请不会重复退款
+func refundWechatPayV3(wechatConfig *config.WechatPayConfig, request *PaymentRefundRequest) (*PaymentRefundResult, error) {
+	if err := checkWechatPayV3Config(wechatConfig); err != nil {
+		return nil, err
+	}
+	body, err := callWechatPayV3(wechatConfig, http.MethodPost, "/v3/refund/domestic/refunds", &wechatPayV3RefundRequest{
+		OutTradeNo:  request.OrderNo,
+		OutRefundNo: request.RefundNo,
+		Reason:      request.Reason,
+		Amount: wechatPayV3RefundAmount{
+			Refund:   request.RefundAmount.Cents(),
+			Total:    request.TotalAmount.Cents(),
+			Currency: "CNY",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response wechatPayV3RefundResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("解析退款应答失败: %v", err)
+	}
+	if response.Status == "CLOSED" || response.Status == "ABNORMAL" {
+		return nil, fmt.Errorf("退款失败，退款状态: %s", response.Status)
+	}
+	status := PaymentRefundProcessing
+	if response.Status == "SUCCESS" {
+		status = PaymentRefundSuccess
+	}
+	return &PaymentRefundResult{
+		RefundNo: request.RefundNo,
+		RefundId: response.RefundID,
+		Status:   status,
+	}, nil
+}
+
+// queryWechatPayV3Refund 查询APIv3退款结果，退款单不存在时返回nil
+func queryWechatPayV3Refund(wechatConfig *config.WechatPayConfig, refundNo string) (*PaymentRefundResult, error) {
+	if err := checkWechatPayV3Config(wechatConfig); err != nil {
+		return nil, err
+	}
+	body, err := callWechatPayV3(wechatConfig, http.MethodGet, "/v3/refund/domestic/refunds/"+url.PathEscape(refundNo), nil)
+	if err != nil {
+		if isWechatPayV3Error(err, "RESOURCE_NOT_EXISTS") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var response wechatPayV3RefundResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("解析退款查询结果失败: %v", err)
+	}
+	if response.Status == "CLOSED" || response.Status == "ABNORMAL" {
+		return nil, fmt.Errorf("退款失败，退款状态: %s", response.Status)
+	}
+	status := PaymentRefundProcessing
+	if response.Status == "SUCCESS" {
+		status = PaymentRefundSuccess
+	}
+	return &PaymentRefundResult{
+		RefundNo: refundNo,
+		RefundId: response.RefundID,
+		Status:   status,
+	}, nil
+}
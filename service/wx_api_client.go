@@ -0,0 +1,239 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"wxcloudrun-golang/config"
+)
+
+// WxAPIClient 微信开放接口客户端
+// 默认实现通过HTTP调用微信服务器，测试或本地联调时可通过SetWxAPIClient替换为模拟实现，
+// 或设置WX_API_BASE_URL指向本地模拟服务
+type WxAPIClient interface {
+	// Code2Session 使用wx.login的code换取openid和session_key
+	Code2Session(code string) (*WxLoginResponse, error)
+	// GetPhoneNumber 使用getPhoneNumber按钮返回的code换取用户手机号
+	GetPhoneNumber(code string) (*WxPhoneInfo, error)
+}
+
+// WxPhoneInfo 微信手机号信息，getuserphonenumber接口和encryptedData解密结果格式相同
+type WxPhoneInfo struct {
+	PhoneNumber     string `json:"phoneNumber"`     // 带区号的手机号（国外手机号会有区号）
+	PurePhoneNumber string `json:"purePhoneNumber"` // 不带区号的手机号
+	CountryCode     string `json:"countryCode"`
+	Watermark       struct {
+		AppId     string `json:"appid"`
+		Timestamp int64  `json:"timestamp"`
+	} `json:"watermark"`
+}
+
+// wxPhoneNumberResponse getuserphonenumber接口响应
+type wxPhoneNumberResponse struct {
+	ErrCode   int          `json:"errcode"`
+	ErrMsg    string       `json:"errmsg"`
+	PhoneInfo *WxPhoneInfo `json:"phone_info"`
+}
+
+// wxAccessTokenResponse 获取access_token接口响应
+type wxAccessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	ErrCode     int    `json:"errcode"`
+	ErrMsg      string `json:"errmsg"`
+}
+
+var (
+	wxAPIClientMutex sync.Mutex
+	wxAPIClient      WxAPIClient
+)
+
+// GetWxAPIClient 获取微信接口客户端，未设置时按当前配置创建HTTP实现
+func GetWxAPIClient() WxAPIClient {
+	wxAPIClientMutex.Lock()
+	defer wxAPIClientMutex.Unlock()
+	if wxAPIClient == nil {
+		wxAPIClient = NewWxHTTPClient(config.GetWxConfig())
+	}
+	return wxAPIClient
+}
+
+// SetWxAPIClient 替换微信接口客户端，传nil时恢复默认实现
+func SetWxAPIClient(client WxAPIClient) {
+	wxAPIClientMutex.Lock()
+	defer wxAPIClientMutex.Unlock()
+	wxAPIClient = client
+}
+
+// wxHTTPClient 通过HTTP调用微信开放接口的默认实现
+type wxHTTPClient struct {
+	baseURL    string
+	appID      string
+	appSecret  string
+	httpClient *http.Client
+
+	tokenMutex     sync.Mutex
+	accessToken    string
+	tokenExpiresAt time.Time
+}
+
+// NewWxHTTPClient 创建微信开放接口HTTP客户端
+func NewWxHTTPClient(wxConfig *config.WxConfig) WxAPIClient {
+	return &wxHTTPClient{
+		baseURL:    strings.TrimRight(wxConfig.APIBaseURL, "/"),
+		appID:      wxConfig.AppID,
+		appSecret:  wxConfig.AppSecret,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// checkConfig 校验AppID和AppSecret已配置
+func (c *wxHTTPClient) checkConfig() error {
+	if c.appID == "" || c.appSecret == "" {
+		LogError("微信配置为空", fmt.Errorf("AppID或AppSecret未配置"))
+		return fmt.Errorf("微信配置未正确设置")
+	}
+	if c.appID == "your_app_id" || c.appSecret == "your_app_secret" {
+		LogError("微信配置为默认值", fmt.Errorf("请配置真实的微信AppID和AppSecret"))
+		return fmt.Errorf("微信配置为默认值，请设置真实配置")
+	}
+	return nil
+}
+
+// Code2Session 使用wx.login的code换取openid和session_key
+func (c *wxHTTPClient) Code2Session(code string) (*WxLoginResponse, error) {
+	if err := c.checkConfig(); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("appid", c.appID)
+	params.Add("secret", c.appSecret)
+	params.Add("js_code", code)
+	params.Add("grant_type", "authorization_code")
+
+	LogStep("调用微信code2session接口", map[string]string{"appID": c.appID})
+	var wxResp WxLoginResponse
+	if err := c.getJSON("/sns/jscode2session?"+params.Encode(), &wxResp); err != nil {
+		return nil, err
+	}
+	return &wxResp, nil
+}
+
+// GetPhoneNumber 使用getPhoneNumber按钮返回的code换取用户手机号
+func (c *wxHTTPClient) GetPhoneNumber(code string) (*WxPhoneInfo, error) {
+	if err := c.checkConfig(); err != nil {
+		return nil, err
+	}
+
+	body, _ := json.Marshal(map[string]string{"code": code})
+	var phoneResp wxPhoneNumberResponse
+	for attempt := 0; attempt < 2; attempt++ {
+		accessToken, err := c.getAccessToken()
+		if err != nil {
+			return nil, err
+		}
+
+		LogStep("调用微信getuserphonenumber接口", nil)
+		phoneResp = wxPhoneNumberResponse{}
+		if err := c.postJSON("/wxa/business/getuserphonenumber?access_token="+url.QueryEscape(accessToken), body, &phoneResp); err != nil {
+			return nil, err
+		}
+		// access_token失效时清除缓存重试一次
+		if phoneResp.ErrCode == 40001 || phoneResp.ErrCode == 42001 {
+			c.clearAccessToken()
+			continue
+		}
+		break
+	}
+
+	if phoneResp.ErrCode != 0 {
+		return nil, fmt.Errorf("获取手机号失败: %d %s", phoneResp.ErrCode, phoneResp.ErrMsg)
+	}
+	if phoneResp.PhoneInfo == nil || phoneResp.PhoneInfo.PurePhoneNumber == "" {
+		return nil, fmt.Errorf("微信未返回手机号")
+	}
+	return phoneResp.PhoneInfo, nil
+}
+
+// getAccessToken 获取接口调用凭证，有效期内复用缓存
+func (c *wxHTTPClient) getAccessToken() (string, error) {
+	c.tokenMutex.Lock()
+	defer c.tokenMutex.Unlock()
+
+	if c.accessToken != "" && time.Now().Before(c.tokenExpiresAt) {
+		return c.accessToken, nil
+	}
+
+	params := url.Values{}
+	params.Add("grant_type", "client_credential")
+	params.Add("appid", c.appID)
+	params.Add("secret", c.appSecret)
+
+	LogStep("获取微信access_token", map[string]string{"appID": c.appID})
+	var tokenResp wxAccessTokenResponse
+	if err := c.getJSON("/cgi-bin/token?"+params.Encode(), &tokenResp); err != nil {
+		return "", err
+	}
+	if tokenResp.ErrCode != 0 || tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("获取access_token失败: %d %s", tokenResp.ErrCode, tokenResp.ErrMsg)
+	}
+
+	// 提前5分钟过期，避免临界时间使用失效的凭证
+	c.accessToken = tokenResp.AccessToken
+	c.tokenExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn)*time.Second - 5*time.Minute)
+	return c.accessToken, nil
+}
+
+// clearAccessToken 清除缓存的接口调用凭证
+func (c *wxHTTPClient) clearAccessToken() {
+	c.tokenMutex.Lock()
+	defer c.tokenMutex.Unlock()
+	c.accessToken = ""
+}
+
+// getJSON 发送GET请求并解析JSON响应
+func (c *wxHTTPClient) getJSON(path string, out interface{}) error {
+	resp, err := c.httpClient.Get(c.baseURL + path)
+	if err != nil {
+		LogError("微信API请求失败", err)
+		return fmt.Errorf("微信API请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	return decodeWxResponse(resp, out)
+}
+
+// postJSON 发送POST请求并解析JSON响应
+func (c *wxHTTPClient) postJSON(path string, body []byte, out interface{}) error {
+	resp, err := c.httpClient.Post(c.baseURL+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		LogError("微信API请求失败", err)
+		return fmt.Errorf("微信API请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	return decodeWxResponse(resp, out)
+}
+
+// decodeWxResponse 读取并解析微信API响应
+func decodeWxResponse(resp *http.Response, out interface{}) error {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		LogError("读取微信API响应失败", err)
+		return fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("微信API返回HTTP %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		LogError("解析微信API响应失败", err)
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"wxcloudrun-golang/config"
+	"wxcloudrun-golang/db/model"
+)
+
+// 微信云托管在小程序通过 wx.cloud.callContainer 调用时注入的身份请求头
+const (
+	wxCloudHeaderOpenId  = "X-WX-OPENID"
+	wxCloudHeaderUnionId = "X-WX-UNIONID"
+	wxCloudHeaderAppId   = "X-WX-APPID"
+)
+
+// WxCloudIdentity 云托管请求头携带的小程序用户身份
+type WxCloudIdentity struct {
+	OpenId  string
+	UnionId string
+}
+
+// getWxCloudIdentity 读取云托管注入的身份请求头
+// 未开启信任开关、请求头缺失或AppID与本小程序不一致时返回nil
+func getWxCloudIdentity(r *http.Request) *WxCloudIdentity {
+	wxConfig := config.GetWxConfig()
+	if !wxConfig.TrustCloudHeaders {
+		return nil
+	}
+
+	openId := strings.TrimSpace(r.Header.Get(wxCloudHeaderOpenId))
+	if openId == "" {
+		return nil
+	}
+
+	if appId := r.Header.Get(wxCloudHeaderAppId); appId != "" && appId != wxConfig.AppID {
+		LogError("云托管身份校验失败", fmt.Errorf("AppID不匹配: %s", appId))
+		return nil
+	}
+
+	return &WxCloudIdentity{
+		OpenId:  openId,
+		UnionId: strings.TrimSpace(r.Header.Get(wxCloudHeaderUnionId)),
+	}
+}
+
+// resolveWxCloudUser 根据云托管身份请求头识别已注册用户，无法识别时返回nil
+func resolveWxCloudUser(r *http.Request) *model.UserModel {
+	identity := getWxCloudIdentity(r)
+	if identity == nil {
+		return nil
+	}
+
+	user, err := resolveUserByWxIdentity(identity.OpenId, identity.UnionId)
+	if err != nil {
+		LogStep("云托管身份未对应已注册用户", map[string]string{"openId": identity.OpenId})
+		return nil
+	}
+	return user
+}
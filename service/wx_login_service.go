@@ -3,13 +3,10 @@ package service
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
-	"wxcloudrun-golang/config"
 	"wxcloudrun-golang/db/dao"
 	"wxcloudrun-golang/db/model"
 	"wxcloudrun-golang/utils"
@@ -64,28 +61,39 @@ func WxLoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	LogRequest("POST", "/api/wx/login", req)
 
-	// 验证必要参数
-	if req.Code == "" {
-		LogError("缺少code参数", fmt.Errorf("code参数为空"))
-		http.Error(w, "缺少code参数", http.StatusBadRequest)
-		return
-	}
+	var wxResp *WxLoginResponse
+	if identity := getWxCloudIdentity(r); identity != nil {
+		// 云托管已注入用户身份，无需再用code换取session
+		LogStep("使用云托管身份请求头登录", map[string]string{"openId": identity.OpenId})
+		wxResp = &WxLoginResponse{
+			OpenId:  identity.OpenId,
+			UnionId: identity.UnionId,
+		}
+	} else {
+		// 验证必要参数
+		if req.Code == "" {
+			LogError("缺少code参数", fmt.Errorf("code参数为空"))
+			http.Error(w, "缺少code参数", http.StatusBadRequest)
+			return
+		}
 
-	LogStep("开始调用微信API", map[string]string{"code": req.Code})
-	// 调用微信API获取用户信息
-	wxResp, err := getWxSession(req.Code)
-	if err != nil {
-		LogError("微信API调用失败", err)
-		http.Error(w, "微信API调用失败: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	LogStep("微信API调用成功", wxResp)
+		LogStep("开始调用微信API", map[string]string{"code": req.Code})
+		// 调用微信API获取用户信息
+		var err error
+		wxResp, err = GetWxAPIClient().Code2Session(req.Code)
+		if err != nil {
+			LogError("微信API调用失败", err)
+			http.Error(w, "微信API调用失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		LogStep("微信API调用成功", wxResp)
 
-	// 检查微信API返回错误
-	if wxResp.ErrCode != 0 {
-		LogError("微信API返回错误", fmt.Errorf("错误码: %d, 错误信息: %s", wxResp.ErrCode, wxResp.ErrMsg))
-		http.Error(w, "微信API错误: "+wxResp.ErrMsg, http.StatusBadRequest)
-		return
+		// 检查微信API返回错误
+		if wxResp.ErrCode != 0 {
+			LogError("微信API返回错误", fmt.Errorf("错误码: %d, 错误信息: %s", wxResp.ErrCode, wxResp.ErrMsg))
+			http.Error(w, "微信API错误: "+wxResp.ErrMsg, http.StatusBadRequest)
+			return
+		}
 	}
 
 	// 检查openId是否有效
@@ -104,7 +112,7 @@ func WxLoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	LogStep("开始处理用户登录", map[string]string{"openId": wxResp.OpenId})
 	// 处理用户登录
-	result, err := processUserLogin(wxResp, &req)
+	result, err := processUserLogin(wxResp, &req, r)
 	if err != nil {
 		LogError("用户登录处理失败", err)
 		http.Error(w, "用户登录处理失败: "+err.Error(), http.StatusInternalServerError)
@@ -117,72 +125,11 @@ func WxLoginHandler(w http.ResponseWriter, r *http.Request) {
 	LogResponse(result, nil)
 }
 
-// getWxSession 调用微信API获取session_key和openid
-func getWxSession(code string) (*WxLoginResponse, error) {
-	LogStep("开始获取微信配置", nil)
-	// 获取微信配置
-	wxConfig := config.GetWxConfig()
-	appID := wxConfig.AppID
-	appSecret := wxConfig.AppSecret
-
-	// 添加配置验证
-	if appID == "" || appSecret == "" {
-		LogError("微信配置为空", fmt.Errorf("AppID或AppSecret未配置"))
-		return nil, fmt.Errorf("微信配置未正确设置")
-	}
-
-	// 检查是否为默认配置
-	if appID == "your_app_id" || appSecret == "your_app_secret" {
-		LogError("微信配置为默认值", fmt.Errorf("请配置真实的微信AppID和AppSecret"))
-		return nil, fmt.Errorf("微信配置为默认值，请设置真实配置")
-	}
-
-	LogStep("微信配置获取成功", map[string]string{"appID": appID})
-
-	// 构建请求URL
-	baseURL := "https://api.weixin.qq.com/sns/jscode2session"
-	params := url.Values{}
-	params.Add("appid", appID)
-	params.Add("secret", appSecret)
-	params.Add("js_code", code)
-	params.Add("grant_type", "authorization_code")
-
-	requestURL := baseURL + "?" + params.Encode()
-	LogStep("构建微信API请求", map[string]string{"url": requestURL})
-
-	// 发送HTTP请求
-	resp, err := http.Get(requestURL)
-	if err != nil {
-		LogError("微信API请求失败", err)
-		return nil, fmt.Errorf("微信API请求失败: %v", err)
-	}
-	defer resp.Body.Close()
-	LogStep("微信API请求发送成功", map[string]int{"status_code": resp.StatusCode})
-
-	// 读取响应
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		LogError("读取微信API响应失败", err)
-		return nil, fmt.Errorf("读取响应失败: %v", err)
-	}
-	LogStep("微信API响应读取成功", map[string]string{"response_body": string(body)})
-
-	// 解析响应
-	var wxResp WxLoginResponse
-	if err := json.Unmarshal(body, &wxResp); err != nil {
-		LogError("解析微信API响应失败", err)
-		return nil, fmt.Errorf("解析响应失败: %v", err)
-	}
-	LogStep("微信API响应解析成功", wxResp)
-
-	return &wxResp, nil
-}
-
 // processUserLogin 处理用户登录逻辑
-func processUserLogin(wxResp *WxLoginResponse, req *WxLoginRequest) (*WxLoginResult, error) {
+func processUserLogin(wxResp *WxLoginResponse, req *WxLoginRequest, r *http.Request) (*WxLoginResult, error) {
 	LogStep("开始查询用户是否存在", map[string]string{"openId": wxResp.OpenId})
-	// 查询用户是否已存在
-	existingUser, err := dao.UserImp.GetUserByOpenId(wxResp.OpenId)
+	// 查询用户是否已存在（按openId、unionId识别，已合并的账号返回保留账号）
+	existingUser, err := resolveUserByWxIdentity(wxResp.OpenId, wxResp.UnionId)
 	LogDBResult("查询", "users", existingUser, err)
 
 	if err != nil && err != gorm.ErrRecordNotFound {
@@ -216,12 +163,19 @@ func processUserLogin(wxResp *WxLoginResponse, req *WxLoginRequest) (*WxLoginRes
 			return nil, fmt.Errorf("创建用户失败: %v", err)
 		}
 		LogDBResult("创建", "users", user, nil)
+		bindUserIdentity(user, wxResp.OpenId, wxResp.UnionId, model.IdentitySourceMiniProgram)
 		isNewUser = true
 		LogStep("新用户创建成功", map[string]interface{}{"userId": user.UserId, "isNewUser": isNewUser})
 	} else {
 		LogStep("用户已存在，开始更新用户信息", map[string]interface{}{"userId": existingUser.UserId, "openId": existingUser.OpenId})
-		// 老用户，更新登录时间和session_key
-		existingUser.SessionKey = wxResp.SessionKey
+		// 老用户，更新登录时间和session_key（云托管身份登录没有session_key，保留原值）
+		// 通过unionId或账号合并识别到的用户，session_key属于其他openId，不能覆盖
+		if wxResp.SessionKey != "" && existingUser.OpenId == wxResp.OpenId {
+			existingUser.SessionKey = wxResp.SessionKey
+		}
+		if existingUser.UnionId == "" && wxResp.UnionId != "" {
+			existingUser.UnionId = wxResp.UnionId
+		}
 		existingUser.LastLoginAt = time.Now()
 		existingUser.UpdatedAt = time.Now()
 
@@ -267,23 +221,33 @@ func processUserLogin(wxResp *WxLoginResponse, req *WxLoginRequest) (*WxLoginRes
 		LogStep("用户信息更新成功", map[string]interface{}{"userId": user.UserId, "isNewUser": isNewUser})
 	}
 
+	LogStep("开始签发登录令牌", map[string]interface{}{"userId": user.UserId})
+	tokens, err := IssueTokenPair(user.UserId, r)
+	if err != nil {
+		LogError("签发登录令牌失败", err)
+		return nil, err
+	}
+
 	LogStep("开始构建返回数据", nil)
 	// 构建返回数据（不包含敏感信息如session_key）
 	userData := map[string]interface{}{
-		"id":          user.Id,
-		"userId":      user.UserId, // 使用新的UserId字段
-		"openId":      user.OpenId,
-		"nickName":    user.NickName,
-		"avatarUrl":   user.AvatarUrl,
-		"gender":      user.Gender,
-		"phone":       user.Phone, // 添加手机号字段
-		"country":     user.Country,
-		"province":    user.Province,
-		"city":        user.City,
-		"language":    user.Language,
-		"lastLoginAt": user.LastLoginAt,
-		"isNewUser":   isNewUser,
-		"token":       generateToken(user.UserId), // 使用UserId生成token
+		"id":               user.Id,
+		"userId":           user.UserId, // 使用新的UserId字段
+		"openId":           user.OpenId,
+		"nickName":         user.NickName,
+		"avatarUrl":        user.AvatarUrl,
+		"gender":           user.Gender,
+		"phone":            user.Phone, // 添加手机号字段
+		"country":          user.Country,
+		"province":         user.Province,
+		"city":             user.City,
+		"language":         user.Language,
+		"lastLoginAt":      user.LastLoginAt,
+		"isNewUser":        isNewUser,
+		"token":            tokens.Token,
+		"refreshToken":     tokens.RefreshToken,
+		"expiresIn":        tokens.ExpiresIn,
+		"refreshExpiresIn": tokens.RefreshExpiresIn,
 	}
 
 	result := &WxLoginResult{
@@ -293,8 +257,3 @@ func processUserLogin(wxResp *WxLoginResponse, req *WxLoginRequest) (*WxLoginRes
 	LogStep("登录处理完成", result)
 	return result, nil
 }
-
-// generateToken 生成简单的token
-func generateToken(userId string) string {
-	return fmt.Sprintf("token_%s_%d", userId, time.Now().Unix())
-}
@@ -3,6 +3,8 @@
 # 推广码功能测试脚本
 
 BASE_URL="http://localhost:80"
+# 批量生成推广码需要超级管理员登录令牌
+ADMIN_TOKEN=${ADMIN_TOKEN:-""}
 
 echo "=== 推广码功能测试 ==="
 
@@ -10,6 +12,7 @@ echo "=== 推广码功能测试 ==="
 echo "1. 测试批量生成推广码..."
 curl -X POST "${BASE_URL}/api/promoter/generate_codes" \
   -H "Content-Type: application/json" \
+  -H "Authorization: Bearer ${ADMIN_TOKEN}" \
   -w "\nHTTP状态码: %{http_code}\n\n"
 
 # 2. 测试获取推广员信息（需要有效的userId）
@@ -2,32 +2,63 @@
 
 # 管理员功能测试脚本
 
+BASE_URL=${BASE_URL:-http://localhost:8080}
+ADMIN_USERNAME=${ADMIN_USERNAME:-anyuyinian}
+ADMIN_PASSWORD=${ADMIN_PASSWORD:-000000}
+NEW_PASSWORD=${NEW_PASSWORD:-Anyu@Admin2024}
+
 echo "开始测试管理员功能..."
 
 # 测试超级管理员登录
 echo "1. 测试超级管理员登录..."
-curl -X POST http://localhost:8080/admin/login \
+LOGIN_RESULT=$(curl -s -X POST $BASE_URL/api/admin/login \
   -H "Content-Type: application/json" \
-  -d '{
-    "username": "anyuyinian",
-    "password": "000000"
-  }'
+  -d "{
+    \"username\": \"$ADMIN_USERNAME\",
+    \"password\": \"$ADMIN_PASSWORD\"
+  }")
+echo "$LOGIN_RESULT"
+TOKEN=$(echo "$LOGIN_RESULT" | sed -n 's/.*"token":"\([^"]*\)".*/\1/p')
 
 echo -e "\n\n2. 测试错误密码..."
-curl -X POST http://localhost:8080/admin/login \
+curl -X POST $BASE_URL/api/admin/login \
+  -H "Content-Type: application/json" \
+  -d "{
+    \"username\": \"$ADMIN_USERNAME\",
+    \"password\": \"wrong_password\"
+  }"
+
+echo -e "\n\n3. 测试修改密码（不符合密码策略）..."
+curl -X POST $BASE_URL/api/admin/password/change \
   -H "Content-Type: application/json" \
-  -d '{
-    "username": "anyuyinian",
-    "password": "wrong_password"
-  }'
+  -H "Authorization: Bearer $TOKEN" \
+  -d "{
+    \"oldPassword\": \"$ADMIN_PASSWORD\",
+    \"newPassword\": \"123456\"
+  }"
+
+echo -e "\n\n4. 测试修改密码..."
+curl -X POST $BASE_URL/api/admin/password/change \
+  -H "Content-Type: application/json" \
+  -H "Authorization: Bearer $TOKEN" \
+  -d "{
+    \"oldPassword\": \"$ADMIN_PASSWORD\",
+    \"newPassword\": \"$NEW_PASSWORD\"
+  }"
+
+echo -e "\n\n5. 测试检查管理员状态..."
+curl -X GET "$BASE_URL/api/admin/check-status" \
+  -H "Authorization: Bearer $TOKEN"
 
-echo -e "\n\n3. 测试检查管理员状态..."
-curl -X GET "http://localhost:8080/admin/check-status?userId=test_user_id"
+echo -e "\n\n6. 测试获取管理员用户列表..."
+curl -X GET "$BASE_URL/api/admin/users?page=1&pageSize=10" \
+  -H "Authorization: Bearer $TOKEN"
 
-echo -e "\n\n4. 测试获取管理员用户列表..."
-curl -X GET "http://localhost:8080/admin/users?adminUserId=admin_super&page=1&pageSize=10"
+echo -e "\n\n7. 测试获取管理员订单列表..."
+curl -X GET "$BASE_URL/api/admin/orders?page=1&pageSize=10" \
+  -H "Authorization: Bearer $TOKEN"
 
-echo -e "\n\n5. 测试获取管理员订单列表..."
-curl -X GET "http://localhost:8080/admin/orders?adminUserId=admin_super&page=1&pageSize=10"
+echo -e "\n\n8. 测试未携带令牌..."
+curl -X GET "$BASE_URL/api/admin/users?page=1&pageSize=10"
 
-echo -e "\n\n管理员功能测试完成！" 
\ No newline at end of file
+echo -e "\n\n管理员功能测试完成！"
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	passwordHashCost  = 12 // bcrypt成本因子
+	PasswordMaxLength = 72 // bcrypt只使用密码的前72个字节，更长的密码拒绝哈希
+
+	legacyPasswordHashAlgorithm = "pbkdf2_sha256" // 早期版本的哈希算法标识，只用于校验，登录成功后升级为bcrypt
+)
+
+var (
+	dummyPasswordHashOnce sync.Once
+	dummyPasswordHash     []byte
+)
+
+// HashPassword 使用bcrypt对密码加盐哈希，输出格式为$2a$成本$盐和哈希值
+func HashPassword(password string) (string, error) {
+	if len(password) > PasswordMaxLength {
+		return "", fmt.Errorf("密码长度不能超过%d个字节", PasswordMaxLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
+	if err != nil {
+		return "", fmt.Errorf("生成密码哈希失败: %v", err)
+	}
+	return string(hash), nil
+}
+
+// VerifyPassword 校验密码与哈希值是否匹配，兼容早期版本的PBKDF2哈希，哈希格式不正确时返回false
+func VerifyPassword(password, encoded string) bool {
+	if isBcryptHash(encoded) {
+		return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) == nil
+	}
+	return verifyLegacyPassword(password, encoded)
+}
+
+// VerifyDummyPassword 账号不存在时对固定哈希做一次同等成本的校验，使响应耗时与账号存在时一致，避免通过耗时枚举账号
+func VerifyDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), passwordHashCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
+
+// PasswordNeedsRehash 判断哈希值是否需要按当前算法和成本重新生成（早期PBKDF2哈希或成本低于当前值）
+func PasswordNeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < passwordHashCost
+}
+
+// IsPasswordHash 判断字符串是否为密码哈希值（bcrypt或早期PBKDF2格式，用于识别历史明文密码）
+func IsPasswordHash(value string) bool {
+	if isBcryptHash(value) {
+		return true
+	}
+	return strings.HasPrefix(value, legacyPasswordHashAlgorithm+"$") && strings.Count(value, "$") == 3
+}
+
+// isBcryptHash 判断是否为bcrypt哈希
+func isBcryptHash(value string) bool {
+	_, err := bcrypt.Cost([]byte(value))
+	return err == nil
+}
+
+// verifyLegacyPassword 校验早期版本的PBKDF2-HMAC-SHA256哈希
+// 格式：pbkdf2_sha256$迭代次数$盐$哈希值（盐和哈希值为base64编码）
+func verifyLegacyPassword(password, encoded string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != legacyPasswordHashAlgorithm {
+		return false
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	key := pbkdf2.Key([]byte(password), salt, iterations, len(expected), sha256.New)
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+// GenerateRandomPassword 生成包含大小写字母、数字和特殊字符的随机密码
+func GenerateRandomPassword(length int) (string, error) {
+	const (
+		lower   = "abcdefghijkmnpqrstuvwxyz"
+		upper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+		digits  = "23456789"
+		special = "!@#$%^&*-_"
+	)
+	classes := []string{lower, upper, digits, special}
+	if length < len(classes) {
+		length = len(classes)
+	}
+
+	all := lower + upper + digits + special
+	result := make([]byte, length)
+	for i := range result {
+		charset := all
+		if i < len(classes) {
+			charset = classes[i] // 保证每类字符至少出现一次
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", fmt.Errorf("生成随机密码失败: %v", err)
+		}
+		result[i] = charset[n.Int64()]
+	}
+
+	// 打乱顺序，避免固定位置的字符类型
+	for i := len(result) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", fmt.Errorf("生成随机密码失败: %v", err)
+		}
+		j := n.Int64()
+		result[i], result[j] = result[j], result[i]
+	}
+	return string(result), nil
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyLegacyPassword(t *testing.T) {
+	// 由RFC 7914第11节等PBKDF2-HMAC-SHA256测试向量构造的早期格式哈希
+	tests := []struct {
+		password string
+		encoded  string
+	}{
+		{"password", "pbkdf2_sha256$1$c2FsdA$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs"},
+		{"password", "pbkdf2_sha256$4096$c2FsdA$xeR41ZKIyEGqUw22hFxMjZYok6ABzk4RpJY4c6qYE0o"},
+		{"passwordPASSWORDpassword", "pbkdf2_sha256$4096$c2FsdFNBTFRzYWx0U0FMVHNhbHRTQUxUc2FsdFNBTFRzYWx0$NIyJ28vTKy8y2BS4EW6EzysXNH68GAAYHE4qH7jdU+HGNVGMfaxH6Q"},
+	}
+	for _, tt := range tests {
+		if !VerifyPassword(tt.password, tt.encoded) {
+			t.Errorf("VerifyPassword(%q, %s) = false", tt.password, tt.encoded)
+		}
+		if VerifyPassword(tt.password+"x", tt.encoded) {
+			t.Errorf("VerifyPassword(%q, %s) = true", tt.password+"x", tt.encoded)
+		}
+		if !PasswordNeedsRehash(tt.encoded) {
+			t.Errorf("PasswordNeedsRehash(%s) = false, legacy hashes must be upgraded", tt.encoded)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("Admin@2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$2a$12$") || len(hash) != 60 {
+		t.Fatalf("unexpected hash format: %s", hash)
+	}
+	if !IsPasswordHash(hash) {
+		t.Errorf("IsPasswordHash(%s) = false", hash)
+	}
+	if PasswordNeedsRehash(hash) {
+		t.Errorf("PasswordNeedsRehash(%s) = true", hash)
+	}
+
+	again, _ := HashPassword("Admin@2024")
+	if hash == again {
+		t.Error("hashes of the same password must use different salts")
+	}
+
+	tests := []struct {
+		password string
+		encoded  string
+		want     bool
+	}{
+		{"Admin@2024", hash, true},
+		{"Admin@2024", again, true},
+		{"admin@2024", hash, false},
+		{"Admin@2024 ", hash, false},
+		{"", hash, false},
+	}
+	for _, tt := range tests {
+		if got := VerifyPassword(tt.password, tt.encoded); got != tt.want {
+			t.Errorf("VerifyPassword(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+
+	if _, err := HashPassword(strings.Repeat("a", PasswordMaxLength+1)); err == nil {
+		t.Errorf("HashPassword accepted a password longer than %d bytes", PasswordMaxLength)
+	}
+}
+
+func TestVerifyPasswordMalformed(t *testing.T) {
+	// "password"/"salt"/1次迭代的已知哈希，用于构造各种格式错误的变体
+	valid := "pbkdf2_sha256$1$c2FsdA$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs"
+	if !VerifyPassword("password", valid) {
+		t.Fatalf("VerifyPassword with known hash failed")
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"明文密码", "password"},
+		{"空字符串", ""},
+		{"算法不匹配", "bcrypt$1$c2FsdA$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs"},
+		{"段数不对", "pbkdf2_sha256$1$c2FsdA"},
+		{"迭代次数不是数字", "pbkdf2_sha256$x$c2FsdA$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs"},
+		{"迭代次数为0", "pbkdf2_sha256$0$c2FsdA$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs"},
+		{"盐不是base64", "pbkdf2_sha256$1$!!$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs"},
+		{"哈希值为空", "pbkdf2_sha256$1$c2FsdA$"},
+		{"迭代次数被篡改", "pbkdf2_sha256$2$c2FsdA$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs"},
+		{"bcrypt哈希被截断", "$2a$12$abcdefghijklmnopqrstuv"},
+		{"bcrypt成本无效", "$2a$xx$abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ01234"},
+	}
+	for _, tt := range tests {
+		if VerifyPassword("password", tt.encoded) {
+			t.Errorf("%s: VerifyPassword(%q) = true", tt.name, tt.encoded)
+		}
+	}
+}
+
+func TestIsPasswordHash(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", true},
+		{"pbkdf2_sha256$310000$c2FsdA$aGFzaA", true},
+		{"Admin@2024", false},
+		{"$2a$10$short", false},
+		{"pbkdf2_sha256$310000$c2FsdA", false},
+		{"pbkdf2_sha256", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsPasswordHash(tt.value); got != tt.want {
+			t.Errorf("IsPasswordHash(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	tests := []struct {
+		encoded string
+		want    bool
+	}{
+		{"$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", true}, // 成本低于当前值
+		{"pbkdf2_sha256$310000$c2FsdA$aGFzaA", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		if got := PasswordNeedsRehash(tt.encoded); got != tt.want {
+			t.Errorf("PasswordNeedsRehash(%q) = %v, want %v", tt.encoded, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateRandomPassword(t *testing.T) {
+	for _, length := range []int{1, 4, 12, 32} {
+		password, err := GenerateRandomPassword(length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantLength := length
+		if wantLength < 4 {
+			wantLength = 4
+		}
+		if len(password) != wantLength {
+			t.Errorf("GenerateRandomPassword(%d) length = %d, want %d", length, len(password), wantLength)
+		}
+		for _, class := range []string{"abcdefghijkmnpqrstuvwxyz", "ABCDEFGHJKLMNPQRSTUVWXYZ", "23456789", "!@#$%^&*-_"} {
+			if !strings.ContainsAny(password, class) {
+				t.Errorf("GenerateRandomPassword(%d) = %q, missing one of %q", length, password, class)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238附录B中SHA1测试向量使用的密钥"12345678901234567890"（base32编码）
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// RFC 6238附录B的8位验证码取后6位
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode(T=%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPCodeSecretFormat(t *testing.T) {
+	step := TOTPStep(time.Unix(59, 0))
+	for _, secret := range []string{
+		strings.ToLower(rfc6238Secret),
+		rfc6238Secret + "====",
+	} {
+		got, err := TOTPCode(secret, step)
+		if err != nil || got != "287082" {
+			t.Errorf("TOTPCode(%q) = %s, %v, want 287082", secret, got, err)
+		}
+	}
+	if _, err := TOTPCode("not-base32!", step); err == nil {
+		t.Error("expected error for invalid secret")
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+	previous, _ := TOTPCode(rfc6238Secret, current-1)
+	next, _ := TOTPCode(rfc6238Secret, current+1)
+	tooOld, _ := TOTPCode(rfc6238Secret, current-2)
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		lastStep int64
+		wantStep int64
+		wantOk   bool
+	}{
+		{"当前时间步", "050471", 1, 0, current, true},
+		{"前后空白", " 050471 ", 1, 0, current, true},
+		{"上一个时间步在偏差内", previous, 1, 0, current - 1, true},
+		{"下一个时间步在偏差内", next, 1, 0, current + 1, true},
+		{"超出偏差", tooOld, 1, 0, 0, false},
+		{"不允许偏差", previous, 0, 0, 0, false},
+		{"已使用的时间步不能重放", "050471", 1, current, 0, false},
+		{"上一个时间步已使用时只接受更新的", previous, 1, current - 1, 0, false},
+		{"位数不对", "50471", 1, 0, 0, false},
+		{"错误验证码", "000000", 1, 0, 0, false},
+	}
+	for _, tt := range tests {
+		step, ok := VerifyTOTP(rfc6238Secret, tt.code, now, tt.skew, tt.lastStep)
+		if ok != tt.wantOk || step != tt.wantStep {
+			t.Errorf("%s: VerifyTOTP = (%d, %v), want (%d, %v)", tt.name, step, ok, tt.wantStep, tt.wantOk)
+		}
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret length = %d, want 32", len(secret))
+	}
+	if _, err := TOTPCode(secret, 1); err != nil {
+		t.Errorf("generated secret is not usable: %v", err)
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	got := TOTPProvisioningURI("安愉颐年", "admin", rfc6238Secret)
+	for _, part := range []string{
+		"otpauth://totp/",
+		"secret=" + rfc6238Secret,
+		"algorithm=SHA1",
+		"digits=6",
+		"period=30",
+	} {
+		if !strings.Contains(got, part) {
+			t.Errorf("TOTPProvisioningURI = %s, missing %s", got, part)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("recovery code %q has wrong format", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) != 10 {
+		t.Errorf("got %d unique codes, want 10", len(seen))
+	}
+
+	hash := HashRecoveryCode("abcde-12345")
+	for _, variant := range []string{"ABCDE-12345", "abcde12345", " abcde - 12345 "} {
+		if HashRecoveryCode(variant) != hash {
+			t.Errorf("HashRecoveryCode(%q) differs from normalized code", variant)
+		}
+	}
+	if HashRecoveryCode("abcde-12346") == hash {
+		t.Error("different codes must not share a hash")
+	}
+}
+
+func TestEncryptSecret(t *testing.T) {
+	encrypted, err := EncryptSecret(rfc6238Secret, "key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := EncryptSecret(rfc6238Secret, "key-1")
+	if encrypted == again {
+		t.Error("encryption must use a random nonce")
+	}
+	tampered := []byte(encrypted)
+	tampered[len(tampered)/2] ^= 0x01
+
+	tests := []struct {
+		name      string
+		encrypted string
+		key       string
+		wantErr   bool
+	}{
+		{"正确密钥", encrypted, "key-1", false},
+		{"错误密钥", encrypted, "key-2", true},
+		{"密文被篡改", string(tampered), "key-1", true},
+		{"不是base64", "!!!", "key-1", true},
+		{"密文过短", "AAAA", "key-1", true},
+	}
+	for _, tt := range tests {
+		got, err := DecryptSecret(tt.encrypted, tt.key)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: DecryptSecret error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != rfc6238Secret {
+			t.Errorf("%s: DecryptSecret = %s", tt.name, got)
+		}
+	}
+}